@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/parsely/parsely/internal/core"
+)
+
+// batchOptions are the flags that run the CLI without the interactive menu
+type batchOptions struct {
+	File   string
+	Export string
+	JSON   bool
+}
+
+// enabled reports whether any non-interactive work was requested
+func (o batchOptions) enabled() bool {
+	return o.File != "" || o.Export != ""
+}
+
+// validate rejects flag combinations that make no sense
+func (o batchOptions) validate() error {
+	if o.JSON && o.File == "" {
+		return errors.New("--json requires --file")
+	}
+	return nil
+}
+
+// runBatch processes opts.File, then exports to opts.Export, reporting to w.
+// When both are given the export includes the newly processed document. A
+// directory with failing files still has its totals written before the
+// error is returned.
+func runBatch(processor *core.Processor, opts batchOptions, w io.Writer) error {
+	if opts.File != "" {
+		result, err := processPath(processor, opts.File)
+		if result != nil {
+			if err := writeResult(w, result, opts.JSON); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Export != "" {
+		format := core.ExportFormatForPath(opts.Export)
+		if err := processor.ExportVocabulary(opts.Export, format); err != nil {
+			return fmt.Errorf("failed to export vocabulary: %w", err)
+		}
+		// Keep stdout a single JSON document when --json is set. The format
+		// is named because .txt paths get Anki flashcards, not plain text.
+		if !opts.JSON {
+			fmt.Fprintf(w, "Exported vocabulary to %s as %s\n", opts.Export, format)
+		}
+	}
+
+	return nil
+}
+
+// writeResult prints a processing result as plain text, or as the same JSON
+// the web API returns when asJSON is set
+func writeResult(w io.Writer, result *core.ProcessingResult, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		return nil
+	}
+
+	text := resultSummary(result)
+	if result.LanguageWarning != "" {
+		text += fmt.Sprintf("Warning: %s\n", result.LanguageWarning)
+	}
+	if result.Partial {
+		text += fmt.Sprintf("Warning: extraction stopped early, results are partial (%s)\n", result.PartialError)
+	}
+
+	if _, err := io.WriteString(w, text); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -10,8 +13,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/config"
 	"github.com/parsely/parsely/internal/core"
 	"github.com/parsely/parsely/internal/db"
+	"github.com/parsely/parsely/internal/parser"
 )
 
 type view int
@@ -42,6 +47,7 @@ type model struct {
 	cursor     int
 	processor  *core.Processor
 	vocabulary []*db.Vocabulary
+	page       int
 	result     *core.ProcessingResult
 	err        error
 	input      textinput.Model
@@ -73,45 +79,65 @@ var (
 			Bold(true)
 )
 
-func initialModel() model {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Error: ANTHROPIC_API_KEY environment variable not set")
-		os.Exit(1)
+func initialModel(processor *core.Processor) model {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	return model{
+		view:      viewMenu,
+		processor: processor,
+		input:     textinput.New(),
+		spinner:   s,
 	}
+}
 
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "parsely.db"
+// newProcessor builds a processor from the environment configuration,
+// with languageFlag overriding LANGUAGE when set
+func newProcessor(languageFlag string) (*core.Processor, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
 	}
 
-	language := os.Getenv("LANGUAGE")
-	if language == "" {
-		language = "auto-detect"
+	language := cfg.Language
+	if languageFlag != "" {
+		language = languageFlag
 	}
 
-	database, err := db.NewDatabase(dbPath)
+	database, err := db.NewDatabaseWithOptions(cfg.DBPath, db.Options{BusyTimeout: cfg.DBBusyTimeout})
 	if err != nil {
-		fmt.Printf("Error initializing database: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	aiClient, err := ai.NewClaudeClient(apiKey)
+	aiClient, err := ai.NewExtractor(cfg.Provider, cfg.AnthropicAPIKey)
 	if err != nil {
-		fmt.Printf("Error initializing AI client: %v\n", err)
-		os.Exit(1)
+		database.Close()
+		return nil, fmt.Errorf("failed to initialize AI client: %w", err)
+	}
+	if client, ok := aiClient.(*ai.ClaudeClient); ok {
+		client.Limits = ai.LengthLimits{MinLength: cfg.MinWordLength, MaxWords: cfg.MaxPhraseWords}
 	}
 
-	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-
-	return model{
-		view:      viewMenu,
-		processor: core.NewProcessor(database, aiClient, language),
-		input:     textinput.New(),
-		spinner:   s,
+	processor := core.NewProcessor(database, aiClient, language)
+	processor.ExtractContext = cfg.ExtractContext
+	processor.QualityFilter = cfg.QualityFilter
+	processor.Parser = parser.ParserConfig{PDFEngine: cfg.PDFEngine}
+	parser.SetMaxFileSize(cfg.MaxFileSizeMB << 20)
+	processor.Concurrency = cfg.Concurrency
+	if cfg.StopWords {
+		stopWords, err := core.LoadStopWords(cfg.StopWordsFile)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to load stop words: %w", err)
+		}
+		processor.StopWords = stopWords
 	}
+	if cfg.AllowDuplicates {
+		processor.OnDuplicate = core.DuplicateCount
+	}
+
+	return processor, nil
 }
 
 func (m model) Init() tea.Cmd {
@@ -157,6 +183,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor++
 			}
 
+		case "pgdown", "n":
+			if m.view == viewList {
+				_, _, pages := pageBounds(len(m.vocabulary), m.page, listPageSize)
+				if m.page < pages-1 {
+					m.page++
+				}
+			}
+
+		case "pgup", "p":
+			if m.view == viewList && m.page > 0 {
+				m.page--
+			}
+
 		case "enter":
 			switch m.view {
 			case viewMenu:
@@ -186,7 +225,7 @@ func (m model) handleMenuSelection() (tea.Model, tea.Cmd) {
 	case 0: // Parse new document
 		m.view = viewInput
 		m.inputMode = inputModeFilePath
-		m.input.Placeholder = "Enter file path (PDF or DOCX)"
+		m.input.Placeholder = "Enter file or directory path (PDF, DOCX, ODT, TXT or image)"
 		m.input.Focus()
 		return m, textinput.Blink
 
@@ -197,12 +236,13 @@ func (m model) handleMenuSelection() (tea.Model, tea.Cmd) {
 		} else {
 			m.vocabulary = vocab
 		}
+		m.page = 0
 		m.view = viewList
 
 	case 2: // Export to JSON
 		m.view = viewInput
 		m.inputMode = inputModeExportPath
-		m.input.Placeholder = "Enter export file path (default: vocabulary_export.json)"
+		m.input.Placeholder = "Enter export file path, .json, .csv, .html or .txt for Anki (default: vocabulary_export.json)"
 		m.input.Focus()
 		return m, textinput.Blink
 
@@ -222,7 +262,7 @@ func (m model) handleInputSubmission() (tea.Model, tea.Cmd) {
 		m.view = viewLoading
 		m.err = nil
 		processCmd := func() tea.Msg {
-			result, err := m.processor.ProcessDocument(inputValue)
+			result, err := processPath(m.processor, inputValue)
 			return processResultMsg{result: result, err: err}
 		}
 		return m, tea.Batch(processCmd, m.spinner.Tick)
@@ -232,7 +272,7 @@ func (m model) handleInputSubmission() (tea.Model, tea.Cmd) {
 			inputValue = "vocabulary_export.json"
 		}
 
-		err := m.processor.ExportVocabulary(inputValue)
+		err := m.processor.ExportVocabulary(inputValue, core.ExportFormatForPath(inputValue))
 		if err != nil {
 			m.err = err
 		} else {
@@ -244,6 +284,63 @@ func (m model) handleInputSubmission() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// processPath processes a single document, or every document when path is
+// a directory
+func processPath(processor *core.Processor, path string) (*core.ProcessingResult, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return processDirectory(processor, path)
+	}
+	return processor.ProcessDocument(context.Background(), path)
+}
+
+// processDirectory processes every document in dir and totals the results.
+// Files that fail are reported together once the rest have been stored.
+func processDirectory(processor *core.Processor, dir string) (*core.ProcessingResult, error) {
+	results, err := processor.ProcessDirectory(context.Background(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	total := &core.ProcessingResult{Language: processor.Language, FilePath: dir, NewItemIDs: []int{}}
+	var failures []error
+	for _, r := range results {
+		if r.Err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", r.File, r.Err))
+			continue
+		}
+		addResult(total, r.File, r.Result)
+	}
+	if len(failures) > 0 {
+		return total, errors.Join(failures...)
+	}
+	return total, nil
+}
+
+// addResult adds the counts of one file's result to a directory total. The
+// total is partial when any file was, naming each such file in PartialError.
+func addResult(total *core.ProcessingResult, file string, result *core.ProcessingResult) {
+	total.NewVocabulary += result.NewVocabulary
+	total.NewItemIDs = append(total.NewItemIDs, result.NewItemIDs...)
+	total.SkippedExisting += result.SkippedExisting
+	total.InDocumentDuplicates += result.InDocumentDuplicates
+	total.RepeatedOccurrences += result.RepeatedOccurrences
+	total.UpdatedTranslations += result.UpdatedTranslations
+	total.TotalProcessed += result.TotalProcessed
+	total.FilteredItems += result.FilteredItems
+	total.StopWordsRemoved += result.StopWordsRemoved
+	total.Pages += result.Pages
+	total.SkippedPages += result.SkippedPages
+	total.CharCount += result.CharCount
+
+	if result.Partial {
+		total.Partial = true
+		if total.PartialError != "" {
+			total.PartialError += "; "
+		}
+		total.PartialError += fmt.Sprintf("%s: %s", file, result.PartialError)
+	}
+}
+
 func (m model) View() string {
 	switch m.view {
 	case viewMenu:
@@ -325,21 +422,69 @@ func (m model) renderVocabularyList() string {
 	} else if len(m.vocabulary) == 0 {
 		s.WriteString("No vocabulary items found.\n")
 	} else {
+		start, end, pages := pageBounds(len(m.vocabulary), m.page, listPageSize)
 		s.WriteString(fmt.Sprintf("Total items: %d\n\n", len(m.vocabulary)))
-		for i, vocab := range m.vocabulary {
-			if i >= 20 {
-				s.WriteString(fmt.Sprintf("\n... and %d more items\n", len(m.vocabulary)-20))
-				break
-			}
-			s.WriteString(fmt.Sprintf("%d. %s (%s)\n", i+1, vocab.Text, vocab.Language))
+		for i, vocab := range m.vocabulary[start:end] {
+			s.WriteString(fmt.Sprintf("%d. %s (%s)\n", start+i+1, vocab.Text, vocab.Language))
 		}
+		s.WriteString(fmt.Sprintf("\nPage %d of %d\n", min(m.page, pages-1)+1, pages))
 	}
 
-	s.WriteString("\n\nPress Enter to return to menu")
+	s.WriteString("\n\nn/PgDn next page, p/PgUp previous page, Enter to return to menu")
 
 	return menuStyle.Render(s.String())
 }
 
+// listPageSize is the number of vocabulary items shown per list page
+const listPageSize = 20
+
+// pageBounds returns the slice bounds of a 0-indexed page of size items out
+// of total, clamping page to the last page, along with the page count. An
+// empty list has a single empty page.
+func pageBounds(total, page, size int) (start, end, pages int) {
+	pages = max((total+size-1)/size, 1)
+	page = min(max(page, 0), pages-1)
+
+	start = page * size
+	end = min(start+size, total)
+	return start, end, pages
+}
+
+// resultSummary lists the counts of a processing result, one per line
+func resultSummary(result *core.ProcessingResult) string {
+	var s strings.Builder
+
+	s.WriteString(fmt.Sprintf("New vocabulary added: %d\n", result.NewVocabulary))
+	s.WriteString(fmt.Sprintf("Already in database: %d\n", result.SkippedExisting))
+	if result.InDocumentDuplicates > 0 {
+		s.WriteString(fmt.Sprintf("Repeats within the document: %d\n", result.InDocumentDuplicates))
+	}
+	if result.RepeatedOccurrences > 0 {
+		s.WriteString(fmt.Sprintf("Repeat occurrences counted: %d\n", result.RepeatedOccurrences))
+	}
+	if result.UpdatedTranslations > 0 {
+		s.WriteString(fmt.Sprintf("Translations added to existing words: %d\n", result.UpdatedTranslations))
+	}
+	if result.StopWordsRemoved > 0 {
+		s.WriteString(fmt.Sprintf("Stop words removed: %d\n", result.StopWordsRemoved))
+	}
+	if result.FilteredItems > 0 {
+		s.WriteString(fmt.Sprintf("Non-vocabulary items filtered: %d\n", result.FilteredItems))
+	}
+	s.WriteString(fmt.Sprintf("Total processed: %d\n", result.TotalProcessed))
+	if result.Pages > 0 {
+		s.WriteString(fmt.Sprintf("Pages processed: %d of %d\n", result.Pages-result.SkippedPages, result.Pages))
+	}
+	if result.Language != "" {
+		s.WriteString(fmt.Sprintf("Language: %s\n", result.Language))
+	}
+	if result.FromCache {
+		s.WriteString("Vocabulary taken from the extraction cache\n")
+	}
+
+	return s.String()
+}
+
 func (m model) renderResults() string {
 	var s strings.Builder
 
@@ -352,11 +497,16 @@ func (m model) renderResults() string {
 		if m.result.TotalProcessed > 0 {
 			s.WriteString(successStyle.Render("Success!"))
 			s.WriteString("\n\n")
-			s.WriteString(fmt.Sprintf("New vocabulary added: %d\n", m.result.NewVocabulary))
-			s.WriteString(fmt.Sprintf("Duplicates skipped: %d\n", m.result.SkippedDuplicates))
-			s.WriteString(fmt.Sprintf("Total processed: %d\n", m.result.TotalProcessed))
-			if m.result.Language != "" {
-				s.WriteString(fmt.Sprintf("Language: %s\n", m.result.Language))
+			s.WriteString(resultSummary(m.result))
+			if m.result.LanguageWarning != "" {
+				s.WriteString("\n")
+				s.WriteString(errorStyle.Render(fmt.Sprintf("Warning: %s", m.result.LanguageWarning)))
+				s.WriteString("\n")
+			}
+			if m.result.Partial {
+				s.WriteString("\n")
+				s.WriteString(errorStyle.Render(fmt.Sprintf("Warning: extraction stopped early, results are partial (%s)", m.result.PartialError)))
+				s.WriteString("\n")
 			}
 		} else {
 			s.WriteString(successStyle.Render("Export completed successfully!"))
@@ -369,9 +519,41 @@ func (m model) renderResults() string {
 }
 
 func main() {
-	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
+	languageFlag := flag.String("language", "", "language of the documents (overrides LANGUAGE)")
+	fileFlag := flag.String("file", "", "process a document or directory, print the result and exit")
+	exportFlag := flag.String("export", "", "export the vocabulary and exit, as .json, .csv, .html, or Anki flashcards for .txt and .tsv")
+	jsonFlag := flag.Bool("json", false, "print the --file result as JSON")
+	forceFlag := flag.Bool("force", false, "send documents to the AI even when the extraction cache already has them")
+	flag.Parse()
+
+	opts := batchOptions{File: *fileFlag, Export: *exportFlag, JSON: *jsonFlag}
+	if err := opts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	processor, err := newProcessor(*languageFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer processor.DB.Close()
+	processor.ForceExtract = *forceFlag
+
+	// Without --file or --export, fall back to the interactive menu
+	if !opts.enabled() {
+		p := tea.NewProgram(initialModel(processor))
+		if _, err := p.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			processor.DB.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := runBatch(processor, opts, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		processor.DB.Close()
 		os.Exit(1)
 	}
 }
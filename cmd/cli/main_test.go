@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parsely/parsely/internal/core"
+	"github.com/parsely/parsely/internal/db"
+)
+
+// TestPageBounds tests the list page slice computation at the boundaries
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		name                       string
+		total, page                int
+		wantStart, wantEnd, wantPg int
+	}{
+		{"empty list", 0, 0, 0, 0, 1},
+		{"single partial page", 5, 0, 0, 5, 1},
+		{"exactly one page", 20, 0, 0, 20, 1},
+		{"first of several", 45, 0, 0, 20, 3},
+		{"middle page", 45, 1, 20, 40, 3},
+		{"last partial page", 45, 2, 40, 45, 3},
+		{"past the end clamps", 45, 7, 40, 45, 3},
+		{"negative clamps", 45, -1, 0, 20, 3},
+		{"last full page", 40, 1, 20, 40, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, pages := pageBounds(tt.total, tt.page, 20)
+			if start != tt.wantStart || end != tt.wantEnd || pages != tt.wantPg {
+				t.Errorf("pageBounds(%d, %d, 20) = (%d, %d, %d), want (%d, %d, %d)",
+					tt.total, tt.page, start, end, pages, tt.wantStart, tt.wantEnd, tt.wantPg)
+			}
+		})
+	}
+}
+
+// TestWriteResult tests the plain text and JSON result output of --file
+func TestWriteResult(t *testing.T) {
+	result := &core.ProcessingResult{
+		NewVocabulary:        3,
+		SkippedExisting:      1,
+		InDocumentDuplicates: 2,
+		TotalProcessed:       4,
+		Language:             "Spanish",
+		LanguageWarning:      "document looks like French",
+	}
+
+	var text bytes.Buffer
+	if err := writeResult(&text, result, false); err != nil {
+		t.Fatalf("Failed to write text result: %v", err)
+	}
+	for _, want := range []string{"New vocabulary added: 3\n", "Already in database: 1\n", "Repeats within the document: 2\n", "Total processed: 4\n", "Language: Spanish\n", "Warning: document looks like French\n"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Expected text output to contain %q, got:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeResult(&out, result, true); err != nil {
+		t.Fatalf("Failed to write JSON result: %v", err)
+	}
+	var decoded core.ProcessingResult
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON output does not decode: %v", err)
+	}
+	if decoded.NewVocabulary != 3 || decoded.Language != "Spanish" {
+		t.Errorf("Unexpected decoded result: %+v", decoded)
+	}
+}
+
+// TestAddResult tests that a directory total adds up every count of its
+// files and is partial when any of them is
+func TestAddResult(t *testing.T) {
+	total := &core.ProcessingResult{NewItemIDs: []int{}}
+	addResult(total, "a.pdf", &core.ProcessingResult{
+		NewVocabulary:       2,
+		NewItemIDs:          []int{1, 2},
+		SkippedExisting:     1,
+		RepeatedOccurrences: 3,
+		TotalProcessed:      6,
+		Pages:               4,
+		SkippedPages:        1,
+	})
+	addResult(total, "b.txt", &core.ProcessingResult{
+		NewVocabulary:  1,
+		NewItemIDs:     []int{3},
+		TotalProcessed: 1,
+		Partial:        true,
+		PartialError:   "rate limited",
+	})
+
+	if total.TotalProcessed != total.NewVocabulary+total.SkippedExisting+total.RepeatedOccurrences+total.UpdatedTranslations {
+		t.Errorf("Total processed %d does not match its parts: %+v", total.TotalProcessed, total)
+	}
+	if total.RepeatedOccurrences != 3 || total.Pages != 4 || total.SkippedPages != 1 || len(total.NewItemIDs) != 3 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+	if !total.Partial || total.PartialError != "b.txt: rate limited" {
+		t.Errorf("Expected the total to be partial because of b.txt, got %v %q", total.Partial, total.PartialError)
+	}
+
+	summary := resultSummary(total)
+	for _, want := range []string{"Repeat occurrences counted: 3\n", "Total processed: 7\n", "Pages processed: 3 of 4\n"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Expected summary to contain %q, got:\n%s", want, summary)
+		}
+	}
+}
+
+// TestBatchOptions tests which flag combinations run without the menu
+func TestBatchOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        batchOptions
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{"no flags", batchOptions{}, false, false},
+		{"file", batchOptions{File: "notes.pdf"}, true, false},
+		{"file as json", batchOptions{File: "notes.pdf", JSON: true}, true, false},
+		{"export", batchOptions{Export: "out.json"}, true, false},
+		{"json without file", batchOptions{Export: "out.json", JSON: true}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.enabled(); got != tt.wantEnabled {
+				t.Errorf("enabled() = %v, want %v", got, tt.wantEnabled)
+			}
+			if err := tt.opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRunBatch tests exporting without the menu and failing on a missing
+// document
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.NewDatabase(filepath.Join(dir, "cli.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer database.Close()
+	if _, err := database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	processor := core.NewProcessor(database, nil, "Spanish")
+
+	exportPath := filepath.Join(dir, "out.json")
+	var out bytes.Buffer
+	if err := runBatch(processor, batchOptions{Export: exportPath}, &out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Exported vocabulary to "+exportPath+" as json") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+	if _, err := os.Stat(exportPath); err != nil {
+		t.Errorf("Export file not written: %v", err)
+	}
+
+	// A .txt path is not plain text, so the output says so
+	out.Reset()
+	ankiPath := filepath.Join(dir, "out.txt")
+	if err := runBatch(processor, batchOptions{Export: ankiPath}, &out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Exported vocabulary to "+ankiPath+" as anki") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	if err := runBatch(processor, batchOptions{File: filepath.Join(dir, "missing.pdf")}, &out); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output on failure, got %q", out.String())
+	}
+}
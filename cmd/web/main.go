@@ -1,58 +1,88 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/parsely/parsely/internal/ai"
 	"github.com/parsely/parsely/internal/api"
+	"github.com/parsely/parsely/internal/config"
 	"github.com/parsely/parsely/internal/core"
 	"github.com/parsely/parsely/internal/db"
+	"github.com/parsely/parsely/internal/parser"
 )
 
-func main() {
-	// Load environment variables
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	if apiKey == "" {
-		log.Fatal("Error: ANTHROPIC_API_KEY environment variable not set")
-	}
-
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "parsely.db"
-	}
+// shutdownTimeout is how long in-flight requests, such as uploads still
+// being processed, get to finish after SIGINT or SIGTERM
+const shutdownTimeout = 30 * time.Second
 
-	language := os.Getenv("LANGUAGE")
-	if language == "" {
-		language = "auto-detect"
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Remove temp files orphaned by a previous crash
+	if removed, err := parser.ReapOrphanedTempFiles(time.Hour); err != nil {
+		log.Printf("Warning: failed to clean up orphaned temp files: %v", err)
+	} else if removed > 0 {
+		log.Printf("Removed %d orphaned temp files", removed)
 	}
 
 	// Initialize database
-	database, err := db.NewDatabase(dbPath)
+	database, err := db.NewDatabaseWithOptions(cfg.DBPath, db.Options{BusyTimeout: cfg.DBBusyTimeout})
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
-	defer database.Close()
 
 	// Initialize AI client
-	aiClient, err := ai.NewClaudeClient(apiKey)
+	aiClient, err := ai.NewExtractor(cfg.Provider, cfg.AnthropicAPIKey)
 	if err != nil {
 		log.Fatalf("Error initializing AI client: %v", err)
 	}
+	if client, ok := aiClient.(*ai.ClaudeClient); ok {
+		client.Limits = ai.LengthLimits{MinLength: cfg.MinWordLength, MaxWords: cfg.MaxPhraseWords}
+	}
 
 	// Create processor
-	processor := core.NewProcessor(database, aiClient, language)
+	processor := core.NewProcessor(database, aiClient, cfg.Language)
+	processor.ExtractContext = cfg.ExtractContext
+	processor.QualityFilter = cfg.QualityFilter
+	processor.Parser = parser.ParserConfig{PDFEngine: cfg.PDFEngine}
+	parser.SetMaxFileSize(cfg.MaxFileSizeMB << 20)
+	if cfg.StopWords {
+		stopWords, err := core.LoadStopWords(cfg.StopWordsFile)
+		if err != nil {
+			log.Fatalf("Error loading stop words: %v", err)
+		}
+		processor.StopWords = stopWords
+	}
+	if cfg.AllowDuplicates {
+		processor.OnDuplicate = core.DuplicateCount
+	}
+	if cfg.WebhookURL != "" {
+		processor.Webhook = core.NewWebhook(cfg.WebhookURL, cfg.WebhookSecret)
+	}
 
 	// Create API handler
 	handler := &api.Handler{
-		Processor: processor,
+		Processor:        processor,
+		MinFreeBytes:     uint64(cfg.MinFreeDiskBytes),
+		DataDir:          filepath.Dir(cfg.DBPath),
+		DailyUploadQuota: cfg.DailyUploadQuota,
+	}
+
+	// A bad key should not stop the server, but it should be obvious
+	if err := handler.CheckAI(context.Background()); err != nil {
+		log.Printf("Warning: AI provider check failed, uploads will fail until this is fixed: %v", err)
 	}
 
 	// Setup router
@@ -60,39 +90,204 @@ func main() {
 
 	// API routes
 	mux.HandleFunc("GET /api/vocabulary", handler.ListVocabulary)
+	mux.HandleFunc("GET /api/vocabulary.csv", handler.ListVocabulary)
+	mux.HandleFunc("POST /api/vocabulary", handler.AddVocabulary)
+	mux.HandleFunc("GET /api/vocabulary/search", handler.SearchVocabulary)
+	mux.HandleFunc("GET /api/vocabulary/random", handler.RandomVocabulary)
 	mux.HandleFunc("GET /api/vocabulary/{id}", handler.GetVocabulary)
+	mux.HandleFunc("GET /api/vocabulary/{id}/similar", handler.SimilarVocabulary)
+	mux.HandleFunc("GET /api/vocabulary/{id}/history", handler.VocabularyHistory)
 	mux.HandleFunc("DELETE /api/vocabulary/{id}", handler.DeleteVocabulary)
-	mux.HandleFunc("POST /api/upload", handler.UploadDocument)
+	mux.HandleFunc("POST /api/vocabulary/{id}/restore", handler.RestoreVocabulary)
+	mux.HandleFunc("POST /api/vocabulary/tag", handler.TagVocabulary)
+	mux.HandleFunc("POST /api/vocabulary/{id}/tags", handler.AddVocabularyTag)
+	mux.HandleFunc("DELETE /api/vocabulary/{id}/tags/{tag}", handler.RemoveVocabularyTag)
+	mux.HandleFunc("POST /api/vocabulary/bulk-delete", handler.BulkDeleteVocabulary)
+	mux.HandleFunc("DELETE /api/vocabulary", handler.DeleteAllVocabulary)
+	mux.HandleFunc("POST /api/vocabulary/translate-missing", handler.TranslateMissing)
+	// Uploads are the routes that call the paid AI API, so they share one
+	// per-client rate limit when it is enabled
+	upload := http.Handler(http.HandlerFunc(handler.UploadDocument))
+	uploadStream := http.Handler(http.HandlerFunc(handler.UploadDocumentStream))
+	uploadURL := http.Handler(http.HandlerFunc(handler.UploadURL))
+	if cfg.UploadRateLimit > 0 {
+		limiter := api.NewRateLimiter(cfg.UploadRateLimit)
+		upload = api.RateLimitMiddleware(limiter, upload)
+		uploadStream = api.RateLimitMiddleware(limiter, uploadStream)
+		uploadURL = api.RateLimitMiddleware(limiter, uploadURL)
+	}
+	mux.Handle("POST /api/upload", upload)
+	mux.Handle("POST /api/upload/stream", uploadStream)
+	mux.Handle("POST /api/upload-url", uploadURL)
 	mux.HandleFunc("POST /api/export", handler.ExportVocabulary)
+	mux.HandleFunc("POST /api/import", handler.ImportVocabulary)
 	mux.HandleFunc("GET /api/stats", handler.GetStats)
+	mux.HandleFunc("POST /api/maintenance/rebuild", handler.RebuildDerived)
+	mux.HandleFunc("POST /api/maintenance/relabel-languages", handler.RelabelLanguages)
+	mux.HandleFunc("POST /api/maintenance/purge-deleted", handler.PurgeDeleted)
+
+	// The backup contains the whole database, so it must be enabled explicitly
+	if cfg.EnableBackupDownload {
+		mux.HandleFunc("GET /api/backup/download", handler.DownloadBackup)
+	}
+
+	if cfg.EnableUI {
+		api.RegisterUI(mux)
+	}
 
 	// Health check
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	mux.HandleFunc("GET /health", handler.Health)
+	mux.HandleFunc("GET /health/ready", handler.Ready)
 
 	// Apply middleware
-	var handlerWithMiddleware http.Handler = mux
-	handlerWithMiddleware = api.CorsMiddleware(handlerWithMiddleware)
+	handlerWithMiddleware := api.RouteMiddleware(mux)
+	handlerWithMiddleware = api.TimeoutMiddleware(cfg.RequestTimeout, handlerWithMiddleware)
+	handlerWithMiddleware = api.BodyLimitMiddleware(cfg.MaxBodyBytes, handlerWithMiddleware)
+	// Inside CORS so browsers can still make preflight requests without a key
+	if cfg.APISecret != "" {
+		handlerWithMiddleware = api.AuthMiddleware(cfg.APISecret, handlerWithMiddleware)
+	}
+	handlerWithMiddleware = api.CorsMiddleware(cfg.CORSOrigins, handlerWithMiddleware)
 	handlerWithMiddleware = api.LoggingMiddleware(handlerWithMiddleware)
+	handlerWithMiddleware = api.RequestIDMiddleware(handlerWithMiddleware)
 	handlerWithMiddleware = api.RecoverMiddleware(handlerWithMiddleware)
 
 	// Start server
-	addr := ":" + port
+	addr := ":" + cfg.Port
 	fmt.Printf("Starting Parsely web server on http://localhost%s\n", addr)
-	fmt.Printf("Database: %s\n", dbPath)
-	fmt.Printf("Language: %s\n", language)
+	fmt.Printf("Database: %s\n", cfg.DBPath)
+	fmt.Printf("Language: %s\n", cfg.Language)
+	if cfg.APISecret != "" {
+		fmt.Println("Authentication: API key required (X-API-Key or Bearer token)")
+	} else {
+		fmt.Println("Authentication: disabled, set API_SECRET before exposing the server")
+	}
+	if cfg.EnableUI {
+		fmt.Printf("Web UI: http://localhost%s/\n", addr)
+	}
 	fmt.Println("\nAPI Endpoints:")
 	fmt.Println("  GET    /api/vocabulary      - List all vocabulary")
+	fmt.Println("  POST   /api/vocabulary      - Add a word by hand")
 	fmt.Println("  GET    /api/vocabulary/{id} - Get vocabulary by ID")
+	fmt.Println("  GET    /api/vocabulary/{id}/similar - Find similarly spelled vocabulary")
 	fmt.Println("  DELETE /api/vocabulary/{id} - Delete vocabulary by ID")
 	fmt.Println("  POST   /api/upload          - Upload and process document")
+	fmt.Println("  POST   /api/upload/stream   - Upload with progress as Server-Sent Events")
+	fmt.Println("  POST   /api/upload-url      - Fetch and process document from URL")
 	fmt.Println("  POST   /api/export          - Export vocabulary to JSON")
 	fmt.Println("  GET    /api/stats           - Get vocabulary statistics")
+	fmt.Println("  POST   /api/maintenance/rebuild - Recompute derived columns")
+	if cfg.EnableBackupDownload {
+		fmt.Println("  GET    /api/backup/download - Download a SQLite backup")
+	}
 	fmt.Println("  GET    /health              - Health check")
+	fmt.Println("  GET    /health/ready        - Readiness check (database, ?ai=true for the AI provider)")
+
+	server := &http.Server{
+		Addr:           addr,
+		Handler:        handlerWithMiddleware,
+		MaxHeaderBytes: int(cfg.MaxHeaderBytes),
+	}
 
-	if err := http.ListenAndServe(addr, handlerWithMiddleware); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	if cfg.BackupInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			runBackups(ctx, database, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+		}()
+	}
+	if cfg.WALCheckpointInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			runCheckpoints(ctx, database, cfg.WALCheckpointInterval)
+		}()
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		database.Close()
 		log.Fatalf("Server error: %v", err)
+	case <-ctx.Done():
+	}
+	// A second Ctrl+C kills the process instead of waiting
+	stop()
+
+	// Shutdown stops accepting connections and waits for in-flight requests,
+	// so uploads being processed finish and store their vocabulary before
+	// the database is closed
+	log.Printf("Shutting down, waiting up to %s for in-flight requests...", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: server shutdown: %v", err)
+	}
+	background.Wait()
+	if processor.Webhook != nil {
+		processor.Webhook.Close()
+	}
+
+	if err := database.Checkpoint(); err != nil {
+		log.Printf("Warning: failed to checkpoint database: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		log.Printf("Warning: failed to close database: %v", err)
+	}
+	log.Println("Shutdown complete")
+}
+
+// runBackups writes a database backup to dir every interval, keeping the
+// newest keep backups, until ctx is cancelled
+func runBackups(ctx context.Context, database *db.Database, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		path, err := database.BackupToDir(dir)
+		if err != nil {
+			log.Printf("Warning: scheduled backup failed: %v", err)
+			continue
+		}
+		log.Printf("Wrote backup %s", path)
+
+		if removed, err := db.PruneBackups(dir, keep); err != nil {
+			log.Printf("Warning: failed to prune old backups: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d old backups", removed)
+		}
+	}
+}
+
+// runCheckpoints truncates the database's write-ahead log every interval
+// until ctx is cancelled
+func runCheckpoints(ctx context.Context, database *db.Database, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := database.Checkpoint(); err != nil {
+			log.Printf("Warning: scheduled WAL checkpoint failed: %v", err)
+		}
 	}
 }
@@ -2,24 +2,116 @@ package ai
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/parsely/parsely/internal/parser"
 )
 
-// AIExtractor defines the interface for vocabulary extraction
+// AIExtractor defines the interface for vocabulary extraction. Its methods
+// give up once ctx is done, so a cancelled request stops its call.
 type AIExtractor interface {
-	ExtractVocabulary(text, language string) ([]string, error)
+	ExtractVocabulary(ctx context.Context, text, language string) ([]string, error)
+
+	// DetectLanguage returns the English name of the language text is
+	// written in, such as "Spanish"
+	DetectLanguage(ctx context.Context, text string) (string, error)
+
+	// Validate checks that the provider is reachable and the credentials
+	// are accepted, without extracting anything
+	Validate(ctx context.Context) error
+}
+
+// ContextExtractor is implemented by extractors that can also return the
+// sentence each vocabulary item appeared in. Items carry a translation and an
+// example too when the extractor can provide them.
+type ContextExtractor interface {
+	ExtractVocabularyWithContext(ctx context.Context, text, language string) ([]VocabularyItem, error)
+}
+
+// TranslationExtractor is implemented by extractors that return an English
+// translation with each extracted item. Items the model could not translate
+// have an empty Translation.
+type TranslationExtractor interface {
+	ExtractVocabularyWithTranslations(ctx context.Context, text, language string) ([]VocabularyItem, error)
+}
+
+// Translator is implemented by extractors that can translate stored
+// vocabulary in batches. The result maps each input word to its English
+// translation; words the model could not translate are left out.
+type Translator interface {
+	TranslateWords(ctx context.Context, words []string, language string) (map[string]string, error)
 }
 
+// LimitedExtractor is implemented by extractors that drop items outside
+// length limits, so the same text extracts differently once they change
+type LimitedExtractor interface {
+	LengthLimits() LengthLimits
+}
+
+// VisionExtractor is implemented by extractors whose model can read
+// vocabulary from images such as photos of textbook pages. mimeType is one
+// of the types accepted by SupportedImageType.
+type VisionExtractor interface {
+	ExtractVocabularyFromImage(ctx context.Context, image []byte, mimeType, language string) ([]string, error)
+}
+
+// SupportedImageType reports whether the vision model accepts images of the
+// given MIME type. It accepts the image types the parser recognizes.
+func SupportedImageType(mimeType string) bool {
+	return parser.SupportedImageMIMEType(mimeType)
+}
+
+// VocabularyItem is an extracted vocabulary entry with optional details
+type VocabularyItem struct {
+	Text        string
+	Context     string
+	Translation string
+
+	// Example is a sentence using the item, taken from or modelled on the
+	// source document; empty when the model gave none
+	Example string
+
+	// Count is how often the item appears in the source document; zero
+	// when it has not been counted
+	Count int
+
+	// Duplicates is how many further times the extractor listed the item;
+	// those copies were collapsed into this one
+	Duplicates int
+}
+
+// DefaultMaxRetries is the number of times NewClaudeClient retries a request
+// that failed because Claude was rate limited, overloaded or briefly
+// unavailable
+const DefaultMaxRetries = 3
+
+// defaultRetryDelay is the backoff before the first retry; it doubles on
+// each further attempt
+const defaultRetryDelay = time.Second
+
 // ClaudeClient implements AIExtractor using Claude API
 type ClaudeClient struct {
 	client *anthropic.Client
+
+	// MaxRetries is how many times a request answered with 429 or 503 is
+	// retried, with exponential backoff, before its error is returned
+	MaxRetries int
+
+	// Limits drops single letters, whole sentences and other items of the
+	// wrong size from extraction results
+	Limits LengthLimits
+
+	// retryDelay is the first backoff; tests shorten it
+	retryDelay time.Duration
 }
 
 // AIError represents an error from the AI API
@@ -41,6 +133,14 @@ func (e *AIError) Error() string {
 	return msg
 }
 
+// ErrResponseTruncated is returned when Claude stopped at the max_tokens
+// limit, so the JSON it produced is incomplete. Callers can retry with a
+// smaller piece of text.
+var ErrResponseTruncated = errors.New("response truncated at max_tokens")
+
+// ErrContentRefused is returned when Claude declined to process the content
+var ErrContentRefused = errors.New("model refused to process the content")
+
 // IsAIError checks if an error is an AIError
 func IsAIError(err error) bool {
 	var aiErr *AIError
@@ -53,54 +153,283 @@ func NewClaudeClient(apiKey string) (*ClaudeClient, error) {
 		return nil, err
 	}
 
+	// Retries are handled by sendBlocks so MaxRetries is the only knob
 	client := anthropic.NewClient(
 		option.WithAPIKey(apiKey),
+		option.WithMaxRetries(0),
 	)
 
 	return &ClaudeClient{
-		client: &client,
+		client:     &client,
+		MaxRetries: DefaultMaxRetries,
+		Limits:     DefaultLengthLimits(),
+		retryDelay: defaultRetryDelay,
 	}, nil
 }
 
+// LengthLimits returns the limits extraction results are filtered by
+func (c *ClaudeClient) LengthLimits() LengthLimits {
+	return c.Limits
+}
+
 // ExtractVocabulary uses Claude to extract vocabulary from text
-func (c *ClaudeClient) ExtractVocabulary(text, language string) ([]string, error) {
+func (c *ClaudeClient) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	items, err := c.ExtractVocabularyWithTranslations(ctx, text, language)
+	if err != nil {
+		return nil, err
+	}
+	return itemTexts(items), nil
+}
+
+// languageSampleSize is the number of characters of a document sent to
+// Claude for language detection
+const languageSampleSize = 2000
+
+// DetectLanguage asks Claude which language a sample from the start of text
+// is written in
+func (c *ClaudeClient) DetectLanguage(ctx context.Context, text string) (string, error) {
+	sample := strings.TrimSpace(text)
+	if sample == "" {
+		return "", errors.New("no text to detect the language of")
+	}
+	if runes := []rune(sample); len(runes) > languageSampleSize {
+		sample = string(runes[:languageSampleSize])
+	}
+
+	response, err := c.sendPrompt(ctx, buildLanguagePrompt(sample))
+	if err != nil {
+		return "", err
+	}
+
+	language, err := parseLanguageResponse(response)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse language response: %w", err)
+	}
+	return language, nil
+}
+
+// ExtractVocabularyWithTranslations uses Claude to extract vocabulary from
+// text together with an English translation of each item
+func (c *ClaudeClient) ExtractVocabularyWithTranslations(ctx context.Context, text, language string) ([]VocabularyItem, error) {
+	if strings.TrimSpace(text) == "" {
+		return []VocabularyItem{}, nil
+	}
+
+	response, err := c.sendPrompt(ctx, buildPrompt(text, language))
+	if err != nil {
+		return nil, err
+	}
+	if response == "" {
+		return []VocabularyItem{}, nil
+	}
+
+	items, err := parseVocabularyResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary response: %w", err)
+	}
+
+	return deduplicateItems(c.Limits.filterItems(sanitizeItems(items))), nil
+}
+
+// ExtractVocabularyWithContext uses Claude to extract vocabulary together with
+// the sentence each word appeared in, a translation and an example sentence
+func (c *ClaudeClient) ExtractVocabularyWithContext(ctx context.Context, text, language string) ([]VocabularyItem, error) {
 	if strings.TrimSpace(text) == "" {
+		return []VocabularyItem{}, nil
+	}
+
+	response, err := c.sendPrompt(ctx, buildContextPrompt(text, language))
+	if err != nil {
+		return nil, err
+	}
+	if response == "" {
+		return []VocabularyItem{}, nil
+	}
+
+	items, err := parseContextResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary response: %w", err)
+	}
+
+	return deduplicateItems(c.Limits.filterItems(sanitizeItems(items))), nil
+}
+
+// TranslateWords uses Claude to translate a batch of vocabulary into English
+func (c *ClaudeClient) TranslateWords(ctx context.Context, words []string, language string) (map[string]string, error) {
+	if len(words) == 0 {
+		return map[string]string{}, nil
+	}
+
+	response, err := c.sendPrompt(ctx, buildTranslationPrompt(words, language))
+	if err != nil {
+		return nil, err
+	}
+
+	translations, err := parseTranslationResponse(response, words)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+
+	return translations, nil
+}
+
+// ExtractVocabularyFromImage uses Claude's vision support to extract
+// vocabulary from an image of course notes
+func (c *ClaudeClient) ExtractVocabularyFromImage(ctx context.Context, image []byte, mimeType, language string) ([]string, error) {
+	if !SupportedImageType(mimeType) {
+		return nil, fmt.Errorf("unsupported image type: %s", mimeType)
+	}
+	if len(image) == 0 {
 		return []string{}, nil
 	}
 
-	prompt := buildPrompt(text, language)
+	response, err := c.sendBlocks(ctx,
+		anthropic.NewImageBlockBase64(mimeType, base64.StdEncoding.EncodeToString(image)),
+		anthropic.NewTextBlock(buildImagePrompt(language)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if response == "" {
+		return []string{}, nil
+	}
+
+	items, err := parseVocabularyResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary response: %w", err)
+	}
+
+	return deduplicateVocabulary(c.Limits.filterTexts(sanitizeVocabulary(itemTexts(items)))), nil
+}
+
+// sendPrompt sends a single-turn prompt to Claude and returns the
+// concatenated text of the response
+func (c *ClaudeClient) sendPrompt(ctx context.Context, prompt string) (string, error) {
+	return c.sendBlocks(ctx, anthropic.NewTextBlock(prompt))
+}
+
+// sendBlocks sends a single user message made of the given content blocks
+// and returns the concatenated text of the response. Responses with a
+// retryableStatus are retried up to MaxRetries times; a retry that would
+// outlast ctx's deadline is not attempted.
+func (c *ClaudeClient) sendBlocks(ctx context.Context, blocks ...anthropic.ContentBlockParamUnion) (string, error) {
+	for attempt := 0; ; attempt++ {
+		text, err := c.sendOnce(ctx, blocks...)
+
+		var aiErr *AIError
+		if err == nil || attempt >= c.MaxRetries || !errors.As(err, &aiErr) || !retryableStatus(aiErr.StatusCode) {
+			return text, err
+		}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		if !sleepContext(ctx, backoff(c.retryDelay, attempt)) {
+			return "", err
+		}
+	}
+}
+
+// sendOnce makes a single Messages API call
+func (c *ClaudeClient) sendOnce(ctx context.Context, blocks ...anthropic.ContentBlockParamUnion) (string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
+	message, err := c.client.Messages.New(callCtx, anthropic.MessageNewParams{
 		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
 		MaxTokens: 2000,
 		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
+			anthropic.NewUserMessage(blocks...),
 		},
 	})
 
 	if err != nil {
-		var apiErr *anthropic.Error
-		if errors.As(err, &apiErr) {
-			return nil, &AIError{
-				Message:     apiErr.Error(),
-				StatusCode:  apiErr.StatusCode,
-				RequestID:   apiErr.RequestID,
-				RawResponse: apiErr.RawJSON(),
-			}
-		}
-		return nil, &AIError{
-			Message:    fmt.Sprintf("failed to call Claude API: %v", err),
-			StatusCode: 500,
+		// A caller that gave up is not an API failure; keep its error
+		// detectable with errors.Is
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			return "", err
 		}
+		return "", toAIError(err)
 	}
 
-	if len(message.Content) == 0 {
-		return []string{}, nil
+	return readMessage(message)
+}
+
+// statusOverloaded is the status Anthropic answers with when Claude is
+// overloaded; net/http has no name for it
+const statusOverloaded = 529
+
+// retryableStatus reports whether a failed request is worth retrying: Claude
+// was rate limited (429), overloaded (529) or hit a transient server error
+// (500, 502, 503)
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, statusOverloaded,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number attempt (0-based): base
+// doubled per attempt, plus up to half as much again of random jitter so
+// concurrent clients do not retry in lockstep
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << attempt
+	if delay <= 0 {
+		return 0
 	}
+	return delay + rand.N(delay/2+1)
+}
+
+// sleepContext waits for d, returning false without waiting if ctx would
+// expire first or is cancelled during the wait
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Validate checks the API key by listing a single model, which costs no
+// tokens
+func (c *ClaudeClient) Validate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := c.client.Models.List(ctx, anthropic.ModelListParams{Limit: anthropic.Int(1)}); err != nil {
+		return toAIError(err)
+	}
+	return nil
+}
+
+// toAIError converts an SDK error into an *AIError
+func toAIError(err error) *AIError {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return &AIError{
+			Message:     apiErr.Error(),
+			StatusCode:  apiErr.StatusCode,
+			RequestID:   apiErr.RequestID,
+			RawResponse: apiErr.RawJSON(),
+		}
+	}
+	return &AIError{
+		Message:    fmt.Sprintf("failed to call Claude API: %v", err),
+		StatusCode: 500,
+	}
+}
+
+// readMessage returns the concatenated text of a response, checking the stop
+// reason so a truncated or refused response is not mistaken for a complete
+// (possibly empty) result
+func readMessage(message *anthropic.Message) (string, error) {
 	var b strings.Builder
 	for _, block := range message.Content {
 		if block.Type == "text" {
@@ -108,15 +437,14 @@ func (c *ClaudeClient) ExtractVocabulary(text, language string) ([]string, error
 		}
 	}
 
-	vocab, err := parseVocabularyResponse(b.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse vocabulary response: %w", err)
+	switch message.StopReason {
+	case anthropic.StopReasonMaxTokens:
+		return b.String(), fmt.Errorf("%w (%d characters received)", ErrResponseTruncated, b.Len())
+	case anthropic.StopReasonRefusal:
+		return "", ErrContentRefused
 	}
 
-	vocab = sanitizeVocabulary(vocab)
-	vocab = deduplicateVocabulary(vocab)
-
-	return vocab, nil
+	return b.String(), nil
 }
 
 // buildPrompt constructs the prompt for Claude
@@ -127,41 +455,281 @@ func buildPrompt(text, language string) string {
 
 	return fmt.Sprintf(`You are a language learning assistant. Extract all vocabulary words and phrases from the following %s language course notes.
 
+Return ONLY a JSON array of unique vocabulary items, each as an object with a "text" field holding the %s item, a "translation" field holding a short English translation and an "example" field holding one short %s sentence using the item. Include:
+- Individual words
+- Common phrases
+- Expressions
+- Greetings
+
+Do NOT include:
+- Lesson titles
+- Section headers
+- English translations as items of their own (only extract the %s text)
+- Duplicate entries
+
+Keep translations short, as they would appear on a flashcard. Use an empty string if you cannot translate an item.
+
+Take the example from the document when the item appears in a full sentence there; otherwise write a simple sentence in the same style. Use an empty string if you cannot give one.
+
+Return format: [{"text": "hola", "translation": "hello", "example": "¡Hola, Ana!"}, {"text": "phrase 2", "translation": "...", "example": "..."}, ...]
+
+Document content:
+%s`, language, language, language, language, text)
+}
+
+// buildLanguagePrompt constructs a prompt asking Claude for the language of
+// a document sample
+func buildLanguagePrompt(sample string) string {
+	return fmt.Sprintf(`You are a language learning assistant. Identify the language the following language course notes teach. Ignore English instructions or translations if the notes teach another language.
+
+Return ONLY the English name of the language as listed in ISO 639, such as "Spanish" or "French", with no other text.
+
+Document content:
+%s`, sample)
+}
+
+// buildImagePrompt constructs the instructions sent alongside an image of
+// course notes
+func buildImagePrompt(language string) string {
+	if language == "" || strings.EqualFold(language, "auto-detect") {
+		language = "the target language"
+	}
+
+	return fmt.Sprintf(`You are a language learning assistant. The image shows %s language course notes, such as a photo of a textbook page or a flashcard. Extract all vocabulary words and phrases that appear in it.
+
 Return ONLY a JSON array of unique vocabulary items, each as a simple string. Include:
 - Individual words
 - Common phrases
 - Expressions
 - Greetings
 
+Do NOT include:
+- Lesson titles
+- Section headers
+- Page numbers
+- English translations (only extract the %s text)
+- Duplicate entries
+
+Return format: ["word1", "phrase 2", "word3", ...]`, language, language)
+}
+
+// buildContextPrompt constructs a prompt asking Claude for each vocabulary
+// item together with the sentence it appeared in, its translation and an
+// example sentence
+func buildContextPrompt(text, language string) string {
+	if language == "" {
+		language = "the target language"
+	}
+
+	return fmt.Sprintf(`You are a language learning assistant. Extract all vocabulary words and phrases from the following %s language course notes, together with the sentence from the notes in which each one appears.
+
+Return ONLY a JSON array of objects with a "word" field, a "context" field, a "translation" field holding a short English translation and an "example" field holding one short %s sentence using the word. Include:
+- Individual words
+- Common phrases
+- Expressions
+- Greetings
+
 Do NOT include:
 - Lesson titles
 - Section headers
 - English translations (only extract the %s text)
 - Duplicate entries
 
-Return format: ["word1", "phrase 2", "word3", ...]
+The "context" must be copied from the document. Use an empty string if the word does not appear in a full sentence.
+
+Keep translations short, as they would appear on a flashcard. Use an empty string if you cannot translate a word.
+
+Write the example in the style of the document, or reuse the context sentence. Use an empty string if you cannot give one.
+
+Return format: [{"word": "hola", "context": "Hola, me llamo Ana.", "translation": "hello", "example": "¡Hola, Ana!"}, ...]
 
 Document content:
-%s`, language, language, text)
+%s`, language, language, language, text)
 }
 
-// parseVocabularyResponse extracts a string slice from Claude's JSON response,
-// handling optional markdown code block wrappers.
-func parseVocabularyResponse(response string) ([]string, error) {
-	response = strings.TrimSpace(response)
+// buildTranslationPrompt constructs a prompt asking Claude to translate a
+// numbered list of vocabulary into English
+func buildTranslationPrompt(words []string, language string) string {
+	if language == "" || strings.EqualFold(language, "auto-detect") {
+		language = "their original language"
+	}
+
+	var list strings.Builder
+	for i, word := range words {
+		fmt.Fprintf(&list, "%d. %s\n", i+1, word)
+	}
 
-	// Remove markdown code blocks if present
+	return fmt.Sprintf(`You are a language learning assistant. Translate each of the following vocabulary items from %s into English.
+
+Return ONLY a JSON array with one object per item, using the item's number as "index":
+[{"index": 1, "word": "original item", "translation": "English translation"}, ...]
+
+Keep translations short, as they would appear on a flashcard. Omit items you cannot translate.
+
+Items:
+%s`, language, list.String())
+}
+
+// parseTranslationResponse maps Claude's translations back to the requested
+// words. Entries are matched by index when the index and word agree, and by
+// word otherwise, so a renumbered or reordered response is still usable.
+func parseTranslationResponse(response string, words []string) (map[string]string, error) {
+	response = stripCodeFence(response)
+
+	var raw []struct {
+		Index       int    `json:"index"`
+		Word        string `json:"word"`
+		Translation string `json:"translation"`
+	}
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	requested := make(map[string]bool, len(words))
+	for _, word := range words {
+		requested[word] = true
+	}
+
+	translations := make(map[string]string, len(raw))
+	for _, entry := range raw {
+		translation := strings.TrimSpace(entry.Translation)
+		if translation == "" {
+			continue
+		}
+
+		word := strings.TrimSpace(entry.Word)
+		if entry.Index >= 1 && entry.Index <= len(words) && (word == "" || word == words[entry.Index-1]) {
+			word = words[entry.Index-1]
+		}
+		if requested[word] {
+			translations[word] = translation
+		}
+	}
+
+	return translations, nil
+}
+
+// parseVocabularyResponse extracts text/translation/example objects from
+// Claude's JSON response, handling optional markdown code block wrappers.
+// Plain string elements, the older response format, are accepted too and
+// become items without a translation or example.
+func parseVocabularyResponse(response string) ([]VocabularyItem, error) {
+	response = stripCodeFence(response)
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	items := make([]VocabularyItem, 0, len(raw))
+	for _, element := range raw {
+		var text string
+		if err := json.Unmarshal(element, &text); err == nil {
+			items = append(items, VocabularyItem{Text: text})
+			continue
+		}
+
+		var entry struct {
+			Text        string `json:"text"`
+			Translation string `json:"translation"`
+			Example     string `json:"example"`
+		}
+		if err := json.Unmarshal(element, &entry); err != nil {
+			return nil, fmt.Errorf("invalid vocabulary item %s: %w", element, err)
+		}
+		items = append(items, VocabularyItem{Text: entry.Text, Translation: entry.Translation, Example: entry.Example})
+	}
+
+	return items, nil
+}
+
+// parseContextResponse extracts word/context/translation/example objects
+// from Claude's JSON response, handling optional markdown code block
+// wrappers. Missing fields are left empty.
+func parseContextResponse(response string) ([]VocabularyItem, error) {
+	response = stripCodeFence(response)
+
+	var raw []struct {
+		Word        string `json:"word"`
+		Context     string `json:"context"`
+		Translation string `json:"translation"`
+		Example     string `json:"example"`
+	}
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	items := make([]VocabularyItem, 0, len(raw))
+	for _, entry := range raw {
+		items = append(items, VocabularyItem{Text: entry.Word, Context: entry.Context, Translation: entry.Translation, Example: entry.Example})
+	}
+
+	return items, nil
+}
+
+// parseLanguageResponse extracts the language name from Claude's response,
+// tolerating surrounding quotes and a trailing period
+func parseLanguageResponse(response string) (string, error) {
+	language := strings.Trim(stripCodeFence(response), "\"'`. \t\r\n")
+	if language == "" {
+		return "", errors.New("empty response")
+	}
+	if strings.ContainsAny(language, "\n{[") || len(strings.Fields(language)) > 3 {
+		return "", fmt.Errorf("unexpected response %q", language)
+	}
+	return language, nil
+}
+
+// stripCodeFence removes surrounding whitespace and markdown code block
+// wrappers from a model response
+func stripCodeFence(response string) string {
+	response = strings.TrimSpace(response)
 	response = strings.TrimPrefix(response, "```json")
 	response = strings.TrimPrefix(response, "```")
 	response = strings.TrimSuffix(response, "```")
-	response = strings.TrimSpace(response)
+	return strings.TrimSpace(response)
+}
 
-	var vocab []string
-	if err := json.Unmarshal([]byte(response), &vocab); err != nil {
-		return nil, fmt.Errorf("invalid JSON response: %w", err)
+// sanitizeItems trims whitespace and drops items without text
+func sanitizeItems(items []VocabularyItem) []VocabularyItem {
+	cleaned := make([]VocabularyItem, 0, len(items))
+	for _, item := range items {
+		item.Text = strings.TrimSpace(item.Text)
+		item.Context = strings.TrimSpace(item.Context)
+		item.Translation = strings.TrimSpace(item.Translation)
+		item.Example = strings.TrimSpace(item.Example)
+		if item.Text != "" {
+			cleaned = append(cleaned, item)
+		}
 	}
+	return cleaned
+}
+
+// deduplicateItems removes items with repeated text, keeping the first and
+// counting the removed copies in its Duplicates
+func deduplicateItems(items []VocabularyItem) []VocabularyItem {
+	seen := make(map[string]int, len(items))
+	unique := make([]VocabularyItem, 0, len(items))
 
-	return vocab, nil
+	for _, item := range items {
+		if i, ok := seen[item.Text]; ok {
+			unique[i].Duplicates += 1 + item.Duplicates
+			continue
+		}
+		seen[item.Text] = len(unique)
+		unique = append(unique, item)
+	}
+
+	return unique
+}
+
+// itemTexts returns the text of each item
+func itemTexts(items []VocabularyItem) []string {
+	texts := make([]string, 0, len(items))
+	for _, item := range items {
+		texts = append(texts, item.Text)
+	}
+	return texts
 }
 
 // sanitizeVocabulary cleans up vocabulary items by trimming whitespace and removing empty entries
@@ -1,9 +1,19 @@
 package ai
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 )
 
 // MockAIExtractor is a mock implementation for testing
@@ -12,20 +22,34 @@ type MockAIExtractor struct {
 	Response    []string
 }
 
-func (m *MockAIExtractor) ExtractVocabulary(text, language string) ([]string, error) {
+func (m *MockAIExtractor) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
 	if m.ShouldError {
 		return nil, &AIError{Message: "mock error", StatusCode: 500}
 	}
 	return m.Response, nil
 }
 
+func (m *MockAIExtractor) DetectLanguage(ctx context.Context, text string) (string, error) {
+	if m.ShouldError {
+		return "", &AIError{Message: "mock error", StatusCode: 500}
+	}
+	return "Spanish", nil
+}
+
+func (m *MockAIExtractor) Validate(ctx context.Context) error {
+	if m.ShouldError {
+		return &AIError{Message: "mock error", StatusCode: 401}
+	}
+	return nil
+}
+
 // TestExtractVocabulary tests basic vocabulary extraction
 func TestExtractVocabulary(t *testing.T) {
 	mock := &MockAIExtractor{
 		Response: []string{"hola", "buenos días", "gracias"},
 	}
 
-	vocab, err := mock.ExtractVocabulary("Some Spanish text", "es")
+	vocab, err := mock.ExtractVocabulary(context.Background(), "Some Spanish text", "es")
 	if err != nil {
 		t.Fatalf("Failed to extract vocabulary: %v", err)
 	}
@@ -53,7 +77,7 @@ func TestExtractVocabularyError(t *testing.T) {
 		ShouldError: true,
 	}
 
-	_, err := mock.ExtractVocabulary("Some text", "es")
+	_, err := mock.ExtractVocabulary(context.Background(), "Some text", "es")
 	if err == nil {
 		t.Error("Expected error, got nil")
 	}
@@ -86,6 +110,10 @@ func TestPromptConstruction(t *testing.T) {
 	if !strings.Contains(prompt, "JSON") {
 		t.Error("Prompt should mention JSON format")
 	}
+
+	if !strings.Contains(prompt, `"translation"`) {
+		t.Error("Prompt should ask for a translation of each item")
+	}
 }
 
 // TestEmptyText tests handling of empty input
@@ -94,7 +122,7 @@ func TestEmptyText(t *testing.T) {
 		Response: []string{},
 	}
 
-	vocab, err := mock.ExtractVocabulary("", "es")
+	vocab, err := mock.ExtractVocabulary(context.Background(), "", "es")
 	if err != nil {
 		t.Errorf("Should handle empty text: %v", err)
 	}
@@ -124,6 +152,24 @@ func TestParseVocabularyResponse(t *testing.T) {
 			expected:    0,
 			expectError: false,
 		},
+		{
+			name:        "Objects with translations",
+			jsonResp:    `[{"text": "hola", "translation": "hello"}, {"text": "adiós", "translation": ""}]`,
+			expected:    2,
+			expectError: false,
+		},
+		{
+			name:        "Mixed strings and objects",
+			jsonResp:    `["hola", {"text": "gracias", "translation": "thank you"}]`,
+			expected:    2,
+			expectError: false,
+		},
+		{
+			name:        "Number element",
+			jsonResp:    `[42]`,
+			expected:    0,
+			expectError: true,
+		},
 		{
 			name:        "Invalid JSON",
 			jsonResp:    `not json`,
@@ -157,6 +203,241 @@ func TestParseVocabularyResponse(t *testing.T) {
 	}
 }
 
+// TestParseContextResponse tests parsing word/context objects, with their
+// translation and example when given
+func TestParseContextResponse(t *testing.T) {
+	response := "```json\n" + `[
+		{"word": "hola", "context": "Hola, ¿qué tal?", "translation": " hello ", "example": "¡Hola, Ana!"},
+		{"word": "  gracias ", "context": " Muchas gracias. "},
+		{"word": "hola", "context": "Hola otra vez."},
+		{"word": "", "context": "Sin palabra."}
+	]` + "\n```"
+
+	items, err := parseContextResponse(response)
+	if err != nil {
+		t.Fatalf("Failed to parse context response: %v", err)
+	}
+
+	items = deduplicateItems(sanitizeItems(items))
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items after cleanup, got %d: %+v", len(items), items)
+	}
+
+	if items[0].Text != "hola" || items[0].Context != "Hola, ¿qué tal?" || items[0].Translation != "hello" || items[0].Example != "¡Hola, Ana!" || items[0].Duplicates != 1 {
+		t.Errorf("Unexpected first item: %+v", items[0])
+	}
+	if items[1].Text != "gracias" || items[1].Context != "Muchas gracias." || items[1].Translation != "" || items[1].Example != "" {
+		t.Errorf("Unexpected second item: %+v", items[1])
+	}
+
+	if _, err := parseContextResponse(`["hola"]`); err == nil {
+		t.Error("Expected error for plain string array")
+	}
+}
+
+// TestParseVocabularyResponseTranslations tests that translations are kept
+// with their items and cleaned up
+func TestParseVocabularyResponseTranslations(t *testing.T) {
+	response := "```json\n" + `[
+		{"text": " hola ", "translation": " hello "},
+		"adiós",
+		{"text": "hola", "translation": "hi"}
+	]` + "\n```"
+
+	items, err := parseVocabularyResponse(response)
+	if err != nil {
+		t.Fatalf("Failed to parse vocabulary response: %v", err)
+	}
+
+	items = deduplicateItems(sanitizeItems(items))
+	want := []VocabularyItem{{Text: "hola", Translation: "hello", Duplicates: 1}, {Text: "adiós"}}
+	if len(items) != len(want) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(want), len(items), items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("Item %d: expected %+v, got %+v", i, want[i], items[i])
+		}
+	}
+}
+
+// TestParseVocabularyResponseExamples tests reading example sentences, with
+// plain strings from the older format falling back to an empty example
+func TestParseVocabularyResponseExamples(t *testing.T) {
+	response := `[
+		{"text": "hola", "translation": "hello", "example": " ¡Hola, Ana! "},
+		"gracias",
+		{"text": "adiós", "translation": "goodbye"}
+	]`
+
+	items, err := parseVocabularyResponse(response)
+	if err != nil {
+		t.Fatalf("Failed to parse vocabulary response: %v", err)
+	}
+
+	items = sanitizeItems(items)
+	want := []VocabularyItem{
+		{Text: "hola", Translation: "hello", Example: "¡Hola, Ana!"},
+		{Text: "gracias"},
+		{Text: "adiós", Translation: "goodbye"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(want), len(items), items)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("Item %d: expected %+v, got %+v", i, want[i], items[i])
+		}
+	}
+
+	if prompt := buildPrompt("Hola amigo.", "Spanish"); !strings.Contains(prompt, `"example"`) {
+		t.Error(`Prompt should ask for an "example" field`)
+	}
+}
+
+// TestContextPromptConstruction tests the context extraction prompt
+func TestContextPromptConstruction(t *testing.T) {
+	prompt := buildContextPrompt("Hola amigo.", "Spanish")
+
+	for _, want := range []string{"Spanish", "Hola amigo.", `"word"`, `"context"`, `"translation"`, `"example"`, "JSON"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt should contain %q", want)
+		}
+	}
+}
+
+// TestParseTranslationResponse tests mapping translations back by index/word
+func TestParseTranslationResponse(t *testing.T) {
+	words := []string{"hola", "gracias", "adiós"}
+	response := "```json\n" + `[
+		{"index": 1, "word": "hola", "translation": "hello"},
+		{"index": 7, "word": "adiós", "translation": " goodbye "},
+		{"index": 2, "word": "gracias", "translation": ""},
+		{"index": 3, "word": "perro", "translation": "dog"}
+	]` + "\n```"
+
+	translations, err := parseTranslationResponse(response, words)
+	if err != nil {
+		t.Fatalf("Failed to parse translation response: %v", err)
+	}
+
+	want := map[string]string{"hola": "hello", "adiós": "goodbye"}
+	if len(translations) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, translations)
+	}
+	for word, translation := range want {
+		if translations[word] != translation {
+			t.Errorf("Expected %q for %q, got %q", translation, word, translations[word])
+		}
+	}
+
+	if _, err := parseTranslationResponse("not json", words); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+// TestTranslationPromptConstruction tests the batch translation prompt
+func TestTranslationPromptConstruction(t *testing.T) {
+	prompt := buildTranslationPrompt([]string{"hola", "gracias"}, "Spanish")
+
+	for _, want := range []string{"Spanish", "English", "1. hola", "2. gracias", `"index"`, `"translation"`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt should contain %q", want)
+		}
+	}
+}
+
+// TestParseLanguageResponse tests reading the language name from Claude's
+// language detection response
+func TestParseLanguageResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{"Plain name", "Spanish", "Spanish", false},
+		{"Quoted with period", " \"French\".\n", "French", false},
+		{"Multi-word name", "Brazilian Portuguese", "Brazilian Portuguese", false},
+		{"Empty", "  ", "", true},
+		{"JSON array", `["Spanish"]`, "", true},
+		{"Sentence", "The notes are written in Spanish and English.", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseLanguageResponse(tc.response)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestLanguagePromptConstruction tests the language detection prompt
+func TestLanguagePromptConstruction(t *testing.T) {
+	prompt := buildLanguagePrompt("Hola, ¿qué tal?")
+
+	for _, want := range []string{"Hola, ¿qué tal?", "ISO 639", "English name"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt should contain %q", want)
+		}
+	}
+}
+
+// TestReadMessageStopReason tests that truncated and refused responses are
+// detected from the stop reason
+func TestReadMessageStopReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		wantText string
+		wantErr  error
+	}{
+		{
+			name:     "end turn",
+			raw:      `{"content":[{"type":"text","text":"[\"hola\"]"}],"stop_reason":"end_turn"}`,
+			wantText: `["hola"]`,
+		},
+		{
+			name:    "max tokens",
+			raw:     `{"content":[{"type":"text","text":"[\"hola\", \"gra"}],"stop_reason":"max_tokens"}`,
+			wantErr: ErrResponseTruncated,
+		},
+		{
+			name:    "refusal",
+			raw:     `{"content":[],"stop_reason":"refusal"}`,
+			wantErr: ErrContentRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message anthropic.Message
+			if err := json.Unmarshal([]byte(tt.raw), &message); err != nil {
+				t.Fatalf("Failed to build message: %v", err)
+			}
+
+			text, err := readMessage(&message)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if text != tt.wantText {
+				t.Errorf("Expected text %q, got %q", tt.wantText, text)
+			}
+		})
+	}
+}
+
 // TestDeduplication tests that duplicates are removed
 func TestDeduplication(t *testing.T) {
 	vocab := []string{"hello", "world", "hello", "goodbye", "world", "hello"}
@@ -202,6 +483,79 @@ func TestSanitizeVocabulary(t *testing.T) {
 	}
 }
 
+// TestFilterJunk tests that only obvious non-vocabulary tokens are dropped
+func TestFilterJunk(t *testing.T) {
+	words := []string{
+		"42", "Unit 3", "p.12", "p. 12", "12/03/2024", "http://x", "www.example.com",
+		"profe@escuela.es", "!", "—", "...",
+		"hola", "buenos días", "¿qué tal?", "señor", "co-op", "it's", "100 años", "test1", "CO2",
+	}
+	want := []string{"hola", "buenos días", "¿qué tal?", "señor", "co-op", "it's", "100 años", "test1", "CO2"}
+
+	items := make([]VocabularyItem, 0, len(words))
+	for _, word := range words {
+		items = append(items, VocabularyItem{Text: word})
+	}
+
+	kept, filtered := FilterJunk(items)
+
+	if filtered != len(words)-len(want) {
+		t.Errorf("Expected %d filtered, got %d", len(words)-len(want), filtered)
+	}
+	if len(kept) != len(want) {
+		t.Fatalf("Expected %d kept, got %d: %+v", len(want), len(kept), kept)
+	}
+	for i, item := range kept {
+		if item.Text != want[i] {
+			t.Errorf("Expected %q at position %d, got %q", want[i], i, item.Text)
+		}
+	}
+}
+
+// TestLengthLimits tests the boundaries of the minimum length and maximum
+// word count
+func TestLengthLimits(t *testing.T) {
+	eight := "uno dos tres cuatro cinco seis siete ocho"
+
+	tests := []struct {
+		name   string
+		limits LengthLimits
+		text   string
+		want   bool
+	}{
+		{"Empty", DefaultLengthLimits(), "", false},
+		{"Whitespace", DefaultLengthLimits(), "   ", false},
+		{"Single letter by default", DefaultLengthLimits(), "y", true},
+		{"Eight words", DefaultLengthLimits(), eight, true},
+		{"Nine words", DefaultLengthLimits(), eight + " nueve", false},
+		{"At minimum length", LengthLimits{MinLength: 2}, "él", true},
+		{"Below minimum length", LengthLimits{MinLength: 2}, " y ", false},
+		{"Minimum counts characters, not bytes", LengthLimits{MinLength: 3}, "él", false},
+		{"At max words", LengthLimits{MaxWords: 2}, "buenos  días", true},
+		{"Above max words", LengthLimits{MaxWords: 2}, "buenos días, señor", false},
+		{"No word limit", LengthLimits{}, eight + " nueve diez", true},
+		{"No limits still drop empty", LengthLimits{}, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.limits.Allows(tc.text); got != tc.want {
+				t.Errorf("Allows(%q) with %+v = %v, want %v", tc.text, tc.limits, got, tc.want)
+			}
+		})
+	}
+
+	limits := LengthLimits{MinLength: 2, MaxWords: 3}
+	items := limits.filterItems([]VocabularyItem{{Text: "a"}, {Text: "hola"}, {Text: "me gusta mucho leer"}, {Text: "de nada"}})
+	if len(items) != 2 || items[0].Text != "hola" || items[1].Text != "de nada" {
+		t.Errorf("Expected [hola, de nada] in order, got %+v", items)
+	}
+	words := limits.filterTexts([]string{"x", "gracias", "por favor"})
+	if strings.Join(words, ",") != "gracias,por favor" {
+		t.Errorf("Expected [gracias, por favor], got %v", words)
+	}
+}
+
 // TestValidateAPIKey tests API key validation
 func TestValidateAPIKey(t *testing.T) {
 	tests := []struct {
@@ -267,3 +621,163 @@ func TestAIErrorInterface(t *testing.T) {
 		t.Error("IsAIError should return false for non-AIError")
 	}
 }
+
+// errorTypes are the error types Anthropic sends with each status
+var errorTypes = map[int]string{
+	400: "invalid_request_error",
+	401: "authentication_error",
+	429: "rate_limit_error",
+	500: "api_error",
+	502: "api_error",
+	503: "api_error",
+	529: "overloaded_error",
+}
+
+// newTestClient returns a ClaudeClient talking to a local server that
+// answers each request with the next status in statuses, then 200
+func newTestClient(t *testing.T, statuses ...int) (*ClaudeClient, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		w.Header().Set("Content-Type", "application/json")
+		if call <= len(statuses) {
+			status := statuses[call-1]
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"type":"error","error":{"type":%q,"message":"try again"}}`, errorTypes[status])
+			return
+		}
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"[\"hola\"]"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("sk-ant-test"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+	return &ClaudeClient{client: &client, MaxRetries: DefaultMaxRetries, retryDelay: time.Millisecond}, &calls
+}
+
+// TestExtractVocabularyRetries tests which failures are retried and how often
+func TestExtractVocabularyRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		wantCalls  int32
+		wantStatus int
+	}{
+		{"Rate limited then ok", []int{429, 503}, DefaultMaxRetries, 3, 0},
+		{"Overloaded then ok", []int{529, 529}, DefaultMaxRetries, 3, 0},
+		{"Server errors then ok", []int{500, 502}, DefaultMaxRetries, 3, 0},
+		{"Overloaded retries exhausted", []int{529, 529, 529, 529}, DefaultMaxRetries, 4, 529},
+		{"Retries exhausted", []int{429, 429, 429, 429}, DefaultMaxRetries, 4, 429},
+		{"Bad request not retried", []int{400}, DefaultMaxRetries, 1, 400},
+		{"Unauthorized not retried", []int{401}, DefaultMaxRetries, 1, 401},
+		{"Retries disabled", []int{503}, 0, 1, 503},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, calls := newTestClient(t, tc.statuses...)
+			client.MaxRetries = tc.maxRetries
+
+			vocab, err := client.ExtractVocabulary(context.Background(), "Hola", "Spanish")
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tc.wantCalls, got)
+			}
+
+			if tc.wantStatus == 0 {
+				if err != nil || len(vocab) != 1 || vocab[0] != "hola" {
+					t.Errorf("Expected [hola], got %v, %v", vocab, err)
+				}
+				return
+			}
+			var aiErr *AIError
+			if !errors.As(err, &aiErr) || aiErr.StatusCode != tc.wantStatus {
+				t.Errorf("Expected AIError with status %d, got %v", tc.wantStatus, err)
+			}
+		})
+	}
+}
+
+// TestRetryHonorsDeadline tests that no retry is attempted when the backoff
+// would outlast the context deadline
+func TestRetryHonorsDeadline(t *testing.T) {
+	client, calls := newTestClient(t, 429, 429)
+	client.retryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.sendBlocks(ctx, anthropic.NewTextBlock("Hola"))
+	if !IsAIError(err) {
+		t.Errorf("Expected the rate limit error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single call, got %d", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up immediately, took %v", elapsed)
+	}
+}
+
+// TestExtractVocabularyCancelled tests that cancelling the context aborts a
+// Claude request that is still waiting for its response
+func TestExtractVocabularyCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	client := anthropic.NewClient(
+		option.WithAPIKey("sk-ant-test"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	)
+	claude := &ClaudeClient{client: &client, MaxRetries: DefaultMaxRetries, retryDelay: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := claude.ExtractVocabulary(ctx, "Hola", "Spanish")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to abort promptly, took %v", elapsed)
+	}
+
+	// A caller's deadline is reported as such rather than as an API error
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := claude.ExtractVocabulary(ctx, "Hola", "Spanish"); !errors.Is(err, context.DeadlineExceeded) || IsAIError(err) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Language detection stops with its context too
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := claude.DetectLanguage(ctx, "Hola"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded from DetectLanguage, got %v", err)
+	}
+}
+
+// TestBackoff tests that delays double per attempt with bounded jitter
+func TestBackoff(t *testing.T) {
+	for attempt, base := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := backoff(time.Second, attempt)
+		if delay < base || delay > base+base/2 {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", attempt, base, base+base/2, delay)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// numberPattern matches page numbers, dates and other digit-only tokens
+	numberPattern = regexp.MustCompile(`^[\d\s.,:/\-]*\d[\d\s.,:/\-]*$`)
+
+	// urlPattern matches web addresses
+	urlPattern = regexp.MustCompile(`(?i)^(https?://|www\.)\S+$`)
+
+	// emailPattern matches email addresses
+	emailPattern = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+	// codePattern matches lesson and page codes such as "Unit 3" or "p.12".
+	// The letters and number must be separated, so words that end in a
+	// digit, such as "test1", are kept.
+	codePattern = regexp.MustCompile(`^\p{L}+(\.\s*|\s+)\d+$`)
+)
+
+// isJunkToken reports whether an extracted item is obviously not vocabulary.
+// It is deliberately conservative: anything containing a real word with no
+// trailing number is kept.
+func isJunkToken(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return true
+	}
+
+	if numberPattern.MatchString(text) || urlPattern.MatchString(text) ||
+		emailPattern.MatchString(text) || codePattern.MatchString(text) {
+		return true
+	}
+
+	for _, r := range text {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterJunk drops numbers, URLs, email addresses, lesson codes and bare
+// punctuation from extracted vocabulary and returns how many were dropped
+func FilterJunk(items []VocabularyItem) ([]VocabularyItem, int) {
+	kept := make([]VocabularyItem, 0, len(items))
+	for _, item := range items {
+		if !isJunkToken(item.Text) {
+			kept = append(kept, item)
+		}
+	}
+	return kept, len(items) - len(kept)
+}
+
+// Defaults for LengthLimits: anything non-empty is long enough, and items
+// of more than eight words are sentences rather than vocabulary
+const (
+	DefaultMinLength = 1
+	DefaultMaxWords  = 8
+)
+
+// LengthLimits bounds the size of extracted items. Items with fewer than
+// MinLength characters or more than MaxWords words are dropped; zero
+// disables either limit.
+type LengthLimits struct {
+	MinLength int
+	MaxWords  int
+}
+
+// DefaultLengthLimits returns the limits used unless configured otherwise
+func DefaultLengthLimits() LengthLimits {
+	return LengthLimits{MinLength: DefaultMinLength, MaxWords: DefaultMaxWords}
+}
+
+// Allows reports whether text is within the limits. Surrounding whitespace
+// does not count towards the length.
+func (l LengthLimits) Allows(text string) bool {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) < max(l.MinLength, 1) {
+		return false
+	}
+	return l.MaxWords <= 0 || len(strings.Fields(text)) <= l.MaxWords
+}
+
+// filterItems drops the items outside the limits
+func (l LengthLimits) filterItems(items []VocabularyItem) []VocabularyItem {
+	kept := make([]VocabularyItem, 0, len(items))
+	for _, item := range items {
+		if l.Allows(item.Text) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// filterTexts drops the words outside the limits
+func (l LengthLimits) filterTexts(words []string) []string {
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		if l.Allows(word) {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrOffline is returned by the offline provider for every AI request
+var ErrOffline = errors.New("AI provider is offline: set PROVIDER=anthropic and ANTHROPIC_API_KEY to process documents")
+
+// OfflineExtractor is the AIExtractor used when no AI provider is
+// configured. Stored vocabulary stays available; extraction always fails.
+type OfflineExtractor struct{}
+
+// ExtractVocabulary always returns ErrOffline
+func (OfflineExtractor) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	return nil, ErrOffline
+}
+
+// DetectLanguage always returns ErrOffline
+func (OfflineExtractor) DetectLanguage(ctx context.Context, text string) (string, error) {
+	return "", ErrOffline
+}
+
+// Validate always returns ErrOffline so health checks show the AI as
+// unavailable
+func (OfflineExtractor) Validate(ctx context.Context) error {
+	return ErrOffline
+}
+
+// NewExtractor creates the AIExtractor for a provider name, either
+// "anthropic" or "offline"
+func NewExtractor(provider, apiKey string) (AIExtractor, error) {
+	switch provider {
+	case "anthropic":
+		client, err := NewClaudeClient(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case "offline":
+		return OfflineExtractor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", provider)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// AIErrorDetails is the Details payload for the "ai_error" code. The
+// Anthropic request ID lets support trace the failed call upstream.
+type AIErrorDetails struct {
+	RequestID          string `json:"request_id,omitempty"`
+	AnthropicRequestID string `json:"anthropic_request_id,omitempty"`
+	Status             int    `json:"status"`
+}
+
+// logger returns the handler's structured logger
+func (h *Handler) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.Default()
+}
+
+// respondAIError logs a failed AI call with both request IDs and sends a 500
+// whose details carry them. The human message leaves the IDs out.
+func (h *Handler) respondAIError(w http.ResponseWriter, r *http.Request, prefix string, aiErr *ai.AIError) {
+	respondJSON(w, http.StatusInternalServerError, h.aiErrorResponse(r, prefix, aiErr))
+}
+
+// aiErrorResponse logs a failed AI call and builds the error body sent by
+// respondAIError
+func (h *Handler) aiErrorResponse(r *http.Request, prefix string, aiErr *ai.AIError) ErrorResponse {
+	requestID := RequestIDFromContext(r.Context())
+	h.logger().Error("AI request failed",
+		"request_id", requestID,
+		"anthropic_request_id", aiErr.RequestID,
+		"status", aiErr.StatusCode,
+		"error", aiErr.Message,
+	)
+
+	return ErrorResponse{
+		Error: fmt.Sprintf("%s: AI API error (%d): %s", prefix, aiErr.StatusCode, aiErr.Message),
+		Code:  "ai_error",
+		Details: AIErrorDetails{
+			RequestID:          requestID,
+			AnthropicRequestID: aiErr.RequestID,
+			Status:             aiErr.StatusCode,
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// DiskSpaceFunc reports the free bytes available to unprivileged users on
+// the filesystem containing path.
+type DiskSpaceFunc func(path string) (uint64, error)
+
+// InsufficientStorageDetails is the Details payload for the
+// "insufficient_storage" code.
+type InsufficientStorageDetails struct {
+	FreeBytes     uint64 `json:"free_bytes"`
+	RequiredBytes uint64 `json:"required_bytes"`
+}
+
+// checkDiskSpace reports whether there is room for an upload of the given
+// size, writing a 507 response when there is not. The check is skipped when
+// MinFreeBytes is zero, and uploads are let through if free space cannot be
+// determined.
+func (h *Handler) checkDiskSpace(w http.ResponseWriter, size int64) bool {
+	if h.MinFreeBytes == 0 {
+		return true
+	}
+
+	freeSpace := h.FreeSpace
+	if freeSpace == nil {
+		freeSpace = freeDiskSpace
+	}
+
+	dir := h.DataDir
+	if dir == "" {
+		dir = "."
+	}
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		log.Printf("failed to check free disk space: %v", err)
+		return true
+	}
+
+	required := h.MinFreeBytes
+	if size > 0 {
+		required += uint64(size)
+	}
+	if free >= required {
+		return true
+	}
+
+	respondJSON(w, http.StatusInsufficientStorage, ErrorResponse{
+		Error:   fmt.Sprintf("Insufficient storage: %s free, %s required", formatBytes(free), formatBytes(required)),
+		Code:    "insufficient_storage",
+		Details: InsufficientStorageDetails{FreeBytes: free, RequiredBytes: required},
+	})
+	return false
+}
+
+// formatBytes renders a byte count in MB with one decimal
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package api
+
+import "errors"
+
+// freeDiskSpace is not implemented on this platform, so the free space
+// check is skipped
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("free disk space check is not supported on this platform")
+}
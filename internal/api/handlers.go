@@ -1,24 +1,75 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/parsely/parsely/internal/ai"
 	"github.com/parsely/parsely/internal/core"
+	"github.com/parsely/parsely/internal/db"
 	"github.com/parsely/parsely/internal/parser"
 )
 
 // Handler contains all HTTP handlers.
 type Handler struct {
 	Processor *core.Processor
+
+	// MinFreeBytes is the free disk space that must remain after an upload;
+	// uploads that would go below it are rejected with 507. Zero disables
+	// the check.
+	MinFreeBytes uint64
+
+	// DataDir is the directory whose filesystem is checked for free space;
+	// empty means the working directory
+	DataDir string
+
+	// FreeSpace reports free disk space; nil uses the operating system
+	FreeSpace DiskSpaceFunc
+
+	// DailyUploadQuota caps document and URL uploads per client IP per UTC
+	// day; zero disables the quota
+	DailyUploadQuota int
+
+	// Logger receives structured logs such as failed AI calls; nil uses
+	// slog.Default
+	Logger *slog.Logger
+
+	// Result of the last CheckAI, reported by Health
+	healthMu  sync.Mutex
+	aiChecked bool
+	aiErr     error
 }
 
 // ErrorResponse represents an error response.
+// Code and Details are set for errors that clients may handle programmatically.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// FileTooLargeDetails is the Details payload for the "file_too_large" code.
+type FileTooLargeDetails struct {
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+	MaxBytes  int64 `json:"max_bytes"`
 }
 
 // SuccessResponse represents a success response.
@@ -27,15 +78,206 @@ type SuccessResponse struct {
 	Data    any    `json:"data,omitempty"`
 }
 
+// Page sizes for GET /api/vocabulary.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// VocabularyPage is one page of GET /api/vocabulary. Total counts every item
+// matching the language and tag filters, so a client can tell how many pages there are from Limit.
+type VocabularyPage struct {
+	Items  any `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
 // ListVocabulary handles GET /api/vocabulary.
+// The optional sort parameter accepts created_at (default), updated_at or
+// frequency, which lists the words found in the most documents first.
+// With compact=true only each item's id and text are returned. limit
+// (default 50) and offset (default 0) select the page. language limits the
+// list to one language and tag to items carrying one tag, both ignoring case.
+// from and to limit the list to items created in that range, inclusive; a
+// date-only to covers the whole day. include_deleted=true adds soft-deleted
+// items, which carry a deleted_at timestamp. The page is written as CSV
+// instead of JSON when the Accept header prefers text/csv or the path is
+// /api/vocabulary.csv; the total then goes in the X-Total-Count header.
 func (h *Handler) ListVocabulary(w http.ResponseWriter, r *http.Request) {
-	vocab, err := h.Processor.GetVocabularyList()
+	w.Header().Add("Vary", "Accept")
+	asCSV := strings.HasSuffix(r.URL.Path, ".csv") || prefersCSV(r.Header.Get("Accept"))
+
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "created_at"
+	}
+	if sort != "created_at" && sort != "updated_at" && sort != "frequency" {
+		respondError(w, http.StatusBadRequest, "sort must be created_at, updated_at or frequency")
+		return
+	}
+
+	compact := false
+	if raw := r.URL.Query().Get("compact"); raw != "" {
+		var err error
+		if compact, err = strconv.ParseBool(raw); err != nil {
+			respondError(w, http.StatusBadRequest, "compact must be true or false")
+			return
+		}
+	}
+
+	includeDeleted := false
+	if raw := r.URL.Query().Get("include_deleted"); raw != "" {
+		var err error
+		if includeDeleted, err = strconv.ParseBool(raw); err != nil {
+			respondError(w, http.StatusBadRequest, "include_deleted must be true or false")
+			return
+		}
+	}
+
+	limit, err := parseIntQuery(r, "limit", defaultListLimit, 1, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, err := parseIntQuery(r, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, err := parseTimeQuery(r, "from", false)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseTimeQuery(r, "to", true)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		respondError(w, http.StatusBadRequest, "Invalid range: from must not be after to")
+		return
+	}
+
+	// The DB matches the language and tag ignoring case and surrounding
+	// whitespace; empty values do not filter
+	filter := db.Filter{
+		Language:       r.URL.Query().Get("language"),
+		Tag:            r.URL.Query().Get("tag"),
+		From:           from,
+		To:             to,
+		IncludeDeleted: includeDeleted,
+	}
+
+	total, err := h.Processor.GetFilteredCount(filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to count vocabulary: %v", err))
+		return
+	}
+
+	if asCSV {
+		h.listVocabularyCSV(w, filter, sort, limit, offset, total, compact)
+		return
+	}
+
+	page := VocabularyPage{Total: total, Limit: limit, Offset: offset}
+	if compact {
+		items, err := h.Processor.GetVocabularyCompactPage(filter, sort, limit, offset)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list vocabulary: %v", err))
+			return
+		}
+		if items == nil {
+			items = []*db.CompactVocabulary{}
+		}
+		page.Items = items
+	} else {
+		items, err := h.Processor.GetVocabularyPage(filter, sort, limit, offset)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list vocabulary: %v", err))
+			return
+		}
+		if items == nil {
+			items = []*db.Vocabulary{}
+		}
+		page.Items = items
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// compactCSVFields are the CSV columns of GET /api/vocabulary?compact=true
+var compactCSVFields = []string{"id", "text"}
+
+// listVocabularyCSV writes one page of the vocabulary list as CSV with the
+// db.CSVFields columns, or only id and text when compact is set
+func (h *Handler) listVocabularyCSV(w http.ResponseWriter, filter db.Filter, sort string, limit, offset, total int, compact bool) {
+	items, err := h.Processor.GetVocabularyPage(filter, sort, limit, offset)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list vocabulary: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, vocab)
+	var fields []string
+	if compact {
+		fields = compactCSVFields
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if err := db.WriteCSVItems(w, items, fields); err != nil {
+		log.Printf("failed to write csv vocabulary list: %v", err)
+	}
+}
+
+// prefersCSV reports whether an Accept header ranks text/csv above JSON.
+// JSON wins ties, so a missing header or */* keeps the JSON response.
+func prefersCSV(accept string) bool {
+	csvQuality, jsonQuality := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "text/csv", "text/*":
+			csvQuality = max(csvQuality, quality)
+		case "application/json", "application/*", "*/*":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+	return csvQuality > jsonQuality
+}
+
+// SearchVocabulary handles GET /api/vocabulary/search. The q parameter is
+// matched as a case-insensitive substring of each item's text.
+func (h *Handler) SearchVocabulary(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondError(w, http.StatusBadRequest, "q must not be empty")
+		return
+	}
+
+	items, err := h.Processor.DB.Search(query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to search vocabulary: %v", err))
+		return
+	}
+	if items == nil {
+		items = []*db.Vocabulary{}
+	}
+
+	respondJSON(w, http.StatusOK, items)
 }
 
 // GetVocabulary handles GET /api/vocabulary/{id}.
@@ -54,6 +296,99 @@ func (h *Handler) GetVocabulary(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, vocab)
 }
 
+// Limits for GET /api/vocabulary/{id}/similar.
+const (
+	defaultSimilarDistance = 2
+	maxSimilarDistance     = 5
+	defaultSimilarLimit    = 10
+	maxSimilarLimit        = 100
+)
+
+// SimilarVocabulary handles GET /api/vocabulary/{id}/similar.
+func (h *Handler) SimilarVocabulary(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseVocabularyID(w, r)
+	if !ok {
+		return
+	}
+
+	distance, err := parseIntQuery(r, "distance", defaultSimilarDistance, 0, maxSimilarDistance)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := parseIntQuery(r, "limit", defaultSimilarLimit, 1, maxSimilarLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	vocab, err := h.Processor.DB.Get(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	similar, err := h.Processor.DB.FindSimilar(vocab.Text, distance, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to find similar vocabulary: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, similar)
+}
+
+// Number of items returned by GET /api/vocabulary/random.
+const (
+	defaultRandomCount = 1
+	maxRandomCount     = 50
+)
+
+// RandomVocabulary handles GET /api/vocabulary/random for quizzes. count
+// (default 1, at most 50) sets how many items are returned and language
+// limits them to one language, ignoring case. An empty vocabulary gives an
+// empty array.
+func (h *Handler) RandomVocabulary(w http.ResponseWriter, r *http.Request) {
+	count, err := parseIntQuery(r, "count", defaultRandomCount, 1, maxRandomCount)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := h.Processor.DB.GetRandomFiltered(db.Filter{Language: r.URL.Query().Get("language")}, count)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get random vocabulary: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, items)
+}
+
+// VocabularyHistory handles GET /api/vocabulary/{id}/history. History outlives
+// a deleted item, so an unknown ID is only reported as not found when it has
+// no history either.
+func (h *Handler) VocabularyHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseVocabularyID(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := h.Processor.DB.History(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get history: %v", err))
+		return
+	}
+
+	if len(history) == 0 {
+		if _, err := h.Processor.DB.Get(id); err != nil {
+			respondError(w, http.StatusNotFound, "Vocabulary not found")
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
 // DeleteVocabulary handles DELETE /api/vocabulary/{id}.
 func (h *Handler) DeleteVocabulary(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseVocabularyID(w, r)
@@ -69,121 +404,1234 @@ func (h *Handler) DeleteVocabulary(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, SuccessResponse{Message: "Vocabulary deleted successfully"})
 }
 
-// UploadDocument handles POST /api/upload.
-func (h *Handler) UploadDocument(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		respondError(w, http.StatusBadRequest, "Failed to parse form")
+// RestoreVocabulary handles POST /api/vocabulary/{id}/restore, undoing a
+// delete. It returns the restored item, or 404 when no deleted item has
+// the ID.
+func (h *Handler) RestoreVocabulary(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseVocabularyID(w, r)
+	if !ok {
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	vocab, err := h.Processor.RestoreVocabulary(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "Deleted vocabulary not found")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "No file uploaded")
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to restore vocabulary: %v", err))
 		return
 	}
-	defer file.Close()
 
-	if err := parser.ValidateFilename(header.Filename); err != nil {
-		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filename: %v", err))
+	respondJSON(w, http.StatusOK, vocab)
+}
+
+// AddVocabularyRequest is the request body for POST /api/vocabulary
+type AddVocabularyRequest struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+}
+
+// AddVocabulary handles POST /api/vocabulary.
+// The language defaults to the configured one; text that is already stored
+// is rejected with 409.
+func (h *Handler) AddVocabulary(w http.ResponseWriter, r *http.Request) {
+	var req AddVocabularyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON body")
 		return
 	}
 
-	if header.Size > parser.MaxFileSize {
-		respondError(w, http.StatusBadRequest, fmt.Sprintf("File too large (max %d bytes)", parser.MaxFileSize))
+	vocab, err := h.Processor.AddVocabulary(req.Text, req.Language)
+	if errors.Is(err, core.ErrEmptyText) {
+		respondError(w, http.StatusBadRequest, "Text is required")
+		return
+	}
+	if errors.Is(err, db.ErrDuplicateText) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Vocabulary already exists: %q", strings.TrimSpace(req.Text)))
 		return
 	}
-
-	tmpPath, err := parser.CreateTempFile(file, header.Filename)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to save file: %v", err))
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to add vocabulary: %v", err))
 		return
 	}
-	defer parser.CleanupTempFile(tmpPath)
 
-	result, err := h.Processor.ProcessDocument(tmpPath)
+	respondJSON(w, http.StatusCreated, vocab)
+}
+
+// BulkDeleteRequest is the request body for POST /api/vocabulary/bulk-delete
+type BulkDeleteRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// BulkDeleteResponse reports how many items were deleted
+type BulkDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// BulkDeleteVocabulary handles POST /api/vocabulary/bulk-delete.
+// IDs that do not exist are ignored; the rest are deleted together.
+func (h *Handler) BulkDeleteVocabulary(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		respondError(w, http.StatusBadRequest, "ids is required")
+		return
+	}
+
+	deleted, err := h.Processor.DB.DeleteMany(req.IDs)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process document: %v", err))
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, result)
+	respondJSON(w, http.StatusOK, BulkDeleteResponse{Deleted: deleted})
 }
 
-// ExportVocabulary handles POST /api/export.
-func (h *Handler) ExportVocabulary(w http.ResponseWriter, r *http.Request) {
-	vocab, err := h.Processor.GetVocabularyList()
+// DeleteAllVocabulary handles DELETE /api/vocabulary?confirm=true. It
+// clears the whole vocabulary, so it is refused without confirm=true.
+func (h *Handler) DeleteAllVocabulary(w http.ResponseWriter, r *http.Request) {
+	confirm, err := strconv.ParseBool(r.URL.Query().Get("confirm"))
+	if err != nil || !confirm {
+		respondError(w, http.StatusBadRequest, "Deleting all vocabulary requires confirm=true")
+		return
+	}
+
+	deleted, err := h.Processor.DB.DeleteAll()
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get vocabulary: %v", err))
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete: %v", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", "attachment; filename=vocabulary_export.json")
+	respondJSON(w, http.StatusOK, BulkDeleteResponse{Deleted: deleted})
+}
 
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(vocab); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to encode JSON: %v", err))
+// uploadFormOverhead is the room allowed on top of parser.MaxFileSize for
+// the rest of an upload form
+const uploadFormOverhead = 1 << 20
+
+// UploadDocument handles POST /api/upload.
+func (h *Handler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	upload, ok := h.prepareUpload(w, r)
+	if !ok {
 		return
 	}
-}
+	defer upload.Close()
 
-// GetStats handles GET /api/stats.
-func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
-	count, err := h.Processor.GetVocabularyCount()
+	result, err := upload.processor.ProcessReader(r.Context(), upload.file, upload.header.Filename, upload.header.Size)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		upload.releaseQuota()
+		status, body := h.processErrorResponse(r, upload.header.Filename, err)
+		respondJSON(w, status, body)
 		return
 	}
 
-	stats := map[string]any{
-		"total_vocabulary": count,
-	}
+	respondJSON(w, http.StatusOK, result)
+}
 
-	respondJSON(w, http.StatusOK, stats)
+// preparedUpload is a validated document upload, ready to be processed
+type preparedUpload struct {
+	processor *core.Processor
+	file      multipart.File
+	header    *multipart.FileHeader
+	form      *multipart.Form
+	// releaseQuota gives the upload back to the daily quota if it fails
+	releaseQuota func()
 }
 
-// parseVocabularyID extracts and validates the "id" path parameter.
-// Returns the parsed ID and true on success, or writes an error response and returns false.
-func parseVocabularyID(w http.ResponseWriter, r *http.Request) (int, bool) {
-	id, err := strconv.Atoi(r.PathValue("id"))
+// Close releases the uploaded file and removes any parts spooled to disk.
+// Handlers defer it so the files go as soon as they are done, even when
+// TimeoutMiddleware has already answered and the server has moved on.
+func (u *preparedUpload) Close() {
+	u.file.Close()
+	u.form.RemoveAll()
+}
+
+// prepareUpload parses and validates an upload form, applies its options to
+// a copy of the processor, and counts it against the upload quota. When the
+// upload is rejected it writes the error response and returns false.
+func (h *Handler) prepareUpload(w http.ResponseWriter, r *http.Request) (*preparedUpload, bool) {
+	// Check before parsing the form, which may spool large uploads to disk
+	if !h.checkDiskSpace(w, r.ContentLength) {
+		return nil, false
+	}
+
+	// The form may carry the file plus a little multipart framing and the
+	// small pages/language fields; anything beyond that cannot be a valid
+	// upload, so it is cut off before being spooled to disk
+	r.Body = http.MaxBytesReader(w, r.Body, parser.MaxFileSize+uploadFormOverhead)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondFileTooLarge(w, &parser.FileTooLargeError{Limit: parser.MaxFileSize})
+			return nil, false
+		}
+		respondError(w, http.StatusBadRequest, "Failed to parse form")
+		return nil, false
+	}
+
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid ID")
-		return 0, false
+		r.MultipartForm.RemoveAll()
+		respondError(w, http.StatusBadRequest, "No file uploaded")
+		return nil, false
 	}
-	return id, true
-}
+	upload := &preparedUpload{processor: h.Processor, file: file, header: header, form: r.MultipartForm}
 
-// respondJSON sends a JSON response with the given status code.
-func respondJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if ok := h.applyUploadOptions(w, r, upload); !ok {
+		upload.Close()
+		return nil, false
 	}
+	return upload, true
 }
 
-// respondError sends an error JSON response with the given status code and message.
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, ErrorResponse{Error: message})
-}
+// applyUploadOptions validates the uploaded file and applies the form's
+// processing options, writing the error response when they are invalid
+func (h *Handler) applyUploadOptions(w http.ResponseWriter, r *http.Request, upload *preparedUpload) (ok bool) {
+	if err := parser.ValidateFilename(upload.header.Filename); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filename: %v", err))
+		return false
+	}
 
-// CorsMiddleware adds CORS headers.
-// In production, restrict Access-Control-Allow-Origin to specific origins.
-func CorsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
-		if origin == "" {
-			origin = "*"
+	if upload.header.Size > parser.MaxFileSize {
+		respondFileTooLarge(w, &parser.FileTooLargeError{Size: upload.header.Size, Limit: parser.MaxFileSize})
+		return false
+	}
+
+	if pages := r.FormValue("pages"); pages != "" {
+		from, to, err := parser.ParsePageRange(pages)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid pages parameter: %v", err))
+			return false
+		}
+		upload.processor = upload.processor.WithPages(from, to)
+	}
+	if onDuplicate := r.FormValue("on_duplicate"); onDuplicate != "" {
+		policy, err := core.ParseDuplicatePolicy(onDuplicate)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid on_duplicate parameter: %v", err))
+			return false
+		}
+		upload.processor = upload.processor.WithDuplicatePolicy(policy)
+	}
+	if tags := db.ParseTags(r.FormValue("tags")); len(tags) > 0 {
+		upload.processor = upload.processor.WithTags(tags)
+	}
+	if force := r.FormValue("force"); force != "" {
+		enabled, err := strconv.ParseBool(force)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid force parameter: must be true or false")
+			return false
 		}
+		if enabled {
+			upload.processor = upload.processor.WithForceExtract()
+		}
+	}
 
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "3600")
+	upload.releaseQuota, ok = h.checkUploadQuota(w, r)
+	return ok
+}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+// processErrorResponse maps an error from processing an uploaded document to
+// the status code and body sent to the client
+func (h *Handler) processErrorResponse(r *http.Request, filename string, err error) (int, ErrorResponse) {
+	var sizeErr *parser.FileTooLargeError
+	if errors.As(err, &sizeErr) {
+		return http.StatusBadRequest, fileTooLargeResponse(sizeErr)
+	}
+	if errors.Is(err, parser.ErrInvalidPageRange) {
+		return http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid pages parameter: %v", err)}
+	}
+	if errors.Is(err, parser.ErrScannedPDF) {
+		return http.StatusUnprocessableEntity, ErrorResponse{Error: fmt.Sprintf("Failed to process document: %v; run OCR on it first", err), Code: "scanned_pdf"}
+	}
+	if errors.Is(err, parser.ErrPasswordProtected) {
+		return http.StatusUnprocessableEntity, ErrorResponse{Error: fmt.Sprintf("Failed to process document: %v", err), Code: "password_protected"}
+	}
+	if errors.Is(err, parser.ErrEncryptedEPUB) {
+		return http.StatusUnprocessableEntity, ErrorResponse{Error: fmt.Sprintf("Failed to process document: %v", err), Code: "encrypted_epub"}
+	}
+	if errors.Is(err, parser.ErrInvalidUTF8) {
+		return http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid text file: %v", err)}
+	}
+	if errors.Is(err, core.ErrDuplicateVocabulary) {
+		return http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("Document contains existing vocabulary: %v", err)}
+	}
+	if errors.Is(err, core.ErrImagesUnsupported) {
+		return http.StatusUnsupportedMediaType, ErrorResponse{Error: fmt.Sprintf("Failed to process image: %v", err)}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable, ErrorResponse{Error: "Processing the document timed out", Code: "request_timeout"}
+	}
+	var panicErr *core.PanicError
+	if errors.As(err, &panicErr) {
+		log.Printf("recovered panic processing %s: %v\n%s", filename, panicErr.Value, panicErr.Stack)
+		return http.StatusInternalServerError, ErrorResponse{Error: "Failed to process document: the file appears to be malformed"}
+	}
+	var aiErr *ai.AIError
+	if errors.As(err, &aiErr) {
+		return http.StatusInternalServerError, h.aiErrorResponse(r, "Failed to process document", aiErr)
+	}
+	return http.StatusInternalServerError, ErrorResponse{Error: fmt.Sprintf("Failed to process document: %v", err)}
+}
+
+// TagRequest is the request body for POST /api/vocabulary/tag. Exactly one
+// of IDs and Query selects the items to tag.
+type TagRequest struct {
+	IDs   []int  `json:"ids"`
+	Query string `json:"query"`
+	Tag   string `json:"tag"`
+}
+
+// TagResponse reports how many items were newly tagged
+type TagResponse struct {
+	Tagged int `json:"tagged"`
+}
+
+// TagVocabulary handles POST /api/vocabulary/tag.
+// Items are selected by explicit IDs or by a search query matched the same
+// way as folded search.
+func (h *Handler) TagVocabulary(w http.ResponseWriter, r *http.Request) {
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if strings.TrimSpace(req.Tag) == "" {
+		respondError(w, http.StatusBadRequest, "Tag is required")
+		return
+	}
+	hasQuery := strings.TrimSpace(req.Query) != ""
+	if (len(req.IDs) > 0) == hasQuery {
+		respondError(w, http.StatusBadRequest, "Provide either ids or query")
+		return
+	}
+
+	var tagged int
+	var err error
+	if hasQuery {
+		tagged, err = h.Processor.DB.TagMatching(req.Query, req.Tag)
+	} else {
+		tagged, err = h.Processor.DB.TagIDs(req.IDs, req.Tag)
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to tag vocabulary: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, TagResponse{Tagged: tagged})
+}
+
+// ItemTagRequest is the request body for POST /api/vocabulary/{id}/tags
+type ItemTagRequest struct {
+	Tag string `json:"tag"`
+}
+
+// AddVocabularyTag handles POST /api/vocabulary/{id}/tags and returns the
+// tagged item.
+func (h *Handler) AddVocabularyTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseVocabularyID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ItemTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if _, err := h.Processor.DB.Get(id); err != nil {
+		respondError(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	if err := h.Processor.DB.AddTag(id, req.Tag); err != nil {
+		if errors.Is(err, db.ErrInvalidTag) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag: %v", err))
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to tag vocabulary: %v", err))
+		return
+	}
+
+	h.respondVocabulary(w, id)
+}
+
+// RemoveVocabularyTag handles DELETE /api/vocabulary/{id}/tags/{tag} and
+// returns the updated item.
+func (h *Handler) RemoveVocabularyTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseVocabularyID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Processor.DB.Get(id); err != nil {
+		respondError(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	if err := h.Processor.DB.RemoveTag(id, r.PathValue("tag")); err != nil {
+		if errors.Is(err, db.ErrInvalidTag) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag: %v", err))
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to remove tag: %v", err))
+		return
+	}
+
+	h.respondVocabulary(w, id)
+}
+
+// respondVocabulary writes the current state of a vocabulary item
+func (h *Handler) respondVocabulary(w http.ResponseWriter, id int) {
+	vocab, err := h.Processor.DB.Get(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Vocabulary not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, vocab)
+}
+
+// UploadURLRequest is the request body for POST /api/upload-url.
+type UploadURLRequest struct {
+	URL      string `json:"url"`
+	Language string `json:"language"`
+
+	// Force re-extracts the document even when the extraction cache has it
+	Force bool `json:"force"`
+}
+
+// UploadURL handles POST /api/upload-url.
+func (h *Handler) UploadURL(w http.ResponseWriter, r *http.Request) {
+	var req UploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		respondError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if strings.TrimSpace(req.URL) == "" {
+		respondError(w, http.StatusBadRequest, "URL is required")
+		return
+	}
+
+	processor := h.Processor
+	if language := strings.TrimSpace(req.Language); language != "" {
+		processor = processor.WithLanguage(language)
+	}
+	if req.Force {
+		processor = processor.WithForceExtract()
+	}
+
+	releaseQuota, ok := h.checkUploadQuota(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := processor.ProcessURL(r.Context(), req.URL)
+	if err != nil {
+		releaseQuota()
+	}
+	var sizeErr *parser.FileTooLargeError
+	if errors.As(err, &sizeErr) {
+		respondFileTooLarge(w, sizeErr)
+		return
+	}
+	if errors.Is(err, core.ErrURLNotAllowed) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid URL: %v", err))
+		return
+	}
+	var aiErr *ai.AIError
+	if errors.As(err, &aiErr) {
+		h.respondAIError(w, r, "Failed to process URL", aiErr)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to process URL: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// ExportVocabulary handles POST /api/export.
+// An optional ?fields=text,translation query restricts each item to the
+// listed fields. ?format=ndjson streams one item per line instead of a
+// versioned JSON document, ?format=csv streams a CSV file and ?format=anki
+// streams tab-separated flashcards. With ?incremental=true only items
+// created since the last incremental export are included, and the marker
+// advances once the export has been written. ?language=Spanish exports only the items in
+// that language, as an empty export when there are none.
+func (h *Handler) ExportVocabulary(w http.ResponseWriter, r *http.Request) {
+	fields, err := db.ParseExportFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid fields: %v", err))
+		return
+	}
+
+	incremental := false
+	if raw := r.URL.Query().Get("incremental"); raw != "" {
+		incremental, err = strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid incremental: must be true or false")
+			return
+		}
+	}
+
+	language := strings.TrimSpace(r.URL.Query().Get("language"))
+	if language != "" && incremental {
+		respondError(w, http.StatusBadRequest, "language cannot be combined with incremental")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+	case "ndjson", "csv", "anki", "html":
+		if incremental {
+			respondError(w, http.StatusBadRequest, "Incremental export is only supported for the json format")
+			return
+		}
+		if language != "" {
+			respondError(w, http.StatusBadRequest, "Language export is only supported for the json format")
+			return
+		}
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid format '%s': must be json, ndjson, csv, anki or html", format))
+		return
+	}
+
+	switch format {
+	case "ndjson":
+		h.exportNDJSON(w, fields)
+		return
+	case "csv":
+		h.exportCSV(w, fields)
+		return
+	case "anki":
+		if fields != nil {
+			respondError(w, http.StatusBadRequest, "fields is not supported for the anki format")
+			return
+		}
+		h.exportAnki(w)
+		return
+	case "html":
+		if fields != nil {
+			respondError(w, http.StatusBadRequest, "fields is not supported for the html format")
+			return
+		}
+		h.exportHTML(w)
+		return
+	}
+
+	var export *db.Export
+	switch {
+	case incremental:
+		export, err = h.incrementalExport()
+	case language != "":
+		export, err = h.Processor.GetLanguageExport(language)
+	default:
+		export, err = h.Processor.GetExport()
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get vocabulary: %v", err))
+		return
+	}
+
+	var body any = export
+	if fields != nil {
+		body = export.Project(fields)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename="+exportFilename(language, ".json"))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(body); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to encode JSON: %v", err))
+		return
+	}
+
+	if incremental && len(export.Items) > 0 {
+		if err := h.Processor.DB.SetLastExportAt(export.Newest()); err != nil {
+			log.Printf("failed to advance export marker: %v", err)
+		}
+	}
+}
+
+// exportFilename returns the download filename for an export, naming the
+// language when the export is limited to one. Anything but ASCII letters and
+// digits in the language becomes a dash so the name is safe in a header.
+func exportFilename(language, ext string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(language) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			slug.WriteRune(r)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimSuffix(slug.String(), "-")
+	if name == "" {
+		return "vocabulary_export" + ext
+	}
+	return "vocabulary_export_" + name + ext
+}
+
+// incrementalExport builds an export of the items created since the last
+// incremental export
+func (h *Handler) incrementalExport() (*db.Export, error) {
+	since, err := h.Processor.DB.LastExportAt()
+	if err != nil {
+		return nil, err
+	}
+	return h.Processor.DB.NewExportSince(since)
+}
+
+// exportNDJSON streams every vocabulary item as one JSON object per line,
+// straight from the database cursor. Once the first line is written the
+// status can no longer change, so later failures are only logged.
+func (h *Handler) exportNDJSON(w http.ResponseWriter, fields []string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", "attachment; filename=vocabulary_export.ndjson")
+
+	encoder := json.NewEncoder(w)
+	err := h.Processor.DB.ForEach(func(item *db.Vocabulary) error {
+		if fields != nil {
+			return encoder.Encode(item.Project(fields))
+		}
+		return encoder.Encode(item)
+	})
+	if err != nil {
+		log.Printf("failed to stream ndjson export: %v", err)
+	}
+}
+
+// exportCSV streams the vocabulary as CSV with a header row. Without a field
+// selection the columns are db.CSVFields.
+func (h *Handler) exportCSV(w http.ResponseWriter, fields []string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=vocabulary_export.csv")
+
+	if err := h.Processor.DB.WriteCSV(w, fields); err != nil {
+		log.Printf("failed to stream csv export: %v", err)
+	}
+}
+
+// exportAnki streams the vocabulary as tab-separated Anki flashcards
+func (h *Handler) exportAnki(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/tab-separated-values; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=vocabulary.txt")
+
+	if err := h.Processor.DB.WriteAnki(w); err != nil {
+		log.Printf("failed to stream anki export: %v", err)
+	}
+}
+
+// exportHTML streams the vocabulary as a printable HTML study sheet
+func (h *Handler) exportHTML(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=vocabulary_study_sheet.html")
+
+	if err := h.Processor.DB.WriteHTML(w); err != nil {
+		log.Printf("failed to stream html export: %v", err)
+	}
+}
+
+// ImportResponse reports how many items an import added and skipped
+type ImportResponse struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// ImportVocabulary handles POST /api/import. It takes a JSON export either
+// as the "file" field of a multipart form or as the raw request body. Items
+// that are already stored, or that lack a text or language, are skipped; a
+// malformed file is rejected with 400 and nothing is written.
+func (h *Handler) ImportVocabulary(w http.ResponseWriter, r *http.Request) {
+	if !h.checkDiskSpace(w, r.ContentLength) {
+		return
+	}
+
+	// Exports can be as large as an uploaded document, so the import is
+	// held to the upload limit rather than the general body limit
+	r.Body = http.MaxBytesReader(w, r.Body, parser.MaxFileSize+uploadFormOverhead)
+
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				respondBodyTooLarge(w, maxErr.Limit)
+				return
+			}
+			respondError(w, http.StatusBadRequest, "Failed to parse form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "No file uploaded")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	imported, skipped, err := h.Processor.DB.ImportFromReader(body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		respondBodyTooLarge(w, maxErr.Limit)
+		return
+	}
+	if errors.Is(err, db.ErrInvalidImport) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid import: %v", err))
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to import vocabulary: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ImportResponse{Imported: imported, Skipped: skipped})
+}
+
+// DownloadBackup handles GET /api/backup/download.
+// It snapshots the database to a temp file and streams it as an attachment.
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	tmpFile, err := os.CreateTemp(os.TempDir(), "parsely-*-backup.db")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create backup file: %v", err))
+		return
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer parser.CleanupTempFile(tmpPath)
+
+	if err := h.Processor.BackupDatabase(tmpPath); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to back up database: %v", err))
+		return
+	}
+
+	backup, err := os.Open(tmpPath)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to open backup: %v", err))
+		return
+	}
+	defer backup.Close()
+
+	info, err := backup.Stat()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read backup: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", "attachment; filename=parsely-backup.db")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	if _, err := io.Copy(w, backup); err != nil {
+		log.Printf("failed to stream backup: %v", err)
+	}
+}
+
+// GetStats handles GET /api/stats. by_language counts items per language,
+// latest_by_language gives the created_at of each language's newest item and
+// by_day counts the items added on each of the last 30 UTC days.
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	count, err := h.Processor.GetVocabularyCount()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		return
+	}
+
+	untranslated, err := h.Processor.GetUntranslatedCount()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		return
+	}
+
+	byLanguage, err := h.Processor.DB.CountByLanguage()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		return
+	}
+
+	latest, err := h.Processor.DB.LatestByLanguage()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		return
+	}
+
+	byDay, err := h.Processor.DB.CountByDay()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get stats: %v", err))
+		return
+	}
+
+	stats := map[string]any{
+		"total_vocabulary":   count,
+		"untranslated":       untranslated,
+		"by_language":        byLanguage,
+		"latest_by_language": latest,
+		"by_day":             byDay,
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// RebuildDerived handles POST /api/maintenance/rebuild.
+func (h *Handler) RebuildDerived(w http.ResponseWriter, r *http.Request) {
+	if err := h.Processor.DB.RebuildDerived(); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to rebuild derived columns: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SuccessResponse{Message: "Derived columns rebuilt successfully"})
+}
+
+// defaultPurgeAgeDays is how long soft-deleted items are kept by
+// POST /api/maintenance/purge-deleted unless older_than_days says otherwise
+const defaultPurgeAgeDays = 30
+
+// PurgeDeletedResponse reports how many soft-deleted items were removed
+type PurgeDeletedResponse struct {
+	Purged int `json:"purged"`
+}
+
+// PurgeDeleted handles POST /api/maintenance/purge-deleted, permanently
+// removing items deleted more than older_than_days days ago (default 30).
+// older_than_days=0 empties the trash.
+func (h *Handler) PurgeDeleted(w http.ResponseWriter, r *http.Request) {
+	days, err := parseIntQuery(r, "older_than_days", defaultPurgeAgeDays, 0, math.MaxInt16)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var before time.Time
+	if days > 0 {
+		before = time.Now().AddDate(0, 0, -days)
+	}
+
+	purged, err := h.Processor.DB.PurgeDeleted(before)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to purge deleted vocabulary: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, PurgeDeletedResponse{Purged: purged})
+}
+
+// RelabelLanguagesResponse reports how many rows received a detected language
+type RelabelLanguagesResponse struct {
+	Updated int `json:"updated"`
+}
+
+// RelabelLanguages handles POST /api/maintenance/relabel-languages.
+func (h *Handler) RelabelLanguages(w http.ResponseWriter, r *http.Request) {
+	updated, err := h.Processor.RelabelAutoDetected(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to relabel languages: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RelabelLanguagesResponse{Updated: updated})
+}
+
+// TranslateMissingResponse reports how many items received a translation
+type TranslateMissingResponse struct {
+	Updated int `json:"updated"`
+}
+
+// TranslateMissing handles POST /api/vocabulary/translate-missing. Batches
+// translated before a failure stay stored, so calling it again resumes.
+func (h *Handler) TranslateMissing(w http.ResponseWriter, r *http.Request) {
+	updated, err := h.Processor.TranslateMissing(r.Context())
+	if err != nil {
+		prefix := fmt.Sprintf("Failed to translate vocabulary after %d updates", updated)
+		var aiErr *ai.AIError
+		switch {
+		case errors.Is(err, core.ErrTranslationUnsupported):
+			respondJSON(w, http.StatusNotImplemented, ErrorResponse{Error: fmt.Sprintf("Failed to translate vocabulary: %v", err), Code: "translation_unsupported"})
+		case errors.As(err, &aiErr) && aiErr.StatusCode == http.StatusTooManyRequests:
+			respondJSON(w, http.StatusTooManyRequests, h.aiErrorResponse(r, prefix, aiErr))
+		case errors.As(err, &aiErr):
+			respondJSON(w, http.StatusInternalServerError, h.aiErrorResponse(r, prefix, aiErr))
+		default:
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, TranslateMissingResponse{Updated: updated})
+}
+
+// parseVocabularyID extracts and validates the "id" path parameter.
+// Returns the parsed ID and true on success, or writes an error response and returns false.
+func parseVocabularyID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// parseIntQuery reads an optional integer query parameter. It returns def
+// when the parameter is absent and an error when it is malformed or outside
+// the range [min, max].
+func parseIntQuery(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: must be an integer", name)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("Invalid %s: must be between %d and %d", name, min, max)
+	}
+
+	return value, nil
+}
+
+// parseTimeQuery reads an optional RFC3339 timestamp or YYYY-MM-DD date
+// query parameter, returning the zero time when it is absent. Dates are
+// midnight UTC, or the last millisecond of the day when endOfDay is set so
+// that an upper bound includes the whole day.
+func parseTimeQuery(r *http.Request, name string, endOfDay bool) (time.Time, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid %s: must be an RFC3339 timestamp or a YYYY-MM-DD date", name)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+	}
+	return day, nil
+}
+
+// respondJSON sends a JSON response with the given status code.
+func respondJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// respondError sends an error JSON response with the given status code and message.
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, ErrorResponse{Error: message})
+}
+
+// respondFileTooLarge sends a 400 with a readable message and the raw byte
+// counts under the "file_too_large" code.
+func respondFileTooLarge(w http.ResponseWriter, err *parser.FileTooLargeError) {
+	respondJSON(w, http.StatusBadRequest, fileTooLargeResponse(err))
+}
+
+// fileTooLargeResponse builds the error body sent by respondFileTooLarge
+func fileTooLargeResponse(err *parser.FileTooLargeError) ErrorResponse {
+	return ErrorResponse{
+		Error:   "File" + strings.TrimPrefix(err.Error(), "file"),
+		Code:    "file_too_large",
+		Details: FileTooLargeDetails{SizeBytes: err.Size, MaxBytes: err.Limit},
+	}
+}
+
+// respondBodyTooLarge sends a 413 under the "request_too_large" code.
+func respondBodyTooLarge(w http.ResponseWriter, limit int64) {
+	respondJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+		Error: fmt.Sprintf("Request body too large (max %d bytes)", limit),
+		Code:  "request_too_large",
+	})
+}
+
+// bodyLimitExempt are the routes BodyLimitMiddleware leaves alone because
+// they enforce their own size limit
+var bodyLimitExempt = map[string]bool{
+	"/api/upload":        true,
+	"/api/upload/stream": true,
+	"/api/import":        true,
+}
+
+// BodyLimitMiddleware caps request bodies at limit bytes. Document upload
+// and import routes are exempt because they enforce their own size limit;
+// they are matched without trailing slashes, as RouteMiddleware routes them.
+// Requests that declare a larger Content-Length are rejected with 413 up
+// front; others are cut off by http.MaxBytesReader while being read.
+func BodyLimitMiddleware(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bodyLimitExempt[strings.TrimRight(r.URL.Path, "/")] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			respondBodyTooLarge(w, limit)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// timeoutExempt are the routes TimeoutMiddleware leaves alone because they
+// report progress while they run, so a long document is not cut off after
+// its client has seen it start
+var timeoutExempt = map[string]bool{
+	"/api/upload/stream": true,
+}
+
+// TimeoutMiddleware cancels the request context after d. If the handler has
+// not started its response by then, the client gets a 503 under the
+// "request_timeout" code and anything the handler writes afterwards is
+// discarded. Unlike http.TimeoutHandler the response is not buffered, so
+// streamed exports are passed through as they are written. The streaming
+// upload route is exempt, matched without trailing slashes like in
+// BodyLimitMiddleware; its client can disconnect to cancel it.
+func TimeoutMiddleware(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeoutExempt[strings.TrimRight(r.URL.Path, "/")] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, header: make(http.Header), ctx: ctx, limit: d}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer close(done)
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-done:
+			select {
+			case p := <-panicked:
+				panic(p)
+			default:
+			}
+		case <-ctx.Done():
+			if tw.timeout() {
+				// RecoverMiddleware can no longer see a panic from here on
+				go func() {
+					<-done
+					select {
+					case p := <-panicked:
+						log.Printf("panic after request timeout: %v", p)
+					default:
+					}
+				}()
+				return
+			}
+			// The response already started, so the handler keeps the
+			// connection until it notices the cancelled context
+			<-done
+		}
+	})
+}
+
+// timeoutWriter forwards a handler's response to w until TimeoutMiddleware
+// gives up on it. Headers are collected separately so a handler still
+// running after the timeout never touches w.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	w        http.ResponseWriter
+	header   http.Header
+	ctx      context.Context
+	limit    time.Duration
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.started || tw.timedOut {
+		return
+	}
+	// A handler woken by the deadline may get here before the middleware
+	// does; its late response must not replace the 503
+	if errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timeoutLocked()
+		return
+	}
+	tw.started = true
+	for key, values := range tw.header {
+		tw.w.Header()[key] = values
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if flusher, ok := tw.w.(http.Flusher); ok && !tw.timedOut {
+		flusher.Flush()
+	}
+}
+
+// timeout sends the 503 response unless the handler already started its
+// own, reporting whether the request timed out
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.started {
+		return false
+	}
+	tw.timeoutLocked()
+	return true
+}
+
+func (tw *timeoutWriter) timeoutLocked() {
+	if tw.timedOut {
+		return
+	}
+	tw.timedOut = true
+	respondJSON(tw.w, http.StatusServiceUnavailable, ErrorResponse{
+		Error: fmt.Sprintf("Request timed out after %s", tw.limit),
+		Code:  "request_timeout",
+	})
+}
+
+// CorsMiddleware adds CORS headers for the origins in allowedOrigins, so
+// browsers on other sites cannot read the responses. An origin is echoed
+// back only when it is listed; "*" in the list allows any origin. With an
+// empty list no CORS headers are sent and only same-origin pages can call
+// the API.
+func CorsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	wildcard := slices.Contains(allowedOrigins, "*")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allow := ""
+		if wildcard {
+			allow = "*"
+		} else if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(allowedOrigins, origin) {
+			allow = origin
+		}
+
+		if allow != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allow)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+			w.Header().Set("Access-Control-Max-Age", "3600")
+		}
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthMiddleware requires every /api/ request to carry expectedKey, either
+// in an X-API-Key header or as an "Authorization: Bearer" token, and answers
+// others with a JSON 401. Other paths, such as /health and the UI page, are
+// left open.
+func AuthMiddleware(expectedKey string, next http.Handler) http.Handler {
+	// Comparing digests keeps the comparison constant-time regardless of
+	// the length of the presented key
+	expected := sha256.Sum256([]byte(expectedKey))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				key = strings.TrimSpace(token)
+			}
+		}
+
+		presented := sha256.Sum256([]byte(key))
+		if key == "" || subtle.ConstantTimeCompare(presented[:], expected[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="parsely"`)
+			respondJSON(w, http.StatusUnauthorized, ErrorResponse{
+				Error: "Missing or invalid API key",
+				Code:  "unauthorized",
+			})
 			return
 		}
 
@@ -194,7 +1642,7 @@ func CorsMiddleware(next http.Handler) http.Handler {
 // LoggingMiddleware logs HTTP requests.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+		log.Printf("%s %s %s request_id=%s", r.RemoteAddr, r.Method, r.URL.Path, RequestIDFromContext(r.Context()))
 		next.ServeHTTP(w, r)
 	})
 }
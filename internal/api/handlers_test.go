@@ -1,34 +1,55 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/parsely/parsely/internal/ai"
 	"github.com/parsely/parsely/internal/core"
 	"github.com/parsely/parsely/internal/db"
+	"github.com/parsely/parsely/internal/parser"
 )
 
 // MockAIExtractor for testing
 type MockAIExtractor struct {
-	Vocabulary []string
-	Err        error
+	Vocabulary  []string
+	Err         error
+	ValidateErr error
 }
 
-func (m *MockAIExtractor) ExtractVocabulary(text, language string) ([]string, error) {
+func (m *MockAIExtractor) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
 	if m.Err != nil {
 		return nil, m.Err
 	}
 	return m.Vocabulary, nil
 }
 
+func (m *MockAIExtractor) DetectLanguage(ctx context.Context, text string) (string, error) {
+	return "", m.Err
+}
+
+func (m *MockAIExtractor) Validate(ctx context.Context) error {
+	return m.ValidateErr
+}
+
 // TestListVocabularyHandler tests GET /api/vocabulary
 func TestListVocabularyHandler(t *testing.T) {
 	handler := setupTestHandler(t)
@@ -49,13 +70,241 @@ func TestListVocabularyHandler(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", res.StatusCode)
 	}
 
-	var vocab []*db.Vocabulary
-	if err := json.NewDecoder(res.Body).Decode(&vocab); err != nil {
+	var page struct {
+		Items []*db.Vocabulary `json:"items"`
+		Total int              `json:"total"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if len(vocab) != 2 {
-		t.Errorf("Expected 2 vocabulary items, got %d", len(vocab))
+	if len(page.Items) != 2 || page.Total != 2 {
+		t.Errorf("Expected 2 vocabulary items, got %d (total %d)", len(page.Items), page.Total)
+	}
+}
+
+// TestListVocabularyPagination tests the limit and offset parameters of
+// GET /api/vocabulary
+func TestListVocabularyPagination(t *testing.T) {
+	handler := setupTestHandler(t)
+	for i := 0; i < 5; i++ {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: fmt.Sprintf("palabra%d", i), Language: "Spanish"})
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantItems  int
+		wantLimit  int
+		wantOffset int
+	}{
+		{"Defaults", "", http.StatusOK, 5, 50, 0},
+		{"First page", "?limit=2", http.StatusOK, 2, 2, 0},
+		{"Last page", "?limit=2&offset=4", http.StatusOK, 1, 2, 4},
+		{"Past the end", "?offset=10", http.StatusOK, 0, 50, 10},
+		{"Compact page", "?compact=true&limit=3", http.StatusOK, 3, 3, 0},
+		{"Negative offset", "?offset=-1", http.StatusBadRequest, 0, 0, 0},
+		{"Negative limit", "?limit=-5", http.StatusBadRequest, 0, 0, 0},
+		{"Zero limit", "?limit=0", http.StatusBadRequest, 0, 0, 0},
+		{"Limit too large", "?limit=5000", http.StatusBadRequest, 0, 0, 0},
+		{"Non-numeric limit", "?limit=ten", http.StatusBadRequest, 0, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/vocabulary"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ListVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var page struct {
+				Items  []map[string]any `json:"items"`
+				Total  int              `json:"total"`
+				Limit  int              `json:"limit"`
+				Offset int              `json:"offset"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if page.Items == nil {
+				t.Error("Expected items to be an array, not null")
+			}
+			if len(page.Items) != tc.wantItems || page.Total != 5 || page.Limit != tc.wantLimit || page.Offset != tc.wantOffset {
+				t.Errorf("Expected %d items (total 5, limit %d, offset %d), got %d (total %d, limit %d, offset %d)",
+					tc.wantItems, tc.wantLimit, tc.wantOffset, len(page.Items), page.Total, page.Limit, page.Offset)
+			}
+		})
+	}
+}
+
+// TestListVocabularyLanguageFilter tests GET /api/vocabulary?language=
+func TestListVocabularyLanguageFilter(t *testing.T) {
+	handler := setupTestHandler(t)
+	for _, word := range []string{"hola", "gracias", "adiós"} {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: word, Language: "Spanish"})
+	}
+	bonjour, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "bonjour", Language: "French"})
+	hola, _ := handler.Processor.DB.GetByText("hola")
+	handler.Processor.DB.TagIDs([]int{hola.ID, bonjour}, "greetings")
+
+	tests := []struct {
+		name      string
+		query     string
+		wantItems int
+		wantTotal int
+	}{
+		{"No filter", "", 4, 4},
+		{"Exact language", "?language=Spanish", 3, 3},
+		{"Case and whitespace folded", "?language=%20sPaNiSh%20", 3, 3},
+		{"Blank filter lists everything", "?language=%20", 4, 4},
+		{"With pagination", "?language=Spanish&limit=2&offset=2", 1, 3},
+		{"Compact", "?language=french&compact=true", 1, 1},
+		{"No match", "?language=German", 0, 0},
+		{"Tag", "?tag=Greetings", 2, 2},
+		{"Language and tag", "?language=spanish&tag=greetings&compact=true", 1, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/vocabulary"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ListVocabulary(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var page struct {
+				Items []map[string]any `json:"items"`
+				Total int              `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if page.Items == nil {
+				t.Error("Expected items to be an array, not null")
+			}
+			if len(page.Items) != tc.wantItems || page.Total != tc.wantTotal {
+				t.Errorf("Expected %d items of %d, got %d of %d", tc.wantItems, tc.wantTotal, len(page.Items), page.Total)
+			}
+		})
+	}
+}
+
+// TestListVocabularyDateRange tests the from and to filters on
+// GET /api/vocabulary
+func TestListVocabularyDateRange(t *testing.T) {
+	handler := setupTestHandler(t)
+	clock := &fakeClock{}
+	handler.Processor.DB.SetClock(clock)
+	for _, word := range []struct {
+		text string
+		at   time.Time
+	}{
+		{"uno", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"dos", time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"tres", time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)},
+		{"cuatro", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		clock.t = word.at
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: word.text, Language: "Spanish"})
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   string
+	}{
+		{"Whole month by date", "?from=2024-01-01&to=2024-01-31", http.StatusOK, "tres,dos,uno"},
+		{"Only from", "?from=2024-01-15", http.StatusOK, "cuatro,tres,dos"},
+		{"Only to", "?to=2024-01-15", http.StatusOK, "dos,uno"},
+		{"RFC3339 bounds", "?from=2024-01-15T12:00:00Z&to=2024-01-31T23:00:00Z", http.StatusOK, "dos"},
+		{"RFC3339 with offset", "?to=2024-01-15T13:00:00%2B01:00", http.StatusOK, "dos,uno"},
+		{"Single day", "?from=2024-02-01&to=2024-02-01", http.StatusOK, "cuatro"},
+		{"Empty range", "?from=2023-01-01&to=2023-12-31", http.StatusOK, ""},
+		{"Unparseable from", "?from=last-week", http.StatusBadRequest, ""},
+		{"Unparseable to", "?to=2024-13-01", http.StatusBadRequest, ""},
+		{"From after to", "?from=2024-02-01&to=2024-01-01", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/vocabulary"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ListVocabulary(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var page struct {
+				Items []db.Vocabulary `json:"items"`
+				Total int             `json:"total"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var got []string
+			for _, item := range page.Items {
+				got = append(got, item.Text)
+			}
+			if strings.Join(got, ",") != tc.want || page.Total != len(got) {
+				t.Errorf("Expected %q, got %q with total %d", tc.want, strings.Join(got, ","), page.Total)
+			}
+		})
+	}
+}
+
+// TestSearchVocabularyHandler tests GET /api/vocabulary/search
+func TestSearchVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "Manzana", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "pan", Language: "Spanish"})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantItems  int
+	}{
+		{"Match", "?q=MANZ", http.StatusOK, 1},
+		{"Shared substring", "?q=an", http.StatusOK, 2},
+		{"No match", "?q=perro", http.StatusOK, 0},
+		{"Missing q", "", http.StatusBadRequest, 0},
+		{"Blank q", "?q=%20", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/vocabulary/search"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.SearchVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var items []*db.Vocabulary
+			if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if items == nil || len(items) != tc.wantItems {
+				t.Errorf("Expected %d items, got %v", tc.wantItems, items)
+			}
+		})
 	}
 }
 
@@ -90,6 +339,123 @@ func TestGetVocabularyHandler(t *testing.T) {
 	}
 }
 
+// TestGetVocabularyHandlerContext tests that stored context is returned
+func TestGetVocabularyHandlerContext(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "contexto", Language: "Spanish", Context: "Un ejemplo con contexto."})
+
+	idStr := fmt.Sprintf("%d", id)
+	req := httptest.NewRequest("GET", "/api/vocabulary/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w := httptest.NewRecorder()
+
+	handler.GetVocabulary(w, req)
+
+	var body map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body["context"] != "Un ejemplo con contexto." {
+		t.Errorf("Expected context in response, got %v", body["context"])
+	}
+}
+
+// TestSimilarVocabularyHandler tests GET /api/vocabulary/{id}/similar
+func TestSimilarVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "casa", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "cosa", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "ventana", Language: "Spanish"})
+
+	idStr := fmt.Sprintf("%d", id)
+	req := httptest.NewRequest("GET", "/api/vocabulary/"+idStr+"/similar?distance=1", nil)
+	req.SetPathValue("id", idStr)
+	w := httptest.NewRecorder()
+
+	handler.SimilarVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var similar []*db.Vocabulary
+	if err := json.NewDecoder(w.Body).Decode(&similar); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(similar) != 1 || similar[0].Text != "cosa" {
+		t.Errorf("Expected only 'cosa', got %+v", similar)
+	}
+
+	// Out-of-range parameters are rejected
+	req = httptest.NewRequest("GET", "/api/vocabulary/"+idStr+"/similar?distance=99", nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+
+	handler.SimilarVocabulary(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid distance, got %d", w.Code)
+	}
+}
+
+// TestRandomVocabularyHandler tests GET /api/vocabulary/random
+func TestRandomVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// An empty database gives an empty array, not an error
+	w := httptest.NewRecorder()
+	handler.RandomVocabulary(w, httptest.NewRequest("GET", "/api/vocabulary/random", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("Expected 200 with [], got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, text := range []string{"casa", "perro", "gato"} {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: text, Language: "Spanish"})
+	}
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "Haus", Language: "German"})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"Default count", "", http.StatusOK, 1},
+		{"Several", "?count=3", http.StatusOK, 3},
+		{"More than stored", "?count=50", http.StatusOK, 4},
+		{"Language filter", "?count=10&language=german", http.StatusOK, 1},
+		{"Count too large", "?count=51", http.StatusBadRequest, 0},
+		{"Count zero", "?count=0", http.StatusBadRequest, 0},
+		{"Count not a number", "?count=many", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.RandomVocabulary(w, httptest.NewRequest("GET", "/api/vocabulary/random"+tc.query, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var items []*db.Vocabulary
+			if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(items) != tc.wantCount {
+				t.Errorf("Expected %d items, got %d", tc.wantCount, len(items))
+			}
+		})
+	}
+}
+
 // TestDeleteVocabularyHandler tests DELETE /api/vocabulary/{id}
 func TestDeleteVocabularyHandler(t *testing.T) {
 	handler := setupTestHandler(t)
@@ -117,13 +483,164 @@ func TestDeleteVocabularyHandler(t *testing.T) {
 	}
 }
 
+// TestRestoreVocabularyHandler tests restoring a deleted item through
+// POST /api/vocabulary/{id}/restore and listing it with include_deleted
+func TestRestoreVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "perro", Language: "Spanish"})
+	handler.Processor.DB.Delete(id)
+	idStr := fmt.Sprintf("%d", id)
+
+	w := httptest.NewRecorder()
+	handler.ListVocabulary(w, httptest.NewRequest("GET", "/api/vocabulary?include_deleted=true", nil))
+	var page struct {
+		Items []*db.Vocabulary `json:"items"`
+		Total int              `json:"total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if page.Total != 1 || len(page.Items) != 1 || page.Items[0].DeletedAt == nil {
+		t.Errorf("Expected the deleted item with deleted_at, got %+v", page)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ListVocabulary(w, httptest.NewRequest("GET", "/api/vocabulary?include_deleted=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid include_deleted, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/vocabulary/"+idStr+"/restore", nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.RestoreVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var restored db.Vocabulary
+	if err := json.NewDecoder(w.Body).Decode(&restored); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if restored.Text != "perro" || restored.DeletedAt != nil {
+		t.Errorf("Expected restored perro, got %+v", restored)
+	}
+
+	// Restoring again finds no deleted item
+	req = httptest.NewRequest("POST", "/api/vocabulary/"+idStr+"/restore", nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	handler.RestoreVocabulary(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	// A deleted word can be added again instead of conflicting with itself
+	handler.Processor.DB.Delete(id)
+	w = httptest.NewRecorder()
+	handler.AddVocabulary(w, httptest.NewRequest("POST", "/api/vocabulary", strings.NewReader(`{"text":"perro"}`)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 adding a deleted word again, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestVocabularyHistoryHandler tests GET /api/vocabulary/{id}/history
+func TestVocabularyHistoryHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "perro", Language: "Spanish"})
+	if err := handler.Processor.DB.SetTranslation(id, "dog"); err != nil {
+		t.Fatalf("SetTranslation failed: %v", err)
+	}
+	unchanged, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "gato", Language: "Spanish"})
+
+	tests := []struct {
+		name        string
+		id          int
+		wantStatus  int
+		wantEntries int
+	}{
+		{"Changed item", id, http.StatusOK, 1},
+		{"Unchanged item", unchanged, http.StatusOK, 0},
+		{"Unknown item", 9999, http.StatusNotFound, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			idStr := fmt.Sprintf("%d", tc.id)
+			req := httptest.NewRequest("GET", "/api/vocabulary/"+idStr+"/history", nil)
+			req.SetPathValue("id", idStr)
+			w := httptest.NewRecorder()
+			handler.VocabularyHistory(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var history []*db.HistoryEntry
+			if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(history) != tc.wantEntries {
+				t.Fatalf("Expected %d entries, got %d", tc.wantEntries, len(history))
+			}
+			if tc.wantEntries > 0 && (history[0].Field != "translation" || history[0].NewValue != "dog") {
+				t.Errorf("Unexpected history entry: %+v", history[0])
+			}
+		})
+	}
+}
+
+// TestPurgeDeletedHandler tests POST /api/maintenance/purge-deleted
+func TestPurgeDeletedHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "perro", Language: "Spanish"})
+	handler.Processor.DB.Delete(id)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPurged int
+	}{
+		{"Recently deleted items are kept", "", http.StatusOK, 0},
+		{"Invalid age", "?older_than_days=-1", http.StatusBadRequest, 0},
+		{"Zero purges everything", "?older_than_days=0", http.StatusOK, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.PurgeDeleted(w, httptest.NewRequest("POST", "/api/maintenance/purge-deleted"+tc.query, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var resp PurgeDeletedResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Purged != tc.wantPurged {
+				t.Errorf("Expected %d purged, got %d", tc.wantPurged, resp.Purged)
+			}
+		})
+	}
+}
+
 // TestUploadHandler tests POST /api/upload
 func TestUploadHandler(t *testing.T) {
 	handler := setupTestHandler(t)
 
 	// Create a test file
 	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "test.txt")
+	testFile := filepath.Join(tmpDir, "test.rtf")
 	os.WriteFile(testFile, []byte("test content"), 0600)
 
 	// Create multipart form
@@ -133,7 +650,7 @@ func TestUploadHandler(t *testing.T) {
 	file, _ := os.Open(testFile)
 	defer file.Close()
 
-	part, _ := writer.CreateFormFile("file", "test.txt")
+	part, _ := writer.CreateFormFile("file", "test.rtf")
 	io.Copy(part, file)
 	writer.Close()
 
@@ -152,105 +669,2330 @@ func TestUploadHandler(t *testing.T) {
 	}
 }
 
-// TestExportHandler tests POST /api/export
-func TestExportHandler(t *testing.T) {
+// TestUploadHandlerInsufficientStorage tests that uploads are rejected with
+// 507 when free disk space is below the threshold plus the upload size
+func TestUploadHandlerInsufficientStorage(t *testing.T) {
 	handler := setupTestHandler(t)
+	handler.MinFreeBytes = 100 << 20
+	handler.FreeSpace = func(path string) (uint64, error) {
+		return 100<<20 + 10, nil
+	}
 
-	// Add test vocabulary
-	handler.Processor.DB.Insert(&db.Vocabulary{Text: "export_test", Language: "Spanish"})
+	newRequest := func() *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "lesson.txt")
+		part.Write([]byte("Hola amigo"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
 
-	req := httptest.NewRequest("POST", "/api/export", nil)
 	w := httptest.NewRecorder()
+	handler.UploadDocument(w, newRequest())
 
-	handler.ExportVocabulary(w, req)
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected status 507, got %d: %s", w.Code, w.Body.String())
+	}
 
-	res := w.Result()
-	defer res.Body.Close()
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errResp.Code != "insufficient_storage" {
+		t.Errorf("Expected code insufficient_storage, got %q", errResp.Code)
+	}
 
-	if res.StatusCode != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", res.StatusCode)
+	// With the check disabled the same upload is processed
+	handler.MinFreeBytes = 0
+	w = httptest.NewRecorder()
+	handler.UploadDocument(w, newRequest())
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the check disabled, got %d: %s", w.Code, w.Body.String())
 	}
+}
 
-	// Check content type
-	contentType := res.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		t.Errorf("Expected application/json, got %s", contentType)
+// fakeClock is a db.Clock whose time is set by the test
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+// TestUploadHandlerDailyQuota tests that uploads beyond the daily quota get
+// 429 and that the quota resets on the next day
+func TestUploadHandlerDailyQuota(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.DailyUploadQuota = 2
+
+	clock := &fakeClock{time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)}
+	handler.Processor.DB.SetClock(clock)
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "lesson.txt")
+		part.Write([]byte(content))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.RemoteAddr = "203.0.113.7:51234"
+		w := httptest.NewRecorder()
+		handler.UploadDocument(w, req)
+		return w
+	}
+
+	// A failed upload is given back and does not use up the quota
+	if w := upload("   "); w.Code == http.StatusOK {
+		t.Fatalf("Expected an empty document to fail, got %d", w.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := upload("Hola amigo"); w.Code != http.StatusOK {
+			t.Fatalf("Upload %d: expected status 200, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+
+	w := upload("Hola amigo")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 over quota, got %d", w.Code)
+	}
+	// Six hours to midnight by the handler's clock, not the wall clock
+	if got := w.Header().Get("Retry-After"); got != "21601" {
+		t.Errorf("Expected Retry-After 21601, got %q", got)
+	}
+	var errResp struct {
+		Code    string               `json:"code"`
+		Details QuotaExceededDetails `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if errResp.Code != "quota_exceeded" || !errResp.Details.ResetAt.Equal(time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected quota response: %+v", errResp)
+	}
+
+	clock.t = clock.t.Add(7 * time.Hour)
+	if w := upload("Hola amigo"); w.Code != http.StatusOK {
+		t.Errorf("Expected quota to reset after midnight, got %d", w.Code)
 	}
 }
 
-// TestCORS tests CORS middleware
-func TestCORS(t *testing.T) {
+// TestHealthReportsAIStatus tests that /health reflects the AI check
+func TestHealthReportsAIStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		validateErr error
+		check       bool
+		wantStatus  string
+		wantAI      string
+	}{
+		{"unchecked", nil, false, "ok", "unchecked"},
+		{"healthy", nil, true, "ok", "healthy"},
+		{"unhealthy", &ai.AIError{Message: "invalid x-api-key", StatusCode: 401}, true, "degraded", "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+			handler.Processor.AI = &MockAIExtractor{ValidateErr: tt.validateErr}
+
+			if tt.check {
+				if err := handler.CheckAI(context.Background()); (err != nil) != (tt.validateErr != nil) {
+					t.Fatalf("Unexpected CheckAI result: %v", err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.Health(w, httptest.NewRequest("GET", "/health", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+
+			var health HealthResponse
+			if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+				t.Fatalf("Failed to decode health response: %v", err)
+			}
+			if health.Status != tt.wantStatus || health.AI != tt.wantAI {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.wantStatus, tt.wantAI, health.Status, health.AI)
+			}
+			if tt.validateErr != nil && !strings.Contains(health.AIError, "invalid x-api-key") {
+				t.Errorf("Expected AI error in response, got %q", health.AIError)
+			}
+		})
+	}
+}
+
+// TestReady tests GET /health/ready against a working and a closed
+// database, with and without the AI check
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		closeDB      bool
+		validateErr  error
+		wantCode     int
+		wantDatabase string
+		wantAI       string
+	}{
+		{"ready", "", false, nil, http.StatusOK, "healthy", "unchecked"},
+		{"ready with ai", "?ai=true", false, nil, http.StatusOK, "healthy", "healthy"},
+		{"database down", "", true, nil, http.StatusServiceUnavailable, "unhealthy", "unchecked"},
+		{"ai down", "?ai=true", false, &ai.AIError{Message: "invalid x-api-key", StatusCode: 401}, http.StatusServiceUnavailable, "healthy", "unhealthy"},
+		{"ai down but unchecked", "?ai=false", false, &ai.AIError{Message: "invalid x-api-key", StatusCode: 401}, http.StatusOK, "healthy", "unchecked"},
+		{"bad ai param", "?ai=maybe", false, nil, http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+			handler.Processor.AI = &MockAIExtractor{ValidateErr: tt.validateErr}
+			if tt.closeDB {
+				handler.Processor.DB.Close()
+			}
+
+			w := httptest.NewRecorder()
+			handler.Ready(w, httptest.NewRequest("GET", "/health/ready"+tt.query, nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode == http.StatusBadRequest {
+				return
+			}
+
+			var ready ReadinessResponse
+			if err := json.NewDecoder(w.Body).Decode(&ready); err != nil {
+				t.Fatalf("Failed to decode readiness response: %v", err)
+			}
+			if ready.Database != tt.wantDatabase || ready.AI != tt.wantAI {
+				t.Errorf("Expected database %s and ai %s, got %+v", tt.wantDatabase, tt.wantAI, ready)
+			}
+			if wantReady := tt.wantCode == http.StatusOK; (ready.Status == "ready") != wantReady {
+				t.Errorf("Unexpected status %q", ready.Status)
+			}
+			if tt.closeDB && ready.DatabaseError == "" {
+				t.Error("Expected the database error in the response")
+			}
+		})
+	}
+}
+
+// TestRouteMiddleware tests trailing-slash normalization and 405 responses
+func TestRouteMiddleware(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/vocabulary", handler.ListVocabulary)
+	mux.HandleFunc("GET /api/vocabulary/{id}", handler.GetVocabulary)
+	mux.HandleFunc("DELETE /api/vocabulary/{id}", handler.DeleteVocabulary)
+	router := RouteMiddleware(mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"Trailing slash", "GET", "/api/vocabulary/", http.StatusOK, ""},
+		{"Repeated trailing slashes", "GET", "/api/vocabulary//", http.StatusOK, ""},
+		{"Disallowed method", "PUT", "/api/vocabulary", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"Disallowed method with parameter", "POST", "/api/vocabulary/1/", http.StatusMethodNotAllowed, "GET, HEAD, DELETE"},
+		{"Unknown path", "GET", "/api/missing", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if allow := w.Header().Get("Allow"); allow != tc.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tc.wantAllow, allow)
+			}
+			if tc.wantStatus == http.StatusMethodNotAllowed {
+				var errResp ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+					t.Errorf("Expected a JSON error body, got %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// TestUIRoutes tests that the embedded UI is served at / alongside the API
+func TestUIRoutes(t *testing.T) {
 	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+
+	mux := http.NewServeMux()
+	RegisterUI(mux)
+	mux.HandleFunc("GET /api/vocabulary", handler.ListVocabulary)
 
-	req := httptest.NewRequest("OPTIONS", "/api/vocabulary", nil)
-	req.Header.Set("Origin", "http://localhost:3000")
 	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for /, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML content type, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "<title>Parsely</title>") {
+		t.Error("Expected the UI page body")
+	}
 
-	// Wrap handler with CORS middleware
-	corsHandler := CorsMiddleware(http.HandlerFunc(handler.ListVocabulary))
-	corsHandler.ServeHTTP(w, req)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/vocabulary", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hola") {
+		t.Errorf("Expected the API to keep working, got %d: %s", w.Code, w.Body.String())
+	}
 
-	res := w.Result()
-	defer res.Body.Close()
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected only the root path to serve the UI, got %d for /missing", w.Code)
+	}
+}
+
+// TestListVocabularySort tests the sort parameter of GET /api/vocabulary
+func TestListVocabularySort(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/api/vocabulary?sort=updated_at", http.StatusOK},
+		{"/api/vocabulary?sort=created_at", http.StatusOK},
+		{"/api/vocabulary?sort=frequency", http.StatusOK},
+		{"/api/vocabulary?sort=text", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", tc.target, nil)
+		w := httptest.NewRecorder()
 
-	// Check CORS headers
-	if res.Header.Get("Access-Control-Allow-Origin") == "" {
-		t.Error("Missing Access-Control-Allow-Origin header")
+		handler.ListVocabulary(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("Expected status %d for %s, got %d", tc.status, tc.target, w.Code)
+		}
 	}
 }
 
-// TestInvalidJSON tests handling of invalid JSON
-func TestInvalidJSON(t *testing.T) {
-	req := httptest.NewRequest("POST", "/api/vocabulary", bytes.NewBufferString("invalid json"))
-	req.Header.Set("Content-Type", "application/json")
+// TestListVocabularyCompact tests the slim ?compact=true response
+func TestListVocabularyCompact(t *testing.T) {
+	handler := setupTestHandler(t)
+	for _, text := range []string{"hola", "adiós"} {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: text, Language: "Spanish", Context: "Una frase de ejemplo."})
+	}
 
-	// This would be a handler that expects JSON
-	// For now, just verify the request setup
-	if req.Header.Get("Content-Type") != "application/json" {
-		t.Error("Content-Type not set correctly")
+	list := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		handler.ListVocabulary(w, req)
+		return w
+	}
+
+	full := list("/api/vocabulary")
+	compact := list("/api/vocabulary?compact=true")
+	if compact.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", compact.Code)
+	}
+
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(compact.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode compact list: %v", err)
+	}
+	items := page.Items
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	for _, item := range items {
+		if len(item) != 2 || item["id"] == nil || item["text"] == nil {
+			t.Errorf("Expected only id and text, got %v", item)
+		}
+		if _, ok := item["language"]; ok {
+			t.Error("Compact item should omit language")
+		}
+		if _, ok := item["created_at"]; ok {
+			t.Error("Compact item should omit created_at")
+		}
+	}
+
+	if compact.Body.Len() >= full.Body.Len() {
+		t.Errorf("Expected compact response (%d bytes) to be smaller than full (%d bytes)", compact.Body.Len(), full.Body.Len())
+	}
+
+	if w := list("/api/vocabulary?compact=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid compact value, got %d", w.Code)
 	}
 }
 
-// TestRateLimiting tests rate limiting on upload endpoint
-func TestRateLimiting(t *testing.T) {
-	// This is a placeholder - rate limiting would require more complex setup
-	// In production, use a proper rate limiting library
-	t.Skip("Rate limiting requires more complex integration test setup")
+// TestListVocabularyCSV tests that the list is written as CSV when asked
+// for through the Accept header or the .csv path, with the filters and
+// pagination still applied
+func TestListVocabularyCSV(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "adiós, amigo", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "bonjour", Language: "French"})
+
+	tests := []struct {
+		name       string
+		target     string
+		accept     string
+		wantHeader []string
+		wantRows   int
+		wantTexts  []string // nil when the order of the rows is not fixed
+		wantTotal  string
+	}{
+		{"Accept header", "/api/vocabulary?language=spanish", "text/csv", db.CSVFields, 2, []string{"adiós, amigo", "hola"}, "2"},
+		{"CSV path", "/api/vocabulary.csv?language=Spanish", "", db.CSVFields, 2, []string{"adiós, amigo", "hola"}, "2"},
+		{"Paginated", "/api/vocabulary?language=Spanish&limit=1&offset=1", "text/csv", db.CSVFields, 1, nil, "2"},
+		{"Compact", "/api/vocabulary?compact=true&language=French", "text/csv", []string{"id", "text"}, 1, []string{"bonjour"}, "1"},
+		{"Preferred over JSON", "/api/vocabulary?language=French", "application/json;q=0.5, text/csv", db.CSVFields, 1, []string{"bonjour"}, "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ListVocabulary(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+				t.Errorf("Expected text/csv, got %q", ct)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != tc.wantTotal {
+				t.Errorf("Expected X-Total-Count %s, got %q", tc.wantTotal, got)
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to parse CSV: %v", err)
+			}
+			if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(tc.wantHeader, ",") {
+				t.Fatalf("Expected header %v, got %v", tc.wantHeader, records)
+			}
+			if len(records)-1 != tc.wantRows {
+				t.Fatalf("Expected %d rows, got %d", tc.wantRows, len(records)-1)
+			}
+			if tc.wantTexts == nil {
+				return
+			}
+			var texts []string
+			for _, record := range records[1:] {
+				texts = append(texts, record[1])
+			}
+			slices.Sort(texts)
+			if !slices.Equal(texts, tc.wantTexts) {
+				t.Errorf("Expected %v, got %v", tc.wantTexts, texts)
+			}
+		})
+	}
 }
 
-// TestLargeFileRejection tests that oversized files are rejected
-func TestLargeFileRejection(t *testing.T) {
+// TestPrefersCSV tests Accept header negotiation between CSV and JSON
+func TestPrefersCSV(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/csv", true},
+		{"text/csv; charset=utf-8", true},
+		{"text/*", true},
+		{"text/csv, application/json", false},
+		{"text/csv, */*;q=0.1", true},
+		{"text/csv;q=0.5, */*", false},
+		{"application/json;q=0.2, text/csv;q=0.8", true},
+		{"text/csv;q=bad", false},
+	}
+
+	for _, tc := range tests {
+		if got := prefersCSV(tc.accept); got != tc.want {
+			t.Errorf("prefersCSV(%q) = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+// TestUploadHandlerAIErrorRequestIDs tests that a failed AI call is logged
+// and reported with both our request ID and Anthropic's
+func TestUploadHandlerAIErrorRequestIDs(t *testing.T) {
 	handler := setupTestHandler(t)
+	handler.Processor.AI = &MockAIExtractor{Err: &ai.AIError{
+		Message:    "Overloaded",
+		StatusCode: 529,
+		RequestID:  "req_011CTest",
+	}}
 
-	// Create a large file
-	tmpDir := t.TempDir()
-	largeFile := filepath.Join(tmpDir, "large.pdf")
-	largeContent := make([]byte, 11*1024*1024) // 11MB
-	os.WriteFile(largeFile, largeContent, 0600)
+	var logs bytes.Buffer
+	handler.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
 
-	// Create multipart form
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
-
-	file, _ := os.Open(largeFile)
-	defer file.Close()
-
-	part, _ := writer.CreateFormFile("file", "large.pdf")
-	io.Copy(part, file)
+	part, _ := writer.CreateFormFile("file", "lesson.txt")
+	part.Write([]byte("Hola amigo"))
 	writer.Close()
 
 	req := httptest.NewRequest("POST", "/api/upload", body)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(RequestIDHeader, "ours-123")
 	w := httptest.NewRecorder()
+	RequestIDMiddleware(http.HandlerFunc(handler.UploadDocument)).ServeHTTP(w, req)
 
-	handler.UploadDocument(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(RequestIDHeader); got != "ours-123" {
+		t.Errorf("Expected request ID header ours-123, got %q", got)
+	}
 
-	res := w.Result()
-	defer res.Body.Close()
+	var errResp struct {
+		Error   string         `json:"error"`
+		Code    string         `json:"code"`
+		Details AIErrorDetails `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	want := AIErrorDetails{RequestID: "ours-123", AnthropicRequestID: "req_011CTest", Status: 529}
+	if errResp.Code != "ai_error" || errResp.Details != want {
+		t.Errorf("Unexpected error response: %+v", errResp)
+	}
+	if strings.Contains(errResp.Error, "req_011CTest") {
+		t.Errorf("Expected the request ID to stay out of the message, got %q", errResp.Error)
+	}
 
-	// Should reject large file
-	if res.StatusCode == http.StatusOK {
-		t.Error("Should reject file over size limit")
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log entry %q: %v", logs.String(), err)
+	}
+	if entry["request_id"] != "ours-123" || entry["anthropic_request_id"] != "req_011CTest" || entry["status"] != float64(529) {
+		t.Errorf("Unexpected log entry: %v", entry)
+	}
+}
+
+// TestRequestIDMiddleware tests that unsafe or missing IDs are replaced
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"Missing", "", false},
+		{"Client supplied", "abc-123", true},
+		{"Control characters", "abc\ninjected", false},
+		{"Too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/api/stats", nil)
+			if tc.incoming != "" {
+				req.Header.Set(RequestIDHeader, tc.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if seen == "" || w.Header().Get(RequestIDHeader) != seen {
+				t.Fatalf("Expected matching context and header IDs, got %q and %q", seen, w.Header().Get(RequestIDHeader))
+			}
+			if (seen == tc.incoming) != tc.keep {
+				t.Errorf("Incoming %q: got %q", tc.incoming, seen)
+			}
+		})
+	}
+}
+
+// TestNewRequestIDFallback tests that IDs stay unique when no random bytes
+// can be read
+func TestNewRequestIDFallback(t *testing.T) {
+	original := randRead
+	randRead = func([]byte) (int, error) { return 0, errors.New("entropy unavailable") }
+	t.Cleanup(func() { randRead = original })
+
+	first, second := newRequestID(), newRequestID()
+	if !validRequestID(first) || !validRequestID(second) {
+		t.Fatalf("Expected valid fallback IDs, got %q and %q", first, second)
+	}
+	if first == second {
+		t.Errorf("Expected distinct fallback IDs, got %q twice", first)
+	}
+}
+
+// TestUploadHandlerPages tests validation of the pages upload parameter
+func TestUploadHandlerPages(t *testing.T) {
+	tests := []struct {
+		name   string
+		pages  string
+		status int
+	}{
+		{"No range", "", http.StatusOK},
+		{"Malformed range", "abc", http.StatusBadRequest},
+		{"Inverted range", "5-2", http.StatusBadRequest},
+		{"Range on non-PDF", "1-2", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "notes.txt")
+			part.Write([]byte("Hola y adiós"))
+			writer.Close()
+
+			target := "/api/upload"
+			if tc.pages != "" {
+				target += "?pages=" + url.QueryEscape(tc.pages)
+			}
+			req := httptest.NewRequest("POST", target, body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			handler.UploadDocument(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestUploadHandlerTags tests that the tags form field is applied to every
+// extracted word, including words that were already stored
+func TestUploadHandlerTags(t *testing.T) {
+	handler := setupTestHandler(t)
+	existing, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "test1", Language: "Spanish"})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("Hola y adiós"))
+	writer.WriteField("tags", "Lesson 3, food,")
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.UploadDocument(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	items, err := handler.Processor.DB.ListByTag("lesson 3")
+	if err != nil {
+		t.Fatalf("ListByTag failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 tagged items, got %d", len(items))
+	}
+	for _, item := range items {
+		if strings.Join(item.Tags, ",") != "food,lesson 3" {
+			t.Errorf("Expected %s to carry food and lesson 3, got %v", item.Text, item.Tags)
+		}
+	}
+	if vocab, _ := handler.Processor.DB.Get(existing); len(vocab.Tags) != 2 {
+		t.Errorf("Expected the existing word to be tagged, got %v", vocab.Tags)
+	}
+}
+
+// TestVocabularyTagHandlers tests adding and removing a single item's tags
+func TestVocabularyTagHandlers(t *testing.T) {
+	handler := setupTestHandler(t)
+	id, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "manzana", Language: "Spanish"})
+	path := fmt.Sprintf("/api/vocabulary/%d/tags", id)
+
+	tests := []struct {
+		name     string
+		method   string
+		id       string
+		tag      string
+		body     string
+		status   int
+		wantTags []string
+	}{
+		{"Add", "POST", strconv.Itoa(id), "", `{"tag":"Food"}`, http.StatusOK, []string{"food"}},
+		{"Add second", "POST", strconv.Itoa(id), "", `{"tag":"fruit"}`, http.StatusOK, []string{"food", "fruit"}},
+		{"Add invalid", "POST", strconv.Itoa(id), "", `{"tag":" "}`, http.StatusBadRequest, nil},
+		{"Add bad JSON", "POST", strconv.Itoa(id), "", `{`, http.StatusBadRequest, nil},
+		{"Add to missing item", "POST", "9999", "", `{"tag":"food"}`, http.StatusNotFound, nil},
+		{"Remove", "DELETE", strconv.Itoa(id), "food", "", http.StatusOK, []string{"fruit"}},
+		{"Remove from missing item", "DELETE", "9999", "food", "", http.StatusNotFound, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+			req.SetPathValue("id", tc.id)
+			req.SetPathValue("tag", tc.tag)
+			w := httptest.NewRecorder()
+
+			if tc.method == "POST" {
+				handler.AddVocabularyTag(w, req)
+			} else {
+				handler.RemoveVocabularyTag(w, req)
+			}
+
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.wantTags == nil {
+				return
+			}
+
+			var vocab db.Vocabulary
+			if err := json.Unmarshal(w.Body.Bytes(), &vocab); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if strings.Join(vocab.Tags, ",") != strings.Join(tc.wantTags, ",") {
+				t.Errorf("Expected tags %v, got %v", tc.wantTags, vocab.Tags)
+			}
+		})
+	}
+}
+
+// TestUploadDocumentStream tests the Server-Sent Events sent while an
+// upload is processed, and that form errors are still plain JSON
+func TestUploadDocumentStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		aiErr      error
+		wantEvents []string
+	}{
+		{"Success", nil, []string{"progress", "progress", "progress", "done"}},
+		{"AI failure", &ai.AIError{Message: "overloaded", StatusCode: 529}, []string{"progress", "progress", "error"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+			handler.Processor.AI.(*MockAIExtractor).Err = tc.aiErr
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "notes.txt")
+			part.Write([]byte("Hola y adiós"))
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/api/upload/stream", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			handler.UploadDocumentStream(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Expected an event stream, got %q", ct)
+			}
+			if !w.Flushed {
+				t.Error("Expected events to be flushed")
+			}
+
+			var events []string
+			var last string
+			for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+				lines := strings.Split(block, "\n")
+				if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+					t.Fatalf("Malformed event %q", block)
+				}
+				events = append(events, strings.TrimPrefix(lines[0], "event: "))
+				last = strings.TrimPrefix(lines[1], "data: ")
+			}
+			if !slices.Equal(events, tc.wantEvents) {
+				t.Fatalf("Expected events %v, got %v", tc.wantEvents, events)
+			}
+
+			if tc.aiErr == nil {
+				var result core.ProcessingResult
+				if err := json.Unmarshal([]byte(last), &result); err != nil || result.NewVocabulary != 2 {
+					t.Errorf("Expected a result with 2 new items, got %s (%v)", last, err)
+				}
+				return
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal([]byte(last), &resp); err != nil || resp.Code != "ai_error" {
+				t.Errorf("Expected an ai_error body, got %s (%v)", last, err)
+			}
+		})
+	}
+
+	// Form errors are answered before the stream starts
+	handler := setupTestHandler(t)
+	req := httptest.NewRequest("POST", "/api/upload/stream", strings.NewReader("not a form"))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	w := httptest.NewRecorder()
+	handler.UploadDocumentStream(w, req)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON 400, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+// TestUploadHandlerMaxFileSize tests that uploads follow a changed file size
+// limit, including bodies too large to parse as a form
+func TestUploadHandlerMaxFileSize(t *testing.T) {
+	t.Cleanup(func() { parser.SetMaxFileSize(parser.DefaultMaxFileSize) })
+	parser.SetMaxFileSize(1024)
+
+	for _, size := range []int{2048, uploadFormOverhead + 4096} {
+		handler := setupTestHandler(t)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "notes.txt")
+		part.Write(bytes.Repeat([]byte("hola "), size/5))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+
+		handler.UploadDocument(w, req)
+
+		var response ErrorResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if w.Code != http.StatusBadRequest || response.Code != "file_too_large" {
+			t.Errorf("%d bytes: expected 400 file_too_large, got %d %q", size, w.Code, response.Code)
+		}
+	}
+}
+
+// TestUploadHandlerInvalidUTF8 tests that a text file in another encoding is
+// rejected as a bad request
+func TestUploadHandlerInvalidUTF8(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "latin1.txt")
+	part.Write([]byte("caf\xe9 con leche"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.UploadDocument(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestUploadHandlerPasswordProtected tests that an encrypted Word document
+// is rejected with 422 and a code the frontend can recognize
+func TestUploadHandlerPasswordProtected(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// An OLE compound file holding an EncryptionInfo stream, which is how
+	// Word saves a DOCX with a password
+	content := []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	for _, c := range "EncryptionInfo" {
+		content = append(content, byte(c), 0)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "lesson.docx")
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.UploadDocument(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Code != "password_protected" {
+		t.Errorf("Expected code password_protected, got %q", resp.Code)
+	}
+}
+
+// TestUploadHandlerNewItemIDs tests that the upload response lists the IDs
+// of the added words only, as an empty array once everything is a duplicate
+func TestUploadHandlerNewItemIDs(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "test1", Language: "Spanish"})
+
+	upload := func() map[string]json.RawMessage {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "notes.txt")
+		part.Write([]byte("test1 test2"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		handler.UploadDocument(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return fields
+	}
+
+	added, err := handler.Processor.DB.GetByText("test1")
+	if err != nil {
+		t.Fatalf("Failed to get existing word: %v", err)
+	}
+
+	first := upload()
+	var ids []int
+	if err := json.Unmarshal(first["NewItemIDs"], &ids); err != nil {
+		t.Fatalf("Failed to decode NewItemIDs %s: %v", first["NewItemIDs"], err)
+	}
+	stored, err := handler.Processor.DB.GetByText("test2")
+	if err != nil {
+		t.Fatalf("New word not stored: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != stored.ID || ids[0] == added.ID {
+		t.Errorf("Expected NewItemIDs [%d], got %v", stored.ID, ids)
+	}
+
+	if second := upload(); string(second["NewItemIDs"]) != "[]" {
+		t.Errorf("Expected empty NewItemIDs array, got %s", second["NewItemIDs"])
+	}
+}
+
+// TestUploadHandlerDuplicatePolicy tests each on_duplicate policy against
+// words that already exist
+func TestUploadHandlerDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		policy          string
+		status          int
+		wantOccurrences int
+		wantNewWord     bool
+	}{
+		{"skip", http.StatusOK, 1, true},
+		{"error", http.StatusConflict, 1, false},
+		{"count", http.StatusOK, 2, true},
+		{"bogus", http.StatusBadRequest, 1, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			handler := setupTestHandler(t)
+			handler.Processor.DB.Insert(&db.Vocabulary{Text: "test1", Language: "Spanish"})
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			writer.WriteField("on_duplicate", tc.policy)
+			part, _ := writer.CreateFormFile("file", "notes.txt")
+			part.Write([]byte("test1 test2"))
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/api/upload", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			handler.UploadDocument(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+
+			existing, err := handler.Processor.DB.GetByText("test1")
+			if err != nil {
+				t.Fatalf("Failed to get existing word: %v", err)
+			}
+			if existing.Occurrences != tc.wantOccurrences {
+				t.Errorf("Expected %d occurrences, got %d", tc.wantOccurrences, existing.Occurrences)
+			}
+
+			added, _ := handler.Processor.DB.ExistsText("test2")
+			if added != tc.wantNewWord {
+				t.Errorf("Expected new word stored = %v, got %v", tc.wantNewWord, added)
+			}
+		})
+	}
+}
+
+// TestUploadHandlerForce tests that a repeated upload is served from the
+// extraction cache unless force=true is sent
+func TestUploadHandlerForce(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name          string
+		force         string
+		wantStatus    int
+		wantFromCache bool
+	}{
+		{"First upload", "", http.StatusOK, false},
+		{"Repeated upload", "", http.StatusOK, true},
+		{"Forced upload", "true", http.StatusOK, false},
+		{"Invalid force", "maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			if tc.force != "" {
+				writer.WriteField("force", tc.force)
+			}
+			part, _ := writer.CreateFormFile("file", "notes.txt")
+			part.Write([]byte("test1 test2"))
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/api/upload", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			handler.UploadDocument(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var result core.ProcessingResult
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result.FromCache != tc.wantFromCache {
+				t.Errorf("Expected FromCache %v, got %v", tc.wantFromCache, result.FromCache)
+			}
+			if result.TextHash == "" {
+				t.Error("Expected the text hash in the result")
+			}
+		})
+	}
+}
+
+// TestUploadURLHandler tests POST /api/upload-url
+func TestUploadURLHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Hola y adiós"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	handler.Processor.URLAllowlist = []string{serverURL.Hostname()}
+
+	body := fmt.Sprintf(`{"url":%q,"language":"French"}`, server.URL+"/article")
+	req := httptest.NewRequest("POST", "/api/upload-url", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	handler.UploadURL(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.StatusCode)
+	}
+
+	var result core.ProcessingResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if result.NewVocabulary != 2 {
+		t.Errorf("Expected 2 new items, got %d", result.NewVocabulary)
+	}
+	if result.Language != "French" {
+		t.Errorf("Expected language override 'French', got %s", result.Language)
+	}
+}
+
+// TestUploadURLHandlerRejectsInternal tests that SSRF targets return 400
+func TestUploadURLHandlerRejectsInternal(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	for _, target := range []string{"http://127.0.0.1/notes.txt", "http://169.254.169.254/latest/meta-data/"} {
+		body := fmt.Sprintf(`{"url":%q}`, target)
+		req := httptest.NewRequest("POST", "/api/upload-url", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		handler.UploadURL(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", target, w.Code)
+		}
+	}
+}
+
+// TestExportHandler tests POST /api/export
+func TestExportHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// Add test vocabulary
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "export_test", Language: "Spanish"})
+
+	req := httptest.NewRequest("POST", "/api/export", nil)
+	w := httptest.NewRecorder()
+
+	handler.ExportVocabulary(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", res.StatusCode)
+	}
+
+	// Check content type
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		t.Errorf("Expected application/json, got %s", contentType)
+	}
+
+	var export db.Export
+	if err := json.NewDecoder(res.Body).Decode(&export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if export.Version != db.ExportVersion || len(export.Items) != 1 {
+		t.Errorf("Unexpected export: version %d with %d items", export.Version, len(export.Items))
+	}
+}
+
+// TestExportHandlerLanguage tests POST /api/export?language=
+func TestExportHandlerLanguage(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "bonjour", Language: "French"})
+
+	tests := []struct {
+		query    string
+		filename string
+		want     []string
+	}{
+		{"?language=Spanish", "vocabulary_export_spanish.json", []string{"hola"}},
+		{"?language=german", "vocabulary_export_german.json", []string{}},
+		{"?language=Brazilian%20Portuguese", "vocabulary_export_brazilian-portuguese.json", []string{}},
+		{"?language=%E6%97%A5%E6%9C%AC%E8%AA%9E", "vocabulary_export.json", []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/export"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ExportVocabulary(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Disposition"); got != "attachment; filename="+tc.filename {
+				t.Errorf("Expected filename %s, got %q", tc.filename, got)
+			}
+
+			var export db.Export
+			if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+				t.Fatalf("Failed to decode export: %v", err)
+			}
+			if export.Items == nil {
+				t.Fatal("Expected an items array, got null")
+			}
+			var got []string
+			for _, item := range export.Items {
+				got = append(got, item.Text)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	for _, query := range []string{"?language=Spanish&format=csv", "?language=Spanish&incremental=true"} {
+		req := httptest.NewRequest("POST", "/api/export"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ExportVocabulary(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestExportHandlerCSV tests POST /api/export?format=csv
+func TestExportHandlerCSV(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello, hi"})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantHeader []string
+	}{
+		{"Default columns", "?format=csv", http.StatusOK, []string{"id", "text", "language", "translation", "created_at"}},
+		{"Selected fields", "?format=csv&fields=text,translation", http.StatusOK, []string{"text", "translation"}},
+		{"Incremental", "?format=csv&incremental=true", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/export"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ExportVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+				t.Errorf("Expected text/csv, got %s", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "vocabulary_export.csv") {
+				t.Errorf("Expected a .csv download filename, got %s", cd)
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Response is not valid CSV: %v", err)
+			}
+			if len(records) != 2 || !slices.Equal(records[0], tc.wantHeader) {
+				t.Fatalf("Expected header %v and one row, got %q", tc.wantHeader, records)
+			}
+			if !slices.Contains(records[1], "hello, hi") {
+				t.Errorf("Expected the translation in the row, got %q", records[1])
+			}
+		})
+	}
+}
+
+// TestExportHandlerHTML tests POST /api/export?format=html
+func TestExportHandlerHTML(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "<b>hola</b>", Language: "Spanish", Translation: "hello"})
+
+	req := httptest.NewRequest("POST", "/api/export?format=html", nil)
+	w := httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html, got %s", ct)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "&lt;b&gt;hola&lt;/b&gt;") || strings.Contains(body, "<b>hola") {
+		t.Errorf("Expected the word to be escaped, got:\n%s", body)
+	}
+
+	for _, query := range []string{"?format=html&fields=text", "?format=html&incremental=true"} {
+		req := httptest.NewRequest("POST", "/api/export"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ExportVocabulary(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestExportHandlerAnki tests POST /api/export?format=anki
+func TestExportHandlerAnki(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello"})
+
+	req := httptest.NewRequest("POST", "/api/export?format=anki", nil)
+	w := httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "filename=vocabulary.txt") {
+		t.Errorf("Expected vocabulary.txt download, got %s", cd)
+	}
+	if w.Body.String() != "hola\thello\n" {
+		t.Errorf("Unexpected body %q", w.Body.String())
+	}
+
+	for _, query := range []string{"?format=anki&fields=text", "?format=anki&incremental=true"} {
+		req := httptest.NewRequest("POST", "/api/export"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ExportVocabulary(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestExportHandlerFields tests POST /api/export?fields=...
+func TestExportHandlerFields(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello"})
+
+	req := httptest.NewRequest("POST", "/api/export?fields=text,translation", nil)
+	w := httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var export struct {
+		Version int                      `json:"version"`
+		Items   []map[string]interface{} `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if export.Version != db.ExportVersion || len(export.Items) != 1 {
+		t.Fatalf("Unexpected export: version %d with %d items", export.Version, len(export.Items))
+	}
+
+	item := export.Items[0]
+	if len(item) != 2 || item["text"] != "hola" || item["translation"] != "hello" {
+		t.Errorf("Expected only text and translation, got %v", item)
+	}
+
+	req = httptest.NewRequest("POST", "/api/export?fields=text,password", nil)
+	w = httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown field, got %d", w.Code)
+	}
+}
+
+// TestExportHandlerNDJSON tests POST /api/export?format=ndjson
+func TestExportHandlerNDJSON(t *testing.T) {
+	handler := setupTestHandler(t)
+	for _, text := range []string{"hola", "adiós", "gracias"} {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: text, Language: "Spanish"})
+	}
+
+	req := httptest.NewRequest("POST", "/api/export?format=ndjson", nil)
+	w := httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected Content-Type application/x-ndjson, got %s", contentType)
+	}
+
+	texts := make(map[string]bool)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var item db.Vocabulary
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+		}
+		if item.ID == 0 || item.Language != "Spanish" {
+			t.Errorf("Unexpected item: %+v", item)
+		}
+		texts[item.Text] = true
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if len(texts) != 3 || !texts["hola"] || !texts["adiós"] || !texts["gracias"] {
+		t.Errorf("Expected one line per item, got %v", texts)
+	}
+
+	req = httptest.NewRequest("POST", "/api/export?format=xml", nil)
+	w = httptest.NewRecorder()
+	handler.ExportVocabulary(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown format, got %d", w.Code)
+	}
+}
+
+// TestExportHandlerIncremental tests POST /api/export?incremental=true
+func TestExportHandlerIncremental(t *testing.T) {
+	handler := setupTestHandler(t)
+	clock := &fakeClock{time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)}
+	handler.Processor.DB.SetClock(clock)
+
+	insert := func(text string) {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: text, Language: "Spanish"})
+		clock.t = clock.t.Add(time.Second)
+	}
+	export := func() []string {
+		req := httptest.NewRequest("POST", "/api/export?incremental=true", nil)
+		w := httptest.NewRecorder()
+		handler.ExportVocabulary(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body db.Export
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode export: %v", err)
+		}
+		var texts []string
+		for _, item := range body.Items {
+			texts = append(texts, item.Text)
+		}
+		return texts
+	}
+
+	insert("hola")
+	insert("adiós")
+	if got := export(); len(got) != 2 {
+		t.Fatalf("Expected the first export to contain both rows, got %v", got)
+	}
+
+	insert("gracias")
+	if got := export(); len(got) != 1 || got[0] != "gracias" {
+		t.Errorf("Expected only the new row, got %v", got)
+	}
+	if got := export(); len(got) != 0 {
+		t.Errorf("Expected nothing new, got %v", got)
+	}
+}
+
+// TestImportVocabularyHandler tests POST /api/import with raw and multipart
+// bodies, invalid records and malformed JSON
+func TestImportVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+
+	export := `{"version": 2, "items": [
+		{"text": "hola", "language": "Spanish"},
+		{"text": "perro", "language": "Spanish", "tags": ["animals"]},
+		{"text": "", "language": "Spanish"},
+		{"text": "gato"}
+	]}`
+
+	multipartBody := func(content string) (*bytes.Buffer, string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "vocabulary_export.json")
+		part.Write([]byte(content))
+		writer.Close()
+		return body, writer.FormDataContentType()
+	}
+
+	tests := []struct {
+		name         string
+		body         func() (io.Reader, string)
+		wantStatus   int
+		wantImported int
+		wantSkipped  int
+	}{
+		{"Raw body", func() (io.Reader, string) {
+			return strings.NewReader(export), "application/json"
+		}, http.StatusOK, 1, 3},
+		{"Multipart file", func() (io.Reader, string) {
+			return multipartBody(`[{"text": "casa", "language": "Spanish"}]`)
+		}, http.StatusOK, 1, 0},
+		{"Malformed JSON", func() (io.Reader, string) {
+			return strings.NewReader(`[{"text": "mesa", "language": "Spanish"}, {"text": `), "application/json"
+		}, http.StatusBadRequest, 0, 0},
+		{"Unsupported version", func() (io.Reader, string) {
+			return strings.NewReader(`{"version": 99, "items": []}`), "application/json"
+		}, http.StatusBadRequest, 0, 0},
+		{"Multipart without file", func() (io.Reader, string) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			writer.WriteField("language", "Spanish")
+			writer.Close()
+			return body, writer.FormDataContentType()
+		}, http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType := tc.body()
+			req := httptest.NewRequest("POST", "/api/import", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			handler.ImportVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var resp ImportResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Imported != tc.wantImported || resp.Skipped != tc.wantSkipped {
+				t.Errorf("Expected %d imported and %d skipped, got %+v", tc.wantImported, tc.wantSkipped, resp)
+			}
+		})
+	}
+
+	// The malformed import wrote nothing
+	if _, err := handler.Processor.DB.GetByText("mesa"); err == nil {
+		t.Error("Expected malformed import to be rolled back")
+	}
+	perro, err := handler.Processor.DB.GetByText("perro")
+	if err != nil {
+		t.Fatalf("Expected perro to be imported: %v", err)
+	}
+	if len(perro.Tags) != 1 || perro.Tags[0] != "animals" {
+		t.Errorf("Expected perro to keep its tag, got %v", perro.Tags)
+	}
+}
+
+// TestDownloadBackupHandler tests GET /api/backup/download
+func TestDownloadBackupHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	for _, text := range []string{"hola", "adiós"} {
+		handler.Processor.DB.Insert(&db.Vocabulary{Text: text, Language: "Spanish"})
+	}
+
+	req := httptest.NewRequest("GET", "/api/backup/download", nil)
+	w := httptest.NewRecorder()
+
+	handler.DownloadBackup(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != "attachment; filename=parsely-backup.db" {
+		t.Errorf("Unexpected Content-Disposition: %s", disposition)
+	}
+
+	backupPath := filepath.Join(t.TempDir(), "downloaded.db")
+	if err := os.WriteFile(backupPath, w.Body.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to save backup: %v", err)
+	}
+
+	backup, err := db.NewDatabase(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to open downloaded backup: %v", err)
+	}
+	defer backup.Close()
+
+	original, _ := handler.Processor.DB.List()
+	restored, err := backup.List()
+	if err != nil {
+		t.Fatalf("Failed to list backup: %v", err)
+	}
+	if len(restored) != len(original) {
+		t.Fatalf("Expected %d rows in backup, got %d", len(original), len(restored))
+	}
+	for i := range original {
+		if restored[i].Text != original[i].Text || restored[i].Language != original[i].Language {
+			t.Errorf("Row %d differs: %+v vs %+v", i, restored[i], original[i])
+		}
+	}
+}
+
+// TestGetStatsHandler tests GET /api/stats
+func TestGetStatsHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	clock := &fakeClock{t: time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)}
+	handler.Processor.DB.SetClock(clock)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "bonjour", Language: "French"})
+	clock.t = clock.t.Add(time.Hour)
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "adiós", Language: "Spanish"})
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats struct {
+		Total            int                  `json:"total_vocabulary"`
+		Untranslated     int                  `json:"untranslated"`
+		ByLanguage       map[string]int       `json:"by_language"`
+		LatestByLanguage map[string]time.Time `json:"latest_by_language"`
+		ByDay            map[string]int       `json:"by_day"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Total != 3 || stats.Untranslated != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(stats.ByLanguage) != 2 || stats.ByLanguage["Spanish"] != 2 || stats.ByLanguage["French"] != 1 {
+		t.Errorf("Unexpected by_language: %v", stats.ByLanguage)
+	}
+	if !stats.LatestByLanguage["Spanish"].Equal(clock.t) || !stats.LatestByLanguage["French"].Equal(clock.t.Add(-time.Hour)) {
+		t.Errorf("Unexpected latest_by_language: %v", stats.LatestByLanguage)
+	}
+	if len(stats.ByDay) != db.CountByDayWindow || stats.ByDay["2025-06-01"] != 3 || stats.ByDay["2025-05-31"] != 0 {
+		t.Errorf("Unexpected by_day: %v", stats.ByDay)
+	}
+}
+
+// TestRebuildDerivedHandler tests POST /api/maintenance/rebuild
+func TestRebuildDerivedHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "Hola", Language: "Spanish"})
+
+	req := httptest.NewRequest("POST", "/api/maintenance/rebuild", nil)
+	w := httptest.NewRecorder()
+
+	handler.RebuildDerived(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+// TestTagVocabularyHandler tests POST /api/vocabulary/tag
+func TestTagVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	manzana, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "manzana", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "pan", Language: "Spanish"})
+	perro, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "perro", Language: "Spanish"})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantTagged int
+	}{
+		{"By IDs", fmt.Sprintf(`{"ids":[%d],"tag":"food"}`, manzana), http.StatusOK, 1},
+		{"By query", `{"query":"pan","tag":"food"}`, http.StatusOK, 1},
+		{"Missing tag", `{"query":"pan"}`, http.StatusBadRequest, 0},
+		{"Both selectors", fmt.Sprintf(`{"ids":[%d],"query":"pan","tag":"food"}`, manzana), http.StatusBadRequest, 0},
+		{"No selector", `{"tag":"food"}`, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/vocabulary/tag", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			handler.TagVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var response TagResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Tagged != tc.wantTagged {
+				t.Errorf("Expected %d tagged, got %d", tc.wantTagged, response.Tagged)
+			}
+		})
+	}
+
+	if tags, _ := handler.Processor.DB.Tags(perro); len(tags) != 0 {
+		t.Errorf("Expected perro to stay untagged, got %v", tags)
+	}
+}
+
+// TestAddVocabularyHandler tests POST /api/vocabulary
+func TestAddVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantText     string
+		wantLanguage string
+	}{
+		{"Default language", `{"text":"  sobremesa "}`, http.StatusCreated, "sobremesa", "Spanish"},
+		{"Explicit language", `{"text":"bonjour","language":"French"}`, http.StatusCreated, "bonjour", "French"},
+		{"Duplicate differing in case", `{"text":"Sobremesa"}`, http.StatusConflict, "", ""},
+		{"Blank text", `{"text":"   "}`, http.StatusBadRequest, "", ""},
+		{"Missing text", `{"language":"Spanish"}`, http.StatusBadRequest, "", ""},
+		{"Invalid JSON", `{"text":`, http.StatusBadRequest, "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/vocabulary", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			handler.AddVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var vocab db.Vocabulary
+			if err := json.NewDecoder(w.Body).Decode(&vocab); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if vocab.ID == 0 || vocab.Text != tc.wantText || vocab.Language != tc.wantLanguage {
+				t.Errorf("Expected %q (%s) with an ID, got %+v", tc.wantText, tc.wantLanguage, vocab)
+			}
+			if _, err := handler.Processor.DB.Get(vocab.ID); err != nil {
+				t.Errorf("Expected the item to be stored: %v", err)
+			}
+		})
+	}
+}
+
+// TestBulkDeleteVocabularyHandler tests POST /api/vocabulary/bulk-delete
+func TestBulkDeleteVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	hola, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+	adios, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "adiós", Language: "Spanish"})
+	gracias, _ := handler.Processor.DB.Insert(&db.Vocabulary{Text: "gracias", Language: "Spanish"})
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantDeleted int
+	}{
+		{"Valid and missing IDs", fmt.Sprintf(`{"ids":[%d,9999,%d]}`, hola, adios), http.StatusOK, 2},
+		{"Already deleted", fmt.Sprintf(`{"ids":[%d]}`, hola), http.StatusOK, 0},
+		{"No IDs", `{"ids":[]}`, http.StatusBadRequest, 0},
+		{"Invalid JSON", `{"ids":`, http.StatusBadRequest, 0},
+		{"Non-numeric ID", `{"ids":["x"]}`, http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/vocabulary/bulk-delete", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			handler.BulkDeleteVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var response BulkDeleteResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Deleted != tc.wantDeleted {
+				t.Errorf("Expected %d deleted, got %d", tc.wantDeleted, response.Deleted)
+			}
+		})
+	}
+
+	if _, err := handler.Processor.DB.Get(gracias); err != nil {
+		t.Errorf("Expected gracias to be kept: %v", err)
+	}
+}
+
+// TestDeleteAllVocabularyHandler tests that clearing the vocabulary needs
+// confirm=true
+func TestDeleteAllVocabularyHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "adiós", Language: "Spanish"})
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantDeleted int
+	}{
+		{"Missing confirm", "", http.StatusBadRequest, 0},
+		{"Confirm false", "?confirm=false", http.StatusBadRequest, 0},
+		{"Invalid confirm", "?confirm=yes", http.StatusBadRequest, 0},
+		{"Confirmed", "?confirm=true", http.StatusOK, 2},
+		{"Already empty", "?confirm=true", http.StatusOK, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/vocabulary"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.DeleteAllVocabulary(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				if count, _ := handler.Processor.DB.Count(); count != 2 {
+					t.Errorf("Expected items to be kept, got %d", count)
+				}
+				return
+			}
+			var response BulkDeleteResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Deleted != tc.wantDeleted {
+				t.Errorf("Expected %d deleted, got %d", tc.wantDeleted, response.Deleted)
+			}
+		})
+	}
+}
+
+// TestRelabelLanguagesHandler tests POST /api/maintenance/relabel-languages
+func TestRelabelLanguagesHandler(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	handler.Processor.DB.Insert(&db.Vocabulary{Text: "manzanas", Language: "auto-detect", Context: "Compramos manzanas para la cena con mi hermana."})
+
+	req := httptest.NewRequest("POST", "/api/maintenance/relabel-languages", nil)
+	w := httptest.NewRecorder()
+
+	handler.RelabelLanguages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response RelabelLanguagesResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Updated != 1 {
+		t.Errorf("Expected 1 row updated, got %d", response.Updated)
+	}
+}
+
+// translatingMockAI translates words from a fixed table
+type translatingMockAI struct {
+	MockAIExtractor
+	Translations map[string]string
+	Err          error
+}
+
+func (m *translatingMockAI) TranslateWords(ctx context.Context, words []string, language string) (map[string]string, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	result := make(map[string]string)
+	for _, word := range words {
+		if translation, ok := m.Translations[word]; ok {
+			result[word] = translation
+		}
+	}
+	return result, nil
+}
+
+// TestTranslateMissingHandler tests POST /api/vocabulary/translate-missing
+func TestTranslateMissingHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		ai          ai.AIExtractor
+		wantStatus  int
+		wantUpdated int
+		wantCode    string
+	}{
+		{"Translates missing", &translatingMockAI{Translations: map[string]string{"hola": "hello", "adiós": "bye"}}, http.StatusOK, 1, ""},
+		{"Unsupported", &MockAIExtractor{}, http.StatusNotImplemented, 0, "translation_unsupported"},
+		{"Rate limited", &translatingMockAI{Err: &ai.AIError{Message: "slow down", StatusCode: http.StatusTooManyRequests}}, http.StatusTooManyRequests, 0, "ai_error"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+			handler.Processor.AI = tc.ai
+			handler.Processor.DB.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+			handler.Processor.DB.Insert(&db.Vocabulary{Text: "adiós", Language: "Spanish", Translation: "goodbye"})
+
+			req := httptest.NewRequest("POST", "/api/vocabulary/translate-missing", nil)
+			w := httptest.NewRecorder()
+			handler.TranslateMissing(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				var response ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Code != tc.wantCode {
+					t.Errorf("Expected code %q, got %q", tc.wantCode, response.Code)
+				}
+				return
+			}
+
+			var response TranslateMissingResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Updated != tc.wantUpdated {
+				t.Errorf("Expected %d updated, got %d", tc.wantUpdated, response.Updated)
+			}
+			if item, _ := handler.Processor.DB.GetByText("adiós"); item == nil || item.Translation != "goodbye" {
+				t.Errorf("Expected existing translation to be kept, got %+v", item)
+			}
+		})
+	}
+}
+
+// TestCORS tests which origins the CORS middleware echoes back
+func TestCORS(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    string
+	}{
+		{"Allowed origin", []string{"http://localhost:3000"}, "http://localhost:3000", "http://localhost:3000"},
+		{"Disallowed origin", []string{"http://localhost:3000"}, "https://evil.example", ""},
+		{"No allowlist", nil, "http://localhost:3000", ""},
+		{"Wildcard", []string{"*"}, "https://any.example", "*"},
+		{"Wildcard without Origin", []string{"*"}, "", "*"},
+		{"No Origin", []string{"http://localhost:3000"}, "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", "/api/vocabulary", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			w := httptest.NewRecorder()
+
+			corsHandler := CorsMiddleware(tc.origins, http.HandlerFunc(handler.ListVocabulary))
+			corsHandler.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.want {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tc.want, got)
+			}
+			if tc.want == "" && w.Header().Get("Access-Control-Allow-Methods") != "" {
+				t.Error("Expected no CORS headers for a disallowed origin")
+			}
+		})
+	}
+}
+
+// TestCORSAllowedOrigins tests that only configured origins get CORS headers
+func TestCORSAllowedOrigins(t *testing.T) {
+	corsHandler := CorsMiddleware([]string{"https://app.example"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example", true},
+		{"https://evil.example", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("OPTIONS", "/api/vocabulary", nil)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		corsHandler.ServeHTTP(w, req)
+
+		got := w.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && got != tt.origin {
+			t.Errorf("Expected %s to be allowed, got %q", tt.origin, got)
+		}
+		if !tt.allowed && got != "" {
+			t.Errorf("Expected %s to be rejected, got %q", tt.origin, got)
+		}
+	}
+}
+
+// TestInvalidJSON tests handling of invalid JSON
+func TestInvalidJSON(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/vocabulary", bytes.NewBufferString("invalid json"))
+	req.Header.Set("Content-Type", "application/json")
+
+	// This would be a handler that expects JSON
+	// For now, just verify the request setup
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Error("Content-Type not set correctly")
+	}
+}
+
+// TestRateLimiting tests that a client gets a 429 once it has used up its
+// uploads for the minute, while other clients are unaffected and tokens
+// come back over time
+func TestRateLimiting(t *testing.T) {
+	const perMinute = 3
+
+	handler := setupTestHandler(t)
+	limiter := NewRateLimiter(perMinute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	limited := RateLimitMiddleware(limiter, http.HandlerFunc(handler.UploadDocument))
+
+	upload := func(remoteAddr string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "notes.txt")
+		part.Write([]byte("Hola y adiós"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range perMinute {
+		if w := upload("192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+
+	// The port changes between connections, so only the IP may count
+	w := upload("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 for request %d, got %d: %s", perMinute+1, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Expected Retry-After 20, got %q", got)
+	}
+	var resp ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != "rate_limited" {
+		t.Errorf("Expected code rate_limited, got %q", resp.Code)
+	}
+
+	if w := upload("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to be unaffected, got %d", w.Code)
+	}
+
+	now = now.Add(20 * time.Second)
+	if w := upload("192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected a token to be back after 20s, got %d", w.Code)
+	}
+	if w := upload("192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected only one token to be back, got %d", w.Code)
+	}
+
+	// Idle clients are forgotten once their bucket would be full again
+	now = now.Add(2 * time.Minute)
+	upload("192.0.2.3:1234")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, have %d", len(limiter.buckets))
+	}
+}
+
+// TestLargeFileRejection tests that oversized files are rejected
+func TestLargeFileRejection(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// Create a large file
+	tmpDir := t.TempDir()
+	largeFile := filepath.Join(tmpDir, "large.pdf")
+	largeContent := make([]byte, 11*1024*1024) // 11MB
+	os.WriteFile(largeFile, largeContent, 0600)
+
+	// Create multipart form
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	file, _ := os.Open(largeFile)
+	defer file.Close()
+
+	part, _ := writer.CreateFormFile("file", "large.pdf")
+	io.Copy(part, file)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.UploadDocument(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	// Should reject large file
+	if res.StatusCode == http.StatusOK {
+		t.Error("Should reject file over size limit")
+	}
+}
+
+// TestBodyLimitMiddleware tests that oversized JSON bodies get a 413
+func TestBodyLimitMiddleware(t *testing.T) {
+	handler := setupTestHandler(t)
+	limited := BodyLimitMiddleware(64, http.HandlerFunc(handler.UploadURL))
+
+	oversized := fmt.Sprintf(`{"url":"https://example.com/%s"}`, strings.Repeat("a", 200))
+
+	tests := []struct {
+		name          string
+		contentLength int64
+	}{
+		{"Declared length", int64(len(oversized))},
+		{"Unknown length", -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/upload-url", strings.NewReader(oversized))
+			req.ContentLength = tc.contentLength
+			w := httptest.NewRecorder()
+
+			limited.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp ErrorResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Code != "request_too_large" {
+				t.Errorf("Expected code request_too_large, got %q", resp.Code)
+			}
+		})
+	}
+
+	// Small bodies pass through to the handler
+	req := httptest.NewRequest("POST", "/api/upload-url", strings.NewReader(`{"url":""}`))
+	w := httptest.NewRecorder()
+	limited.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected handler's 400 for a small body, got %d", w.Code)
+	}
+
+	// Upload routes are exempt with or without a trailing slash
+	reached := BodyLimitMiddleware(64, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, path := range []string{"/api/upload", "/api/upload/", "/api/upload/stream/", "/api/import/"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(oversized))
+		w := httptest.NewRecorder()
+		reached.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: expected the size limit to be skipped, got %d", path, w.Code)
+		}
+	}
+}
+
+// TestAuthMiddleware tests that API routes require the key in either header
+// while other paths stay open
+func TestAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	protected := AuthMiddleware("s3cret", next)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		status int
+	}{
+		{"No key", "/api/vocabulary", "", "", http.StatusUnauthorized},
+		{"API key header", "/api/vocabulary", "X-API-Key", "s3cret", http.StatusOK},
+		{"Bearer token", "/api/vocabulary/1", "Authorization", "Bearer s3cret", http.StatusOK},
+		{"Wrong key", "/api/vocabulary", "X-API-Key", "guess", http.StatusUnauthorized},
+		{"Key prefix", "/api/vocabulary", "X-API-Key", "s3c", http.StatusUnauthorized},
+		{"Other scheme", "/api/vocabulary", "Authorization", "Basic s3cret", http.StatusUnauthorized},
+		{"Empty bearer", "/api/vocabulary", "Authorization", "Bearer ", http.StatusUnauthorized},
+		{"Health", "/health", "", "", http.StatusOK},
+		{"UI page", "/", "", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			w := httptest.NewRecorder()
+
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+			if tc.status != http.StatusUnauthorized {
+				return
+			}
+
+			var resp ErrorResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Code != "unauthorized" {
+				t.Errorf("Expected code unauthorized, got %q", resp.Code)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+// TestTimeoutMiddleware tests that slow requests get a 503 unless their
+// response already started
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantCode   string
+		wantBody   string
+	}{
+		{
+			name: "Fast handler passes through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "kept")
+				respondJSON(w, http.StatusCreated, SuccessResponse{Message: "done"})
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+		},
+		{
+			name: "Slow handler times out",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				w.Header().Set("X-Test", "kept")
+				respondJSON(w, http.StatusOK, SuccessResponse{Message: "too late"})
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   "request_timeout",
+		},
+		{
+			name: "Started response is kept",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "kept")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("partial"))
+				<-r.Context().Done()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "partial",
+		},
+		{
+			name: "Streaming upload is not cut off",
+			path: "/api/upload/stream/",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "kept")
+				w.WriteHeader(http.StatusOK)
+				select {
+				case <-r.Context().Done():
+					w.Write([]byte("cancelled"))
+				case <-time.After(100 * time.Millisecond):
+					w.Write([]byte("finished"))
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "finished",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := tc.path
+			if path == "" {
+				path = "/api/stats"
+			}
+			req := httptest.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+
+			TimeoutMiddleware(20*time.Millisecond, tc.handler).ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantCode != "" {
+				var resp ErrorResponse
+				json.NewDecoder(w.Body).Decode(&resp)
+				if resp.Code != tc.wantCode {
+					t.Errorf("Expected code %q, got %q", tc.wantCode, resp.Code)
+				}
+				if w.Header().Get("X-Test") != "" {
+					t.Error("Expected headers set after the timeout to be dropped")
+				}
+				return
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) || w.Header().Get("X-Test") != "kept" {
+				t.Errorf("Expected %q with the handler's headers, got %q (%v)", tc.wantBody, w.Body.String(), w.Header())
+			}
+		})
+	}
+}
+
+// TestTimeoutMiddlewarePanic tests that a panic before the timeout still
+// reaches RecoverMiddleware
+func TestTimeoutMiddlewarePanic(t *testing.T) {
+	handler := RecoverMiddleware(TimeoutMiddleware(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+// TestRespondFileTooLarge tests the structured size error payload
+func TestRespondFileTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondFileTooLarge(w, &parser.FileTooLargeError{Size: 11534336, Limit: 10485760})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Error   string              `json:"error"`
+		Code    string              `json:"code"`
+		Details FileTooLargeDetails `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body.Error != "File too large: 11.0 MB (max 10.0 MB)" {
+		t.Errorf("Unexpected error message: %s", body.Error)
+	}
+	if body.Code != "file_too_large" {
+		t.Errorf("Expected code file_too_large, got %s", body.Code)
+	}
+	if body.Details.SizeBytes != 11534336 || body.Details.MaxBytes != 10485760 {
+		t.Errorf("Expected raw byte counts in details, got %+v", body.Details)
 	}
 }
 
@@ -260,6 +3002,9 @@ func setupTestHandler(t *testing.T) *Handler {
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
+	// Closing releases the shared in-memory database so tests don't see
+	// each other's rows
+	t.Cleanup(func() { database.Close() })
 
 	mockAI := &MockAIExtractor{
 		Vocabulary: []string{"test1", "test2"},
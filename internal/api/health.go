@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// HealthResponse is returned by GET /health.
+// AI is "unchecked" until CheckAI has run.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	AI      string `json:"ai"`
+	AIError string `json:"ai_error,omitempty"`
+}
+
+// CheckAI validates the AI provider configuration and records the outcome
+// for the health endpoint
+func (h *Handler) CheckAI(ctx context.Context) error {
+	err := h.Processor.AI.Validate(ctx)
+
+	h.healthMu.Lock()
+	defer h.healthMu.Unlock()
+	h.aiChecked = true
+	h.aiErr = err
+
+	return err
+}
+
+// Health handles GET /health.
+// The server reports "degraded" when the last AI check failed; it still
+// answers 200 since everything except processing keeps working.
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	h.healthMu.Lock()
+	checked, aiErr := h.aiChecked, h.aiErr
+	h.healthMu.Unlock()
+
+	resp := HealthResponse{Status: "ok", AI: "unchecked"}
+	switch {
+	case aiErr != nil:
+		resp.Status = "degraded"
+		resp.AI = "unhealthy"
+		resp.AIError = aiErr.Error()
+	case checked:
+		resp.AI = "healthy"
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// ReadinessResponse is returned by GET /health/ready. Status is "ready" or
+// "unavailable"; AI is "unchecked" unless the request asked for it.
+type ReadinessResponse struct {
+	Status        string `json:"status"`
+	Database      string `json:"database"`
+	DatabaseError string `json:"database_error,omitempty"`
+	AI            string `json:"ai"`
+	AIError       string `json:"ai_error,omitempty"`
+}
+
+// Ready handles GET /health/ready, a readiness probe. It answers 200 once
+// the database responds to a ping and 503 naming the failed component
+// otherwise. With ?ai=true the AI provider is validated too, which also
+// refreshes the status reported by /health. GET /health stays a cheap
+// liveness probe.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	checkAI := false
+	if raw := r.URL.Query().Get("ai"); raw != "" {
+		var err error
+		checkAI, err = strconv.ParseBool(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ai: must be true or false")
+			return
+		}
+	}
+
+	resp := ReadinessResponse{Status: "ready", Database: "healthy", AI: "unchecked"}
+	if err := h.Processor.DB.Ping(); err != nil {
+		resp.Status = "unavailable"
+		resp.Database = "unhealthy"
+		resp.DatabaseError = err.Error()
+	}
+	if checkAI {
+		resp.AI = "healthy"
+		if err := h.CheckAI(r.Context()); err != nil {
+			resp.Status = "unavailable"
+			resp.AI = "unhealthy"
+			resp.AIError = err.Error()
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	respondJSON(w, status, resp)
+}
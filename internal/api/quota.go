@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QuotaExceededDetails is the Details payload for the "quota_exceeded" code.
+type QuotaExceededDetails struct {
+	Limit   int       `json:"limit"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// checkUploadQuota counts an upload against the client's daily quota and
+// reports whether it may proceed, writing a 429 response when it may not.
+// The check is skipped when DailyUploadQuota is zero, and uploads are let
+// through if the quota cannot be read. The returned release gives the upload
+// back; callers run it when the upload fails, so only successful uploads
+// count.
+func (h *Handler) checkUploadQuota(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	release = func() {}
+	if h.DailyUploadQuota <= 0 {
+		return release, true
+	}
+
+	client := clientIP(r)
+	usage, allowed, err := h.Processor.DB.ConsumeQuota(client, h.DailyUploadQuota)
+	if err != nil {
+		log.Printf("failed to check upload quota: %v", err)
+		return release, true
+	}
+	if allowed {
+		return func() {
+			if err := h.Processor.DB.ReleaseQuota(client, usage); err != nil {
+				log.Printf("%v", err)
+			}
+		}, true
+	}
+
+	retryAfter := int(usage.RetryAfter.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	respondJSON(w, http.StatusTooManyRequests, ErrorResponse{
+		Error:   fmt.Sprintf("Daily upload quota of %d exceeded; resets at %s", usage.Limit, usage.ResetAt.Format(time.RFC3339)),
+		Code:    "quota_exceeded",
+		Details: QuotaExceededDetails{Limit: usage.Limit, ResetAt: usage.ResetAt},
+	})
+	return release, false
+}
+
+// clientIP returns the host part of the request's remote address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket per client IP. Each client may make up to
+// perMinute requests at once, and regains one request every minute/perMinute.
+type RateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	interval  time.Duration // time to regain one token
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// tokenBucket holds the tokens a client had left at last
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perMinute requests per client
+// IP per minute. perMinute must be positive.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	return &RateLimiter{
+		perMinute: perMinute,
+		interval:  time.Minute / time.Duration(perMinute),
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token for client. When none is left it returns false and
+// how long the client has to wait for the next one.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.perMinute), last: now}
+		l.buckets[client] = b
+	}
+
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(float64(l.perMinute), b.tokens+float64(elapsed)/float64(l.interval))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(l.interval))
+}
+
+// sweep drops the buckets of clients idle for over a minute, which would be
+// full again anyway, so the map does not grow with every IP ever seen
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for client, b := range l.buckets {
+		if now.Sub(b.last) > time.Minute {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// RateLimitMiddleware rejects requests from clients that have used up their
+// tokens in limiter with a 429 under the "rate_limited" code and a
+// Retry-After header in whole seconds.
+func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, wait := limiter.Allow(clientIP(r))
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			respondJSON(w, http.StatusTooManyRequests, ErrorResponse{
+				Error: fmt.Sprintf("Rate limit of %d requests per minute exceeded", limiter.perMinute),
+				Code:  "rate_limited",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader carries the ID that ties a request to its log lines. A
+// client-supplied value is kept so IDs can be traced across services.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// randRead fills request IDs; tests replace it to simulate a failing source
+var randRead = rand.Read
+
+// requestIDCounter numbers the fallback IDs used when randRead fails
+var requestIDCounter atomic.Uint64
+
+// RequestIDMiddleware assigns every request an ID, echoes it in the
+// response header and stores it in the request context.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns the request ID set by RequestIDMiddleware, or
+// "" outside of it
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random 16-byte hex ID. If no random bytes can be
+// read it falls back to the current time and a counter, which are still
+// unique within the process.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := randRead(b); err != nil {
+		return fmt.Sprintf("%x-%x", time.Now().UnixNano(), requestIDCounter.Add(1))
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts short IDs of visible ASCII characters, so a client
+// cannot inject newlines or control characters into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
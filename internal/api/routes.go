@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// routeMethods are the methods probed when building an Allow header
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// RouteMiddleware sits in front of mux. It strips trailing slashes so
+// "/api/vocabulary/" reaches the "/api/vocabulary" route, and answers a known
+// path requested with the wrong method with a JSON 405 and an Allow header
+// listing the methods the path does accept.
+func RouteMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trimmed := strings.TrimRight(r.URL.Path, "/"); trimmed != "" && trimmed != r.URL.Path {
+			r.URL.Path = trimmed
+			r.URL.RawPath = ""
+		}
+
+		if _, pattern := mux.Handler(r); pattern == "" {
+			if allowed := allowedMethods(mux, r); len(allowed) > 0 {
+				w.Header().Set("Allow", strings.Join(allowed, ", "))
+				respondError(w, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed; use %s", r.Method, strings.Join(allowed, ", ")))
+				return
+			}
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// allowedMethods returns the methods for which mux has a route matching the
+// request's path
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/parsely/parsely/internal/core"
+)
+
+// UploadDocumentStream handles POST /api/upload/stream. It takes the same
+// form as POST /api/upload but answers with Server-Sent Events: a progress
+// event carrying a core.Progress as each stage starts, then either a done
+// event carrying the ProcessingResult or an error event carrying an
+// ErrorResponse. Problems with the form itself are reported as plain JSON
+// errors before the stream starts. When the client disconnects, extraction
+// stops before the next chunk.
+func (h *Handler) UploadDocumentStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	upload, ok := h.prepareUpload(w, r)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stop reverse proxies such as nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := &eventWriter{w: w, flusher: flusher}
+	processor := upload.processor.WithProgress(func(progress core.Progress) {
+		events.send("progress", progress)
+	})
+
+	result, err := processor.ProcessReader(r.Context(), upload.file, upload.header.Filename, upload.header.Size)
+	if err != nil {
+		upload.releaseQuota()
+	}
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		// The client is gone, so there is nobody to tell
+		return
+	}
+	if err != nil {
+		_, body := h.processErrorResponse(r, upload.header.Filename, err)
+		events.send("error", body)
+		return
+	}
+
+	events.send("done", result)
+}
+
+// eventWriter writes Server-Sent Events, flushing after each one so the
+// client sees it straight away
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send writes one event with data encoded as JSON. Write errors mean the
+// client has gone away and are ignored; the request context tells the
+// handler to stop.
+func (e *eventWriter) send(event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		payload, _ = json.Marshal(ErrorResponse{Error: fmt.Sprintf("Failed to encode %s event: %v", event, err)})
+		event = "error"
+	}
+
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload)
+	e.flusher.Flush()
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed ui/index.html
+var uiFiles embed.FS
+
+// RegisterUI serves the embedded web front-end at the site root. The page
+// only talks to the JSON API, so it can be left out without affecting it.
+func RegisterUI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", ServeUI)
+}
+
+// ServeUI handles GET / with the embedded single-page front-end.
+func ServeUI(w http.ResponseWriter, r *http.Request) {
+	page, err := uiFiles.ReadFile("ui/index.html")
+	if err != nil {
+		http.Error(w, "UI not available", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/db"
+	"github.com/parsely/parsely/internal/parser"
+)
+
+// Supported AI providers
+const (
+	ProviderAnthropic = "anthropic"
+
+	// ProviderOffline runs without an AI provider: stored vocabulary can be
+	// browsed and exported, but documents cannot be processed
+	ProviderOffline = "offline"
+)
+
+// Defaults for settings that are not required
+const (
+	DefaultDBPath       = "parsely.db"
+	DefaultLanguage     = "auto-detect"
+	DefaultPort         = "8080"
+	DefaultProvider     = ProviderAnthropic
+	DefaultMaxBodyBytes = 1 << 20
+	DefaultBackupDir    = "backups"
+	DefaultBackupKeep   = 7
+
+	DefaultRequestTimeout = 2 * time.Minute
+)
+
+// Config holds the settings shared by the CLI and web server. Web-only
+// settings are ignored by the CLI.
+type Config struct {
+	DBPath   string // DATABASE_PATH
+	Language string // LANGUAGE
+	Port     string // PORT
+
+	DBBusyTimeout         time.Duration // DB_BUSY_TIMEOUT, how long a write waits for a locked database
+	WALCheckpointInterval time.Duration // WAL_CHECKPOINT_INTERVAL, periodic checkpoints are off when unset
+
+	Provider        string // PROVIDER: anthropic or offline
+	AnthropicAPIKey string // ANTHROPIC_API_KEY, required for anthropic
+
+	ExtractContext  bool   // EXTRACT_CONTEXT
+	QualityFilter   bool   // QUALITY_FILTER, on by default
+	StopWords       bool   // STOP_WORDS, implied by STOP_WORDS_FILE
+	StopWordsFile   string // STOP_WORDS_FILE
+	AllowDuplicates bool   // ALLOW_DUPLICATES
+	PDFEngine       string // PDF_ENGINE: internal or pdftotext
+	Concurrency     int    // CONCURRENCY, files processed at once from a directory
+	MinWordLength   int    // MIN_WORD_LENGTH, shortest extracted item kept, in characters
+	MaxPhraseWords  int    // MAX_PHRASE_WORDS, longest extracted item kept, in words; 0 disables the limit
+
+	MaxFileSizeMB    int64 // MAX_FILE_SIZE_MB, largest document accepted
+	MaxBodyBytes     int64 // MAX_BODY_BYTES
+	MaxHeaderBytes   int64 // MAX_HEADER_BYTES
+	MinFreeDiskBytes int64 // MIN_FREE_DISK_BYTES, 0 disables the check
+	DailyUploadQuota int   // DAILY_UPLOAD_QUOTA, 0 disables the quota
+	UploadRateLimit  int   // UPLOAD_RATE_LIMIT, uploads per client IP per minute, 0 disables the limit
+
+	RequestTimeout time.Duration // REQUEST_TIMEOUT, longest a request may take
+
+	// CORSOrigins lists the origins allowed to call the API (CORS_ORIGINS,
+	// comma separated); "*" allows any origin and empty allows none
+	CORSOrigins []string
+
+	EnableBackupDownload bool          // ENABLE_BACKUP_DOWNLOAD
+	EnableUI             bool          // ENABLE_UI
+	BackupInterval       time.Duration // BACKUP_INTERVAL, 0 disables scheduled backups
+	BackupDir            string        // BACKUP_DIR
+	BackupKeep           int           // BACKUP_KEEP
+
+	WebhookURL    string // WEBHOOK_URL
+	WebhookSecret string // WEBHOOK_SECRET
+
+	// APISecret is the key API requests must present (API_SECRET); empty
+	// leaves the API unauthenticated
+	APISecret string
+}
+
+// Load reads the configuration from environment variables, applying
+// defaults and validating the result. All problems are reported together.
+func Load() (*Config, error) {
+	r := &reader{}
+
+	cfg := &Config{
+		DBPath:   r.str("DATABASE_PATH", DefaultDBPath),
+		Language: r.str("LANGUAGE", DefaultLanguage),
+		Port:     r.str("PORT", DefaultPort),
+
+		DBBusyTimeout:         r.duration("DB_BUSY_TIMEOUT", db.DefaultBusyTimeout),
+		WALCheckpointInterval: r.duration("WAL_CHECKPOINT_INTERVAL", 0),
+
+		Provider:        strings.ToLower(r.str("PROVIDER", DefaultProvider)),
+		AnthropicAPIKey: os.Getenv("ANTHROPIC_API_KEY"),
+
+		ExtractContext:  r.boolean("EXTRACT_CONTEXT", false),
+		QualityFilter:   r.boolean("QUALITY_FILTER", true),
+		StopWords:       r.boolean("STOP_WORDS", false),
+		StopWordsFile:   os.Getenv("STOP_WORDS_FILE"),
+		AllowDuplicates: r.boolean("ALLOW_DUPLICATES", false),
+		PDFEngine:       strings.ToLower(r.str("PDF_ENGINE", parser.PDFEngineInternal)),
+		Concurrency:     int(r.int64("CONCURRENCY", 1, 1)),
+		MinWordLength:   int(r.int64("MIN_WORD_LENGTH", ai.DefaultMinLength, 1)),
+		MaxPhraseWords:  int(r.int64("MAX_PHRASE_WORDS", ai.DefaultMaxWords, 0)),
+
+		MaxFileSizeMB:    r.int64("MAX_FILE_SIZE_MB", parser.DefaultMaxFileSize>>20, 1),
+		MaxBodyBytes:     r.int64("MAX_BODY_BYTES", DefaultMaxBodyBytes, 1),
+		MaxHeaderBytes:   r.int64("MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes, 1),
+		MinFreeDiskBytes: r.int64("MIN_FREE_DISK_BYTES", 0, 0),
+		DailyUploadQuota: int(r.int64("DAILY_UPLOAD_QUOTA", 0, 0)),
+		UploadRateLimit:  int(r.int64("UPLOAD_RATE_LIMIT", 0, 0)),
+
+		RequestTimeout: r.duration("REQUEST_TIMEOUT", DefaultRequestTimeout),
+
+		CORSOrigins: r.list("CORS_ORIGINS"),
+
+		EnableBackupDownload: r.boolean("ENABLE_BACKUP_DOWNLOAD", false),
+		EnableUI:             r.boolean("ENABLE_UI", false),
+		BackupInterval:       r.duration("BACKUP_INTERVAL", 0),
+		BackupDir:            r.str("BACKUP_DIR", DefaultBackupDir),
+		BackupKeep:           int(r.int64("BACKUP_KEEP", DefaultBackupKeep, 1)),
+
+		WebhookURL:    os.Getenv("WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+
+		APISecret: strings.TrimSpace(os.Getenv("API_SECRET")),
+	}
+	if cfg.StopWordsFile != "" {
+		cfg.StopWords = true
+	}
+
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		r.fail("PORT must be a number between 1 and 65535, got %q", cfg.Port)
+	}
+
+	switch cfg.Provider {
+	case ProviderAnthropic:
+		if strings.TrimSpace(cfg.AnthropicAPIKey) == "" {
+			r.fail("ANTHROPIC_API_KEY must be set for the %s provider", ProviderAnthropic)
+		}
+	case ProviderOffline:
+	default:
+		r.fail("PROVIDER must be %s or %s, got %q", ProviderAnthropic, ProviderOffline, cfg.Provider)
+	}
+
+	if cfg.PDFEngine != parser.PDFEngineInternal && cfg.PDFEngine != parser.PDFEnginePdftotext {
+		r.fail("PDF_ENGINE must be %s or %s, got %q", parser.PDFEngineInternal, parser.PDFEnginePdftotext, cfg.PDFEngine)
+	}
+
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// reader reads environment variables, collecting parse errors
+type reader struct {
+	errs []error
+}
+
+func (r *reader) fail(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Errorf(format, args...))
+}
+
+func (r *reader) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration: %w", errors.Join(r.errs...))
+}
+
+// str returns the trimmed variable, or def when it is unset or blank
+func (r *reader) str(name, def string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return def
+}
+
+func (r *reader) boolean(name string, def bool) bool {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		r.fail("%s must be true or false, got %q", name, raw)
+		return def
+	}
+	return value
+}
+
+func (r *reader) int64(name string, def, min int64) int64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < min {
+		r.fail("%s must be an integer of at least %d, got %q", name, min, raw)
+		return def
+	}
+	return value
+}
+
+func (r *reader) duration(name string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		r.fail("%s must be a positive duration such as 6h, got %q", name, raw)
+		return def
+	}
+	return value
+}
+
+// list splits a comma separated variable, dropping empty entries
+func (r *reader) list(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
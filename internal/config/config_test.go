@@ -0,0 +1,140 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/db"
+)
+
+// clearEnv unsets every variable Load reads so the host environment does
+// not leak into tests
+func clearEnv(t *testing.T) {
+	for _, name := range []string{
+		"DATABASE_PATH", "LANGUAGE", "PORT", "PROVIDER", "ANTHROPIC_API_KEY",
+		"EXTRACT_CONTEXT", "QUALITY_FILTER", "STOP_WORDS", "STOP_WORDS_FILE", "ALLOW_DUPLICATES", "PDF_ENGINE",
+		"CONCURRENCY", "MIN_WORD_LENGTH", "MAX_PHRASE_WORDS",
+		"MAX_FILE_SIZE_MB", "MAX_BODY_BYTES", "MAX_HEADER_BYTES", "MIN_FREE_DISK_BYTES", "DAILY_UPLOAD_QUOTA",
+		"UPLOAD_RATE_LIMIT",
+		"CORS_ORIGINS", "ENABLE_BACKUP_DOWNLOAD", "ENABLE_UI", "BACKUP_INTERVAL", "BACKUP_DIR",
+		"BACKUP_KEEP", "WEBHOOK_URL", "WEBHOOK_SECRET", "REQUEST_TIMEOUT",
+		"API_SECRET", "DB_BUSY_TIMEOUT", "WAL_CHECKPOINT_INTERVAL",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+// TestLoadValid tests defaults and explicit values
+func TestLoadValid(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	t.Setenv("PORT", "9090")
+	t.Setenv("QUALITY_FILTER", "false")
+	t.Setenv("STOP_WORDS_FILE", "extra.txt")
+	t.Setenv("CORS_ORIGINS", "https://a.example, https://b.example,")
+	t.Setenv("BACKUP_INTERVAL", "6h")
+	t.Setenv("MAX_FILE_SIZE_MB", "30")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.DBPath != DefaultDBPath || cfg.Language != DefaultLanguage || cfg.Provider != ProviderAnthropic {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+	if cfg.Port != "9090" || cfg.QualityFilter || !cfg.StopWords {
+		t.Errorf("Expected explicit values to apply, got %+v", cfg)
+	}
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "https://b.example" {
+		t.Errorf("Unexpected CORS origins: %v", cfg.CORSOrigins)
+	}
+	if cfg.BackupInterval != 6*time.Hour || cfg.BackupKeep != DefaultBackupKeep {
+		t.Errorf("Unexpected backup settings: %v, %d", cfg.BackupInterval, cfg.BackupKeep)
+	}
+	if cfg.MaxFileSizeMB != 30 {
+		t.Errorf("Expected a 30MB file size limit, got %d", cfg.MaxFileSizeMB)
+	}
+	if cfg.MinWordLength != ai.DefaultMinLength || cfg.MaxPhraseWords != ai.DefaultMaxWords {
+		t.Errorf("Expected the default length limits, got %d and %d", cfg.MinWordLength, cfg.MaxPhraseWords)
+	}
+	if cfg.RequestTimeout != DefaultRequestTimeout {
+		t.Errorf("Expected the default request timeout, got %v", cfg.RequestTimeout)
+	}
+
+	t.Setenv("REQUEST_TIMEOUT", "45s")
+	if cfg, err := Load(); err != nil || cfg.RequestTimeout != 45*time.Second {
+		t.Errorf("Expected a 45s request timeout, got %v, %v", cfg, err)
+	}
+
+	if cfg.DBBusyTimeout != db.DefaultBusyTimeout || cfg.WALCheckpointInterval != 0 {
+		t.Errorf("Expected the default database settings, got %v and %v", cfg.DBBusyTimeout, cfg.WALCheckpointInterval)
+	}
+	t.Setenv("DB_BUSY_TIMEOUT", "10s")
+	t.Setenv("WAL_CHECKPOINT_INTERVAL", "15m")
+	if cfg, err := Load(); err != nil || cfg.DBBusyTimeout != 10*time.Second || cfg.WALCheckpointInterval != 15*time.Minute {
+		t.Errorf("Expected a 10s busy timeout and 15m checkpoints, got %v, %v", cfg, err)
+	}
+
+	if cfg.APISecret != "" {
+		t.Errorf("Expected authentication to be off by default, got %q", cfg.APISecret)
+	}
+	t.Setenv("API_SECRET", " s3cret\n")
+	if cfg, err := Load(); err != nil || cfg.APISecret != "s3cret" {
+		t.Errorf("Expected a trimmed API secret, got %v, %v", cfg, err)
+	}
+}
+
+// TestLoadOfflineWithoutKey tests that the offline provider needs no key
+func TestLoadOfflineWithoutKey(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PROVIDER", "offline")
+
+	if _, err := Load(); err != nil {
+		t.Errorf("Expected offline provider to load without a key, got %v", err)
+	}
+}
+
+// TestLoadInvalid tests that invalid settings are rejected with a clear error
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"missing key", map[string]string{}, "ANTHROPIC_API_KEY"},
+		{"non-numeric port", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "PORT": "http"}, "PORT"},
+		{"port out of range", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "PORT": "70000"}, "PORT"},
+		{"unknown provider", map[string]string{"PROVIDER": "gpt"}, "PROVIDER"},
+		{"bad boolean", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "ENABLE_UI": "yes please"}, "ENABLE_UI"},
+		{"bad limit", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "MAX_BODY_BYTES": "0"}, "MAX_BODY_BYTES"},
+		{"unknown pdf engine", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "PDF_ENGINE": "mutool"}, "PDF_ENGINE"},
+		{"zero file size", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "MAX_FILE_SIZE_MB": "0"}, "MAX_FILE_SIZE_MB"},
+		{"zero timeout", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "REQUEST_TIMEOUT": "0s"}, "REQUEST_TIMEOUT"},
+		{"bad busy timeout", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "DB_BUSY_TIMEOUT": "5000"}, "DB_BUSY_TIMEOUT"},
+		{"zero checkpoint interval", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "WAL_CHECKPOINT_INTERVAL": "0"}, "WAL_CHECKPOINT_INTERVAL"},
+		{"zero concurrency", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "CONCURRENCY": "0"}, "CONCURRENCY"},
+		{"negative rate limit", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "UPLOAD_RATE_LIMIT": "-1"}, "UPLOAD_RATE_LIMIT"},
+		{"zero word length", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "MIN_WORD_LENGTH": "0"}, "MIN_WORD_LENGTH"},
+		{"negative phrase words", map[string]string{"ANTHROPIC_API_KEY": "sk-test", "MAX_PHRASE_WORDS": "-1"}, "MAX_PHRASE_WORDS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error mentioning %s, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/db"
+)
+
+// WithForceExtract returns a copy of the processor that always sends
+// documents to the AI, refreshing the extraction cache instead of reading it
+func (p *Processor) WithForceExtract() *Processor {
+	clone := *p
+	clone.ForceExtract = true
+	return &clone
+}
+
+// textHash returns the hex SHA-256 of parsed document text
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// extractionKey returns the cache key for text extracted with the
+// processor's current settings
+func (p *Processor) extractionKey(hash string) db.ExtractionKey {
+	_, contextual := p.AI.(ai.ContextExtractor)
+	var limits string
+	if limited, ok := p.AI.(ai.LimitedExtractor); ok {
+		l := limited.LengthLimits()
+		limits = fmt.Sprintf("min=%d max=%d", l.MinLength, l.MaxWords)
+	}
+	return db.ExtractionKey{
+		TextHash:    hash,
+		Language:    p.Language,
+		WithContext: p.ExtractContext && contextual,
+		Limits:      limits,
+	}
+}
+
+// cachedExtraction returns the vocabulary cached under key and the language
+// it was extracted in. The cache only saves AI calls, so a failed read is
+// logged and treated as a miss.
+func (p *Processor) cachedExtraction(key db.ExtractionKey) ([]ai.VocabularyItem, string, bool) {
+	if p.ForceExtract {
+		return nil, "", false
+	}
+
+	cached, err := p.DB.GetCachedExtraction(key)
+	if err != nil {
+		log.Printf("extraction cache lookup failed: %v", err)
+		return nil, "", false
+	}
+	if cached == nil {
+		return nil, "", false
+	}
+
+	var vocabulary []ai.VocabularyItem
+	if err := json.Unmarshal([]byte(cached.Items), &vocabulary); err != nil {
+		log.Printf("ignoring unreadable extraction cache entry %s: %v", key.TextHash, err)
+		return nil, "", false
+	}
+	return vocabulary, cached.Language, true
+}
+
+// cacheExtraction stores a complete extraction under key. Like the lookup,
+// a failure only costs a later AI call, so it is logged rather than
+// returned.
+func (p *Processor) cacheExtraction(key db.ExtractionKey, language string, vocabulary []ai.VocabularyItem) {
+	items, err := json.Marshal(vocabulary)
+	if err != nil {
+		log.Printf("failed to encode extraction for cache: %v", err)
+		return
+	}
+	if err := p.DB.CacheExtraction(key, db.CachedExtraction{Language: language, Items: string(items)}); err != nil {
+		log.Printf("extraction cache write failed: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// defaultChunkSize is the maximum number of characters sent to the AI per
+// request when Processor.ChunkSize is not set
+const defaultChunkSize = 12000
+
+// chunkText splits text into pieces of at most maxChars characters, breaking
+// on line boundaries where possible so sentences stay intact
+func chunkText(text string, maxChars int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+
+		if currentLen+lineLen > maxChars {
+			flush()
+		}
+
+		// A single line longer than the limit is split on rune boundaries
+		for lineLen > maxChars {
+			runes := []rune(line)
+			chunks = append(chunks, strings.TrimSpace(string(runes[:maxChars])))
+			line = string(runes[maxChars:])
+			lineLen -= maxChars
+		}
+
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return chunks
+}
+
+// mergeVocabulary appends items whose text is not already present,
+// preserving order. seen maps each text to its index in dst; an item that
+// is already present is counted in the Duplicates of the one kept.
+func mergeVocabulary(dst []ai.VocabularyItem, seen map[string]int, items []ai.VocabularyItem) []ai.VocabularyItem {
+	for _, item := range items {
+		if i, ok := seen[item.Text]; ok {
+			dst[i].Duplicates += 1 + item.Duplicates
+			continue
+		}
+		seen[item.Text] = len(dst)
+		dst = append(dst, item)
+	}
+	return dst
+}
+
+// inDocumentDuplicates returns how many extracted items were collapsed into
+// the items of vocabulary
+func inDocumentDuplicates(vocabulary []ai.VocabularyItem) int {
+	total := 0
+	for _, item := range vocabulary {
+		total += item.Duplicates
+	}
+	return total
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// TestChunkText tests splitting document text into AI-sized pieces
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		expected []string
+	}{
+		{"empty", "   ", 10, nil},
+		{"fits in one chunk", "hola mundo", 20, []string{"hola mundo"}},
+		{"no limit", "hola mundo", 0, []string{"hola mundo"}},
+		{"splits on lines", "uno dos\ntres cuatro\ncinco", 12, []string{"uno dos", "tres cuatro", "cinco"}},
+		{"long line split on runes", "áéíóúáéíóú", 4, []string{"áéíó", "úáéí", "óú"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := chunkText(tc.text, tc.maxChars)
+			if strings.Join(result, "|") != strings.Join(tc.expected, "|") || len(result) != len(tc.expected) {
+				t.Errorf("chunkText(%q, %d) = %q, expected %q", tc.text, tc.maxChars, result, tc.expected)
+			}
+		})
+	}
+}
+
+// TestChunkTextRespectsLimit tests that no chunk exceeds the size limit
+func TestChunkTextRespectsLimit(t *testing.T) {
+	text := strings.Repeat("una línea de texto en español\n", 200)
+
+	for _, chunk := range chunkText(text, 100) {
+		if n := utf8.RuneCountInString(chunk); n > 100 {
+			t.Errorf("Chunk has %d characters, expected at most 100", n)
+		}
+	}
+}
+
+// truncatingMockAI reports a truncated response for text longer than Limit
+// and otherwise returns the first word of the text
+type truncatingMockAI struct {
+	Limit int
+	calls int
+}
+
+func (m *truncatingMockAI) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	m.calls++
+	if len(text) > m.Limit {
+		return nil, fmt.Errorf("chunk: %w", ai.ErrResponseTruncated)
+	}
+	return strings.Fields(text)[:1], nil
+}
+
+func (m *truncatingMockAI) DetectLanguage(ctx context.Context, text string) (string, error) {
+	return "", nil
+}
+
+func (m *truncatingMockAI) Validate(ctx context.Context) error {
+	return nil
+}
+
+// TestExtractVocabularyRetriesTruncatedChunks tests that a truncated response
+// is retried on smaller pieces of the chunk
+func TestExtractVocabularyRetriesTruncatedChunks(t *testing.T) {
+	var lines []string
+	for i := 0; i < 40; i++ {
+		lines = append(lines, fmt.Sprintf("palabra%02d %s", i, strings.Repeat("x", 40)))
+	}
+	text := strings.Join(lines, "\n")
+
+	mockAI := &truncatingMockAI{Limit: 1000}
+	processor := &Processor{AI: mockAI, ChunkSize: len(text)}
+
+	vocabulary, err := processor.extractVocabulary(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Expected truncated chunk to be retried, got %v", err)
+	}
+	if mockAI.calls < 3 {
+		t.Errorf("Expected the chunk to be split and retried, got %d calls", mockAI.calls)
+	}
+	if len(vocabulary) < 2 || vocabulary[0].Text != "palabra00" {
+		t.Errorf("Unexpected vocabulary from split chunks: %v", vocabulary)
+	}
+
+	// A chunk that is still truncated at the minimum size is an error
+	small := &Processor{AI: &truncatingMockAI{Limit: 10}}
+	if _, err := small.extractVocabulary(context.Background(), strings.Repeat("palabra ", 100)); !errors.Is(err, ai.ErrResponseTruncated) {
+		t.Errorf("Expected ErrResponseTruncated, got %v", err)
+	}
+}
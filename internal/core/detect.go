@@ -0,0 +1,131 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// minDetectionHits is the number of stop words a text must contain
+	// before its language is guessed at all
+	minDetectionHits = 5
+
+	// minDetectionConfidence is the share of stop word hits the leading
+	// language needs for a mismatch to be reported
+	minDetectionConfidence = 0.6
+
+	// minRelabelHits is the number of stop words a stored word and its
+	// context must contain before the row is relabeled
+	minRelabelHits = 2
+)
+
+// detectionStopWords is the embedded stop word set used for detection,
+// independent of any user-configured Processor.StopWords. If it cannot be
+// read, detection finds no stop words and so never guesses a language.
+var detectionStopWords = sync.OnceValue(func() *StopWords {
+	stopWords, err := DefaultStopWords()
+	if err != nil {
+		log.Printf("language detection disabled: %v", err)
+		return &StopWords{}
+	}
+	return stopWords
+})
+
+// DetectLanguage guesses the language of text by counting stop words from
+// each embedded list. It returns the list name (such as "french") and the
+// leading language's share of all hits, or "" and 0 when the text has too
+// few stop words to judge.
+func DetectLanguage(text string) (string, float64) {
+	hits, total := stopWordHits(text)
+
+	best, bestHits := "", 0
+	for language, count := range hits {
+		if count > bestHits || (count == bestHits && language < best) {
+			best, bestHits = language, count
+		}
+	}
+
+	if bestHits < minDetectionHits {
+		return "", 0
+	}
+	return best, float64(bestHits) / float64(total)
+}
+
+// detectWordLanguage guesses the language of a stored word and its context.
+// Such text is too short for DetectLanguage's thresholds, so it only needs
+// minRelabelHits stop words, but the leading language must beat every other
+// one outright; "" means the text could not be told apart.
+func detectWordLanguage(text string) string {
+	hits, _ := stopWordHits(text)
+
+	best, bestHits, runnerUp := "", 0, 0
+	for language, count := range hits {
+		switch {
+		case count > bestHits:
+			best, bestHits, runnerUp = language, count, bestHits
+		case count > runnerUp:
+			runnerUp = count
+		}
+	}
+
+	if bestHits < minRelabelHits || bestHits == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// stopWordHits counts the words of text found in each embedded stop word
+// list, along with the total across all lists
+func stopWordHits(text string) (map[string]int, int) {
+	stopWords := detectionStopWords()
+
+	hits := make(map[string]int)
+	total := 0
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		for language, list := range stopWords.lists {
+			if language != "*" && list[word] {
+				hits[language]++
+				total++
+			}
+		}
+	}
+
+	return hits, total
+}
+
+// languageWarning returns a message when an explicitly chosen language
+// clearly disagrees with the language detected in text
+func (p *Processor) languageWarning(text string) string {
+	if !isExplicitLanguage(p.Language) {
+		return ""
+	}
+
+	detected, confidence := DetectLanguage(text)
+	if detected == "" || confidence < minDetectionConfidence || detected == stopWordKey(p.Language) {
+		return ""
+	}
+
+	return fmt.Sprintf("document looks like %s rather than %s (%.0f%% confidence)",
+		capitalize(detected), p.Language, confidence*100)
+}
+
+// isExplicitLanguage reports whether language was set by the user rather
+// than left to auto-detection
+func isExplicitLanguage(language string) bool {
+	language = strings.TrimSpace(language)
+	return language != "" && !strings.EqualFold(language, "auto-detect")
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	for i, r := range s {
+		return string(unicode.ToUpper(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parsely/parsely/internal/db"
+)
+
+const (
+	frenchSample  = "Je suis allé au marché avec ma sœur et nous avons acheté des pommes pour le dîner. Il fait beau dans la ville et les enfants jouent sur la place."
+	spanishSample = "Ayer fui al mercado con mi hermana y compramos manzanas para la cena. Hace buen tiempo en la ciudad y los niños juegan en la plaza."
+)
+
+// TestDetectLanguage tests stop-word based detection
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"French", frenchSample, "french"},
+		{"Spanish", spanishSample, "spanish"},
+		{"Too short", "Bonjour", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, confidence := DetectLanguage(tc.text)
+			if got != tc.want {
+				t.Errorf("Expected %q, got %q (confidence %.2f)", tc.want, got, confidence)
+			}
+		})
+	}
+}
+
+// TestProcessDocumentLanguageWarning tests that a conflicting explicit
+// language produces a warning without blocking processing
+func TestProcessDocumentLanguageWarning(t *testing.T) {
+	tests := []struct {
+		name        string
+		language    string
+		text        string
+		wantWarning bool
+	}{
+		{"French text labeled Spanish", "Spanish", frenchSample, true},
+		{"Spanish text labeled Spanish", "Spanish", spanishSample, false},
+		{"Spanish text labeled by code", "es", spanishSample, false},
+		{"Auto-detect never warns", "auto-detect", frenchSample, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			database := setupTestDB(t)
+			defer database.Close()
+
+			processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"pommes", "marché"}}, tc.language)
+
+			testFile := filepath.Join(t.TempDir(), "lesson.txt")
+			if err := os.WriteFile(testFile, []byte(tc.text), 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			result, err := processor.ProcessDocument(context.Background(), testFile)
+			if err != nil {
+				t.Fatalf("Processing should proceed despite the warning: %v", err)
+			}
+			if result.NewVocabulary != 2 {
+				t.Errorf("Expected 2 new items, got %d", result.NewVocabulary)
+			}
+
+			if tc.wantWarning {
+				if !strings.Contains(result.LanguageWarning, "French") || !strings.Contains(result.LanguageWarning, tc.language) {
+					t.Errorf("Expected warning naming French and %s, got %q", tc.language, result.LanguageWarning)
+				}
+			} else if result.LanguageWarning != "" {
+				t.Errorf("Expected no warning, got %q", result.LanguageWarning)
+			}
+		})
+	}
+}
+
+// TestRelabelAutoDetected tests that rows without a concrete language are
+// relabeled from their word and context
+func TestRelabelAutoDetected(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	seed := []*db.Vocabulary{
+		{Text: "pommes", Language: "auto-detect", Context: "Nous avons acheté des pommes pour le dîner avec ma sœur."},
+		{Text: "manzanas", Language: "", Context: "Compramos manzanas para la cena con mi hermana."},
+		{Text: "xyz", Language: "auto-detect"},
+		{Text: "Hola", Language: "Spanish"},
+	}
+	for _, item := range seed {
+		if _, err := database.Insert(item); err != nil {
+			t.Fatalf("Failed to insert %q: %v", item.Text, err)
+		}
+	}
+
+	processor := NewProcessor(database, &MockAIExtractor{}, "auto-detect")
+	updated, err := processor.RelabelAutoDetected(context.Background())
+	if err != nil {
+		t.Fatalf("RelabelAutoDetected failed: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("Expected 2 rows updated, got %d", updated)
+	}
+
+	want := map[string]string{
+		"pommes":   "French",
+		"manzanas": "Spanish",
+		"xyz":      "auto-detect",
+		"Hola":     "Spanish",
+	}
+	for text, language := range want {
+		item, err := database.GetByText(text)
+		if err != nil {
+			t.Fatalf("Failed to get %q: %v", text, err)
+		}
+		if item.Language != language {
+			t.Errorf("Expected %q to be labeled %q, got %q", text, language, item.Language)
+		}
+	}
+}
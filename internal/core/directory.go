@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DirectoryResult is the outcome of one file processed by ProcessDirectory.
+// Exactly one of Result and Err is set.
+type DirectoryResult struct {
+	File   string
+	Result *ProcessingResult
+	Err    error
+}
+
+// ProcessDirectory processes every supported document directly inside dir,
+// running up to Concurrency files at once. Results are sorted by filename
+// whatever order the files finish in. A file that fails is reported on its
+// DirectoryResult without stopping the others; cancelling ctx aborts the
+// files in progress, stops those that have not started yet and returns
+// ctx.Err().
+func (p *Processor) ProcessDirectory(ctx context.Context, dir string) ([]DirectoryResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isValidFileType(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+
+	// Concurrent files share one lock around their database writes, so
+	// inserts never contend for SQLite's write lock
+	worker := *p
+	worker.storeMu = &sync.Mutex{}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(p.Concurrency, 1))
+
+	results := make([]DirectoryResult, len(files))
+	for i, name := range files {
+		group.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+			result, err := worker.ProcessDocument(groupCtx, filepath.Join(dir, name))
+			results[i] = DirectoryResult{File: name, Result: result, Err: err}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results, nil
+}
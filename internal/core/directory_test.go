@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedMockAI returns the words of the text it is given once the test
+// closes the release channel of the text's first word, so the test decides
+// the order in which files finish. Each call announces its first word on
+// started before waiting.
+type gatedMockAI struct {
+	MockAIExtractor
+	started     chan string
+	release     map[string]chan struct{}
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+// newGatedMockAI creates a gatedMockAI for texts starting with the given
+// words
+func newGatedMockAI(words ...string) *gatedMockAI {
+	m := &gatedMockAI{started: make(chan string, len(words)), release: make(map[string]chan struct{})}
+	for _, word := range words {
+		m.release[word] = make(chan struct{})
+	}
+	return m
+}
+
+func (m *gatedMockAI) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	current := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	for {
+		seen := m.maxInFlight.Load()
+		if current <= seen || m.maxInFlight.CompareAndSwap(seen, current) {
+			break
+		}
+	}
+
+	words := strings.Fields(text)
+	m.started <- words[0]
+	select {
+	case <-m.release[words[0]]:
+		return words, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TestProcessDirectoryConcurrent tests that every file is processed and the
+// results come back sorted by filename regardless of completion order
+func TestProcessDirectoryConcurrent(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"c.txt":     "charlie tres",
+		"a.txt":     "alpha uno",
+		"d.txt":     "delta cuatro",
+		"b.txt":     "bravo dos",
+		"notes.rtf": "ignored",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	mockAI := newGatedMockAI("alpha", "bravo", "charlie", "delta")
+	processor := NewProcessor(database, mockAI, "Spanish")
+	processor.Concurrency = 3
+
+	var results []DirectoryResult
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, err = processor.ProcessDirectory(context.Background(), dir)
+	}()
+
+	// Fill the pool, then let the files finish in the reverse of the order
+	// they started, and the last file after them
+	var first []string
+	for range processor.Concurrency {
+		first = append(first, <-mockAI.started)
+	}
+	if inFlight := mockAI.inFlight.Load(); inFlight != 3 {
+		t.Errorf("Expected 3 files in flight, got %d", inFlight)
+	}
+	for i := len(first) - 1; i >= 0; i-- {
+		close(mockAI.release[first[i]])
+	}
+	close(mockAI.release[<-mockAI.started])
+	<-done
+
+	if err != nil {
+		t.Fatalf("ProcessDirectory failed: %v", err)
+	}
+
+	want := []string{"a.txt", "b.txt", "c.txt", "d.txt"}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %d", len(want), len(results))
+	}
+	for i, result := range results {
+		if result.File != want[i] {
+			t.Errorf("Result %d: expected %s, got %s", i, want[i], result.File)
+		}
+		if result.Err != nil || result.Result == nil || result.Result.NewVocabulary != 2 {
+			t.Errorf("%s: expected 2 new words, got %+v", result.File, result)
+		}
+	}
+
+	if count, _ := database.Count(); count != 8 {
+		t.Errorf("Expected 8 stored words, got %d", count)
+	}
+	if peak := mockAI.maxInFlight.Load(); peak != 3 {
+		t.Errorf("Expected at most 3 files in flight, got %d", peak)
+	}
+}
+
+// TestProcessDirectoryCancelled tests that a cancelled context stops the pool
+func TestProcessDirectoryCancelled(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha uno"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	processor := NewProcessor(database, newGatedMockAI("alpha"), "Spanish")
+	if _, err := processor.ProcessDirectory(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if count, _ := database.Count(); count != 0 {
+		t.Errorf("Expected nothing stored after cancellation, got %d", count)
+	}
+}
+
+// blockingMockAI waits for the context to be cancelled, like an AI request
+// that has not answered yet
+type blockingMockAI struct {
+	MockAIExtractor
+	calls atomic.Int32
+}
+
+func (m *blockingMockAI) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	m.calls.Add(1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestProcessDocumentCancelled tests that cancelling the context aborts the
+// AI request in flight and that a context cancelled up front makes no
+// request at all
+func TestProcessDocumentCancelled(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	testFile := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(testFile, []byte("hola mundo"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	mockAI := &blockingMockAI{}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := processor.ProcessDocument(ctx, testFile); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if calls := mockAI.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 AI call, got %d", calls)
+	}
+
+	if _, err := processor.ProcessDocument(ctx, testFile); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if calls := mockAI.calls.Load(); calls != 1 {
+		t.Errorf("Expected no AI call once cancelled, got %d", calls)
+	}
+	if count, _ := database.Count(); count != 0 {
+		t.Errorf("Expected nothing stored after cancellation, got %d", count)
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// DuplicatePolicy controls what happens when extracted vocabulary is
+// already stored
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip leaves existing words untouched and counts them as
+	// skipped duplicates. It is the default.
+	DuplicateSkip DuplicatePolicy = "skip"
+
+	// DuplicateError rejects the whole document with ErrDuplicateVocabulary
+	// before anything is stored
+	DuplicateError DuplicatePolicy = "error"
+
+	// DuplicateCount bumps the occurrence counter of existing words
+	DuplicateCount DuplicatePolicy = "count"
+)
+
+// ErrDuplicateVocabulary is returned under DuplicateError when extracted
+// vocabulary already exists
+var ErrDuplicateVocabulary = errors.New("vocabulary already exists")
+
+// ParseDuplicatePolicy parses an on_duplicate value; empty means DuplicateSkip
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return DuplicateSkip, nil
+	case DuplicateSkip, DuplicateError, DuplicateCount:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q (expected skip, error or count)", s)
+	}
+}
+
+// WithDuplicatePolicy returns a copy of the processor using the given
+// duplicate policy
+func (p *Processor) WithDuplicatePolicy(policy DuplicatePolicy) *Processor {
+	clone := *p
+	clone.OnDuplicate = policy
+	return &clone
+}
+
+// checkDuplicates returns ErrDuplicateVocabulary listing the words that are
+// already stored, or nil if none are
+func (p *Processor) checkDuplicates(vocabulary []ai.VocabularyItem) error {
+	var existing []string
+	for _, item := range vocabulary {
+		exists, err := p.DB.ExistsNormalized(item.Text, p.Language)
+		if err != nil {
+			return err
+		}
+		if exists {
+			existing = append(existing, item.Text)
+		}
+	}
+
+	if len(existing) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateVocabulary, strings.Join(existing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/db"
+)
+
+// defaultEnrichBatchSize is used when EnrichAll is called with a
+// non-positive batch size
+const defaultEnrichBatchSize = 50
+
+// ErrTranslationUnsupported is returned when the AI extractor cannot
+// translate stored vocabulary
+var ErrTranslationUnsupported = errors.New("AI extractor does not support translation")
+
+// TranslateMissing translates every stored item that has no translation yet,
+// in batches of defaultEnrichBatchSize, and returns how many were updated.
+// Items that already have a translation are never sent to the AI, so it is
+// safe to call repeatedly.
+func (p *Processor) TranslateMissing(ctx context.Context) (int, error) {
+	return p.EnrichAll(ctx, defaultEnrichBatchSize)
+}
+
+// EnrichAll translates stored vocabulary that has no translation yet. Rows are
+// sent to the AI in batches of batchSize, grouped by language, and updated as
+// each batch completes, so a cancelled or rate limited run keeps its progress.
+// Rows the AI cannot translate are left untouched.
+func (p *Processor) EnrichAll(ctx context.Context, batchSize int) (int, error) {
+	translator, ok := p.AI.(ai.Translator)
+	if !ok {
+		return 0, ErrTranslationUnsupported
+	}
+	if batchSize <= 0 {
+		batchSize = defaultEnrichBatchSize
+	}
+
+	enriched := 0
+	// Rows the AI left untranslated stay at the front of the result set, so
+	// skip past them instead of asking for them again
+	offset := 0
+
+	for first := true; ; first = false {
+		if err := ctx.Err(); err != nil {
+			return enriched, err
+		}
+
+		items, err := p.DB.ListUntranslated(batchSize, offset)
+		if err != nil {
+			return enriched, fmt.Errorf("failed to list untranslated vocabulary: %w", err)
+		}
+		if len(items) == 0 {
+			return enriched, nil
+		}
+
+		if !first {
+			if err := waitFor(ctx, p.EnrichDelay); err != nil {
+				return enriched, err
+			}
+		}
+
+		translated, err := p.enrichBatch(ctx, translator, items)
+		enriched += translated
+		if err != nil {
+			var aiErr *ai.AIError
+			if errors.As(err, &aiErr) && aiErr.StatusCode == http.StatusTooManyRequests {
+				return enriched, fmt.Errorf("stopped after rate limit: %w", err)
+			}
+			return enriched, err
+		}
+
+		offset += len(items) - translated
+	}
+}
+
+// enrichBatch translates one batch of rows and stores the results, returning
+// how many rows were updated
+func (p *Processor) enrichBatch(ctx context.Context, translator ai.Translator, items []*db.Vocabulary) (int, error) {
+	byLanguage := make(map[string][]*db.Vocabulary)
+	var languages []string
+	for _, item := range items {
+		if _, ok := byLanguage[item.Language]; !ok {
+			languages = append(languages, item.Language)
+		}
+		byLanguage[item.Language] = append(byLanguage[item.Language], item)
+	}
+
+	updated := 0
+	for _, language := range languages {
+		group := byLanguage[language]
+		words := make([]string, len(group))
+		for i, item := range group {
+			words[i] = item.Text
+		}
+
+		translations, err := translator.TranslateWords(ctx, words, language)
+		if err != nil {
+			return updated, fmt.Errorf("failed to translate vocabulary: %w", err)
+		}
+
+		for _, item := range group {
+			translation, ok := translations[item.Text]
+			if !ok || translation == "" {
+				continue
+			}
+			if err := p.DB.SetTranslation(item.ID, translation); err != nil {
+				return updated, fmt.Errorf("failed to store translation: %w", err)
+			}
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// waitFor blocks for d or until ctx is done
+func waitFor(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/parsely/parsely/internal/ai"
+	"github.com/parsely/parsely/internal/db"
+)
+
+// translatingMockAI implements ai.Translator for testing
+type translatingMockAI struct {
+	MockAIExtractor
+	Translations map[string]string
+	Err          error
+	Batches      [][]string
+}
+
+func (m *translatingMockAI) TranslateWords(ctx context.Context, words []string, language string) (map[string]string, error) {
+	m.Batches = append(m.Batches, words)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	result := make(map[string]string)
+	for _, word := range words {
+		if translation, ok := m.Translations[word]; ok {
+			result[word] = translation
+		}
+	}
+	return result, nil
+}
+
+// TestEnrichAll tests batch translation of untranslated vocabulary
+func TestEnrichAll(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	for _, v := range []*db.Vocabulary{
+		{Text: "hola", Language: "Spanish"},
+		{Text: "gracias", Language: "Spanish"},
+		{Text: "adiós", Language: "Spanish", Translation: "goodbye"},
+		{Text: "perro", Language: "Spanish"},
+		{Text: "xyzzy", Language: "Spanish"},
+	} {
+		if _, err := database.Insert(v); err != nil {
+			t.Fatalf("Failed to insert %q: %v", v.Text, err)
+		}
+	}
+
+	mockAI := &translatingMockAI{Translations: map[string]string{
+		"hola":    "hello",
+		"gracias": "thank you",
+		"adiós":   "farewell",
+		"perro":   "dog",
+	}}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	enriched, err := processor.EnrichAll(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("EnrichAll failed: %v", err)
+	}
+	if enriched != 3 {
+		t.Errorf("Expected 3 enriched rows, got %d", enriched)
+	}
+
+	for _, batch := range mockAI.Batches {
+		for _, word := range batch {
+			if word == "adiós" {
+				t.Error("Already translated rows should not be sent to the AI")
+			}
+		}
+	}
+
+	want := map[string]string{
+		"hola":    "hello",
+		"gracias": "thank you",
+		"adiós":   "goodbye",
+		"perro":   "dog",
+		"xyzzy":   "",
+	}
+	for text, translation := range want {
+		item, err := database.GetByText(text)
+		if err != nil {
+			t.Fatalf("Failed to get %q: %v", text, err)
+		}
+		if item.Translation != translation {
+			t.Errorf("Expected translation %q for %q, got %q", translation, text, item.Translation)
+		}
+	}
+}
+
+// TestEnrichAllErrors tests cancellation, rate limiting and unsupported AIs
+func TestEnrichAllErrors(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	if _, err := database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockAI := &translatingMockAI{Translations: map[string]string{"hola": "hello"}}
+	if _, err := NewProcessor(database, mockAI, "").EnrichAll(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(mockAI.Batches) != 0 {
+		t.Error("Cancelled run should not call the AI")
+	}
+
+	limited := &translatingMockAI{Err: &ai.AIError{Message: "rate limited", StatusCode: 429}}
+	enriched, err := NewProcessor(database, limited, "").EnrichAll(context.Background(), 10)
+	if !ai.IsAIError(err) || enriched != 0 {
+		t.Errorf("Expected rate limit AIError with 0 enriched, got %d, %v", enriched, err)
+	}
+
+	if _, err := NewProcessor(database, &MockAIExtractor{}, "").EnrichAll(context.Background(), 10); err == nil {
+		t.Error("Expected error when the AI does not support translation")
+	}
+}
+
+// TestTranslateMissing tests that a second run sends nothing once every
+// item is translated, and that extractors without translation are refused
+func TestTranslateMissing(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+	database.Insert(&db.Vocabulary{Text: "gracias", Language: "Spanish", Translation: "thanks"})
+
+	mockAI := &translatingMockAI{Translations: map[string]string{"hola": "hello", "gracias": "thank you"}}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	updated, err := processor.TranslateMissing(context.Background())
+	if err != nil || updated != 1 {
+		t.Fatalf("Expected 1 updated, got %d, %v", updated, err)
+	}
+	if len(mockAI.Batches) != 1 || len(mockAI.Batches[0]) != 1 || mockAI.Batches[0][0] != "hola" {
+		t.Errorf("Expected only hola to be sent, got %v", mockAI.Batches)
+	}
+
+	updated, err = processor.TranslateMissing(context.Background())
+	if err != nil || updated != 0 {
+		t.Errorf("Expected nothing updated on the second run, got %d, %v", updated, err)
+	}
+	if len(mockAI.Batches) != 1 {
+		t.Errorf("Expected no AI request on the second run, got %d batches", len(mockAI.Batches))
+	}
+
+	processor.AI = &MockAIExtractor{}
+	if _, err := processor.TranslateMissing(context.Background()); !errors.Is(err, ErrTranslationUnsupported) {
+		t.Errorf("Expected ErrTranslationUnsupported, got %v", err)
+	}
+}
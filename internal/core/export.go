@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ExportFormat selects the file format written by ExportVocabulary
+type ExportFormat string
+
+const (
+	// ExportJSON writes the versioned JSON export. It is the default.
+	ExportJSON ExportFormat = "json"
+
+	// ExportCSV writes a spreadsheet-friendly CSV file with a header row
+	ExportCSV ExportFormat = "csv"
+
+	// ExportAnki writes tab-separated front/back flashcards for Anki
+	ExportAnki ExportFormat = "anki"
+
+	// ExportHTML writes a printable study sheet grouped by language
+	ExportHTML ExportFormat = "html"
+)
+
+// ParseExportFormat parses a format name; empty means ExportJSON
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case "":
+		return ExportJSON, nil
+	case ExportJSON, ExportCSV, ExportAnki, ExportHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (expected json, csv, anki or html)", s)
+	}
+}
+
+// ExportFormatForPath picks the export format matching a file's extension,
+// falling back to ExportJSON. .txt and .tsv files, which is what Anki
+// imports, get ExportAnki, and .htm files ExportHTML.
+func ExportFormatForPath(filePath string) ExportFormat {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".txt", ".tsv":
+		return ExportAnki
+	case ".htm":
+		return ExportHTML
+	}
+	if format, err := ParseExportFormat(strings.TrimPrefix(filepath.Ext(filePath), ".")); err == nil {
+		return format
+	}
+	return ExportJSON
+}
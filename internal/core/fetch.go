@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/parsely/parsely/internal/parser"
+)
+
+// urlFetchTimeout bounds how long a remote document download may take
+const urlFetchTimeout = 30 * time.Second
+
+// maxRedirects is the number of redirects followed when fetching a URL
+const maxRedirects = 5
+
+// ErrURLNotAllowed is returned when a URL is malformed or points at an
+// address that must not be fetched (loopback, private, link-local, etc.)
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// contentTypeExtensions maps supported MIME types to parser file extensions
+var contentTypeExtensions = map[string]string{
+	"application/epub+zip": ".epub",
+	"application/pdf":      ".pdf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/vnd.oasis.opendocument.text":                                 ".odt",
+	"text/plain":    ".txt",
+	"text/markdown": ".md",
+}
+
+// ProcessURL downloads a remote document and processes it like a local file
+func (p *Processor) ProcessURL(ctx context.Context, rawURL string) (*ProcessingResult, error) {
+	target, err := validateRemoteURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	allowPrivate := slices.Contains(p.URLAllowlist, target.Hostname())
+	if !allowPrivate {
+		if ip := net.ParseIP(target.Hostname()); ip != nil && isBlockedIP(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to a blocked address", ErrURLNotAllowed, target.Hostname())
+		}
+	}
+
+	// The timeout covers the download only; processing is bounded by ctx
+	fetchCtx, cancel := context.WithTimeout(ctx, urlFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := newFetchClient(target.Hostname(), allowPrivate).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch URL: remote server returned %s", resp.Status)
+	}
+
+	if resp.ContentLength > parser.MaxFileSize {
+		return nil, &parser.FileTooLargeError{Size: resp.ContentLength, Limit: parser.MaxFileSize}
+	}
+
+	ext := detectRemoteExtension(resp.Header.Get("Content-Type"), target.Path)
+	if ext == "" {
+		return nil, fmt.Errorf("unsupported remote content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	// CreateTempFile enforces MaxFileSize with an io.LimitReader
+	tmpPath, err := parser.CreateTempFile(resp.Body, "download"+ext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save remote document: %w", err)
+	}
+	defer parser.CleanupTempFile(tmpPath)
+
+	result, err := p.processDocument(ctx, tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	result.FilePath = target.String()
+	p.notify(result)
+
+	return result, nil
+}
+
+// validateRemoteURL checks that a URL is absolute and uses http or https
+func validateRemoteURL(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be http or https", ErrURLNotAllowed)
+	}
+	if target.Hostname() == "" {
+		return nil, fmt.Errorf("%w: missing host", ErrURLNotAllowed)
+	}
+	if target.User != nil {
+		return nil, fmt.Errorf("%w: credentials in URL are not supported", ErrURLNotAllowed)
+	}
+	return target, nil
+}
+
+// newFetchClient builds an HTTP client whose dialer refuses blocked addresses.
+// The check runs on the resolved IP at connect time, so DNS names that point
+// at internal addresses (or change between lookups) are rejected as well.
+func newFetchClient(host string, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+			}
+			if ip := net.ParseIP(ipStr); ip == nil || isBlockedIP(ip) {
+				return fmt.Errorf("%w: %s is a blocked address", ErrURLNotAllowed, ipStr)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   urlFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if _, err := validateRemoteURL(req.URL.String()); err != nil {
+				return err
+			}
+			// Allowlisted hosts skip the address check, so never let them
+			// redirect somewhere else
+			if allowPrivate && req.URL.Hostname() != host {
+				return fmt.Errorf("%w: redirect away from allowlisted host", ErrURLNotAllowed)
+			}
+			return nil
+		},
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate does not cover but is just as internal
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isBlockedIP reports whether an IP address belongs to a range that remote
+// fetches must never reach (loopback, private, shared, link-local, metadata,
+// etc.)
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		sharedAddressSpace.Contains(ip) ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified()
+}
+
+// detectRemoteExtension picks a parser extension from the response
+// Content-Type, falling back to the extension in the URL path
+func detectRemoteExtension(contentType, urlPath string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext, ok := contentTypeExtensions[mediaType]; ok {
+			return ext
+		}
+	}
+
+	ext := strings.ToLower(path.Ext(urlPath))
+	if isValidFileType("file" + ext) {
+		return ext
+	}
+	return ""
+}
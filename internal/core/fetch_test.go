@@ -0,0 +1,169 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/parsely/parsely/internal/parser"
+)
+
+// TestProcessURL tests fetching and processing a remote text document
+func TestProcessURL(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Hola, buenos días. Gracias por todo."))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	processor := NewProcessor(database, &MockAIExtractor{
+		Vocabulary: []string{"hola", "buenos días", "gracias"},
+	}, "Spanish")
+	// The test server listens on loopback, which is blocked by default
+	processor.URLAllowlist = []string{serverURL.Hostname()}
+
+	result, err := processor.ProcessURL(context.Background(), server.URL+"/lesson")
+	if err != nil {
+		t.Fatalf("Failed to process URL: %v", err)
+	}
+
+	if result.NewVocabulary != 3 {
+		t.Errorf("Expected 3 new items, got %d", result.NewVocabulary)
+	}
+	if result.FilePath != server.URL+"/lesson" {
+		t.Errorf("Expected FilePath to be the URL, got %s", result.FilePath)
+	}
+}
+
+// TestProcessURLUnsupportedType tests rejecting remote content we can't parse
+func TestProcessURLUnsupportedType(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("not a document"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	processor := NewProcessor(database, &MockAIExtractor{}, "Spanish")
+	processor.URLAllowlist = []string{serverURL.Hostname()}
+
+	if _, err := processor.ProcessURL(context.Background(), server.URL+"/photo"); err == nil {
+		t.Error("Expected error for unsupported content type")
+	}
+}
+
+// TestProcessURLSSRFProtection tests that internal addresses are rejected
+func TestProcessURLSSRFProtection(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Blocked address should never be contacted")
+	}))
+	defer server.Close()
+
+	processor := NewProcessor(database, &MockAIExtractor{}, "Spanish")
+
+	blocked := []string{
+		server.URL + "/lesson.txt",
+		"http://localhost/lesson.txt",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/internal.txt",
+		"http://100.64.0.1/internal.txt",
+		"http://100.127.255.254/internal.txt",
+		"http://[::1]/lesson.txt",
+		"http://0.0.0.0/lesson.txt",
+		"file:///etc/passwd",
+		"ftp://example.com/notes.txt",
+	}
+
+	for _, rawURL := range blocked {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := processor.ProcessURL(context.Background(), rawURL)
+			if !errors.Is(err, ErrURLNotAllowed) {
+				t.Errorf("Expected ErrURLNotAllowed for %s, got: %v", rawURL, err)
+			}
+		})
+	}
+}
+
+// TestDetectRemoteExtension tests mapping Content-Type and URL path to a parser type
+func TestDetectRemoteExtension(t *testing.T) {
+	tests := []struct {
+		contentType string
+		urlPath     string
+		expected    string
+	}{
+		{"application/pdf", "/download", ".pdf"},
+		{"text/plain; charset=utf-8", "/notes", ".txt"},
+		{"application/octet-stream", "/lesson.DOCX", ".docx"},
+		{"text/markdown; charset=utf-8", "/notes", ".md"},
+		{"application/epub+zip", "/download", ".epub"},
+		{"application/octet-stream", "/README.markdown", ".markdown"},
+		{"text/html", "/article", ""},
+		{"", "/notes.rtf", ""},
+	}
+
+	for _, tc := range tests {
+		result := detectRemoteExtension(tc.contentType, tc.urlPath)
+		if result != tc.expected {
+			t.Errorf("detectRemoteExtension(%q, %q) = %q, expected %q", tc.contentType, tc.urlPath, result, tc.expected)
+		}
+	}
+}
+
+// TestProcessURLParserPanic tests that a panicking parser returns a
+// PanicError and the downloaded temp file is still removed
+func TestProcessURLParserPanic(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	original := parseFile
+	parseFile = func(_ parser.ParserConfig, _ context.Context, filePath string) (string, parser.ParseMetadata, error) {
+		panic("malformed cross-reference table")
+	}
+	t.Cleanup(func() { parseFile = original })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Hola"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola"}}, "Spanish")
+	processor.URLAllowlist = []string{serverURL.Hostname()}
+
+	result, err := processor.ProcessURL(context.Background(), server.URL+"/lesson.txt")
+	if !IsPanicError(err) {
+		t.Fatalf("Expected PanicError, got %v", err)
+	}
+	if result != nil {
+		t.Error("Expected no result after a panic")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected temp file to be cleaned up, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// countInDocument sets each item's Count to how often it appears in text.
+// Items the AI returned in a form that does not occur literally in the
+// document are counted once.
+func countInDocument(text string, items []ai.VocabularyItem) {
+	lowered := strings.ToLower(text)
+	for i := range items {
+		items[i].Count = max(countOccurrences(lowered, strings.ToLower(items[i].Text)), 1)
+	}
+}
+
+// countOccurrences counts non-overlapping matches of word in text that start
+// and end on word boundaries, so "sol" is not counted inside "soltero".
+// Both arguments are expected to be lowercased already.
+func countOccurrences(text, word string) int {
+	if word == "" {
+		return 0
+	}
+
+	count := 0
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(word)
+
+		if isBoundary(text, start, end) {
+			count++
+			offset = end
+		} else {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			offset = start + size
+		}
+	}
+	return count
+}
+
+// isBoundary reports whether text[start:end] is not surrounded by letters
+// or digits
+func isBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune reports whether r can be part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestCountOccurrences tests whole-word, case-insensitive counting
+func TestCountOccurrences(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		want int
+	}{
+		{"hola amigo, hola", "hola", 2},
+		{"el sol y el soltero", "sol", 1},
+		{"buenos días. buenos días!", "buenos días", 2},
+		{"niño niños", "niño", 1},
+		{"aaa", "aa", 0},
+		{"hola", "", 0},
+	}
+
+	for _, tt := range tests {
+		if got := countOccurrences(tt.text, tt.word); got != tt.want {
+			t.Errorf("countOccurrences(%q, %q) = %d, want %d", tt.text, tt.word, got, tt.want)
+		}
+	}
+}
+
+// TestProcessReaderWordFrequency tests that per-document counts are reported
+// and stored as the initial occurrence count
+func TestProcessReaderWordFrequency(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	text := "Hola amigo. ¡Hola! ¿Cómo estás? Hola otra vez, amigo."
+	processor := NewProcessor(database, &MockAIExtractor{
+		Vocabulary: []string{"hola", "amigo", "cómo estás", "hasta luego"},
+	}, "Spanish")
+	processor.CollectWords = true
+
+	result, err := processor.ProcessReader(context.Background(), strings.NewReader(text), "lesson.txt", int64(len(text)))
+	if err != nil {
+		t.Fatalf("ProcessReader failed: %v", err)
+	}
+
+	want := map[string]int{"hola": 3, "amigo": 2, "cómo estás": 1, "hasta luego": 1}
+	if len(result.NewWords) != len(want) {
+		t.Fatalf("Expected %d new words, got %v", len(want), result.NewWords)
+	}
+	for _, word := range result.NewWords {
+		if word.Count != want[word.Text] {
+			t.Errorf("Expected count %d for %q, got %d", want[word.Text], word.Text, word.Count)
+		}
+	}
+
+	stored, err := database.GetByText("hola")
+	if err != nil {
+		t.Fatalf("Failed to get stored word: %v", err)
+	}
+	if stored.Occurrences != 3 {
+		t.Errorf("Expected stored occurrences 3, got %d", stored.Occurrences)
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned when a parser or extractor panics while processing
+// a document. The panic is recovered inside the processor so deferred
+// cleanup (temp files, transaction rollbacks) runs and callers get an
+// ordinary error instead of a crashed request.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while processing document: %v", e.Value)
+}
+
+// IsPanicError checks if an error is a PanicError
+func IsPanicError(err error) bool {
+	var panicErr *PanicError
+	return errors.As(err, &panicErr)
+}
+
+// recoverPanic converts a panic into a *PanicError stored in *err. It must be
+// deferred directly by the function whose panics it recovers.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
@@ -1,10 +1,17 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/parsely/parsely/internal/ai"
 	"github.com/parsely/parsely/internal/db"
@@ -16,83 +23,638 @@ type Processor struct {
 	DB       *db.Database
 	AI       ai.AIExtractor
 	Language string
+
+	// ExtractContext asks the AI for the sentence each word appeared in and
+	// stores it alongside the word. It has no effect when the extractor does
+	// not implement ai.ContextExtractor.
+	ExtractContext bool
+
+	// OnDuplicate controls how words that are already stored are handled;
+	// the zero value behaves like DuplicateSkip
+	OnDuplicate DuplicatePolicy
+
+	// CollectWords records which words were added and which were skipped as
+	// duplicates on ProcessingResult; off by default to keep large imports lean
+	CollectWords bool
+
+	// QualityFilter drops obvious non-vocabulary tokens such as page numbers,
+	// lesson codes, URLs and bare punctuation before they are stored.
+	// NewProcessor enables it.
+	QualityFilter bool
+
+	// StopWords drops ultra-common words for the processing language before
+	// insert; nil disables stop word filtering
+	StopWords *StopWords
+
+	// ChunkSize is the maximum number of characters sent to the AI per
+	// request; zero uses defaultChunkSize
+	ChunkSize int
+
+	// Parser selects the PDF text-extraction engine; the zero value uses the
+	// internal engine
+	Parser parser.ParserConfig
+
+	// FromPage and ToPage limit PDF extraction to a 1-indexed, inclusive
+	// page range; zero values process the whole document
+	FromPage int
+	ToPage   int
+
+	// Tags are applied to every word stored from a document, including
+	// words that were already stored; nil applies no tags
+	Tags []string
+
+	// URLAllowlist lists hosts that ProcessURL may fetch from even when they
+	// resolve to private or loopback addresses
+	URLAllowlist []string
+
+	// EnrichDelay is the pause between translation batches in EnrichAll,
+	// used to stay under the AI provider's rate limit
+	EnrichDelay time.Duration
+
+	// Webhook is notified after each successfully processed document; nil
+	// disables notifications
+	Webhook *Webhook
+
+	// OnProgress, when set, is called as a document moves through parsing,
+	// language detection, extraction of each chunk and storage
+	OnProgress func(Progress)
+
+	// Concurrency is the number of files ProcessDirectory processes at
+	// once; zero or less processes one file at a time
+	Concurrency int
+
+	// ForceExtract sends every document to the AI even when the extraction
+	// cache already holds the vocabulary for its text
+	ForceExtract bool
+
+	// storeMu serializes database writes while ProcessDirectory runs files
+	// concurrently; nil outside of it
+	storeMu *sync.Mutex
 }
 
 // ProcessingResult contains the results of processing a document
 type ProcessingResult struct {
-	NewVocabulary     int
-	SkippedDuplicates int
-	TotalProcessed    int
-	Language          string
-	FilePath          string
+	NewVocabulary  int
+	TotalProcessed int
+	Language       string
+	FilePath       string
+
+	// SkippedExisting counts extracted items that were already stored and
+	// were skipped
+	SkippedExisting int
+
+	// InDocumentDuplicates counts extracted items collapsed because the
+	// document yielded them more than once; they are not included in
+	// TotalProcessed
+	InDocumentDuplicates int
+
+	// FilteredItems counts extracted items dropped by the quality filter;
+	// they are not included in TotalProcessed
+	FilteredItems int
+
+	// StopWordsRemoved counts extracted items dropped as stop words; they are
+	// not included in TotalProcessed
+	StopWordsRemoved int
+
+	// RepeatedOccurrences counts existing words whose occurrence counter was
+	// incremented; only used with the DuplicateCount policy
+	RepeatedOccurrences int
+
+	// UpdatedTranslations counts existing words that had no translation and
+	// were given the one extracted from this document
+	UpdatedTranslations int
+
+	// TextHash is the SHA-256 of the parsed document text, which keys the
+	// extraction cache; empty for images
+	TextHash string
+
+	// FromCache is set when the vocabulary came from the extraction cache
+	// instead of the AI
+	FromCache bool
+
+	// Pages is the number of PDF pages in the processed range and
+	// SkippedPages how many of them could not be read; both are zero for
+	// other documents
+	Pages        int
+	SkippedPages int
+
+	// CharCount is the number of characters in the parsed document text;
+	// zero for images
+	CharCount int
+
+	// LanguageWarning is set when the requested language clearly differs
+	// from the language detected in the document; processing still runs
+	LanguageWarning string
+
+	// Partial is set when extraction failed part-way through a document;
+	// vocabulary from the chunks that succeeded is still stored
+	Partial      bool
+	PartialError string
+
+	// NewItemIDs holds the database ID of each newly added item, in
+	// extraction order. It is empty, never nil, when nothing was added.
+	NewItemIDs []int
+
+	// NewWords and SkippedWords are only populated when
+	// Processor.CollectWords is set. Each new word carries how often it
+	// appeared in the document.
+	NewWords     []WordCount
+	SkippedWords []string
+}
+
+// WordCount is a word together with its frequency in a single document
+type WordCount struct {
+	Text  string
+	Count int
 }
 
 // NewProcessor creates a new Processor instance
 func NewProcessor(database *db.Database, aiClient ai.AIExtractor, language string) *Processor {
 	return &Processor{
-		DB:       database,
-		AI:       aiClient,
-		Language: language,
+		DB:            database,
+		AI:            aiClient,
+		Language:      language,
+		QualityFilter: true,
+	}
+}
+
+// WithLanguage returns a copy of the processor that extracts and stores
+// vocabulary under the given language instead of the configured default
+func (p *Processor) WithLanguage(language string) *Processor {
+	clone := *p
+	clone.Language = language
+	return &clone
+}
+
+// WithPages returns a copy of the processor that only extracts the given
+// page range from PDF documents
+func (p *Processor) WithPages(from, to int) *Processor {
+	clone := *p
+	clone.FromPage = from
+	clone.ToPage = to
+	return &clone
+}
+
+// WithTags returns a copy of the processor that tags every word it stores
+// with the given tags
+func (p *Processor) WithTags(tags []string) *Processor {
+	clone := *p
+	clone.Tags = tags
+	return &clone
+}
+
+// ErrImagesUnsupported is returned for image uploads when the AI extractor
+// cannot read images
+var ErrImagesUnsupported = errors.New("AI provider does not support image extraction")
+
+// parseFile and parseStream are the parser entry points; tests replace them
+// to simulate parser failures
+var (
+	parseFile   = parser.ParserConfig.ParseDocumentWithMeta
+	parseStream = parser.ParserConfig.ParseDocumentFromReaderWithMeta
+)
+
+// ProcessDocument processes a document file and extracts vocabulary.
+// Cancelling ctx aborts the AI request in flight. A panic in the parser or
+// extractor is returned as a *PanicError.
+func (p *Processor) ProcessDocument(ctx context.Context, filePath string) (*ProcessingResult, error) {
+	result, err := p.processDocument(ctx, filePath)
+	if err != nil {
+		return nil, err
 	}
+	p.notify(result)
+	return result, nil
 }
 
-// ProcessDocument processes a document file and extracts vocabulary
-func (p *Processor) ProcessDocument(filePath string) (*ProcessingResult, error) {
+// processDocument is ProcessDocument without the webhook notification
+func (p *Processor) processDocument(ctx context.Context, filePath string) (result *ProcessingResult, err error) {
+	defer recoverPanic(&err)
+
 	if err := validateFilePath(filePath); err != nil {
 		return nil, fmt.Errorf("invalid file path: %w", err)
 	}
 
 	if !isValidFileType(filePath) {
-		return nil, fmt.Errorf("unsupported file type: %s (only .pdf and .docx are supported)", filepath.Ext(filePath))
+		return nil, fmt.Errorf("unsupported file type: %s (only .pdf, .docx, .odt, .txt, .md, .epub, .jpg and .png are supported)", filepath.Ext(filePath))
+	}
+
+	if parser.DetectFileType(filePath) == parser.TypeImage {
+		image, err := parser.ReadImageFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return p.processImage(ctx, image, filePath)
+	}
+
+	text, meta, err := p.parseDocument(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return p.processText(ctx, text, meta, filePath)
+}
+
+// ProcessReader processes an in-memory document whose type is taken from
+// filename, without writing it to disk. A panic in the parser or extractor
+// is returned as a *PanicError.
+func (p *Processor) ProcessReader(ctx context.Context, r io.Reader, filename string, size int64) (result *ProcessingResult, err error) {
+	defer recoverPanic(&err)
+
+	if err := parser.ValidateFilename(filename); err != nil {
+		return nil, fmt.Errorf("invalid filename: %w", err)
+	}
+
+	if !isValidFileType(filename) {
+		return nil, fmt.Errorf("unsupported file type: %s (only .pdf, .docx, .odt, .txt, .md, .epub, .jpg and .png are supported)", filepath.Ext(filename))
+	}
+
+	if parser.DetectFileType(filename) == parser.TypeImage {
+		image, err := parser.ReadImage(r, size)
+		if err != nil {
+			return nil, err
+		}
+		result, err = p.processImage(ctx, image, filename)
+		if err != nil {
+			return nil, err
+		}
+		p.notify(result)
+		return result, nil
 	}
 
-	text, err := parser.ParseDocument(filePath)
+	text, meta, err := p.parseReader(ctx, r, filename, size)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse document: %w", err)
 	}
 
-	vocabulary, err := p.AI.ExtractVocabulary(text, p.Language)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err = p.processText(ctx, text, meta, filename)
+	if err != nil {
+		return nil, err
+	}
+	p.notify(result)
+	return result, nil
+}
+
+// notify sends result to the webhook, if one is configured
+func (p *Processor) notify(result *ProcessingResult) {
+	if p.Webhook != nil {
+		p.Webhook.Notify(result)
+	}
+}
+
+// processImage extracts vocabulary from an image with the AI's vision
+// support and stores it. Without an explicit language, the language is
+// detected from the extracted words, since the image has no text to detect
+// it from beforehand. Extractors without vision support fail with
+// ErrImagesUnsupported.
+func (p *Processor) processImage(ctx context.Context, image []byte, source string) (*ProcessingResult, error) {
+	vision, ok := p.AI.(ai.VisionExtractor)
+	if !ok {
+		return nil, ErrImagesUnsupported
+	}
+
+	words, err := vision.ExtractVocabularyFromImage(ctx, image, parser.ImageMIMEType(source), p.Language)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract vocabulary: %w", err)
 	}
 
-	newCount, skipCount := p.processVocabulary(vocabulary)
+	if !isExplicitLanguage(p.Language) && len(words) > 0 {
+		detected, err := p.AI.DetectLanguage(ctx, strings.Join(words, "\n"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect language: %w", err)
+		}
+		if detected = strings.TrimSpace(detected); detected != "" {
+			p = p.WithLanguage(detected)
+		}
+	}
+
+	vocabulary := make([]ai.VocabularyItem, len(words))
+	for i, word := range words {
+		vocabulary[i] = ai.VocabularyItem{Text: word}
+	}
+
+	result := &ProcessingResult{
+		Language: p.Language,
+		FilePath: source,
+	}
+	if err := p.storeVocabulary(vocabulary, "", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// processText extracts vocabulary from parsed document text and stores it.
+// The result reports source as its FilePath and copies the page counts from
+// meta. Text that was extracted before with the same settings is taken from
+// the extraction cache unless ForceExtract is set. Extraction stops before
+// the next chunk once ctx is done.
+func (p *Processor) processText(ctx context.Context, text string, meta parser.ParseMetadata, source string) (*ProcessingResult, error) {
+	p.progress(StageParsed, 0, 0)
+	warning := p.languageWarning(text)
+
+	hash := textHash(text)
+	key := p.extractionKey(hash)
+	vocabulary, language, fromCache := p.cachedExtraction(key)
+
+	var extractErr error
+	if fromCache {
+		p = p.WithLanguage(language)
+	} else {
+		// Without an explicit language, ask the AI first so that extraction
+		// and the stored rows use the detected language
+		if !isExplicitLanguage(p.Language) {
+			p.progress(StageDetecting, 0, 0)
+			detected, err := p.AI.DetectLanguage(ctx, text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to detect language: %w", err)
+			}
+			if detected = strings.TrimSpace(detected); detected != "" {
+				p = p.WithLanguage(detected)
+			}
+		}
+
+		vocabulary, extractErr = p.extractVocabulary(ctx, text)
+		if extractErr != nil && len(vocabulary) == 0 {
+			return nil, fmt.Errorf("failed to extract vocabulary: %w", extractErr)
+		}
+
+		// A partial extraction would hide the missing chunks on the next
+		// upload, so only complete ones are cached
+		if extractErr == nil {
+			p.cacheExtraction(key, p.Language, vocabulary)
+		}
+	}
+
+	result := &ProcessingResult{
+		Language:             p.Language,
+		FilePath:             source,
+		TextHash:             hash,
+		FromCache:            fromCache,
+		Pages:                meta.Pages,
+		SkippedPages:         meta.SkippedPages,
+		CharCount:            meta.CharCount,
+		LanguageWarning:      warning,
+		InDocumentDuplicates: inDocumentDuplicates(vocabulary),
+	}
+	p.progress(StageStoring, 0, 0)
+	if err := p.storeVocabulary(vocabulary, text, result); err != nil {
+		return nil, err
+	}
+
+	if extractErr != nil {
+		result.Partial = true
+		result.PartialError = extractErr.Error()
+	}
 
-	return &ProcessingResult{
-		NewVocabulary:     newCount,
-		SkippedDuplicates: skipCount,
-		TotalProcessed:    newCount + skipCount,
-		Language:          p.Language,
-		FilePath:          filePath,
-	}, nil
+	return result, nil
 }
 
-// processVocabulary inserts new vocabulary items and counts duplicates
-func (p *Processor) processVocabulary(vocabulary []string) (newCount, skipCount int) {
-	for _, word := range vocabulary {
-		exists, err := p.DB.ExistsText(word)
+// storeVocabulary filters extracted vocabulary, counts each item in text
+// when there is any, and stores it, recording the outcome on result
+func (p *Processor) storeVocabulary(vocabulary []ai.VocabularyItem, text string, result *ProcessingResult) error {
+	if p.storeMu != nil {
+		p.storeMu.Lock()
+		defer p.storeMu.Unlock()
+	}
+
+	if p.QualityFilter {
+		vocabulary, result.FilteredItems = ai.FilterJunk(vocabulary)
+	}
+	if p.StopWords != nil {
+		vocabulary, result.StopWordsRemoved = p.StopWords.Filter(p.Language, vocabulary)
+	}
+	if text != "" {
+		countInDocument(text, vocabulary)
+	}
+	if err := p.processVocabulary(vocabulary, result); err != nil {
+		return err
+	}
+
+	if len(p.Tags) > 0 {
+		texts := make([]string, len(vocabulary))
+		for i, item := range vocabulary {
+			texts[i] = item.Text
+		}
+		if err := p.DB.TagTexts(texts, p.Language, p.Tags); err != nil {
+			return fmt.Errorf("failed to tag vocabulary: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseDocument extracts the document text, honoring the page range if set
+func (p *Processor) parseDocument(ctx context.Context, filePath string) (string, parser.ParseMetadata, error) {
+	if p.FromPage == 0 && p.ToPage == 0 {
+		return parseFile(p.Parser, ctx, filePath)
+	}
+
+	if parser.DetectFileType(filePath) != parser.TypePDF {
+		return "", parser.ParseMetadata{}, fmt.Errorf("%w: page ranges are only supported for PDF documents", parser.ErrInvalidPageRange)
+	}
+	return p.Parser.ParsePDFWithMeta(ctx, filePath, p.FromPage, p.ToPage)
+}
+
+// parseReader is parseDocument for in-memory documents
+func (p *Processor) parseReader(ctx context.Context, r io.Reader, filename string, size int64) (string, parser.ParseMetadata, error) {
+	if p.FromPage == 0 && p.ToPage == 0 {
+		return parseStream(p.Parser, ctx, r, filename, size)
+	}
+
+	if parser.DetectFileType(filename) != parser.TypePDF {
+		return "", parser.ParseMetadata{}, fmt.Errorf("%w: page ranges are only supported for PDF documents", parser.ErrInvalidPageRange)
+	}
+	return p.Parser.ParsePDFFromReaderWithMeta(ctx, r, size, p.FromPage, p.ToPage)
+}
+
+// extractVocabulary sends the document to the AI one chunk at a time.
+// If a chunk fails, the vocabulary gathered from earlier chunks is returned
+// together with the error so the caller can still keep it. Once ctx is done
+// nothing is returned, as nobody is waiting for a partial result.
+func (p *Processor) extractVocabulary(ctx context.Context, text string) ([]ai.VocabularyItem, error) {
+	chunkSize := p.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	chunks := chunkText(text, chunkSize)
+	vocabulary := make([]ai.VocabularyItem, 0)
+	seen := make(map[string]int)
+
+	for i, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p.progress(StageExtracting, i+1, len(chunks))
+
+		items, err := p.extractChunk(ctx, chunk)
 		if err != nil {
+			return vocabulary, fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		vocabulary = mergeVocabulary(vocabulary, seen, items)
+	}
+
+	return vocabulary, nil
+}
+
+// minRetryChunkSize is the smallest chunk that is split again when the AI
+// response is truncated
+const minRetryChunkSize = 500
+
+// extractChunk runs a single AI extraction. If the response was cut off at
+// the token limit, the chunk is split in half and each half extracted
+// separately.
+func (p *Processor) extractChunk(ctx context.Context, text string) ([]ai.VocabularyItem, error) {
+	items, err := p.extractOnce(ctx, text)
+	if !errors.Is(err, ai.ErrResponseTruncated) || len(text) < 2*minRetryChunkSize {
+		return items, err
+	}
+
+	var vocabulary []ai.VocabularyItem
+	seen := make(map[string]int)
+	for _, half := range chunkText(text, utf8.RuneCountInString(text)/2+1) {
+		items, err := p.extractChunk(ctx, half)
+		if err != nil {
+			return nil, err
+		}
+		vocabulary = mergeVocabulary(vocabulary, seen, items)
+	}
+	return vocabulary, nil
+}
+
+// extractOnce runs a single AI extraction, using context extraction when
+// it is enabled and supported by the extractor, and otherwise collecting
+// translations when the extractor can provide them. Context extraction
+// returns translations and examples as well.
+func (p *Processor) extractOnce(ctx context.Context, text string) ([]ai.VocabularyItem, error) {
+	if extractor, ok := p.AI.(ai.ContextExtractor); ok && p.ExtractContext {
+		return extractor.ExtractVocabularyWithContext(ctx, text, p.Language)
+	}
+	if extractor, ok := p.AI.(ai.TranslationExtractor); ok {
+		return extractor.ExtractVocabularyWithTranslations(ctx, text, p.Language)
+	}
+
+	words, err := p.AI.ExtractVocabulary(ctx, text, p.Language)
+	if err != nil {
+		return nil, err
+	}
+	return textItems(words), nil
+}
+
+// textItems wraps plain words as vocabulary items without details
+func textItems(words []string) []ai.VocabularyItem {
+	items := make([]ai.VocabularyItem, 0, len(words))
+	for _, word := range words {
+		items = append(items, ai.VocabularyItem{Text: word})
+	}
+	return items
+}
+
+// processVocabulary stores vocabulary items and records the new and
+// duplicate counts on result. New items are inserted, and words that already
+// exist have their frequency incremented, in one transaction. Existing words
+// are then given the extracted translation if they have none yet, one at a
+// time; as the new items are already stored by then, a failed backfill is
+// logged and the word counted as skipped. Under DuplicateError nothing is
+// inserted if any word already exists.
+func (p *Processor) processVocabulary(vocabulary []ai.VocabularyItem, result *ProcessingResult) error {
+	if p.OnDuplicate == DuplicateError {
+		if err := p.checkDuplicates(vocabulary); err != nil {
+			return err
+		}
+	}
+
+	rows := make([]*db.Vocabulary, len(vocabulary))
+	for i, item := range vocabulary {
+		rows[i] = &db.Vocabulary{
+			Text:        item.Text,
+			Language:    p.Language,
+			Translation: item.Translation,
+			Context:     item.Context,
+			Example:     item.Example,
+			Occurrences: max(item.Count, 1),
+		}
+	}
+
+	// Words that already exist are skipped by InsertMany, which counts
+	// another document for them, and left with no ID, then handled
+	// according to the duplicate policy. Words that only differ by case
+	// from an earlier word of the document are repeats within it.
+	_, repeated, err := p.DB.InsertMany(rows)
+	if err != nil {
+		return fmt.Errorf("failed to store vocabulary: %w", err)
+	}
+
+	if result.NewItemIDs == nil {
+		result.NewItemIDs = []int{}
+	}
+
+	for i, row := range rows {
+		if repeated[i] {
+			result.InDocumentDuplicates++
 			continue
 		}
-		if exists {
-			skipCount++
+		if row.ID != 0 {
+			result.NewVocabulary++
+			result.NewItemIDs = append(result.NewItemIDs, row.ID)
+			if p.CollectWords {
+				result.NewWords = append(result.NewWords, WordCount{Text: row.Text, Count: row.Occurrences})
+			}
 			continue
 		}
-
-		_, err = p.DB.Insert(&db.Vocabulary{
-			Text:     word,
-			Language: p.Language,
-		})
+		updated, err := p.backfillTranslation(row)
 		if err != nil {
-			// Insert failure (e.g., race condition) is treated as a duplicate
-			skipCount++
-			continue
+			log.Printf("%v", err)
+		}
+		counted := p.OnDuplicate == DuplicateCount && p.DB.IncrementOccurrences(row.Text, row.Language) == nil
+		switch {
+		case updated:
+			result.UpdatedTranslations++
+		case counted:
+			result.RepeatedOccurrences++
+		default:
+			p.recordSkipped(result, row.Text)
 		}
+	}
+
+	result.TotalProcessed = result.NewVocabulary + result.SkippedExisting + result.RepeatedOccurrences + result.UpdatedTranslations
+	return nil
+}
 
-		newCount++
+// backfillTranslation stores the translation of an extracted word on the
+// existing item when that item has none, so re-processing a document after
+// enabling translations fills them in. It reports whether the item changed.
+func (p *Processor) backfillTranslation(row *db.Vocabulary) (bool, error) {
+	if row.Translation == "" {
+		return false, nil
 	}
 
-	return newCount, skipCount
+	existing, err := p.DB.GetByNormalized(row.Text, row.Language)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %q for its translation: %w", row.Text, err)
+	}
+	if existing.Translation != "" {
+		return false, nil
+	}
+
+	if _, _, err := p.DB.Upsert(row); err != nil {
+		return false, fmt.Errorf("failed to update translation of %q: %w", row.Text, err)
+	}
+	return true, nil
+}
+
+// recordSkipped counts a word that was already stored on the result
+func (p *Processor) recordSkipped(result *ProcessingResult, word string) {
+	result.SkippedExisting++
+	if p.CollectWords {
+		result.SkippedWords = append(result.SkippedWords, word)
+	}
 }
 
 // validateFilePath checks if a file path is valid, exists, and is a regular file
@@ -115,7 +677,7 @@ func validateFilePath(filePath string) error {
 // isValidFileType checks if the file has a supported extension
 func isValidFileType(filePath string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == ".pdf" || ext == ".docx"
+	return ext == ".pdf" || ext == ".docx" || ext == ".odt" || ext == ".txt" || ext == ".md" || ext == ".markdown" || ext == ".epub" || parser.ImageMIMEType(filePath) != ""
 }
 
 // GetVocabularyList retrieves all vocabulary from the database
@@ -123,14 +685,53 @@ func (p *Processor) GetVocabularyList() ([]*db.Vocabulary, error) {
 	return p.DB.List()
 }
 
+// GetVocabularyPage retrieves one page of the vocabulary matching filter,
+// ordered newest first by the given timestamp field
+func (p *Processor) GetVocabularyPage(filter db.Filter, field string, limit, offset int) ([]*db.Vocabulary, error) {
+	return p.DB.ListFilteredPaginated(filter, field, limit, offset)
+}
+
+// GetVocabularyCompactPage retrieves the ID and text of one page of
+// vocabulary like GetVocabularyPage
+func (p *Processor) GetVocabularyCompactPage(filter db.Filter, field string, limit, offset int) ([]*db.CompactVocabulary, error) {
+	return p.DB.ListCompactFilteredPaginated(filter, field, limit, offset)
+}
+
 // GetVocabularyByLanguage retrieves vocabulary for a specific language
 func (p *Processor) GetVocabularyByLanguage(language string) ([]*db.Vocabulary, error) {
 	return p.DB.SearchByLanguage(language)
 }
 
-// ExportVocabulary exports all vocabulary to a JSON file
-func (p *Processor) ExportVocabulary(filePath string) error {
-	return p.DB.ExportToJSON(filePath)
+// ExportVocabulary exports all vocabulary to a file in the given format
+func (p *Processor) ExportVocabulary(filePath string, format ExportFormat) error {
+	switch format {
+	case ExportJSON, "":
+		return p.DB.ExportToJSON(filePath)
+	case ExportCSV:
+		return p.DB.ExportToCSV(filePath)
+	case ExportAnki:
+		return p.DB.ExportToAnki(filePath)
+	case ExportHTML:
+		return p.DB.ExportToHTML(filePath)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// GetExport builds a versioned export of all vocabulary
+func (p *Processor) GetExport() (*db.Export, error) {
+	return p.DB.NewExport()
+}
+
+// GetLanguageExport builds a versioned export of the vocabulary in one
+// language
+func (p *Processor) GetLanguageExport(language string) (*db.Export, error) {
+	return p.DB.NewLanguageExport(language)
+}
+
+// BackupDatabase writes a consistent snapshot of the database to filePath
+func (p *Processor) BackupDatabase(filePath string) error {
+	return p.DB.BackupTo(filePath)
 }
 
 // GetVocabularyCount returns the total number of vocabulary items
@@ -138,7 +739,50 @@ func (p *Processor) GetVocabularyCount() (int, error) {
 	return p.DB.Count()
 }
 
-// DeleteVocabulary removes a vocabulary item by ID
+// GetFilteredCount returns the number of vocabulary items matching filter
+func (p *Processor) GetFilteredCount(filter db.Filter) (int, error) {
+	return p.DB.CountFiltered(filter)
+}
+
+// GetUntranslatedCount returns the number of vocabulary items still waiting
+// for a translation
+func (p *Processor) GetUntranslatedCount() (int, error) {
+	return p.DB.CountUntranslated()
+}
+
+// ErrEmptyText is returned by AddVocabulary for blank text
+var ErrEmptyText = errors.New("vocabulary text must not be empty")
+
+// AddVocabulary stores a single word or phrase entered by hand, under
+// language or the processor's language when it is empty, and returns the
+// stored item. It fails with db.ErrDuplicateText when the text is already
+// stored.
+func (p *Processor) AddVocabulary(text, language string) (*db.Vocabulary, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyText
+	}
+	if language = strings.TrimSpace(language); language == "" {
+		language = p.Language
+	}
+
+	id, err := p.DB.Insert(&db.Vocabulary{Text: text, Language: language})
+	if err != nil {
+		return nil, err
+	}
+	return p.DB.Get(id)
+}
+
+// DeleteVocabulary soft-deletes a vocabulary item by ID
 func (p *Processor) DeleteVocabulary(id int) error {
 	return p.DB.Delete(id)
 }
+
+// RestoreVocabulary brings back a soft-deleted vocabulary item and returns
+// it
+func (p *Processor) RestoreVocabulary(id int) (*db.Vocabulary, error) {
+	if err := p.DB.Restore(id); err != nil {
+		return nil, err
+	}
+	return p.DB.Get(id)
+}
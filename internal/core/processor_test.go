@@ -1,28 +1,107 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/parsely/parsely/internal/ai"
 	"github.com/parsely/parsely/internal/db"
+	"github.com/parsely/parsely/internal/parser"
 )
 
 // MockAIExtractor for testing
 type MockAIExtractor struct {
-	Vocabulary []string
-	Err        error
+	Vocabulary       []string
+	Err              error
+	DetectedLanguage string
 }
 
-func (m *MockAIExtractor) ExtractVocabulary(text, language string) ([]string, error) {
+func (m *MockAIExtractor) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
 	if m.Err != nil {
 		return nil, m.Err
 	}
 	return m.Vocabulary, nil
 }
 
+func (m *MockAIExtractor) DetectLanguage(ctx context.Context, text string) (string, error) {
+	if m.Err != nil {
+		return "", m.Err
+	}
+	return m.DetectedLanguage, nil
+}
+
+func (m *MockAIExtractor) Validate(ctx context.Context) error {
+	return nil
+}
+
+// chunkedMockAI returns a different vocabulary for each call and fails
+// from the FailOnCall-th call onwards (1-indexed, 0 never fails)
+type chunkedMockAI struct {
+	Responses  [][]string
+	FailOnCall int
+	calls      int
+}
+
+func (m *chunkedMockAI) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	m.calls++
+	if m.FailOnCall > 0 && m.calls >= m.FailOnCall {
+		return nil, &ai.AIError{Message: "request timed out", StatusCode: 504}
+	}
+	return m.Responses[(m.calls-1)%len(m.Responses)], nil
+}
+
+func (m *chunkedMockAI) DetectLanguage(ctx context.Context, text string) (string, error) {
+	return "", nil
+}
+
+func (m *chunkedMockAI) Validate(ctx context.Context) error {
+	return nil
+}
+
+// contextMockAI implements ai.ContextExtractor for testing
+type contextMockAI struct {
+	MockAIExtractor
+	Items []ai.VocabularyItem
+}
+
+func (m *contextMockAI) ExtractVocabularyWithContext(ctx context.Context, text, language string) ([]ai.VocabularyItem, error) {
+	return m.Items, nil
+}
+
+// translationMockAI implements ai.TranslationExtractor for testing
+type translationMockAI struct {
+	MockAIExtractor
+	Items []ai.VocabularyItem
+}
+
+func (m *translationMockAI) ExtractVocabularyWithTranslations(ctx context.Context, text, language string) ([]ai.VocabularyItem, error) {
+	return m.Items, nil
+}
+
+// recordingMockAI records the text and language it is asked to extract from
+type recordingMockAI struct {
+	MockAIExtractor
+	Texts     []string
+	Languages []string
+}
+
+func (m *recordingMockAI) ExtractVocabulary(ctx context.Context, text, language string) ([]string, error) {
+	m.Texts = append(m.Texts, text)
+	m.Languages = append(m.Languages, language)
+	return m.MockAIExtractor.ExtractVocabulary(ctx, text, language)
+}
+
 // TestProcessDocument tests end-to-end document processing
 func TestProcessDocument(t *testing.T) {
 	// Setup test database
@@ -43,15 +122,15 @@ func TestProcessDocument(t *testing.T) {
 
 	// Create a test file
 	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "test.txt")
+	testFile := filepath.Join(tmpDir, "test.rtf")
 	err := os.WriteFile(testFile, []byte("Spanish lesson content"), 0600)
 	if err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	// Note: Processing a .txt file will fail because we only support PDF/DOCX
+	// Note: Processing a .rtf file will fail because we only support PDF/DOCX/TXT
 	// This tests that the processor validates file types
-	result, err := processor.ProcessDocument(testFile)
+	result, err := processor.ProcessDocument(context.Background(), testFile)
 	if err == nil {
 		t.Error("Expected error for unsupported file type")
 	}
@@ -81,13 +160,313 @@ func TestProcessDocumentDeduplication(t *testing.T) {
 
 	// For this test, we'll directly test the vocabulary processing
 	vocab := mockAI.Vocabulary
-	newCount, skipCount := processor.processVocabulary(vocab)
+	result := &ProcessingResult{}
+	processor.processVocabulary(textItems(vocab), result)
+
+	if result.NewVocabulary != 1 {
+		t.Errorf("Expected 1 new item, got %d", result.NewVocabulary)
+	}
+	if result.SkippedExisting != 2 {
+		t.Errorf("Expected 2 skipped items, got %d", result.SkippedExisting)
+	}
+	if result.NewWords != nil || result.SkippedWords != nil {
+		t.Error("Word lists should not be collected unless CollectWords is set")
+	}
+}
+
+// TestProcessVocabularyCollectWords tests that new and skipped words are
+// reported individually when CollectWords is enabled
+func TestProcessVocabularyCollectWords(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+	database.Insert(&db.Vocabulary{Text: "gracias", Language: "Spanish"})
+
+	processor := &Processor{
+		DB:           database,
+		Language:     "Spanish",
+		CollectWords: true,
+	}
+
+	result := &ProcessingResult{}
+	processor.processVocabulary(textItems([]string{"hola", "adiós", "gracias", "por favor"}), result)
+
+	expectedNew := []WordCount{{Text: "adiós", Count: 1}, {Text: "por favor", Count: 1}}
+	expectedSkipped := []string{"hola", "gracias"}
+
+	if !slices.Equal(result.NewWords, expectedNew) {
+		t.Errorf("Expected NewWords %v, got %v", expectedNew, result.NewWords)
+	}
+	if strings.Join(result.SkippedWords, ",") != strings.Join(expectedSkipped, ",") {
+		t.Errorf("Expected SkippedWords %v, got %v", expectedSkipped, result.SkippedWords)
+	}
+	if result.TotalProcessed != 4 {
+		t.Errorf("Expected TotalProcessed=4, got %d", result.TotalProcessed)
+	}
+}
+
+// TestProcessVocabularyNewItemIDs tests that only newly added words report
+// their IDs, and that the list is empty rather than nil otherwise
+func TestProcessVocabularyNewItemIDs(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+
+	processor := &Processor{
+		DB:       database,
+		Language: "Spanish",
+	}
+
+	result := &ProcessingResult{}
+	if err := processor.processVocabulary(textItems([]string{"hola", "adiós", "por favor"}), result); err != nil {
+		t.Fatalf("Failed to process vocabulary: %v", err)
+	}
+
+	var want []int
+	for _, text := range []string{"adiós", "por favor"} {
+		vocab, err := database.GetByText(text)
+		if err != nil {
+			t.Fatalf("Failed to get %q: %v", text, err)
+		}
+		want = append(want, vocab.ID)
+	}
+	if !slices.Equal(result.NewItemIDs, want) {
+		t.Errorf("Expected NewItemIDs %v, got %v", want, result.NewItemIDs)
+	}
+
+	result = &ProcessingResult{}
+	if err := processor.processVocabulary(textItems([]string{"hola"}), result); err != nil {
+		t.Fatalf("Failed to process vocabulary: %v", err)
+	}
+	if result.NewItemIDs == nil || len(result.NewItemIDs) != 0 {
+		t.Errorf("Expected empty non-nil NewItemIDs, got %#v", result.NewItemIDs)
+	}
+}
+
+// TestProcessVocabularyCountDuplicates tests that repeated words bump the
+// occurrence counter instead of being skipped
+func TestProcessVocabularyCountDuplicates(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	processor := &Processor{
+		DB:          database,
+		Language:    "Spanish",
+		OnDuplicate: DuplicateCount,
+	}
+
+	result := &ProcessingResult{}
+	processor.processVocabulary(textItems([]string{"hola", "gracias"}), result)
+	if result.NewVocabulary != 2 {
+		t.Errorf("Expected 2 new items, got %d", result.NewVocabulary)
+	}
+
+	for i := 0; i < 2; i++ {
+		result = &ProcessingResult{}
+		processor.processVocabulary(textItems([]string{"hola"}), result)
+
+		if result.RepeatedOccurrences != 1 || result.SkippedExisting != 0 {
+			t.Errorf("Expected 1 repeated occurrence and no skips, got %+v", result)
+		}
+	}
+
+	hola, err := database.GetByText("hola")
+	if err != nil {
+		t.Fatalf("Failed to get 'hola': %v", err)
+	}
+	if hola.Occurrences != 3 {
+		t.Errorf("Expected 3 occurrences of 'hola', got %d", hola.Occurrences)
+	}
+
+	gracias, _ := database.GetByText("gracias")
+	if gracias.Occurrences != 1 {
+		t.Errorf("Expected 1 occurrence of 'gracias', got %d", gracias.Occurrences)
+	}
+}
+
+// TestProcessVocabularyFrequency tests that words found again in later
+// documents have their frequency incremented under the skip and count
+// policies, while still being reported as existing
+func TestProcessVocabularyFrequency(t *testing.T) {
+	for _, policy := range []DuplicatePolicy{DuplicateSkip, DuplicateCount} {
+		t.Run(string(policy), func(t *testing.T) {
+			database := setupTestDB(t)
+			defer database.Close()
+
+			processor := &Processor{DB: database, Language: "Spanish", OnDuplicate: policy}
+			processor.processVocabulary(textItems([]string{"hola", "gracias"}), &ProcessingResult{})
+
+			for i := 0; i < 2; i++ {
+				result := &ProcessingResult{}
+				if err := processor.processVocabulary(textItems([]string{"hola"}), result); err != nil {
+					t.Fatalf("Failed to process vocabulary: %v", err)
+				}
+				if result.NewVocabulary != 0 {
+					t.Errorf("Expected no new items, got %d", result.NewVocabulary)
+				}
+			}
+
+			hola, _ := database.GetByText("hola")
+			if hola.Frequency != 3 {
+				t.Errorf("Expected frequency 3 for 'hola', got %d", hola.Frequency)
+			}
+			gracias, _ := database.GetByText("gracias")
+			if gracias.Frequency != 1 {
+				t.Errorf("Expected frequency 1 for 'gracias', got %d", gracias.Frequency)
+			}
+		})
+	}
+}
+
+// TestProcessVocabularyBackfillsTranslations tests that re-processing a
+// document fills in missing translations without replacing existing ones
+func TestProcessVocabularyBackfillsTranslations(t *testing.T) {
+	for _, policy := range []DuplicatePolicy{DuplicateSkip, DuplicateCount} {
+		t.Run(string(policy), func(t *testing.T) {
+			database := setupTestDB(t)
+			defer database.Close()
+
+			processor := &Processor{DB: database, Language: "Spanish", OnDuplicate: policy}
+			processor.processVocabulary(textItems([]string{"hola", "gracias"}), &ProcessingResult{})
+			database.SetTranslation(2, "thanks")
+
+			result := &ProcessingResult{}
+			items := []ai.VocabularyItem{
+				{Text: "Hola", Translation: "hello"},
+				{Text: "gracias", Translation: "thank you"},
+				{Text: "adiós", Translation: "goodbye"},
+			}
+			if err := processor.processVocabulary(items, result); err != nil {
+				t.Fatalf("Failed to process vocabulary: %v", err)
+			}
+
+			if result.NewVocabulary != 1 || result.UpdatedTranslations != 1 || result.TotalProcessed != 3 {
+				t.Errorf("Expected 1 new, 1 updated, 3 total, got %+v", result)
+			}
+
+			hola, _ := database.GetByText("hola")
+			if hola.Translation != "hello" {
+				t.Errorf("Expected 'hola' to get translation 'hello', got %q", hola.Translation)
+			}
+			gracias, _ := database.GetByText("gracias")
+			if gracias.Translation != "thanks" {
+				t.Errorf("Expected existing translation 'thanks' to be kept, got %q", gracias.Translation)
+			}
+		})
+	}
+}
+
+// TestProcessVocabularyErrorOnDuplicate tests that the error policy rejects
+// the batch without inserting anything
+func TestProcessVocabularyErrorOnDuplicate(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	database.Insert(&db.Vocabulary{Text: "hola", Language: "Spanish"})
+
+	processor := &Processor{
+		DB:          database,
+		Language:    "Spanish",
+		OnDuplicate: DuplicateError,
+	}
+
+	result := &ProcessingResult{}
+	err := processor.processVocabulary(textItems([]string{"gracias", "hola"}), result)
+	if !errors.Is(err, ErrDuplicateVocabulary) {
+		t.Fatalf("Expected ErrDuplicateVocabulary, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "hola") {
+		t.Errorf("Error should name the duplicate word: %v", err)
+	}
+
+	exists, _ := database.ExistsText("gracias")
+	if exists {
+		t.Error("No words should be inserted when a duplicate is rejected")
+	}
+}
+
+// TestProcessVocabularyCaseInsensitiveDuplicates tests that a word differing
+// from a stored one only by case is skipped, keeping the stored casing, and
+// that case variants within one document count as repeats in it
+func TestProcessVocabularyCaseInsensitiveDuplicates(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	processor := &Processor{DB: database, Language: "Spanish"}
+
+	result := &ProcessingResult{}
+	if err := processor.processVocabulary(textItems([]string{"Madrid", "madrid"}), result); err != nil {
+		t.Fatalf("processVocabulary failed: %v", err)
+	}
+	if result.NewVocabulary != 1 || result.InDocumentDuplicates != 1 || result.SkippedExisting != 0 {
+		t.Errorf("Expected 1 new and 1 repeat, got %d new, %d repeats and %d skipped", result.NewVocabulary, result.InDocumentDuplicates, result.SkippedExisting)
+	}
 
-	if newCount != 1 {
-		t.Errorf("Expected 1 new item, got %d", newCount)
+	result = &ProcessingResult{}
+	if err := processor.processVocabulary(textItems([]string{"MADRID"}), result); err != nil {
+		t.Fatalf("processVocabulary failed: %v", err)
 	}
-	if skipCount != 2 {
-		t.Errorf("Expected 2 skipped items, got %d", skipCount)
+	if result.NewVocabulary != 0 || result.SkippedExisting != 1 {
+		t.Errorf("Expected the stored word to be skipped, got %d new and %d skipped", result.NewVocabulary, result.SkippedExisting)
+	}
+
+	items, _ := database.List()
+	if len(items) != 1 || items[0].Text != "Madrid" {
+		t.Errorf("Expected only the original casing to be stored, got %+v", items)
+	}
+}
+
+// TestParseDuplicatePolicy tests parsing on_duplicate values
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		input string
+		want  DuplicatePolicy
+		valid bool
+	}{
+		{"", DuplicateSkip, true},
+		{"skip", DuplicateSkip, true},
+		{"ERROR", DuplicateError, true},
+		{" count ", DuplicateCount, true},
+		{"ignore", "", false},
+	}
+
+	for _, tc := range tests {
+		policy, err := ParseDuplicatePolicy(tc.input)
+		if tc.valid && (err != nil || policy != tc.want) {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, %v; want %q", tc.input, policy, err, tc.want)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("ParseDuplicatePolicy(%q) expected error", tc.input)
+		}
+	}
+}
+
+// TestExportFormatForPath tests choosing the export format from a file name
+func TestExportFormatForPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want ExportFormat
+	}{
+		{"vocabulary.json", ExportJSON},
+		{"vocabulary.CSV", ExportCSV},
+		{"vocabulary", ExportJSON},
+		{"vocabulary.xlsx", ExportJSON},
+		{"deck.txt", ExportAnki},
+		{"deck.tsv", ExportAnki},
+		{"sheet.html", ExportHTML},
+		{"sheet.HTM", ExportHTML},
+	}
+
+	for _, tc := range tests {
+		if got := ExportFormatForPath(tc.path); got != tc.want {
+			t.Errorf("ExportFormatForPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+
+	if _, err := ParseExportFormat("xml"); err == nil {
+		t.Error("Expected error for unknown export format")
 	}
 }
 
@@ -99,7 +478,12 @@ func TestFileTypeDetection(t *testing.T) {
 	}{
 		{"test.pdf", true},
 		{"test.docx", true},
-		{"test.txt", false},
+		{"test.odt", true},
+		{"test.txt", true},
+		{"test.md", true},
+		{"test.markdown", true},
+		{"test.epub", true},
+		{"test.rtf", false},
 		{"test.doc", false},
 		{"test.PDF", true},
 		{"test.DOCX", true},
@@ -113,13 +497,777 @@ func TestFileTypeDetection(t *testing.T) {
 	}
 }
 
+// TestProcessDocumentInDocumentDuplicates tests that repeats within a
+// document are counted apart from words that were already stored
+func TestProcessDocumentInDocumentDuplicates(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	database.Insert(&db.Vocabulary{Text: "gracias", Language: "Spanish"})
+
+	// hola repeats within the first chunk and again with different case in
+	// the second, adiós across both chunks
+	mockAI := &chunkedMockAI{
+		Responses: [][]string{{"hola", "hola", "adiós"}, {"adiós", "gracias", "Hola"}},
+	}
+
+	processor := NewProcessor(database, mockAI, "Spanish")
+	processor.ChunkSize = 20
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	content := "Lección uno: hola\nLección dos: adiós\n"
+	if err := os.WriteFile(testFile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+
+	if result.InDocumentDuplicates != 3 {
+		t.Errorf("Expected 3 in-document duplicates, got %d", result.InDocumentDuplicates)
+	}
+	if result.SkippedExisting != 1 {
+		t.Errorf("Expected 1 existing item skipped, got %d", result.SkippedExisting)
+	}
+	if result.NewVocabulary != 2 || result.TotalProcessed != 3 {
+		t.Errorf("Expected 2 new of 3 processed, got %d of %d", result.NewVocabulary, result.TotalProcessed)
+	}
+	// One document yields hola once, whatever its casing
+	if vocab, err := database.GetByText("hola"); err != nil || vocab.Frequency != 1 {
+		t.Errorf("Expected hola at frequency 1, got %+v (%v)", vocab, err)
+	}
+}
+
+// TestProcessDocumentPartialResult tests that words from chunks extracted
+// before an AI failure are still stored
+func TestProcessDocumentPartialResult(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &chunkedMockAI{
+		Responses:  [][]string{{"hola"}, {"adiós"}, {"gracias"}},
+		FailOnCall: 3,
+	}
+
+	processor := NewProcessor(database, mockAI, "Spanish")
+	processor.ChunkSize = 20
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	content := "Lección uno: hola\nLección dos: adiós\nLección tres: gracias\n"
+	if err := os.WriteFile(testFile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Partial extraction should not fail the document: %v", err)
+	}
+
+	if !result.Partial {
+		t.Error("Expected Partial to be true")
+	}
+	if result.PartialError == "" {
+		t.Error("Expected PartialError to describe the failure")
+	}
+	if result.NewVocabulary != 2 {
+		t.Errorf("Expected 2 new items from earlier chunks, got %d", result.NewVocabulary)
+	}
+
+	for _, word := range []string{"hola", "adiós"} {
+		if exists, _ := database.ExistsText(word); !exists {
+			t.Errorf("Expected %q from an earlier chunk to be inserted", word)
+		}
+	}
+	if exists, _ := database.ExistsText("gracias"); exists {
+		t.Error("Word from the failed chunk should not be inserted")
+	}
+}
+
+// TestProcessReaderProgress tests the progress reported for a chunked
+// document and that cancelling stops extraction before the next chunk
+func TestProcessReaderProgress(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	content := "Lección uno: hola\nLección dos: adiós\nLección tres: gracias\n"
+	mockAI := &chunkedMockAI{Responses: [][]string{{"hola"}, {"adiós"}, {"gracias"}}}
+	processor := NewProcessor(database, mockAI, "auto-detect")
+	processor.ChunkSize = 22
+
+	var stages []Progress
+	_, err := processor.WithProgress(func(p Progress) {
+		stages = append(stages, p)
+	}).ProcessReader(context.Background(), strings.NewReader(content), "lesson.txt", int64(len(content)))
+	if err != nil {
+		t.Fatalf("ProcessReader failed: %v", err)
+	}
+
+	want := []Progress{
+		{Stage: StageParsed},
+		{Stage: StageDetecting},
+		{Stage: StageExtracting, Chunk: 1, Chunks: 3},
+		{Stage: StageExtracting, Chunk: 2, Chunks: 3},
+		{Stage: StageExtracting, Chunk: 3, Chunks: 3},
+		{Stage: StageStoring},
+	}
+	if !slices.Equal(stages, want) {
+		t.Errorf("Expected progress %v, got %v", want, stages)
+	}
+
+	// Cancel while the first chunk is being extracted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockAI = &chunkedMockAI{Responses: [][]string{{"uno"}, {"dos"}, {"tres"}}}
+	processor = NewProcessor(database, mockAI, "Spanish").WithProgress(func(p Progress) {
+		if p.Chunk == 1 {
+			cancel()
+		}
+	})
+	processor.ChunkSize = 22
+
+	_, err = processor.ProcessReader(ctx, strings.NewReader(content), "lesson.txt", int64(len(content)))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if mockAI.calls != 1 {
+		t.Errorf("Expected extraction to stop after the first chunk, got %d calls", mockAI.calls)
+	}
+	if exists, _ := database.ExistsText("uno"); exists {
+		t.Error("Expected nothing to be stored from a cancelled document")
+	}
+}
+
+// TestProcessDocumentFirstChunkFails tests that a failure with nothing
+// extracted is still reported as an error
+func TestProcessDocumentFirstChunkFails(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(database, &chunkedMockAI{Responses: [][]string{{"hola"}}, FailOnCall: 1}, "Spanish")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("hola"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err == nil {
+		t.Error("Expected error when no chunk succeeds")
+	}
+	if result != nil {
+		t.Error("Result should be nil on error")
+	}
+}
+
+// TestProcessReader tests processing an in-memory PDF without a file on disk
+func TestProcessReader(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	content, err := os.ReadFile("../../testdata/lesson.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read PDF fixture: %v", err)
+	}
+
+	mockAI := &recordingMockAI{MockAIExtractor: MockAIExtractor{Vocabulary: []string{"hola", "amigo"}}}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	result, err := processor.ProcessReader(context.Background(), bytes.NewReader(content), "lesson.pdf", int64(len(content)))
+	if err != nil {
+		t.Fatalf("Failed to process reader: %v", err)
+	}
+
+	if result.NewVocabulary != 2 {
+		t.Errorf("Expected 2 new items, got %d", result.NewVocabulary)
+	}
+	if result.FilePath != "lesson.pdf" {
+		t.Errorf("Expected FilePath to be the filename, got %q", result.FilePath)
+	}
+	if len(mockAI.Texts) != 1 || !strings.Contains(mockAI.Texts[0], "Hola amigo") || !strings.Contains(mockAI.Texts[0], "Muchas gracias") {
+		t.Errorf("Expected the PDF text to reach the AI, got %q", mockAI.Texts)
+	}
+
+	// A page range limits the text sent to the AI
+	mockAI.Texts = nil
+	_, err = processor.WithPages(2, 2).ProcessReader(context.Background(), bytes.NewReader(content), "lesson.pdf", int64(len(content)))
+	if err != nil {
+		t.Fatalf("Failed to process page range: %v", err)
+	}
+	if len(mockAI.Texts) != 1 || strings.Contains(mockAI.Texts[0], "Hola amigo") {
+		t.Errorf("Expected only page 2 text, got %q", mockAI.Texts)
+	}
+}
+
+// visionMockAI is a MockAIExtractor that can also read images
+type visionMockAI struct {
+	MockAIExtractor
+	ImageVocabulary []string
+	MIMEType        string
+	ImageBytes      []byte
+}
+
+func (m *visionMockAI) ExtractVocabularyFromImage(ctx context.Context, image []byte, mimeType, language string) ([]string, error) {
+	m.MIMEType = mimeType
+	m.ImageBytes = image
+	return m.ImageVocabulary, nil
+}
+
+// TestProcessReaderImage tests that images go to the vision extractor and
+// are rejected when the extractor has no vision support
+func TestProcessReaderImage(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	image := []byte("\xff\xd8\xff\xe0 fake jpeg")
+	mockAI := &visionMockAI{ImageVocabulary: []string{"la manzana", "el libro"}}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	result, err := processor.ProcessReader(context.Background(), bytes.NewReader(image), "page.jpg", int64(len(image)))
+	if err != nil {
+		t.Fatalf("Failed to process image: %v", err)
+	}
+	if result.NewVocabulary != 2 {
+		t.Errorf("Expected 2 new items, got %d", result.NewVocabulary)
+	}
+	if mockAI.MIMEType != "image/jpeg" || !bytes.Equal(mockAI.ImageBytes, image) {
+		t.Errorf("Expected the JPEG bytes to reach the AI, got %q with %d bytes", mockAI.MIMEType, len(mockAI.ImageBytes))
+	}
+	for _, word := range mockAI.ImageVocabulary {
+		if exists, _ := database.ExistsText(word); !exists {
+			t.Errorf("Expected %q to be stored", word)
+		}
+	}
+
+	// Without an explicit language, the extracted words decide it
+	detecting := &visionMockAI{
+		MockAIExtractor: MockAIExtractor{DetectedLanguage: "Spanish"},
+		ImageVocabulary: []string{"la mesa"},
+	}
+	result, err = NewProcessor(database, detecting, "auto-detect").ProcessReader(context.Background(), bytes.NewReader(image), "menu.png", int64(len(image)))
+	if err != nil {
+		t.Fatalf("Failed to process image: %v", err)
+	}
+	if result.Language != "Spanish" {
+		t.Errorf("Expected detected language Spanish, got %q", result.Language)
+	}
+	if vocab, err := database.GetByText("la mesa"); err != nil || vocab.Language != "Spanish" {
+		t.Errorf("Expected la mesa stored as Spanish, got %+v (%v)", vocab, err)
+	}
+
+	textOnly := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola"}}, "Spanish")
+	_, err = textOnly.ProcessReader(context.Background(), bytes.NewReader(image), "page.png", int64(len(image)))
+	if !errors.Is(err, ErrImagesUnsupported) {
+		t.Errorf("Expected ErrImagesUnsupported, got %v", err)
+	}
+}
+
+// TestProcessReaderParserPanic tests that a parser panic becomes an error
+// and nothing is stored
+func TestProcessReaderParserPanic(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	original := parseStream
+	parseStream = func(_ parser.ParserConfig, _ context.Context, r io.Reader, filename string, size int64) (string, parser.ParseMetadata, error) {
+		var pages []string
+		return pages[3], parser.ParseMetadata{}, nil
+	}
+	t.Cleanup(func() { parseStream = original })
+
+	processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola"}}, "Spanish")
+
+	_, err := processor.ProcessReader(context.Background(), strings.NewReader("Hola"), "lesson.docx", 4)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected PanicError, got %v", err)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("Expected PanicError to carry a stack trace")
+	}
+
+	if count, _ := database.Count(); count != 0 {
+		t.Errorf("Expected nothing stored after a panic, got %d items", count)
+	}
+}
+
+// TestProcessReaderRejectsInvalidInput tests filename, type and size checks
+func TestProcessReaderRejectsInvalidInput(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola"}}, "Spanish")
+
+	tests := []struct {
+		name     string
+		filename string
+		size     int64
+	}{
+		{"Unsupported type", "notes.rtf", 4},
+		{"Path in filename", "../notes.txt", 4},
+		{"Declared size too large", "notes.txt", parser.MaxFileSize + 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := processor.ProcessReader(context.Background(), strings.NewReader("hola"), tc.filename, tc.size)
+			if err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+
+	var sizeErr *parser.FileTooLargeError
+	_, err := processor.ProcessReader(context.Background(), strings.NewReader("hola"), "notes.txt", parser.MaxFileSize+1)
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("Expected FileTooLargeError, got %v", err)
+	}
+}
+
+// TestProcessDocumentQualityFilter tests that junk tokens are dropped and
+// counted before insertion
+func TestProcessDocumentQualityFilter(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &MockAIExtractor{Vocabulary: []string{"hola", "42", "Unit 3", "gracias", "?"}}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Unit 3 - hola, gracias. 42"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+
+	if result.FilteredItems != 3 {
+		t.Errorf("Expected 3 filtered items, got %d", result.FilteredItems)
+	}
+	if result.NewVocabulary != 2 || result.TotalProcessed != 2 {
+		t.Errorf("Expected 2 new and 2 processed, got %d and %d", result.NewVocabulary, result.TotalProcessed)
+	}
+
+	exists, _ := database.ExistsText("42")
+	if exists {
+		t.Error("Filtered token should not be stored")
+	}
+}
+
+// TestProcessDocumentWithContext tests that sentence context is stored
+// when context extraction is enabled
+func TestProcessDocumentWithContext(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &contextMockAI{
+		MockAIExtractor: MockAIExtractor{Vocabulary: []string{"hola"}},
+		Items: []ai.VocabularyItem{
+			{Text: "hola", Context: "Hola, ¿cómo estás?"},
+			{Text: "gracias", Context: "Muchas gracias por todo."},
+		},
+	}
+
+	processor := NewProcessor(database, mockAI, "Spanish")
+	processor.ExtractContext = true
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Hola, ¿cómo estás? Muchas gracias por todo."), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := processor.ProcessDocument(context.Background(), testFile); err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+
+	vocab, err := database.GetByText("gracias")
+	if err != nil {
+		t.Fatalf("Expected 'gracias' to be stored: %v", err)
+	}
+	if vocab.Context != "Muchas gracias por todo." {
+		t.Errorf("Expected context to be stored, got %q", vocab.Context)
+	}
+
+	// Without the flag, the plain extractor is used and no context is stored
+	processor.ExtractContext = false
+	database.Delete(vocab.ID)
+	mockAI.Vocabulary = []string{"gracias"}
+	if _, err := processor.ProcessDocument(context.Background(), testFile); err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	vocab, _ = database.GetByText("gracias")
+	if vocab == nil || vocab.Context != "" {
+		t.Errorf("Expected no context when ExtractContext is off, got %+v", vocab)
+	}
+}
+
+// TestProcessDocumentWithContextTranslations tests that context extraction
+// with the Claude client still stores each word's translation and example
+func TestProcessDocumentWithContextTranslations(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	items := `[{"word": "gracias", "context": "Muchas gracias por todo.", "translation": "thank you", "example": "Gracias, Ana."}]`
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil && len(req.Messages) > 0 && len(req.Messages[0].Content) > 0 {
+			prompts = append(prompts, req.Messages[0].Content[0].Text)
+		}
+		text, _ := json.Marshal(items)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":%s}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`, text)
+	}))
+	defer server.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", server.URL)
+
+	client, err := ai.NewClaudeClient("sk-ant-test")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	processor := NewProcessor(database, client, "Spanish")
+	processor.ExtractContext = true
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Muchas gracias por todo."), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := processor.ProcessDocument(context.Background(), testFile); err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+
+	if len(prompts) != 1 || !strings.Contains(prompts[0], `"context"`) {
+		t.Fatalf("Expected one context extraction request, got %q", prompts)
+	}
+	vocab, err := database.GetByText("gracias")
+	if err != nil {
+		t.Fatalf("Expected 'gracias' to be stored: %v", err)
+	}
+	if vocab.Context != "Muchas gracias por todo." || vocab.Translation != "thank you" || vocab.Example != "Gracias, Ana." {
+		t.Errorf("Expected context, translation and example to be stored, got %+v", vocab)
+	}
+}
+
+// TestProcessDocumentStoresTranslations tests that translations returned by
+// the extractor are stored with each word
+func TestProcessDocumentStoresTranslations(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &translationMockAI{
+		Items: []ai.VocabularyItem{
+			{Text: "hola", Translation: "hello"},
+			{Text: "sobremesa"},
+		},
+	}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Hola. La sobremesa fue larga."), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := processor.ProcessDocument(context.Background(), testFile); err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+
+	tests := []struct {
+		text        string
+		translation string
+	}{
+		{"hola", "hello"},
+		{"sobremesa", ""},
+	}
+	for _, tc := range tests {
+		vocab, err := database.GetByText(tc.text)
+		if err != nil {
+			t.Fatalf("Expected %q to be stored: %v", tc.text, err)
+		}
+		if vocab.Translation != tc.translation {
+			t.Errorf("%s: expected translation %q, got %q", tc.text, tc.translation, vocab.Translation)
+		}
+	}
+}
+
+// TestProcessDocumentDetectsLanguage tests that auto-detect asks the AI for
+// the document language and uses it for extraction, storage and the result
+func TestProcessDocumentDetectsLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		detected string
+		want     string
+	}{
+		{"Auto-detect uses detected language", "auto-detect", "Spanish", "Spanish"},
+		{"Empty language uses detected language", "", "Spanish", "Spanish"},
+		{"Explicit language is kept", "French", "Spanish", "French"},
+		{"Undetected language stays auto-detect", "auto-detect", "", "auto-detect"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			database := setupTestDB(t)
+			defer database.Close()
+
+			mockAI := &recordingMockAI{MockAIExtractor: MockAIExtractor{
+				Vocabulary:       []string{"hola"},
+				DetectedLanguage: tc.detected,
+			}}
+			processor := NewProcessor(database, mockAI, tc.language)
+
+			testFile := filepath.Join(t.TempDir(), "lesson.txt")
+			if err := os.WriteFile(testFile, []byte("Hola, ¿qué tal?"), 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			result, err := processor.ProcessDocument(context.Background(), testFile)
+			if err != nil {
+				t.Fatalf("Failed to process document: %v", err)
+			}
+
+			if result.Language != tc.want {
+				t.Errorf("Expected result language %q, got %q", tc.want, result.Language)
+			}
+			if len(mockAI.Languages) != 1 || mockAI.Languages[0] != tc.want {
+				t.Errorf("Expected extraction in %q, got %v", tc.want, mockAI.Languages)
+			}
+			vocab, err := database.GetByText("hola")
+			if err != nil {
+				t.Fatalf("Expected hola to be stored: %v", err)
+			}
+			if vocab.Language != tc.want {
+				t.Errorf("Expected stored language %q, got %q", tc.want, vocab.Language)
+			}
+			if processor.Language != tc.language {
+				t.Errorf("Processor language changed to %q", processor.Language)
+			}
+		})
+	}
+}
+
+// TestProcessDocumentExtractionCache tests that processing the same text
+// again reuses the cached extraction, including its detected language, and
+// that ForceExtract and other settings go back to the AI
+func TestProcessDocumentExtractionCache(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &recordingMockAI{MockAIExtractor: MockAIExtractor{
+		Vocabulary:       []string{"hola", "amigo"},
+		DetectedLanguage: "Spanish",
+	}}
+	processor := NewProcessor(database, mockAI, "auto-detect")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Hola, amigo."), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	first, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	if first.FromCache || first.TextHash == "" {
+		t.Errorf("Expected a fresh extraction with a text hash, got %+v", first)
+	}
+
+	// Clearing the vocabulary shows the cached words go through the
+	// normal insert path again
+	if _, err := database.DeleteAll(); err != nil {
+		t.Fatalf("Failed to clear vocabulary: %v", err)
+	}
+
+	second, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document again: %v", err)
+	}
+	if !second.FromCache || second.TextHash != first.TextHash {
+		t.Errorf("Expected a cache hit for hash %s, got %+v", first.TextHash, second)
+	}
+	if len(mockAI.Texts) != 1 {
+		t.Errorf("Expected 1 AI extraction, got %d", len(mockAI.Texts))
+	}
+	if second.NewVocabulary != 2 || second.Language != "Spanish" {
+		t.Errorf("Expected 2 new Spanish words from the cache, got %d in %q", second.NewVocabulary, second.Language)
+	}
+	if vocab, err := database.GetByText("hola"); err != nil || vocab.Language != "Spanish" {
+		t.Errorf("Expected hola stored as Spanish, got %v (%v)", vocab, err)
+	}
+
+	if _, err := processor.WithForceExtract().ProcessDocument(context.Background(), testFile); err != nil {
+		t.Fatalf("Failed to force extraction: %v", err)
+	}
+	if len(mockAI.Texts) != 2 {
+		t.Errorf("Expected ForceExtract to call the AI, got %d extractions", len(mockAI.Texts))
+	}
+
+	result, err := processor.WithLanguage("French").ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document in French: %v", err)
+	}
+	if result.FromCache || len(mockAI.Texts) != 3 {
+		t.Errorf("Expected a different language to miss the cache, got %d extractions", len(mockAI.Texts))
+	}
+}
+
+// limitedMockAI is a recordingMockAI that reports length limits like the
+// Claude client
+type limitedMockAI struct {
+	recordingMockAI
+	Limits ai.LengthLimits
+}
+
+func (m *limitedMockAI) LengthLimits() ai.LengthLimits {
+	return m.Limits
+}
+
+// TestProcessDocumentExtractionCacheLimits tests that changing the length
+// limits misses the extraction cache, since they change what is extracted
+func TestProcessDocumentExtractionCacheLimits(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &limitedMockAI{
+		recordingMockAI: recordingMockAI{MockAIExtractor: MockAIExtractor{Vocabulary: []string{"hola"}}},
+		Limits:          ai.DefaultLengthLimits(),
+	}
+	processor := NewProcessor(database, mockAI, "Spanish")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Hola, amigo."), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for i, want := range []bool{false, true} {
+		result, err := processor.ProcessDocument(context.Background(), testFile)
+		if err != nil {
+			t.Fatalf("Failed to process document: %v", err)
+		}
+		if result.FromCache != want {
+			t.Errorf("Run %d: expected FromCache %v, got %v", i+1, want, result.FromCache)
+		}
+	}
+
+	mockAI.Limits = ai.LengthLimits{MinLength: 3, MaxWords: 2}
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	if result.FromCache || len(mockAI.Texts) != 2 {
+		t.Errorf("Expected new limits to miss the cache, got %d extractions", len(mockAI.Texts))
+	}
+}
+
+// TestProcessDocumentPartialNotCached tests that an extraction that failed
+// part-way is not reused
+func TestProcessDocumentPartialNotCached(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &chunkedMockAI{Responses: [][]string{{"uno"}, {"dos"}}, FailOnCall: 2}
+	processor := NewProcessor(database, mockAI, "Spanish")
+	processor.ChunkSize = 50
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	text := strings.Repeat("Uno dos tres cuatro cinco seis siete ocho. ", 4)
+	if err := os.WriteFile(testFile, []byte(text), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	if !result.Partial {
+		t.Fatal("Expected a partial result")
+	}
+
+	mockAI.calls = 0
+	mockAI.FailOnCall = 0
+	result, err = processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document again: %v", err)
+	}
+	if result.FromCache || mockAI.calls == 0 {
+		t.Errorf("Expected the partial extraction not to be cached, got %d AI calls", mockAI.calls)
+	}
+}
+
+// TestProcessDocumentParseMetadata tests that the parser's page and
+// character counts are reported on the result
+func TestProcessDocumentParseMetadata(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola"}}, "Spanish")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("¡Hola, niño!"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	result, err := processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	if result.CharCount != 12 || result.Pages != 0 || result.SkippedPages != 0 {
+		t.Errorf("Expected 12 characters and no pages, got %+v", result)
+	}
+
+	original := parseFile
+	parseFile = func(_ parser.ParserConfig, _ context.Context, filePath string) (string, parser.ParseMetadata, error) {
+		return "Hola amigo", parser.ParseMetadata{Pages: 15, SkippedPages: 3, CharCount: 10}, nil
+	}
+	t.Cleanup(func() { parseFile = original })
+
+	result, err = processor.ProcessDocument(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Failed to process document: %v", err)
+	}
+	if result.Pages != 15 || result.SkippedPages != 3 || result.CharCount != 10 {
+		t.Errorf("Expected 15 pages with 3 skipped and 10 characters, got %+v", result)
+	}
+}
+
+// TestProcessDocumentDetectionError tests that a failed language detection
+// aborts processing before extraction
+func TestProcessDocumentDetectionError(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	mockAI := &MockAIExtractor{Err: &ai.AIError{Message: "overloaded", StatusCode: 529}}
+	processor := NewProcessor(database, mockAI, "auto-detect")
+
+	testFile := filepath.Join(t.TempDir(), "lesson.txt")
+	if err := os.WriteFile(testFile, []byte("Hola, ¿qué tal?"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	_, err := processor.ProcessDocument(context.Background(), testFile)
+	if err == nil || !strings.Contains(err.Error(), "failed to detect language") {
+		t.Fatalf("Expected a detection error, got %v", err)
+	}
+	if !ai.IsAIError(err) {
+		t.Errorf("Expected the AI error to be wrapped, got %v", err)
+	}
+}
+
 // TestProcessingResult tests the result structure
 func TestProcessingResult(t *testing.T) {
 	result := &ProcessingResult{
-		NewVocabulary:     5,
-		SkippedDuplicates: 3,
-		TotalProcessed:    8,
-		Language:          "Spanish",
+		NewVocabulary:   5,
+		SkippedExisting: 3,
+		TotalProcessed:  8,
+		Language:        "Spanish",
 	}
 
 	if result.NewVocabulary != 5 {
@@ -147,13 +1295,14 @@ func TestEmptyDocument(t *testing.T) {
 		Language:  "Spanish",
 	}
 
-	newCount, skipCount := processor.processVocabulary([]string{})
+	result := &ProcessingResult{}
+	processor.processVocabulary(textItems([]string{}), result)
 
-	if newCount != 0 {
-		t.Errorf("Expected 0 new items for empty vocab, got %d", newCount)
+	if result.NewVocabulary != 0 {
+		t.Errorf("Expected 0 new items for empty vocab, got %d", result.NewVocabulary)
 	}
-	if skipCount != 0 {
-		t.Errorf("Expected 0 skipped items for empty vocab, got %d", skipCount)
+	if result.SkippedExisting != 0 {
+		t.Errorf("Expected 0 skipped items for empty vocab, got %d", result.SkippedExisting)
 	}
 }
 
@@ -168,7 +1317,7 @@ func TestAIError(t *testing.T) {
 	}
 
 	// Test that AI errors are propagated
-	_, err := mockAI.ExtractVocabulary("test", "Spanish")
+	_, err := mockAI.ExtractVocabulary(context.Background(), "test", "Spanish")
 	if err == nil {
 		t.Error("Expected error from mock AI")
 	}
@@ -190,20 +1339,22 @@ func TestProcessVocabularyInsertError(t *testing.T) {
 
 	// Insert a vocabulary item
 	vocab := []string{"test"}
-	newCount, skipCount := processor.processVocabulary(vocab)
+	result := &ProcessingResult{}
+	processor.processVocabulary(textItems(vocab), result)
 
-	if newCount != 1 {
-		t.Errorf("Expected 1 new item, got %d", newCount)
+	if result.NewVocabulary != 1 {
+		t.Errorf("Expected 1 new item, got %d", result.NewVocabulary)
 	}
 
 	// Try to insert the same item again (should be skipped)
-	newCount, skipCount = processor.processVocabulary(vocab)
+	result = &ProcessingResult{}
+	processor.processVocabulary(textItems(vocab), result)
 
-	if newCount != 0 {
-		t.Errorf("Expected 0 new items on duplicate, got %d", newCount)
+	if result.NewVocabulary != 0 {
+		t.Errorf("Expected 0 new items on duplicate, got %d", result.NewVocabulary)
 	}
-	if skipCount != 1 {
-		t.Errorf("Expected 1 skipped item on duplicate, got %d", skipCount)
+	if result.SkippedExisting != 1 {
+		t.Errorf("Expected 1 skipped item on duplicate, got %d", result.SkippedExisting)
 	}
 }
 
@@ -0,0 +1,32 @@
+package core
+
+// Stages reported through Processor.OnProgress, in the order they happen
+const (
+	StageParsed     = "parsed"
+	StageDetecting  = "detecting_language"
+	StageExtracting = "extracting"
+	StageStoring    = "storing"
+)
+
+// Progress reports how far processing a document has got. Chunk and Chunks
+// are only set for StageExtracting, where Chunk is the 1-indexed chunk about
+// to be sent to the AI.
+type Progress struct {
+	Stage  string `json:"stage"`
+	Chunk  int    `json:"chunk,omitempty"`
+	Chunks int    `json:"chunks,omitempty"`
+}
+
+// WithProgress returns a copy of the processor that reports progress to fn
+func (p *Processor) WithProgress(fn func(Progress)) *Processor {
+	clone := *p
+	clone.OnProgress = fn
+	return &clone
+}
+
+// progress calls OnProgress, if set
+func (p *Processor) progress(stage string, chunk, chunks int) {
+	if p.OnProgress != nil {
+		p.OnProgress(Progress{Stage: stage, Chunk: chunk, Chunks: chunks})
+	}
+}
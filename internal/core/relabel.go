@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// RelabelAutoDetected assigns a concrete language to rows stored as
+// "auto-detect" or with no language, which predate language detection. The
+// language is guessed from the word and its stored context; rows whose
+// language cannot be told apart are left unchanged.
+func (p *Processor) RelabelAutoDetected(ctx context.Context) (updated int, err error) {
+	items, err := p.DB.ListAutoDetected()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		detected := detectWordLanguage(item.Text + " " + item.Context)
+		if detected == "" {
+			continue
+		}
+
+		if err := p.DB.SetLanguage(item.ID, capitalize(detected)); err != nil {
+			return updated, fmt.Errorf("failed to relabel vocabulary: %w", err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
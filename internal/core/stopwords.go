@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+//go:embed stopwords/*.txt
+var defaultStopWordFiles embed.FS
+
+// languageCodes maps ISO 639-1 codes to the language names used as stop
+// word list keys
+var languageCodes = map[string]string{
+	"en": "english",
+	"es": "spanish",
+	"fr": "french",
+	"de": "german",
+	"it": "italian",
+	"pt": "portuguese",
+}
+
+// StopWords holds per-language lists of ultra-common words that are not
+// worth storing as vocabulary
+type StopWords struct {
+	lists map[string]map[string]bool
+}
+
+// DefaultStopWords returns the embedded stop word lists
+func DefaultStopWords() (*StopWords, error) {
+	s := &StopWords{lists: make(map[string]map[string]bool)}
+
+	entries, err := defaultStopWordFiles.ReadDir("stopwords")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded stop words: %w", err)
+	}
+	for _, entry := range entries {
+		if err := s.readEmbedded(entry.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// readEmbedded adds the words of one embedded list, keyed by its file name
+func (s *StopWords) readEmbedded(name string) error {
+	file, err := defaultStopWordFiles.Open(path.Join("stopwords", name))
+	if err != nil {
+		return fmt.Errorf("failed to open embedded stop words %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if err := s.read(file, strings.TrimSuffix(name, ".txt")); err != nil {
+		return fmt.Errorf("failed to read embedded stop words %s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadStopWords returns the embedded stop words plus those in filePath, if
+// it is not empty
+func LoadStopWords(filePath string) (*StopWords, error) {
+	s, err := DefaultStopWords()
+	if err != nil {
+		return nil, err
+	}
+	if filePath == "" {
+		return s, nil
+	}
+	if err := s.LoadFile(filePath); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadFile adds stop words from a user-provided file. Words are listed one
+// per line under a "[language]" header; words before the first header apply
+// to every language. Lines starting with "#" are comments.
+func (s *StopWords) LoadFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open stop words file: %w", err)
+	}
+	defer file.Close()
+
+	if err := s.read(file, "*"); err != nil {
+		return fmt.Errorf("failed to read stop words file: %w", err)
+	}
+	return nil
+}
+
+// read adds the words from r, starting under the given language key
+func (s *StopWords) read(r io.Reader, language string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			language = stopWordKey(strings.Trim(line, "[]"))
+			continue
+		}
+
+		if s.lists[language] == nil {
+			s.lists[language] = make(map[string]bool)
+		}
+		s.lists[language][strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Contains reports whether word is a stop word in the given language
+func (s *StopWords) Contains(language, word string) bool {
+	word = strings.ToLower(strings.TrimSpace(word))
+	return s.lists[stopWordKey(language)][word] || s.lists["*"][word]
+}
+
+// Filter drops stop words for the given language and returns how many were
+// dropped
+func (s *StopWords) Filter(language string, items []ai.VocabularyItem) ([]ai.VocabularyItem, int) {
+	kept := make([]ai.VocabularyItem, 0, len(items))
+	for _, item := range items {
+		if !s.Contains(language, item.Text) {
+			kept = append(kept, item)
+		}
+	}
+	return kept, len(items) - len(kept)
+}
+
+// stopWordKey normalizes a language name or code to a list key
+func stopWordKey(language string) string {
+	key := strings.ToLower(strings.TrimSpace(language))
+	if name, ok := languageCodes[key]; ok {
+		return name
+	}
+	return key
+}
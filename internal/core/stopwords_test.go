@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parsely/parsely/internal/ai"
+)
+
+// TestStopWordsFilter tests that stop words are only dropped for the
+// matching language
+func TestStopWordsFilter(t *testing.T) {
+	stopWords, err := DefaultStopWords()
+	if err != nil {
+		t.Fatalf("DefaultStopWords failed: %v", err)
+	}
+	items := textItems([]string{"de", "la", "Los", "biblioteca", "hablar", "the"})
+
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{"Spanish", []string{"biblioteca", "hablar", "the"}},
+		{"es", []string{"biblioteca", "hablar", "the"}},
+		{"English", []string{"de", "la", "Los", "biblioteca", "hablar"}},
+		{"Klingon", []string{"de", "la", "Los", "biblioteca", "hablar", "the"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.language, func(t *testing.T) {
+			kept, removed := stopWords.Filter(tc.language, items)
+			if removed != len(items)-len(tc.want) {
+				t.Errorf("Expected %d removed, got %d", len(items)-len(tc.want), removed)
+			}
+			if len(kept) != len(tc.want) {
+				t.Fatalf("Expected %v, got %+v", tc.want, kept)
+			}
+			for i, item := range kept {
+				if item.Text != tc.want[i] {
+					t.Errorf("Expected %q at %d, got %q", tc.want[i], i, item.Text)
+				}
+			}
+		})
+	}
+}
+
+// TestStopWordsLoadFile tests user-provided stop words
+func TestStopWordsLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords.txt")
+	content := "# comment\netc\n[Spanish]\npues\n[fr]\nbon\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write stop words file: %v", err)
+	}
+
+	stopWords, err := LoadStopWords(path)
+	if err != nil {
+		t.Fatalf("Failed to load stop words: %v", err)
+	}
+
+	tests := []struct {
+		language, word string
+		want           bool
+	}{
+		{"Spanish", "pues", true},
+		{"Spanish", "de", true},
+		{"English", "pues", false},
+		{"French", "bon", true},
+		{"German", "etc", true},
+		{"Spanish", "# comment", false},
+	}
+
+	for _, tc := range tests {
+		if got := stopWords.Contains(tc.language, tc.word); got != tc.want {
+			t.Errorf("Contains(%q, %q) = %v, want %v", tc.language, tc.word, got, tc.want)
+		}
+	}
+
+	if _, err := LoadStopWords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected error for a missing stop words file")
+	}
+}
+
+// TestProcessDocumentStopWords tests the toggle on the processor
+func TestProcessDocumentStopWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		wantNew  int
+		wantStop int
+	}{
+		{"Enabled", true, 2, 2},
+		{"Disabled", false, 4, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			database := setupTestDB(t)
+			defer database.Close()
+
+			mockAI := &contextMockAI{Items: []ai.VocabularyItem{{Text: "el"}, {Text: "perro"}, {Text: "de"}, {Text: "casa"}}}
+			processor := NewProcessor(database, mockAI, "Spanish")
+			processor.ExtractContext = true
+			if tc.enabled {
+				stopWords, err := DefaultStopWords()
+				if err != nil {
+					t.Fatalf("DefaultStopWords failed: %v", err)
+				}
+				processor.StopWords = stopWords
+			}
+
+			testFile := filepath.Join(t.TempDir(), "lesson.txt")
+			if err := os.WriteFile(testFile, []byte("El perro de la casa."), 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			result, err := processor.ProcessDocument(context.Background(), testFile)
+			if err != nil {
+				t.Fatalf("Failed to process document: %v", err)
+			}
+
+			if result.NewVocabulary != tc.wantNew || result.StopWordsRemoved != tc.wantStop {
+				t.Errorf("Expected %d new and %d stop words removed, got %d and %d",
+					tc.wantNew, tc.wantStop, result.NewVocabulary, result.StopWordsRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// prefixed with "sha256=", when a webhook secret is configured
+const WebhookSignatureHeader = "X-Parsely-Signature"
+
+const (
+	webhookTimeout     = 10 * time.Second
+	webhookRetries     = 2
+	webhookConcurrency = 4
+)
+
+// Webhook posts processing results to a URL in the background. At most
+// webhookConcurrency deliveries run at once; notifications beyond that are
+// dropped rather than queued so a slow receiver cannot pile up goroutines.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+
+	// Retries is how many times a failed delivery is retried
+	Retries int
+
+	// RetryDelay is the pause before the first retry; it grows linearly
+	RetryDelay time.Duration
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	// ctx is cancelled by Close to stop deliveries waiting to retry
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWebhook creates a webhook for url. An empty secret disables signing.
+func NewWebhook(url, secret string) *Webhook {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Webhook{
+		URL:        url,
+		Secret:     secret,
+		Client:     &http.Client{Timeout: webhookTimeout},
+		Retries:    webhookRetries,
+		RetryDelay: 500 * time.Millisecond,
+		slots:      make(chan struct{}, webhookConcurrency),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Notify delivers result in a background goroutine and returns immediately
+func (wh *Webhook) Notify(result *ProcessingResult) {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("failed to encode webhook payload: %v", err)
+		return
+	}
+
+	select {
+	case wh.slots <- struct{}{}:
+	default:
+		log.Printf("webhook delivery dropped: %d deliveries already in flight", cap(wh.slots))
+		return
+	}
+
+	wh.wg.Add(1)
+	go func() {
+		defer wh.wg.Done()
+		defer func() { <-wh.slots }()
+
+		if err := wh.deliver(wh.ctx, payload); err != nil {
+			log.Printf("webhook delivery failed: %v", err)
+		}
+	}()
+}
+
+// Wait blocks until all in-flight deliveries have finished
+func (wh *Webhook) Wait() {
+	wh.wg.Wait()
+}
+
+// Close abandons deliveries that are waiting to retry or still posting, then
+// waits for them to return
+func (wh *Webhook) Close() {
+	wh.cancel()
+	wh.wg.Wait()
+}
+
+// deliver posts payload, retrying on network errors and 5xx responses until
+// ctx is done
+func (wh *Webhook) deliver(ctx context.Context, payload []byte) error {
+	var lastErr error
+	for attempt := 0; attempt <= wh.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(time.Duration(attempt) * wh.RetryDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%w (gave up retrying: %w)", lastErr, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		retry, err := wh.post(ctx, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// post sends a single delivery attempt and reports whether a failure is
+// worth retrying
+func (wh *Webhook) post(ctx context.Context, payload []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(wh.Secret, payload))
+	}
+
+	resp, err := wh.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post webhook: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return true, fmt.Errorf("webhook receiver returned %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return false, fmt.Errorf("webhook receiver returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of payload under secret
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWebhookNotifiesAfterProcessing tests that a processed document is
+// posted to the webhook with a valid signature
+func TestWebhookNotifiesAfterProcessing(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	var mu sync.Mutex
+	var bodies [][]byte
+	var signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		signatures = append(signatures, r.Header.Get(WebhookSignatureHeader))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	processor := NewProcessor(database, &MockAIExtractor{Vocabulary: []string{"hola", "amigo"}}, "Spanish")
+	processor.Webhook = NewWebhook(server.URL, "s3cret")
+
+	text := "Hola amigo"
+	if _, err := processor.ProcessReader(context.Background(), strings.NewReader(text), "lesson.txt", int64(len(text))); err != nil {
+		t.Fatalf("ProcessReader failed: %v", err)
+	}
+	processor.Webhook.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 webhook delivery, got %d", len(bodies))
+	}
+
+	var result ProcessingResult
+	if err := json.Unmarshal(bodies[0], &result); err != nil {
+		t.Fatalf("Failed to decode webhook payload: %v", err)
+	}
+	if result.NewVocabulary != 2 || result.FilePath != "lesson.txt" {
+		t.Errorf("Unexpected webhook payload: %+v", result)
+	}
+
+	if want := "sha256=" + SignWebhookPayload("s3cret", bodies[0]); signatures[0] != want {
+		t.Errorf("Expected signature %q, got %q", want, signatures[0])
+	}
+}
+
+// TestWebhookRetries tests that 5xx responses are retried and 4xx are not
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{"retries server errors", []int{500, 503, 200}, 3},
+		{"gives up after retries", []int{500, 500, 500, 500}, 3},
+		{"does not retry client errors", []int{400, 200}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+
+			webhook := NewWebhook(server.URL, "")
+			webhook.RetryDelay = time.Millisecond
+			webhook.Notify(&ProcessingResult{NewVocabulary: 1})
+			webhook.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if calls != tt.want {
+				t.Errorf("Expected %d attempts, got %d", tt.want, calls)
+			}
+		})
+	}
+}
+
+// TestWebhookCloseStopsRetries tests that Close abandons a delivery waiting
+// to retry instead of sleeping through the delay
+func TestWebhookCloseStopsRetries(t *testing.T) {
+	attempted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL, "")
+	webhook.RetryDelay = time.Hour
+	webhook.Notify(&ProcessingResult{NewVocabulary: 1})
+	<-attempted
+
+	closed := make(chan struct{})
+	go func() {
+		webhook.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the pending retry")
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ankiEscaper keeps each field on one line of an Anki import: Anki reads
+// fields as HTML, so line breaks become <br>, and tabs, which separate
+// fields, become spaces
+var ankiEscaper = strings.NewReplacer("\r\n", "<br>", "\n", "<br>", "\r", "<br>", "\t", " ")
+
+// WriteAnki streams every vocabulary item to w as an Anki-compatible
+// tab-separated file, one "text<TAB>translation" card per line and no header.
+// Items without a translation get the text on both sides so they still
+// import.
+func (db *Database) WriteAnki(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	err := db.ForEach(func(item *Vocabulary) error {
+		back := item.Translation
+		if strings.TrimSpace(back) == "" {
+			back = item.Text
+		}
+		_, err := fmt.Fprintf(buf, "%s\t%s\n", ankiEscaper.Replace(item.Text), ankiEscaper.Replace(back))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write Anki export: %w", err)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write Anki export: %w", err)
+	}
+	return nil
+}
+
+// ExportToAnki exports all vocabulary items to a file Anki can import
+func (db *Database) ExportToAnki(filePath string) error {
+	// Create file with secure permissions (0600 - owner read/write only)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	if err := db.WriteAnki(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix and backupSuffix frame the timestamped file names written by
+// BackupToDir; PruneBackups only touches files matching them exactly. The
+// prefix differs from the upload temp files' "parsely-", so a backup
+// directory shared with TMPDIR is not pruned of uploads or reaped of backups.
+const (
+	backupPrefix     = "parsely-backup-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405"
+)
+
+// isBackupName reports whether name is a file name written by BackupToDir
+func isBackupName(name string) bool {
+	stamp, ok := strings.CutPrefix(name, backupPrefix)
+	if !ok {
+		return false
+	}
+	stamp, ok = strings.CutSuffix(stamp, backupSuffix)
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(backupTimeLayout, stamp)
+	return err == nil
+}
+
+// BackupTo writes a consistent snapshot of the database to filePath using
+// VACUUM INTO, which reads through the WAL rather than copying the raw file.
+// filePath must not exist or must be an empty file.
+func (db *Database) BackupTo(filePath string) error {
+	if _, err := db.conn.Exec(`VACUUM INTO ?`, filePath); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// BackupToDir writes a snapshot into dir named after the current time of the
+// database clock, creating dir if needed, and returns the file path
+func (db *Database) BackupToDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupPrefix + db.clock.Now().UTC().Format(backupTimeLayout) + backupSuffix
+	path := filepath.Join(dir, name)
+	if err := db.BackupTo(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// PruneBackups deletes all but the newest keep backups written by
+// BackupToDir in dir and returns how many were removed. Other files in dir
+// are left alone.
+func PruneBackups(dir string, keep int) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && isBackupName(name) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return 0, nil
+	}
+
+	// Timestamps in the names sort chronologically, oldest first
+	sort.Strings(backups)
+
+	removed := 0
+	for _, name := range backups[:len(backups)-max(keep, 0)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ExtractionKey identifies a cached AI extraction. The same text gives
+// different vocabulary depending on the requested language, on whether
+// context sentences were asked for and on the length limits results were
+// filtered by, so all of them are part of the key.
+type ExtractionKey struct {
+	TextHash    string
+	Language    string
+	WithContext bool
+
+	// Limits describes the extractor's length limits; empty when it has none
+	Limits string
+}
+
+// CachedExtraction is the stored result of an AI extraction
+type CachedExtraction struct {
+	// Language is the language the vocabulary was extracted in, which
+	// differs from the key's when the language was detected
+	Language string
+
+	// Items is the extracted vocabulary as the JSON the caller saved
+	Items string
+}
+
+// GetCachedExtraction returns the extraction stored under key, or nil if
+// there is none
+func (db *Database) GetCachedExtraction(key ExtractionKey) (*CachedExtraction, error) {
+	var cached CachedExtraction
+	err := db.conn.QueryRow(`SELECT detected_language, items FROM extraction_cache WHERE text_hash = ? AND language = ? AND with_context = ? AND limits = ?`,
+		key.TextHash, key.Language, key.WithContext, key.Limits).Scan(&cached.Language, &cached.Items)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extraction cache: %w", err)
+	}
+	return &cached, nil
+}
+
+// CacheExtraction stores an extraction under key, replacing any earlier one
+func (db *Database) CacheExtraction(key ExtractionKey, cached CachedExtraction) error {
+	query := `INSERT INTO extraction_cache (text_hash, language, with_context, limits, detected_language, items, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(text_hash, language, with_context, limits) DO UPDATE SET detected_language = excluded.detected_language, items = excluded.items, created_at = excluded.created_at`
+	if _, err := db.conn.Exec(query, key.TextHash, key.Language, key.WithContext, key.Limits, cached.Language, cached.Items, db.now()); err != nil {
+		return fmt.Errorf("failed to save extraction cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import "time"
+
+// timestampLayout is the UTC format used for timestamps written from Go.
+// It matches SQLite's CURRENT_TIMESTAMP with millisecond precision added, so
+// old and new rows sort and compare consistently as text.
+const timestampLayout = "2006-01-02 15:04:05.000"
+
+// Clock provides the current time for timestamps written by the database
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock replaces the clock used for timestamps, typically with a fixed
+// clock in tests. Passing nil restores the system clock.
+func (db *Database) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	db.clock = clock
+}
+
+// now returns the current time from the database clock formatted for storage
+func (db *Database) now() string {
+	return formatTimestamp(db.clock.Now())
+}
+
+// formatTimestamp converts a time to the stored UTC text format
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// parseStoredTimestamp parses a timestamp read back as text, written either
+// from Go or by SQLite's CURRENT_TIMESTAMP default
+func parseStoredTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(timestampLayout, value)
+	if err != nil {
+		return time.Parse(time.DateTime, value)
+	}
+	return t, nil
+}
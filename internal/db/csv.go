@@ -0,0 +1,96 @@
+package db
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVFields are the columns written by ExportToCSV, in order
+var CSVFields = []string{"id", "text", "language", "translation", "created_at"}
+
+// WriteCSV streams every vocabulary item to w as CSV, newest first, with a
+// header row naming the columns. fields selects the columns from
+// ExportFields; nil writes CSVFields. Values containing commas, quotes or
+// newlines are quoted.
+func (db *Database) WriteCSV(w io.Writer, fields []string) error {
+	return writeCSV(w, fields, db.ForEach)
+}
+
+// WriteCSVItems writes the given items to w as CSV, in the same format as
+// WriteCSV
+func WriteCSVItems(w io.Writer, items []*Vocabulary, fields []string) error {
+	return writeCSV(w, fields, func(fn func(*Vocabulary) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeCSV writes the header row followed by one record for every item
+// that each passes to its callback
+func writeCSV(w io.Writer, fields []string, each func(func(*Vocabulary) error) error) error {
+	if fields == nil {
+		fields = CSVFields
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(fields); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	record := make([]string, len(fields))
+	err := each(func(item *Vocabulary) error {
+		for i, field := range fields {
+			record[i] = csvValue(item.FieldValue(field))
+		}
+		return writer.Write(record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// ExportToCSV exports all vocabulary items to a CSV file with the CSVFields
+// columns
+func (db *Database) ExportToCSV(filePath string) error {
+	// Create file with secure permissions (0600 - owner read/write only)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	if err := db.WriteCSV(file, nil); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// csvValue formats a FieldValue for a CSV cell
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,354 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExportVersion is the current version of the JSON export format.
+// Version 1 was a bare array of vocabulary items.
+const ExportVersion = 2
+
+// Export is the versioned top-level object written by ExportToJSON
+type Export struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Items      []*Vocabulary `json:"items"`
+}
+
+// NewExport builds an export of all vocabulary items
+func (db *Database) NewExport() (*Export, error) {
+	items, err := db.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary for export: %w", err)
+	}
+	if items == nil {
+		items = []*Vocabulary{}
+	}
+
+	return &Export{
+		Version:    ExportVersion,
+		ExportedAt: db.clock.Now().UTC(),
+		Items:      items,
+	}, nil
+}
+
+// NewLanguageExport builds an export of the vocabulary items in one
+// language, matched like Filter.Language. The export is empty, not an error,
+// when nothing matches.
+func (db *Database) NewLanguageExport(language string) (*Export, error) {
+	items, err := db.ListFilteredPaginated(Filter{Language: language}, "created_at", -1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary for export: %w", err)
+	}
+	if items == nil {
+		items = []*Vocabulary{}
+	}
+
+	return &Export{
+		Version:    ExportVersion,
+		ExportedAt: db.clock.Now().UTC(),
+		Items:      items,
+	}, nil
+}
+
+// ExportToJSON exports all vocabulary items to a JSON file
+func (db *Database) ExportToJSON(filePath string) error {
+	export, err := db.NewExport()
+	if err != nil {
+		return err
+	}
+	return writeExportFile(export, filePath)
+}
+
+// ExportLanguageToJSON exports the vocabulary items in one language to a
+// JSON file
+func (db *Database) ExportLanguageToJSON(language, filePath string) error {
+	export, err := db.NewLanguageExport(language)
+	if err != nil {
+		return err
+	}
+	return writeExportFile(export, filePath)
+}
+
+// writeExportFile writes export to filePath as indented JSON
+func writeExportFile(export *Export, filePath string) error {
+	// Create file with secure permissions (0600 - owner read/write only)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(export); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return nil
+}
+
+// importBatchSize is the number of rows written per INSERT during import
+const importBatchSize = 500
+
+// ErrInvalidImport is returned when an import file is not a JSON export
+// this build can read
+var ErrInvalidImport = errors.New("invalid import file")
+
+// ImportFromJSON loads vocabulary from a JSON export, accepting both the
+// legacy bare-array format and the versioned object. Items whose text
+// already exists, or that lack a text or language, are skipped. Nothing is
+// written if the file is malformed.
+func (db *Database) ImportFromJSON(filePath string) (imported, skipped int, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read import file: %w", err)
+	}
+	defer file.Close()
+
+	return db.ImportFromReader(file)
+}
+
+// ImportFromReader is ImportFromJSON for an arbitrary reader. Items are
+// decoded one at a time and inserted in batches inside a single
+// transaction, so memory use does not grow with the size of the export.
+func (db *Database) ImportFromReader(r io.Reader) (imported, skipped int, err error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch := &importBatch{db: db, tx: tx}
+	if err := streamExport(json.NewDecoder(r), batch.add); err != nil {
+		return 0, 0, err
+	}
+	if err := batch.flush(); err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	return batch.imported, batch.skipped, nil
+}
+
+// importBatch buffers decoded items and writes them with multi-row inserts
+type importBatch struct {
+	db       *Database
+	tx       *sql.Tx
+	items    []*Vocabulary
+	imported int
+	skipped  int
+}
+
+// add queues an item, writing the batch once it is full. Items without a
+// text or language are counted as skipped.
+func (b *importBatch) add(item *Vocabulary) error {
+	if strings.TrimSpace(item.Text) == "" || strings.TrimSpace(item.Language) == "" {
+		b.skipped++
+		return nil
+	}
+
+	b.items = append(b.items, item)
+	if len(b.items) >= importBatchSize {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush writes the queued items. Items matching a soft-deleted row revive
+// it; the rest go through one INSERT OR IGNORE, which skips existing texts,
+// so the skipped count is the number left minus the rows actually inserted.
+func (b *importBatch) flush() error {
+	if len(b.items) == 0 {
+		return nil
+	}
+
+	const columns = 12
+	placeholders := make([]string, 0, len(b.items))
+	args := make([]any, 0, len(b.items)*columns)
+	for _, item := range b.items {
+		createdAt := b.db.now()
+		if !item.CreatedAt.IsZero() {
+			createdAt = formatTimestamp(item.CreatedAt)
+		}
+		updatedAt := createdAt
+		if !item.UpdatedAt.IsZero() {
+			updatedAt = formatTimestamp(item.UpdatedAt)
+		}
+
+		revived, err := reviveDeleted(b.tx, item, createdAt, updatedAt)
+		if err != nil {
+			return err
+		}
+		if revived != 0 {
+			b.imported++
+			continue
+		}
+
+		occurrences := item.Occurrences
+		if occurrences < 1 {
+			occurrences = 1
+		}
+
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, item.Text, item.Language, normalizeText(item.Text, item.Language), foldText(item.Text, item.Language), item.Translation, item.Context, item.Example, occurrences, max(item.Frequency, 1), createdAt, updatedAt, languageKey(item.Language))
+	}
+	if len(placeholders) > 0 {
+		// Rows whose normalized text is already stored are skipped like
+		// exact duplicates, keeping the casing that was stored first
+		query := `INSERT OR IGNORE INTO vocabulary (text, language, normalized, ascii_fold, translation, context, example, occurrences, frequency, created_at, updated_at, language_key)
+			SELECT * FROM (VALUES ` + strings.Join(placeholders, ", ") + `) AS v
+			WHERE NOT EXISTS (SELECT 1 FROM vocabulary WHERE normalized = v.column3)`
+		result, err := b.tx.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to import vocabulary: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		b.imported += int(rowsAffected)
+		b.skipped += len(placeholders) - int(rowsAffected)
+	}
+
+	if err := b.restoreTags(); err != nil {
+		return err
+	}
+
+	b.items = b.items[:0]
+	return nil
+}
+
+// restoreTags applies the tags of the queued items to the stored rows with
+// the same normalized text, including rows that were skipped as existing.
+// Tags that are not valid are dropped.
+func (b *importBatch) restoreTags() error {
+	var stmt *sql.Stmt
+	for _, item := range b.items {
+		for _, tag := range item.Tags {
+			tag, err := normalizeTag(tag)
+			if err != nil {
+				continue
+			}
+			if stmt == nil {
+				stmt, err = b.tx.Prepare(`INSERT OR IGNORE INTO vocabulary_tags (vocab_id, tag) SELECT id, ? FROM vocabulary WHERE normalized = ? AND ` + notDeleted)
+				if err != nil {
+					return fmt.Errorf("failed to prepare tag insert: %w", err)
+				}
+				defer stmt.Close()
+			}
+			if _, err := stmt.Exec(tag, normalizeText(item.Text, item.Language)); err != nil {
+				return fmt.Errorf("failed to import tags: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// streamExport decodes either export format, calling add for each item as
+// soon as it is read
+func streamExport(dec *json.Decoder, add func(*Vocabulary) error) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		// Version 1: bare array
+		return streamItems(dec, add)
+	case json.Delim('{'):
+		return streamVersioned(dec, add)
+	default:
+		return fmt.Errorf("%w: failed to decode JSON: expected an array or object, got %v", ErrInvalidImport, tok)
+	}
+}
+
+// streamVersioned reads the fields of a versioned export object whose
+// opening brace has already been consumed
+func streamVersioned(dec *json.Decoder, add func(*Vocabulary) error) error {
+	version := 0
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+		}
+
+		switch tok {
+		case "version":
+			if err := dec.Decode(&version); err != nil {
+				return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+			}
+			if err := checkExportVersion(version); err != nil {
+				return err
+			}
+		case "items":
+			tok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+			}
+			if tok != json.Delim('[') {
+				return fmt.Errorf("%w: failed to decode JSON: items must be an array", ErrInvalidImport)
+			}
+			if err := streamItems(dec, add); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+			}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+	}
+
+	// Items may precede the version; the caller's transaction is only
+	// committed once the version has been checked here
+	return checkExportVersion(version)
+}
+
+// streamItems decodes array elements whose opening bracket has already been
+// consumed
+func streamItems(dec *json.Decoder, add func(*Vocabulary) error) error {
+	for dec.More() {
+		var item Vocabulary
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+		}
+		if err := add(&item); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("%w: failed to decode JSON: %w", ErrInvalidImport, err)
+	}
+	return nil
+}
+
+// checkExportVersion rejects versioned exports this build cannot read
+func checkExportVersion(version int) error {
+	if version < 2 || version > ExportVersion {
+		return fmt.Errorf("%w: unsupported export version %d", ErrInvalidImport, version)
+	}
+	return nil
+}
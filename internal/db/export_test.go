@@ -0,0 +1,677 @@
+package db
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeImportFile writes content to a temp file and returns its path
+func writeImportFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "import.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write import file: %v", err)
+	}
+	return path
+}
+
+// TestExportToJSONVersioned tests that exports are wrapped in a versioned object
+func TestExportToJSONVersioned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	exportedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	db.SetClock(&fixedClock{exportedAt})
+
+	if _, err := db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.json")
+	if err := db.ExportToJSON(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+
+	var export Export
+	if err := json.Unmarshal(content, &export); err != nil {
+		t.Fatalf("Export is not a versioned object: %v", err)
+	}
+
+	if export.Version != ExportVersion {
+		t.Errorf("Expected version %d, got %d", ExportVersion, export.Version)
+	}
+	if !export.ExportedAt.Equal(exportedAt) {
+		t.Errorf("Expected exported_at %v, got %v", exportedAt, export.ExportedAt)
+	}
+	if len(export.Items) != 1 || export.Items[0].Text != "hola" {
+		t.Errorf("Unexpected export items: %+v", export.Items)
+	}
+}
+
+// TestExportLanguageToJSON tests that only the requested language is
+// written, and that a language without items gives an empty export
+func TestExportLanguageToJSON(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, v := range []*Vocabulary{
+		{Text: "hola", Language: "Spanish"},
+		{Text: "adiós", Language: " spanish "},
+		{Text: "bonjour", Language: "French"},
+	} {
+		if _, err := db.Insert(v); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{"Spanish", []string{"adiós", "hola"}},
+		{"french", []string{"bonjour"}},
+		{"German", []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.language, func(t *testing.T) {
+			exportPath := filepath.Join(t.TempDir(), "export.json")
+			if err := db.ExportLanguageToJSON(tc.language, exportPath); err != nil {
+				t.Fatalf("Failed to export: %v", err)
+			}
+
+			content, err := os.ReadFile(exportPath)
+			if err != nil {
+				t.Fatalf("Failed to read export file: %v", err)
+			}
+
+			var export Export
+			if err := json.Unmarshal(content, &export); err != nil {
+				t.Fatalf("Export is not a versioned object: %v", err)
+			}
+			if export.Items == nil {
+				t.Fatal("Expected an items array, got null")
+			}
+
+			var got []string
+			for _, item := range export.Items {
+				got = append(got, item.Text)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestImportFromJSON tests importing both the legacy and versioned formats
+func TestImportFromJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantContext string
+		wantExample string
+		wantOcc     int
+	}{
+		{
+			name:        "v1 bare array",
+			content:     `[{"id": 7, "text": "hola", "language": "Spanish", "created_at": "2024-01-02T03:04:05Z"}]`,
+			wantContext: "",
+			wantOcc:     1,
+		},
+		{
+			name: "v2 object",
+			content: `{"version": 2, "exported_at": "2025-01-01T00:00:00Z", "items": [
+				{"id": 7, "text": "hola", "language": "Spanish", "context": "Hola, amigo.", "example": "¡Hola, Ana!", "occurrences": 3, "created_at": "2024-01-02T03:04:05Z"}
+			]}`,
+			wantContext: "Hola, amigo.",
+			wantExample: "¡Hola, Ana!",
+			wantOcc:     3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			imported, skipped, err := db.ImportFromJSON(writeImportFile(t, tc.content))
+			if err != nil {
+				t.Fatalf("Failed to import: %v", err)
+			}
+			if imported != 1 || skipped != 0 {
+				t.Errorf("Expected 1 imported and 0 skipped, got %d and %d", imported, skipped)
+			}
+
+			vocab, err := db.GetByText("hola")
+			if err != nil {
+				t.Fatalf("Imported item not found: %v", err)
+			}
+			if vocab.Language != "Spanish" {
+				t.Errorf("Expected language Spanish, got %s", vocab.Language)
+			}
+			if vocab.Context != tc.wantContext {
+				t.Errorf("Expected context %q, got %q", tc.wantContext, vocab.Context)
+			}
+			if vocab.Example != tc.wantExample {
+				t.Errorf("Expected example %q, got %q", tc.wantExample, vocab.Example)
+			}
+			if vocab.Occurrences != tc.wantOcc {
+				t.Errorf("Expected %d occurrences, got %d", tc.wantOcc, vocab.Occurrences)
+			}
+			if !vocab.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Errorf("Expected created_at to be preserved, got %v", vocab.CreatedAt)
+			}
+		})
+	}
+}
+
+// TestImportFromJSONSkipsExisting tests re-importing an export into the same database
+func TestImportFromJSONSkipsExisting(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, text := range []string{"uno", "dos"} {
+		if _, err := db.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.json")
+	if err := db.ExportToJSON(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	imported, skipped, err := db.ImportFromJSON(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if imported != 0 || skipped != 2 {
+		t.Errorf("Expected 0 imported and 2 skipped, got %d and %d", imported, skipped)
+	}
+}
+
+// TestExportImportTags tests that tags survive a JSON export and import,
+// and are merged into items that already exist
+func TestExportImportTags(t *testing.T) {
+	source := setupTestDB(t)
+	defer source.Close()
+
+	id, err := source.Insert(&Vocabulary{Text: "manzana", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	source.Insert(&Vocabulary{Text: "perro", Language: "Spanish"})
+	source.AddTag(id, "food")
+	source.AddTag(id, "lesson 1")
+
+	exportPath := filepath.Join(t.TempDir(), "export.json")
+	if err := source.ExportToJSON(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if !strings.Contains(string(content), `"tags": [`) {
+		t.Errorf("Expected export to contain tags, got %s", content)
+	}
+
+	// In-memory databases are shared, so the target needs its own file
+	target, err := NewDatabase(filepath.Join(t.TempDir(), "target.db"))
+	if err != nil {
+		t.Fatalf("Failed to create target database: %v", err)
+	}
+	defer target.Close()
+
+	existing, _ := target.Insert(&Vocabulary{Text: "Manzana", Language: "Spanish"})
+	target.AddTag(existing, "fruit")
+
+	if _, _, err := target.ImportFromJSON(exportPath); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+
+	vocab, err := target.Get(existing)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if strings.Join(vocab.Tags, ",") != "food,fruit,lesson 1" {
+		t.Errorf("Expected merged tags, got %v", vocab.Tags)
+	}
+
+	perro, err := target.GetByText("perro")
+	if err != nil {
+		t.Fatalf("GetByText failed: %v", err)
+	}
+	if len(perro.Tags) != 0 {
+		t.Errorf("Expected perro to stay untagged, got %v", perro.Tags)
+	}
+}
+
+// TestImportFromJSONInvalid tests rejection of malformed and unknown formats
+func TestImportFromJSONInvalid(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, content := range []string{"", "not json", `{"version": 99, "items": []}`, `[{"text": "uno", "language": "es"}, {"text": 5}]`} {
+		if _, _, err := db.ImportFromJSON(writeImportFile(t, content)); err == nil {
+			t.Errorf("Expected error importing %q", content)
+		}
+	}
+
+	count, err := db.Count()
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no rows after failed imports, got %d", count)
+	}
+}
+
+// TestImportFromJSONSkipsIncomplete tests that records without a text or
+// language are skipped rather than stored
+func TestImportFromJSONSkipsIncomplete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	content := `[
+		{"text": "uno", "language": "Spanish"},
+		{"text": "  ", "language": "Spanish"},
+		{"text": "dos", "language": ""},
+		{"language": "Spanish"}
+	]`
+	imported, skipped, err := db.ImportFromJSON(writeImportFile(t, content))
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if imported != 1 || skipped != 3 {
+		t.Errorf("Expected 1 imported and 3 skipped, got %d and %d", imported, skipped)
+	}
+	if count, _ := db.Count(); count != 1 {
+		t.Errorf("Expected 1 row, got %d", count)
+	}
+
+	if _, _, err := db.ImportFromJSON(writeImportFile(t, "not json")); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("Expected ErrInvalidImport for malformed JSON, got %v", err)
+	}
+}
+
+// TestImportFromReaderStreaming tests importing a large export generated on
+// the fly, so it never exists in memory as a whole
+func TestImportFromReaderStreaming(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	const total = 20000
+
+	tests := []struct {
+		name   string
+		prefix string
+		suffix string
+	}{
+		{"v1 bare array", `[`, `]`},
+		{"v2 object", `{"version": 2, "exported_at": "2025-01-01T00:00:00Z", "items": [`, `]}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, writer := io.Pipe()
+			go func() {
+				bw := bufio.NewWriter(writer)
+				bw.WriteString(tc.prefix)
+				for i := 0; i < total; i++ {
+					if i > 0 {
+						bw.WriteString(",")
+					}
+					fmt.Fprintf(bw, `{"text": "%s_%d", "language": "es", "occurrences": 2}`, tc.name, i)
+				}
+				bw.WriteString(tc.suffix)
+				bw.Flush()
+				writer.Close()
+			}()
+
+			imported, skipped, err := db.ImportFromReader(reader)
+			if err != nil {
+				t.Fatalf("Failed to import: %v", err)
+			}
+			if imported != total || skipped != 0 {
+				t.Errorf("Expected %d imported and 0 skipped, got %d and %d", total, imported, skipped)
+			}
+		})
+	}
+
+	count, err := db.Count()
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 2*total {
+		t.Errorf("Expected %d rows, got %d", 2*total, count)
+	}
+}
+
+// TestImportFromJSONVersionAfterItems tests that a bad version rolls back
+// items that were streamed before it
+func TestImportFromJSONVersionAfterItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	content := `{"items": [{"text": "uno", "language": "es"}], "version": 7}`
+	if _, _, err := db.ImportFromJSON(writeImportFile(t, content)); err == nil {
+		t.Fatal("Expected error for unsupported version")
+	}
+
+	if count, _ := db.Count(); count != 0 {
+		t.Errorf("Expected no rows after rejected import, got %d", count)
+	}
+}
+
+// TestParseExportFields tests export field selection parsing
+func TestParseExportFields(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"text,translation", []string{"text", "translation"}, false},
+		{" Text , text,id ", []string{"text", "id"}, false},
+		{",", nil, false},
+		{"text,secret", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExportFields(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnknownExportField) {
+				t.Errorf("ParseExportFields(%q) expected ErrUnknownExportField, got %v", tt.input, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseExportFields(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || (got == nil) != (tt.want == nil) {
+			t.Errorf("ParseExportFields(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestExportProject tests that projected items keep only the selected fields in order
+func TestExportProject(t *testing.T) {
+	export := &Export{
+		Version: ExportVersion,
+		Items:   []*Vocabulary{{ID: 7, Text: "hola", Language: "Spanish", Translation: "hello"}},
+	}
+
+	data, err := json.Marshal(export.Project([]string{"translation", "text"}))
+	if err != nil {
+		t.Fatalf("Failed to marshal projection: %v", err)
+	}
+
+	if !strings.Contains(string(data), `"items":[{"translation":"hello","text":"hola"}]`) {
+		t.Errorf("Unexpected projection: %s", data)
+	}
+}
+
+// TestExportSince tests that an incremental export only contains rows
+// created after the marker
+func TestExportSince(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	clock := &fixedClock{time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)}
+	database.SetClock(clock)
+
+	insert := func(texts ...string) {
+		for _, text := range texts {
+			if _, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+				t.Fatalf("Failed to insert %q: %v", text, err)
+			}
+			clock.t = clock.t.Add(time.Second)
+		}
+	}
+	exportedTexts := func(since time.Time) []string {
+		var buf strings.Builder
+		if err := database.ExportSince(since, &buf); err != nil {
+			t.Fatalf("ExportSince failed: %v", err)
+		}
+		var export Export
+		if err := json.Unmarshal([]byte(buf.String()), &export); err != nil {
+			t.Fatalf("Failed to decode export: %v", err)
+		}
+		if export.Version != ExportVersion {
+			t.Errorf("Expected version %d, got %d", ExportVersion, export.Version)
+		}
+		texts := make([]string, len(export.Items))
+		for i, item := range export.Items {
+			texts[i] = item.Text
+		}
+		return texts
+	}
+
+	marker, err := database.LastExportAt()
+	if err != nil {
+		t.Fatalf("LastExportAt failed: %v", err)
+	}
+	if !marker.IsZero() {
+		t.Fatalf("Expected no marker before the first export, got %v", marker)
+	}
+
+	insert("hola", "adiós")
+	if got := exportedTexts(marker); strings.Join(got, ",") != "adiós,hola" {
+		t.Fatalf("Expected the first export to contain every row, got %v", got)
+	}
+
+	first, err := database.NewExportSince(marker)
+	if err != nil {
+		t.Fatalf("NewExportSince failed: %v", err)
+	}
+	if err := database.SetLastExportAt(first.Newest()); err != nil {
+		t.Fatalf("SetLastExportAt failed: %v", err)
+	}
+
+	insert("gracias")
+	marker, err = database.LastExportAt()
+	if err != nil {
+		t.Fatalf("LastExportAt failed: %v", err)
+	}
+	if got := exportedTexts(marker); len(got) != 1 || got[0] != "gracias" {
+		t.Errorf("Expected only the new row, got %v", got)
+	}
+}
+
+// TestImportSkipsCaseOnlyDuplicates tests that importing a differently cased
+// copy of a stored word keeps the stored casing
+func TestImportSkipsCaseOnlyDuplicates(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	if _, err := database.Insert(&Vocabulary{Text: "Madrid", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	imported, skipped, err := database.ImportFromReader(strings.NewReader(`[{"text":"madrid","language":"Spanish"},{"text":"Sevilla","language":"Spanish"}]`))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported != 1 || skipped != 1 {
+		t.Errorf("Expected 1 imported and 1 skipped, got %d and %d", imported, skipped)
+	}
+	if _, err := database.GetByText("madrid"); err == nil {
+		t.Error("Expected the lowercase copy to be skipped")
+	}
+}
+
+// TestImportRevivesDeleted tests that importing a soft-deleted word brings
+// it back with the imported fields and tags
+func TestImportRevivesDeleted(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	id, err := database.Insert(&Vocabulary{Text: "Madrid", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := database.Delete(id); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	imported, skipped, err := database.ImportFromReader(strings.NewReader(`[{"text":"madrid","language":"Spanish","translation":"Madrid","tags":["cities"]},{"text":"MADRID","language":"Spanish"}]`))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported != 1 || skipped != 1 {
+		t.Errorf("Expected 1 imported and 1 skipped, got %d and %d", imported, skipped)
+	}
+
+	vocab, err := database.Get(id)
+	if err != nil {
+		t.Fatalf("Expected the deleted row to be revived: %v", err)
+	}
+	if vocab.Text != "madrid" || vocab.Translation != "Madrid" || len(vocab.Tags) != 1 || vocab.Tags[0] != "cities" {
+		t.Errorf("Expected the imported fields and tag, got %+v", vocab)
+	}
+}
+
+// TestExportToCSV tests the header row and quoting of awkward values
+func TestExportToCSV(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.SetClock(&fixedClock{time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)})
+
+	id, err := db.Insert(&Vocabulary{Text: `dijo "hola"`, Language: "Spanish", Translation: "said hello, twice\nreally"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.csv")
+	if err := db.ExportToCSV(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	file, err := os.Open(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to open export file: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v", err)
+	}
+
+	want := [][]string{
+		{"id", "text", "language", "translation", "created_at"},
+		{fmt.Sprint(id), `dijo "hola"`, "Spanish", "said hello, twice\nreally", "2025-03-01T12:00:00Z"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %d: %q", len(want), len(records), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("Record %d: expected %q, got %q", i, want[i], records[i])
+		}
+	}
+}
+
+// TestExportToAnki tests one card per line, the translation fallback and
+// escaping of tabs and newlines
+func TestExportToAnki(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clock := &fixedClock{time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	db.SetClock(clock)
+	for _, v := range []*Vocabulary{
+		{Text: "hola", Language: "Spanish", Translation: "hello"},
+		{Text: "sobremesa", Language: "Spanish"},
+		{Text: "uno\tdos", Language: "Spanish", Translation: "one\ntwo"},
+	} {
+		clock.t = clock.t.Add(time.Minute)
+		if _, err := db.Insert(v); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "vocabulary.txt")
+	if err := db.ExportToAnki(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+
+	want := "uno dos\tone<br>two\nsobremesa\tsobremesa\nhola\thello\n"
+	if string(content) != want {
+		t.Errorf("Expected %q, got %q", want, content)
+	}
+}
+
+// TestExportToHTML tests the printable study sheet: one section per
+// language, the total count, and escaping of stored markup
+func TestExportToHTML(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, v := range []*Vocabulary{
+		{Text: "hola", Language: "Spanish", Translation: "hello"},
+		{Text: "adiós", Language: "Spanish", Translation: "goodbye"},
+		{Text: "<script>alert(1)</script>", Language: "spanish ", Translation: `"quoted" & <i>`},
+		{Text: "bonjour", Language: "French", Translation: "hello"},
+	} {
+		if _, err := db.Insert(v); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "sheet.html")
+	if err := db.ExportToHTML(exportPath); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+	page := string(content)
+
+	if strings.Contains(page, "<script>") || strings.Contains(page, "<i>") {
+		t.Errorf("Stored markup was not escaped:\n%s", page)
+	}
+	for _, want := range []string{
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"&#34;quoted&#34; &amp; &lt;i&gt;",
+		"4 items",
+		"<h2>French (1)</h2>",
+		"<h2>Spanish (3)</h2>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+	if strings.Index(page, "<h2>French") > strings.Index(page, "<h2>Spanish") {
+		t.Error("Expected language sections in alphabetical order")
+	}
+	if strings.Count(page, "<h2>") != 2 {
+		t.Errorf("Expected 2 language sections, got %d", strings.Count(page, "<h2>"))
+	}
+}
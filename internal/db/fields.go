@@ -0,0 +1,142 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExportFields lists the vocabulary fields that can be selected for export,
+// in their default output order
+var ExportFields = []string{"id", "text", "language", "translation", "context", "example", "occurrences", "frequency", "created_at", "updated_at", "tags"}
+
+// ErrUnknownExportField is returned when a field selection names a field
+// that is not in ExportFields
+var ErrUnknownExportField = errors.New("unknown export field")
+
+// ParseExportFields parses a comma separated field selection such as
+// "text,translation". Repeated fields are kept once, in first-seen order.
+// An empty selection returns nil, meaning all fields.
+func ParseExportFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	allowed := make(map[string]bool, len(ExportFields))
+	for _, field := range ExportFields {
+		allowed[field] = true
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" || seen[field] {
+			continue
+		}
+		if !allowed[field] {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownExportField, field)
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// FieldValue returns the value of the named export field
+func (v *Vocabulary) FieldValue(field string) any {
+	switch field {
+	case "id":
+		return v.ID
+	case "text":
+		return v.Text
+	case "language":
+		return v.Language
+	case "translation":
+		return v.Translation
+	case "context":
+		return v.Context
+	case "example":
+		return v.Example
+	case "occurrences":
+		return v.Occurrences
+	case "frequency":
+		return v.Frequency
+	case "created_at":
+		return v.CreatedAt
+	case "updated_at":
+		return v.UpdatedAt
+	case "tags":
+		return v.Tags
+	}
+	return nil
+}
+
+// ProjectedExport is an Export whose items only carry a selection of fields
+type ProjectedExport struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Items      []ProjectedItem `json:"items"`
+}
+
+// ProjectedItem is a vocabulary item restricted to a selection of fields.
+// It marshals to a JSON object with the fields in selection order.
+type ProjectedItem struct {
+	Fields []string
+	Values []any
+}
+
+// Project returns the export restricted to the given fields, which should
+// come from ParseExportFields
+func (e *Export) Project(fields []string) *ProjectedExport {
+	items := make([]ProjectedItem, len(e.Items))
+	for i, v := range e.Items {
+		items[i] = v.Project(fields)
+	}
+
+	return &ProjectedExport{
+		Version:    e.Version,
+		ExportedAt: e.ExportedAt,
+		Items:      items,
+	}
+}
+
+// Project restricts a single item to the given fields
+func (v *Vocabulary) Project(fields []string) ProjectedItem {
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		values[i] = v.FieldValue(field)
+	}
+	return ProjectedItem{Fields: fields, Values: values}
+}
+
+// MarshalJSON encodes the item as an object keyed by field name
+func (p ProjectedItem) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range p.Fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
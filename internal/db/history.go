@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// HistoryEntry records one field of a vocabulary item changing value
+type HistoryEntry struct {
+	ID        int       `json:"id"`
+	VocabID   int       `json:"vocab_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// fieldChange is a field value before and after a mutation
+type fieldChange struct {
+	field    string
+	old, new string
+}
+
+// recordHistory writes a history row for each change whose value actually
+// differs, inside the transaction that made the change
+func recordHistory(tx *sql.Tx, id int, changedAt string, changes ...fieldChange) error {
+	for _, c := range changes {
+		if c.old == c.new {
+			continue
+		}
+		query := `INSERT INTO vocabulary_history (vocab_id, field, old_value, new_value, changed_at) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.Exec(query, id, c.field, c.old, c.new, changedAt); err != nil {
+			return fmt.Errorf("failed to record %s history: %w", c.field, err)
+		}
+	}
+	return nil
+}
+
+// History returns the recorded changes of a vocabulary item, oldest first.
+// History is kept after the item is deleted so the audit trail survives it;
+// the final values are the new_value of each field's last entry.
+func (db *Database) History(id int) ([]*HistoryEntry, error) {
+	rows, err := db.conn.Query(`SELECT id, vocab_id, field, old_value, new_value, changed_at FROM vocabulary_history WHERE vocab_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.VocabID, &entry.Field, &entry.OldValue, &entry.NewValue, &entry.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// studySheetTemplate renders the printable HTML export. html/template
+// escapes every field, so markup in stored words is shown as text.
+var studySheetTemplate = template.Must(template.New("study-sheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Vocabulary study sheet</title>
+<style>
+  body { font-family: Georgia, serif; margin: 2rem; color: #222; }
+  h1 { margin-bottom: 0.2rem; }
+  .summary { color: #666; margin-top: 0; }
+  h2 { border-bottom: 2px solid #5a8f29; padding-bottom: 0.2rem; margin-top: 2rem; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { border: 1px solid #ccc; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+  th { background: #f0f5ea; }
+  tr { page-break-inside: avoid; }
+  @media print {
+    body { margin: 0; }
+    section { page-break-before: auto; }
+  }
+</style>
+</head>
+<body>
+<h1>Vocabulary study sheet</h1>
+<p class="summary">{{.Total}} {{if eq .Total 1}}item{{else}}items{{end}} &middot; generated {{.GeneratedAt.Format "2006-01-02"}}</p>
+{{range .Sections}}
+<section>
+<h2>{{.Language}} ({{len .Items}})</h2>
+<table>
+<thead><tr><th>Text</th><th>Translation</th><th>Language</th></tr></thead>
+<tbody>
+{{- range .Items}}
+<tr><td>{{.Text}}</td><td>{{.Translation}}</td><td>{{.Language}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{else}}
+<p>No vocabulary yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// studySheet is the data rendered by studySheetTemplate
+type studySheet struct {
+	Total       int
+	GeneratedAt time.Time
+	Sections    []studySection
+}
+
+// studySection holds the items of one language
+type studySection struct {
+	Language string
+	Items    []*Vocabulary
+}
+
+// WriteHTML renders every vocabulary item to w as a printable HTML study
+// sheet with one table per language, in alphabetical order. Languages that
+// differ only by case or surrounding whitespace share a section, headed by
+// the spelling most of its items use.
+func (db *Database) WriteHTML(w io.Writer) error {
+	items, err := db.queryVocabulary(`SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE ` + notDeleted + ` ORDER BY language_key, text COLLATE NOCASE`)
+	if err != nil {
+		return fmt.Errorf("failed to list vocabulary for export: %w", err)
+	}
+
+	sheet := studySheet{Total: len(items), GeneratedAt: db.clock.Now().UTC()}
+	for _, item := range items {
+		n := len(sheet.Sections)
+		if n == 0 || languageKey(sheet.Sections[n-1].Items[0].Language) != languageKey(item.Language) {
+			sheet.Sections = append(sheet.Sections, studySection{})
+			n++
+		}
+		sheet.Sections[n-1].Items = append(sheet.Sections[n-1].Items, item)
+	}
+	for i := range sheet.Sections {
+		sheet.Sections[i].Language = sectionHeading(sheet.Sections[i].Items)
+	}
+
+	buf := bufio.NewWriter(w)
+	if err := studySheetTemplate.Execute(buf, sheet); err != nil {
+		return fmt.Errorf("failed to write HTML export: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write HTML export: %w", err)
+	}
+	return nil
+}
+
+// sectionHeading returns the most common trimmed language spelling among
+// items, preferring the first seen on a tie
+func sectionHeading(items []*Vocabulary) string {
+	counts := make(map[string]int)
+	heading := ""
+	for _, item := range items {
+		name := strings.TrimSpace(item.Language)
+		counts[name]++
+		if counts[name] > counts[heading] {
+			heading = name
+		}
+	}
+
+	if heading == "" {
+		return "Unknown language"
+	}
+	return heading
+}
+
+// ExportToHTML exports all vocabulary items to a printable HTML file
+func (db *Database) ExportToHTML(filePath string) error {
+	// Create file with secure permissions (0600 - owner read/write only)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	if err := db.WriteHTML(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
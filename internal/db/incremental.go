@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// lastExportKey is the metadata key holding the created_at of the newest
+// row included in the last incremental export
+const lastExportKey = "last_export_at"
+
+// LastExportAt returns the incremental export marker, or the zero time if
+// no incremental export has run yet
+func (db *Database) LastExportAt() (time.Time, error) {
+	var value string
+	err := db.conn.QueryRow(`SELECT value FROM metadata WHERE key = ?`, lastExportKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last export time: %w", err)
+	}
+
+	t, err := time.Parse(timestampLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse last export time %q: %w", value, err)
+	}
+	return t, nil
+}
+
+// SetLastExportAt moves the incremental export marker
+func (db *Database) SetLastExportAt(t time.Time) error {
+	query := `INSERT INTO metadata (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`
+	if _, err := db.conn.Exec(query, lastExportKey, formatTimestamp(t)); err != nil {
+		return fmt.Errorf("failed to set last export time: %w", err)
+	}
+	return nil
+}
+
+// NewExportSince builds an export of the items created after t, newest
+// first. A zero t includes every item.
+func (db *Database) NewExportSince(t time.Time) (*Export, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE created_at > ? AND ` + notDeleted + ` ORDER BY ` + sortOrders["created_at"]
+
+	items, err := db.queryVocabulary(query, formatTimestamp(t))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary for export: %w", err)
+	}
+	if items == nil {
+		items = []*Vocabulary{}
+	}
+
+	return &Export{
+		Version:    ExportVersion,
+		ExportedAt: db.clock.Now().UTC(),
+		Items:      items,
+	}, nil
+}
+
+// ExportSince writes the items created after t to w as a versioned JSON
+// export
+func (db *Database) ExportSince(t time.Time, w io.Writer) error {
+	export, err := db.NewExportSince(t)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+// Newest returns the latest created_at among the export's items, or the
+// zero time for an empty export
+func (e *Export) Newest() time.Time {
+	var newest time.Time
+	for _, item := range e.Items {
+		if item.CreatedAt.After(newest) {
+			newest = item.CreatedAt
+		}
+	}
+	return newest
+}
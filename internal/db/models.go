@@ -4,8 +4,26 @@ import "time"
 
 // Vocabulary represents a vocabulary item stored in the database
 type Vocabulary struct {
-	ID        int       `json:"id"`
-	Text      string    `json:"text"`
-	Language  string    `json:"language"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int       `json:"id"`
+	Text        string    `json:"text"`
+	Language    string    `json:"language"`
+	Translation string    `json:"translation"`
+	Context     string    `json:"context"`
+	Example     string    `json:"example"`
+	Occurrences int       `json:"occurrences"`
+	Frequency   int       `json:"frequency"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Tags        []string  `json:"tags"`
+
+	// DeletedAt is set on soft-deleted items, which are only listed when
+	// asked for
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// CompactVocabulary is the slim projection of a vocabulary item returned by
+// ListCompact, for clients that only need to identify items
+type CompactVocabulary struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
 }
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// languageNames maps the English language names used in the language column
+// to tags for the languages whose casing rules differ from the default
+var languageNames = map[string]language.Tag{
+	"turkish":     language.Turkish,
+	"azerbaijani": language.Azerbaijani,
+	"lithuanian":  language.Lithuanian,
+	"greek":       language.Greek,
+	"german":      language.German,
+	"dutch":       language.Dutch,
+}
+
+// languageTag resolves a stored language, either a name such as "Turkish" or
+// a BCP 47 code such as "tr", to a tag. Unknown values, including
+// "auto-detect", give language.Und.
+func languageTag(lang string) language.Tag {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if tag, ok := languageNames[lang]; ok {
+		return tag
+	}
+	if tag, err := language.Parse(lang); err == nil {
+		return tag
+	}
+	return language.Und
+}
+
+// normalizeText derives the comparison form of a vocabulary item stored in
+// the normalized column. It lowercases with the rules of the item's language
+// (so Turkish "İ" becomes "i" and "I" becomes "ı"), then applies Unicode case
+// folding so that "ß" matches "ss" and final sigma matches sigma.
+func normalizeText(text, lang string) string {
+	lower := cases.Lower(languageTag(lang)).String(strings.TrimSpace(text))
+	return cases.Fold().String(lower)
+}
+
+// foldText derives the accent-insensitive search form stored in the
+// ascii_fold column: the normalized text with combining marks removed, so
+// "sí" folds to "si" and "Ñandú" to "nandu". It is only used for searching;
+// deduplication stays accent-sensitive through the normalized column.
+func foldText(text, lang string) string {
+	decomposed := norm.NFD.String(normalizeText(text, lang))
+
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
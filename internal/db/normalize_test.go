@@ -0,0 +1,74 @@
+package db
+
+import "testing"
+
+// TestNormalizeTextCaseFolding tests locale-aware folding for deduplication
+func TestNormalizeTextCaseFolding(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		language string
+		same     bool
+	}{
+		{"Turkish dotted capital I", "İstanbul", "istanbul", "Turkish", true},
+		{"Turkish dotless capital I", "ISPARTA", "ısparta", "tr", true},
+		{"Turkish I is not i", "ISPARTA", "isparta", "Turkish", false},
+		{"Default I is i", "ISPARTA", "isparta", "English", true},
+		{"German sharp s", "Straße", "STRASSE", "German", true},
+		{"German sharp s without locale", "straße", "strasse", "auto-detect", true},
+		{"Greek final sigma", "ΟΔΟΣ", "οδος", "Greek", true},
+		{"Greek final and medial sigma", "οδος", "οδοσ", "el", true},
+		{"Accents are kept", "café", "cafe", "French", false},
+		{"Whitespace is trimmed", "  Hola ", "hola", "Spanish", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := normalizeText(tc.a, tc.language)
+			b := normalizeText(tc.b, tc.language)
+			if (a == b) != tc.same {
+				t.Errorf("normalizeText(%q) = %q, normalizeText(%q) = %q; want same = %v", tc.a, a, tc.b, b, tc.same)
+			}
+		})
+	}
+}
+
+// TestLanguageTag tests resolving stored language values
+func TestLanguageTag(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Turkish", "tr"},
+		{"tr", "tr"},
+		{"de-AT", "de-AT"},
+		{"auto-detect", "und"},
+		{"", "und"},
+	}
+
+	for _, tc := range tests {
+		if got := languageTag(tc.input).String(); got != tc.want {
+			t.Errorf("languageTag(%q) = %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+// TestFoldText tests the accent-insensitive search form
+func TestFoldText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"sí", "si"},
+		{"Ñandú", "nandu"},
+		{"Straße", "strasse"},
+		{"Ça va", "ca va"},
+		{"si", "si"},
+	}
+
+	for _, tt := range tests {
+		if got := foldText(tt.text, ""); got != tt.want {
+			t.Errorf("foldText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
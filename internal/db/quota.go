@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// quotaDayLayout is the format of the day column in upload_quota
+const quotaDayLayout = "2006-01-02"
+
+// QuotaUsage describes a client's uploads for the current UTC day
+type QuotaUsage struct {
+	Used    int
+	Limit   int
+	ResetAt time.Time
+	// RetryAfter is the time left until ResetAt by the database clock
+	RetryAfter time.Duration
+
+	day string
+}
+
+// ConsumeQuota records one upload for client against a daily limit. If the
+// client has already used limit uploads today nothing is recorded and
+// allowed is false. Days roll over at midnight UTC according to the database
+// clock, and counts from earlier days are discarded.
+func (db *Database) ConsumeQuota(client string, limit int) (usage QuotaUsage, allowed bool, err error) {
+	now := db.clock.Now().UTC()
+	day := now.Format(quotaDayLayout)
+	usage = QuotaUsage{
+		Limit:   limit,
+		ResetAt: time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC),
+		day:     day,
+	}
+	usage.RetryAfter = usage.ResetAt.Sub(now)
+
+	if _, err := db.conn.Exec(`DELETE FROM upload_quota WHERE day < ?`, day); err != nil {
+		return usage, false, fmt.Errorf("failed to expire upload quota: %w", err)
+	}
+
+	// The conditional upsert only counts the upload while under the limit,
+	// so concurrent requests cannot overshoot it
+	result, err := db.conn.Exec(`
+		INSERT INTO upload_quota (client, day, count) VALUES (?, ?, 1)
+		ON CONFLICT(client, day) DO UPDATE SET count = count + 1 WHERE count < ?`,
+		client, day, limit)
+	if err != nil {
+		return usage, false, fmt.Errorf("failed to record upload quota: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return usage, false, fmt.Errorf("failed to record upload quota: %w", err)
+	}
+
+	if err := db.conn.QueryRow(`SELECT count FROM upload_quota WHERE client = ? AND day = ?`, client, day).Scan(&usage.Used); err != nil {
+		return usage, false, fmt.Errorf("failed to read upload quota: %w", err)
+	}
+
+	return usage, affected > 0, nil
+}
+
+// ReleaseQuota gives back an upload recorded by ConsumeQuota, for uploads
+// that failed and should not count. usage is the value ConsumeQuota
+// returned; an upload recorded on a day that has since been discarded is
+// not released.
+func (db *Database) ReleaseQuota(client string, usage QuotaUsage) error {
+	_, err := db.conn.Exec(`UPDATE upload_quota SET count = count - 1 WHERE client = ? AND day = ? AND count > 0`, client, usage.day)
+	if err != nil {
+		return fmt.Errorf("failed to release upload quota: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FindSimilar returns stored vocabulary within maxDistance edits of text,
+// closest first. Candidates are narrowed in SQL by length (an edit distance
+// of d can change the length by at most d) before distances are computed.
+// The word itself is excluded.
+func (db *Database) FindSimilar(text string, maxDistance int, limit int) ([]*Vocabulary, error) {
+	if maxDistance < 0 {
+		return nil, fmt.Errorf("max distance cannot be negative")
+	}
+	if limit <= 0 {
+		return []*Vocabulary{}, nil
+	}
+
+	length := utf8.RuneCountInString(text)
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary
+		WHERE text != ? AND length(text) BETWEEN ? AND ? AND ` + notDeleted
+
+	candidates, err := db.queryVocabulary(query, text, length-maxDistance, length+maxDistance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find similar vocabulary: %w", err)
+	}
+
+	target := strings.ToLower(text)
+	distances := make(map[int]int, len(candidates))
+	similar := make([]*Vocabulary, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		distance := levenshtein(target, strings.ToLower(candidate.Text))
+		if distance <= maxDistance {
+			distances[candidate.ID] = distance
+			similar = append(similar, candidate)
+		}
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		if distances[similar[i].ID] != distances[similar[j].ID] {
+			return distances[similar[i].ID] < distances[similar[j].ID]
+		}
+		return similar[i].Text < similar[j].Text
+	})
+
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+
+	return similar, nil
+}
+
+// levenshtein computes the edit distance between two strings by rune
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
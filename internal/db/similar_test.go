@@ -0,0 +1,71 @@
+package db
+
+import "testing"
+
+// TestLevenshtein tests edit distance computation
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"gato", "gato", 0},
+		{"gato", "pato", 1},
+		{"gato", "gatos", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"", "hola", 4},
+	}
+
+	for _, tc := range tests {
+		result := levenshtein(tc.a, tc.b)
+		if result != tc.expected {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", tc.a, tc.b, result, tc.expected)
+		}
+	}
+}
+
+// TestFindSimilar tests returning near-spellings and excluding others
+func TestFindSimilar(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, text := range []string{"gato", "pato", "gatos", "Gata", "perro", "mariposa", "gastronomía"} {
+		if _, err := db.Insert(&Vocabulary{Text: text, Language: "es"}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	similar, err := db.FindSimilar("gato", 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to find similar: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, vocab := range similar {
+		found[vocab.Text] = true
+	}
+
+	for _, want := range []string{"pato", "gatos", "Gata"} {
+		if !found[want] {
+			t.Errorf("Expected %q to be similar to 'gato'", want)
+		}
+	}
+	for _, unwanted := range []string{"gato", "perro", "mariposa", "gastronomía"} {
+		if found[unwanted] {
+			t.Errorf("Did not expect %q to be similar to 'gato'", unwanted)
+		}
+	}
+
+	limited, err := db.FindSimilar("gato", 1, 2)
+	if err != nil {
+		t.Fatalf("Failed to find similar with limit: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("Expected limit of 2 results, got %d", len(limited))
+	}
+
+	if _, err := db.FindSimilar("gato", -1, 10); err == nil {
+		t.Error("Expected error for negative distance")
+	}
+}
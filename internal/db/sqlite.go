@@ -2,16 +2,23 @@ package db
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// ErrDuplicateText is returned by Insert when an item with the same
+// normalized text already exists
+var ErrDuplicateText = errors.New("vocabulary already exists")
+
 // Database represents a SQLite database connection
 type Database struct {
-	conn *sql.DB
+	conn  *sql.DB
+	clock Clock
 }
 
 const schema = `
@@ -23,16 +30,193 @@ CREATE TABLE IF NOT EXISTS vocabulary (
 );
 CREATE INDEX IF NOT EXISTS idx_text ON vocabulary(text);
 CREATE INDEX IF NOT EXISTS idx_language ON vocabulary(language);
+CREATE TABLE IF NOT EXISTS upload_quota (
+    client TEXT NOT NULL,
+    day TEXT NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (client, day)
+);
+CREATE TABLE IF NOT EXISTS vocabulary_tags (
+    vocab_id INTEGER NOT NULL REFERENCES vocabulary(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (vocab_id, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_tags_tag ON vocabulary_tags(tag);
+CREATE TABLE IF NOT EXISTS vocabulary_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    vocab_id INTEGER NOT NULL,
+    field TEXT NOT NULL,
+    old_value TEXT NOT NULL,
+    new_value TEXT NOT NULL,
+    changed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_vocabulary_history_vocab ON vocabulary_history(vocab_id);
+CREATE TABLE IF NOT EXISTS metadata (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS extraction_cache (
+    text_hash TEXT NOT NULL,
+    language TEXT NOT NULL,
+    with_context INTEGER NOT NULL,
+    limits TEXT NOT NULL,
+    detected_language TEXT NOT NULL,
+    items TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (text_hash, language, with_context, limits)
+);
 `
 
-// NewDatabase creates a new database connection and initializes the schema
+// migration is one ordered step of the schema upgrade applied by migrate.
+// Steps must be idempotent: databases created before schema_version existed
+// start at version 0 but may already have some of the changes.
+type migration struct {
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change made after the initial schema, in
+// order. The schema version of a database is the number of steps applied,
+// so new steps are only ever appended.
+var migrations = []migration{
+	{"add normalized column", addColumn("normalized", "TEXT")},
+	{"add source column", addColumn("source", "TEXT DEFAULT ''")},
+	{"add context column", addColumn("context", "TEXT DEFAULT ''")},
+	{"add occurrences column", addColumn("occurrences", "INTEGER DEFAULT 1")},
+	{"add updated_at column", addColumn("updated_at", "DATETIME")},
+	{"add translation column", addColumn("translation", "TEXT DEFAULT ''")},
+	{"add ascii_fold column", addColumn("ascii_fold", "TEXT")},
+	{"add example column", addColumn("example", "TEXT DEFAULT ''")},
+	{"add frequency column", addColumn("frequency", "INTEGER DEFAULT 1")},
+	{"add deleted_at column", addColumn("deleted_at", "DATETIME")},
+	{"add language_key column", addLanguageKey},
+}
+
+// vocabularyColumns is the column list read by scanVocabulary. Columns added
+// by migration are coalesced so rows from older databases scan cleanly;
+// updated_at is left as-is because COALESCE would lose its DATETIME type,
+// and scanVocabulary falls back to created_at instead. Tags are read as one
+// comma separated string, which is safe because tags cannot contain commas.
+const vocabularyColumns = `id, text, language, COALESCE(translation, ''), COALESCE(context, ''), COALESCE(example, ''), COALESCE(occurrences, 1), COALESCE(frequency, 1), created_at, updated_at, deleted_at,
+	(SELECT group_concat(tag, ',') FROM vocabulary_tags WHERE vocab_id = vocabulary.id)`
+
+// notDeleted is the WHERE condition leaving out soft-deleted rows. Reads
+// and duplicate checks apply it unless they are asked for deleted rows;
+// storing a deleted row's text again revives the row.
+const notDeleted = `deleted_at IS NULL`
+
+// storedItem selects the ID of the live item stored under a text, taking
+// the text, its normalized form and the text again. An exact match wins
+// over one that only shares the normalized form.
+const storedItem = `SELECT id FROM vocabulary WHERE (text = ? OR normalized = ?) AND ` + notDeleted + ` ORDER BY text = ? DESC, id LIMIT 1`
+
+// sortOrders maps the sort fields accepted by ListSorted to ORDER BY clauses
+var sortOrders = map[string]string{
+	"created_at": "created_at DESC",
+	"updated_at": "COALESCE(updated_at, created_at) DESC",
+	"frequency":  "COALESCE(frequency, 1) DESC, created_at DESC, id DESC",
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanVocabulary reads a row selected with vocabularyColumns
+func scanVocabulary(row rowScanner) (*Vocabulary, error) {
+	var vocab Vocabulary
+	var updatedAt, deletedAt sql.NullTime
+	var tags sql.NullString
+	err := row.Scan(
+		&vocab.ID,
+		&vocab.Text,
+		&vocab.Language,
+		&vocab.Translation,
+		&vocab.Context,
+		&vocab.Example,
+		&vocab.Occurrences,
+		&vocab.Frequency,
+		&vocab.CreatedAt,
+		&updatedAt,
+		&deletedAt,
+		&tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	vocab.UpdatedAt = vocab.CreatedAt
+	if updatedAt.Valid {
+		vocab.UpdatedAt = updatedAt.Time
+	}
+	if deletedAt.Valid {
+		vocab.DeletedAt = &deletedAt.Time
+	}
+	vocab.Tags = []string{}
+	if tags.String != "" {
+		vocab.Tags = strings.Split(tags.String, ",")
+		sort.Strings(vocab.Tags)
+	}
+	return &vocab, nil
+}
+
+// queryVocabulary runs a query selecting vocabularyColumns and scans all rows
+func (db *Database) queryVocabulary(query string, args ...any) ([]*Vocabulary, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Vocabulary
+	for rows.Next() {
+		vocab, err := scanVocabulary(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vocabulary: %w", err)
+		}
+		items = append(items, vocab)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// DefaultBusyTimeout is how long a connection waits for a lock held by
+// another one before failing with "database is locked"
+const DefaultBusyTimeout = 5 * time.Second
+
+// ErrCheckpointBusy is returned by Checkpoint when other connections kept
+// the write-ahead log from being fully copied and truncated
+var ErrCheckpointBusy = errors.New("WAL checkpoint blocked by active connections")
+
+// Options configures NewDatabaseWithOptions. They only apply to file-backed
+// databases; in-memory databases ignore them.
+type Options struct {
+	// BusyTimeout is how long a write waits for another connection's lock;
+	// zero uses DefaultBusyTimeout
+	BusyTimeout time.Duration
+}
+
+// NewDatabase creates a new database connection with the default Options
+// and initializes the schema
 func NewDatabase(dbPath string) (*Database, error) {
+	return NewDatabaseWithOptions(dbPath, Options{})
+}
+
+// NewDatabaseWithOptions creates a new database connection and initializes
+// the schema
+func NewDatabaseWithOptions(dbPath string, opts Options) (*Database, error) {
 	// For in-memory databases, use shared cache mode for concurrent access
-	if dbPath == ":memory:" {
-		dbPath = "file::memory:?cache=shared"
+	inMemory := dbPath == ":memory:"
+	dsn := "file::memory:?cache=shared"
+	if !inMemory {
+		dsn = withBusyTimeout(dbPath, opts.BusyTimeout)
 	}
 
-	conn, err := sql.Open("sqlite3", dbPath)
+	conn, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -42,7 +226,7 @@ func NewDatabase(dbPath string) (*Database, error) {
 	conn.SetMaxIdleConns(5)
 
 	// Enable WAL mode for better concurrent access (skip for in-memory)
-	if dbPath != "file::memory:?cache=shared" {
+	if !inMemory {
 		if _, err := conn.Exec("PRAGMA journal_mode=WAL"); err != nil {
 			conn.Close()
 			return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
@@ -61,208 +245,1358 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to create schema: %w", err)
 	}
 
-	return &Database{conn: conn}, nil
+	if err := migrate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The normalized column only exists once the migrations above have run.
+	// This is retried on every open rather than being a migration step, so
+	// a database that had to fall back to a plain index gets the unique one
+	// once its duplicates are gone.
+	if err := createNormalizedIndex(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Database{conn: conn, clock: systemClock{}}, nil
 }
 
-// Close closes the database connection
-func (db *Database) Close() error {
-	if db.conn != nil {
-		return db.conn.Close()
+// withBusyTimeout adds the busy timeout to a database path as a connection
+// parameter. A PRAGMA busy_timeout would only reach the one pooled
+// connection it ran on, while the parameter is applied to every connection
+// the pool opens.
+func withBusyTimeout(dbPath string, timeout time.Duration) string {
+	if timeout <= 0 {
+		timeout = DefaultBusyTimeout
+	}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_busy_timeout=%d", dbPath, sep, timeout.Milliseconds())
+}
+
+// createNormalizedIndex makes the normalized column unique, so "Hola" and
+// "hola" cannot both be stored while "Café" and "cafe" still can. Rows from
+// before the column existed have a NULL normalized value and do not
+// collide. A database that already holds colliding rows keeps a plain
+// index instead: Insert and imports still check for duplicates themselves.
+func createNormalizedIndex(conn *sql.DB) error {
+	_, err := conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_normalized_unique ON vocabulary(normalized)`)
+	if isUniqueViolation(err) {
+		if _, err := conn.Exec(`CREATE INDEX IF NOT EXISTS idx_normalized ON vocabulary(normalized)`); err != nil {
+			return fmt.Errorf("failed to create normalized index: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create normalized index: %w", err)
+	}
+
+	// The unique index replaces the plain one created by earlier versions
+	if _, err := conn.Exec(`DROP INDEX IF EXISTS idx_normalized`); err != nil {
+		return fmt.Errorf("failed to drop old normalized index: %w", err)
 	}
 	return nil
 }
 
-// Insert adds a new vocabulary item to the database
-// Returns the ID of the inserted item or an error if it already exists
-func (db *Database) Insert(vocab *Vocabulary) (int, error) {
-	query := `INSERT INTO vocabulary (text, language) VALUES (?, ?)`
-	result, err := db.conn.Exec(query, vocab.Text, vocab.Language)
+// isUniqueViolation reports whether err is a UNIQUE constraint failure
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// ErrSchemaTooNew is returned when a database was written by a newer version
+// of parsely with migrations this version does not know
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of parsely supports")
+
+// migrate brings the schema up to date by applying the migrations after the
+// version stored in schema_version. Each step runs in its own transaction
+// together with the version bump, so a failed step leaves the database at the
+// last version that applied cleanly.
+func migrate(conn *sql.DB) error {
+	if _, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	for {
+		applied, err := migrateStep(conn)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return nil
+		}
+	}
+}
+
+// migrateStep applies the next pending migration, reporting false once the
+// schema is current. The version is read inside the transaction so two
+// processes opening the same file do not apply a step twice.
+func migrateStep(conn *sql.DB) (bool, error) {
+	tx, err := conn.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert vocabulary: %w", err)
+		return false, fmt.Errorf("failed to begin migration: %w", err)
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
+	version, err := readSchemaVersion(tx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+		return false, err
+	}
+	if version > len(migrations) {
+		return false, fmt.Errorf("%w: version %d, supported %d", ErrSchemaTooNew, version, len(migrations))
+	}
+	if version == len(migrations) {
+		return false, nil
+	}
+
+	step := migrations[version]
+	if err := step.apply(tx); err != nil {
+		return false, fmt.Errorf("failed to migrate schema to version %d (%s): %w", version+1, step.description, err)
 	}
 
-	return int(id), nil
+	if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+		return false, fmt.Errorf("failed to update schema version: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version+1); err != nil {
+		return false, fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration: %w", err)
+	}
+	return true, nil
 }
 
-// Get retrieves a vocabulary item by ID
-func (db *Database) Get(id int) (*Vocabulary, error) {
-	query := `SELECT id, text, language, created_at FROM vocabulary WHERE id = ?`
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
 
-	var vocab Vocabulary
-	err := db.conn.QueryRow(query, id).Scan(
-		&vocab.ID,
-		&vocab.Text,
-		&vocab.Language,
-		&vocab.CreatedAt,
-	)
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("vocabulary with ID %d not found", id)
-	}
+// readSchemaVersion returns the stored schema version, 0 when none is stored
+func readSchemaVersion(q rowQuerier) (int, error) {
+	var version int
+	err := q.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get vocabulary: %w", err)
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
 	}
+	return version, nil
+}
 
-	return &vocab, nil
+// SchemaVersion returns the schema version of the database
+func (db *Database) SchemaVersion() (int, error) {
+	return readSchemaVersion(db.conn)
 }
 
-// List retrieves all vocabulary items ordered by creation date (newest first)
-func (db *Database) List() ([]*Vocabulary, error) {
-	query := `SELECT id, text, language, created_at FROM vocabulary ORDER BY created_at DESC`
+// addColumn returns a migration step adding a column to the vocabulary
+// table unless it already exists
+func addColumn(name, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := hasColumn(tx, "vocabulary", name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
 
-	rows, err := db.conn.Query(query)
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE vocabulary ADD COLUMN %s %s`, name, definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// addLanguageKey adds the language_key column that language filters match
+// on, fills it for existing rows and indexes it
+func addLanguageKey(tx *sql.Tx) error {
+	if err := addColumn("language_key", "TEXT")(tx); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT language FROM vocabulary`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list vocabulary: %w", err)
+		return fmt.Errorf("failed to read languages: %w", err)
+	}
+	var languages []string
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan language: %w", err)
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("error iterating languages: %w", err)
+	}
+	rows.Close()
+
+	for _, language := range languages {
+		if _, err := tx.Exec(`UPDATE vocabulary SET language_key = ? WHERE language = ?`, languageKey(language), language); err != nil {
+			return fmt.Errorf("failed to fill language_key: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_language_key ON vocabulary(language_key)`); err != nil {
+		return fmt.Errorf("failed to index language_key: %w", err)
+	}
+	return nil
+}
+
+// hasColumn reports whether table has a column with the given name
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
 	}
 	defer rows.Close()
 
-	var items []*Vocabulary
 	for rows.Next() {
-		var vocab Vocabulary
-		err := rows.Scan(
-			&vocab.ID,
-			&vocab.Text,
-			&vocab.Language,
-			&vocab.CreatedAt,
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
 		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan vocabulary: %w", err)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("failed to scan schema: %w", err)
+		}
+		if name == column {
+			return true, nil
 		}
-		items = append(items, &vocab)
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return false, fmt.Errorf("error iterating schema: %w", err)
 	}
 
-	return items, nil
+	return false, nil
 }
 
-// Delete removes a vocabulary item by ID
-func (db *Database) Delete(id int) error {
-	query := `DELETE FROM vocabulary WHERE id = ?`
-	result, err := db.conn.Exec(query, id)
+// Ping checks that the database connection is still usable
+func (db *Database) Ping() error {
+	if err := db.conn.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (db *Database) Close() error {
+	if db.conn != nil {
+		return db.conn.Close()
+	}
+	return nil
+}
+
+// Checkpoint copies the write-ahead log into the database file and
+// truncates it, so the -wal file does not keep growing while the server
+// runs. It returns ErrCheckpointBusy if other connections held the log
+// open. In-memory databases have no log and always succeed.
+func (db *Database) Checkpoint() error {
+	var busy, logFrames, checkpointed int
+	err := db.conn.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed)
 	if err != nil {
-		return fmt.Errorf("failed to delete vocabulary: %w", err)
+		return fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	if busy != 0 {
+		return ErrCheckpointBusy
 	}
+	return nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// Insert adds a new vocabulary item to the database
+// Returns the ID of the inserted item or an error if it already exists
+func (db *Database) Insert(vocab *Vocabulary) (int, error) {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("vocabulary with ID %d not found", id)
+	now := db.now()
+	id, err := insertVocabulary(tx, vocab, now)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("failed to insert vocabulary: %w: %q", ErrDuplicateText, vocab.Text)
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit insert: %w", err)
+	}
+
+	return id, nil
 }
 
-// ExistsText checks if a vocabulary item with the given text already exists
-func (db *Database) ExistsText(text string) (bool, error) {
-	query := `SELECT COUNT(*) FROM vocabulary WHERE text = ?`
+// insertVocabulary stores vocab unless a live item already has its text or
+// normalized form, and returns the new ID or 0 for a duplicate. A
+// soft-deleted item with the same text is revived instead of blocking the
+// insert.
+func insertVocabulary(tx *sql.Tx, vocab *Vocabulary, now string) (int, error) {
+	id, err := reviveDeleted(tx, vocab, now, now)
+	if err != nil || id != 0 {
+		return id, err
+	}
 
-	var count int
-	err := db.conn.QueryRow(query, text).Scan(&count)
+	// The normalized column drives uniqueness: a row that differs from an
+	// existing one only by case is not inserted, so the first-seen casing
+	// is the one kept
+	normalized := normalizeText(vocab.Text, vocab.Language)
+	query := `INSERT INTO vocabulary (text, language, language_key, normalized, ascii_fold, translation, context, example, occurrences, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM vocabulary WHERE normalized = ?)`
+	result, err := tx.Exec(query, vocab.Text, vocab.Language, languageKey(vocab.Language), normalized, foldText(vocab.Text, vocab.Language), vocab.Translation, vocab.Context, vocab.Example, max(vocab.Occurrences, 1), now, now, normalized)
+	if isUniqueViolation(err) {
+		return 0, nil
+	}
 	if err != nil {
-		return false, fmt.Errorf("failed to check if text exists: %w", err)
+		return 0, fmt.Errorf("failed to insert vocabulary %q: %w", vocab.Text, err)
 	}
 
-	return count > 0, nil
-}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return 0, nil
+	}
 
-// GetByText retrieves a vocabulary item by its text
-func (db *Database) GetByText(text string) (*Vocabulary, error) {
-	query := `SELECT id, text, language, created_at FROM vocabulary WHERE text = ?`
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert ID: %w", err)
+	}
+	return int(lastID), nil
+}
 
-	var vocab Vocabulary
-	err := db.conn.QueryRow(query, text).Scan(
-		&vocab.ID,
-		&vocab.Text,
-		&vocab.Language,
-		&vocab.CreatedAt,
-	)
+// reviveDeleted brings back the soft-deleted item stored under vocab's text
+// or normalized form, overwriting it with vocab as if it had just been
+// inserted: the old tags are dropped, its history is kept. It returns the
+// item's ID, or 0 when there is no such item or a live item already holds
+// the text.
+func reviveDeleted(tx *sql.Tx, vocab *Vocabulary, createdAt, updatedAt string) (int, error) {
+	normalized := normalizeText(vocab.Text, vocab.Language)
 
+	// A single UPDATE rather than a lookup first, so the transaction starts
+	// as a write and waits for the busy timeout instead of failing to
+	// upgrade its read lock
+	query := `UPDATE vocabulary SET text = ?, language = ?, language_key = ?, normalized = ?, ascii_fold = ?, translation = ?, context = ?, example = ?,
+			occurrences = ?, frequency = ?, created_at = ?, updated_at = ?, deleted_at = NULL
+		WHERE id = (SELECT id FROM vocabulary WHERE (text = ? OR normalized = ?) AND deleted_at IS NOT NULL ORDER BY text = ? DESC, id LIMIT 1)
+			AND NOT EXISTS (` + storedItem + `)
+		RETURNING id`
+	var id int
+	err := tx.QueryRow(query, vocab.Text, vocab.Language, languageKey(vocab.Language), normalized, foldText(vocab.Text, vocab.Language), vocab.Translation, vocab.Context, vocab.Example,
+		max(vocab.Occurrences, 1), max(vocab.Frequency, 1), createdAt, updatedAt,
+		vocab.Text, normalized, vocab.Text,
+		vocab.Text, normalized, vocab.Text).Scan(&id)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("vocabulary with text '%s' not found", text)
+		return 0, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get vocabulary by text: %w", err)
+		return 0, fmt.Errorf("failed to revive vocabulary %q: %w", vocab.Text, err)
 	}
 
-	return &vocab, nil
+	if _, err := tx.Exec(`DELETE FROM vocabulary_tags WHERE vocab_id = ?`, id); err != nil {
+		return 0, fmt.Errorf("failed to clear tags: %w", err)
+	}
+
+	return id, nil
 }
 
-// ExportToJSON exports all vocabulary items to a JSON file
-func (db *Database) ExportToJSON(filePath string) error {
-	items, err := db.List()
-	if err != nil {
-		return fmt.Errorf("failed to list vocabulary for export: %w", err)
+// InsertMany adds vocabulary items in a single transaction. Items whose text
+// or normalized form was already stored are skipped rather than failing the
+// batch, and count as another document yielding the stored item: its
+// frequency goes up by one. Items sharing the normalized form of an earlier
+// item in the batch come from the same document, so they are skipped without
+// changing the frequency and flagged in repeated, which has one entry per
+// item. Soft-deleted items are revived like in Insert. Each inserted item has
+// its ID set and skipped items are left with ID 0. Any other error rolls the
+// whole batch back.
+func (db *Database) InsertMany(items []*Vocabulary) (inserted int, repeated []bool, err error) {
+	if len(items) == 0 {
+		return 0, nil, nil
 	}
 
-	// Create file with secure permissions (0600 - owner read/write only)
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to create export file: %w", err)
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer file.Close()
+	defer tx.Rollback()
+	defer func() {
+		// Nothing was kept, so no item keeps an ID
+		if err != nil {
+			for _, vocab := range items {
+				vocab.ID = 0
+			}
+		}
+	}()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	now := db.now()
+	repeated = make([]bool, len(items))
+	seen := make(map[string]bool, len(items))
+	for i, vocab := range items {
+		normalized := normalizeText(vocab.Text, vocab.Language)
+		if seen[normalized] {
+			vocab.ID = 0
+			repeated[i] = true
+			continue
+		}
+		seen[normalized] = true
 
-	if err := encoder.Encode(items); err != nil {
-		return fmt.Errorf("failed to encode JSON: %w", err)
+		vocab.ID, err = insertVocabulary(tx, vocab, now)
+		if err != nil {
+			return 0, nil, err
+		}
+		if vocab.ID != 0 {
+			inserted++
+			continue
+		}
+		if _, err := incrementFrequency(tx, vocab.Text, vocab.Language); err != nil {
+			return 0, nil, err
+		}
 	}
 
-	return nil
-}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit inserts: %w", err)
+	}
 
-// Count returns the total number of vocabulary items
-func (db *Database) Count() (int, error) {
-	query := `SELECT COUNT(*) FROM vocabulary`
+	return inserted, repeated, nil
+}
 
-	var count int
-	err := db.conn.QueryRow(query).Scan(&count)
+// Upsert inserts a vocabulary item, or sets the translation of the item
+// already stored under its text. It returns the item's ID and whether it was
+// newly inserted. A text that differs from the stored item only by case
+// updates that item. An empty or unchanged translation leaves the stored
+// item untouched, so upserting the same item again is a no-op.
+func (db *Database) Upsert(vocab *Vocabulary) (int, bool, error) {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to count vocabulary: %w", err)
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return count, nil
-}
+	now := db.now()
+	id, err := reviveDeleted(tx, vocab, now, now)
+	if err != nil {
+		return 0, false, err
+	}
+	if id != 0 {
+		if err := tx.Commit(); err != nil {
+			return 0, false, fmt.Errorf("failed to commit upsert: %w", err)
+		}
+		return id, true, nil
+	}
 
-// SearchByLanguage returns all vocabulary items for a specific language
-func (db *Database) SearchByLanguage(language string) ([]*Vocabulary, error) {
-	query := `SELECT id, text, language, created_at FROM vocabulary WHERE language = ? ORDER BY created_at DESC`
+	// Look up the stored text first, so a case-only difference conflicts on
+	// text rather than failing on the unique normalized index
+	normalized := normalizeText(vocab.Text, vocab.Language)
+	text := vocab.Text
+	var old string
+	err = tx.QueryRow(`SELECT id, text, COALESCE(translation, '') FROM vocabulary
+		WHERE (text = ? OR normalized = ?) AND `+notDeleted+` ORDER BY text = ? DESC, id LIMIT 1`, vocab.Text, normalized, vocab.Text).Scan(&id, &text, &old)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to get vocabulary: %w", err)
+	}
+	inserted := err == sql.ErrNoRows
 
-	rows, err := db.conn.Query(query, language)
+	query := `INSERT INTO vocabulary (text, language, language_key, normalized, ascii_fold, translation, context, example, occurrences, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(text) DO UPDATE SET translation = excluded.translation, updated_at = excluded.updated_at
+		WHERE excluded.translation != '' AND excluded.translation != COALESCE(vocabulary.translation, '')`
+	result, err := tx.Exec(query, text, vocab.Language, languageKey(vocab.Language), normalized, foldText(vocab.Text, vocab.Language), vocab.Translation, vocab.Context, vocab.Example, max(vocab.Occurrences, 1), now, now)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search by language: %w", err)
+		return 0, false, fmt.Errorf("failed to upsert vocabulary: %w", err)
 	}
-	defer rows.Close()
 
-	var items []*Vocabulary
-	for rows.Next() {
-		var vocab Vocabulary
-		err := rows.Scan(
-			&vocab.ID,
-			&vocab.Text,
-			&vocab.Language,
-			&vocab.CreatedAt,
-		)
+	if inserted {
+		lastID, err := result.LastInsertId()
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan vocabulary: %w", err)
+			return 0, false, fmt.Errorf("failed to get last insert ID: %w", err)
+		}
+		id = int(lastID)
+	} else if vocab.Translation != "" {
+		if err := recordHistory(tx, id, now, fieldChange{"translation", old, vocab.Translation}); err != nil {
+			return 0, false, err
 		}
-		items = append(items, &vocab)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to commit upsert: %w", err)
 	}
 
-	return items, nil
+	return id, inserted, nil
+}
+
+// Get retrieves a vocabulary item by ID. Soft-deleted items are not found.
+func (db *Database) Get(id int) (*Vocabulary, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE id = ? AND ` + notDeleted
+
+	vocab, err := scanVocabulary(db.conn.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("vocabulary with ID %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vocabulary: %w", err)
+	}
+
+	return vocab, nil
+}
+
+// List retrieves all vocabulary items ordered by creation date (newest first)
+func (db *Database) List() ([]*Vocabulary, error) {
+	return db.ListSorted("created_at")
 }
+
+// ListSorted retrieves all vocabulary items ordered newest first by the given
+// field, either "created_at" or "updated_at"
+func (db *Database) ListSorted(field string) ([]*Vocabulary, error) {
+	return db.ListSortedPaginated(field, -1, 0)
+}
+
+// ListPaginated retrieves one page of vocabulary items ordered by creation
+// date (newest first)
+func (db *Database) ListPaginated(limit, offset int) ([]*Vocabulary, error) {
+	return db.ListSortedPaginated("created_at", limit, offset)
+}
+
+// ListSortedPaginated is ListSorted limited to one page. A negative limit
+// returns every item from offset on.
+func (db *Database) ListSortedPaginated(field string, limit, offset int) ([]*Vocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE ` + notDeleted + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+
+	items, err := db.queryVocabulary(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// ForEach calls fn for every vocabulary item, newest first, reading one row
+// at a time so memory use does not grow with the table. Iteration stops at
+// the first error fn returns.
+func (db *Database) ForEach(fn func(*Vocabulary) error) error {
+	rows, err := db.conn.Query(`SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE ` + notDeleted + ` ORDER BY ` + sortOrders["created_at"])
+	if err != nil {
+		return fmt.Errorf("failed to list vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		vocab, err := scanVocabulary(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan vocabulary: %w", err)
+		}
+		if err := fn(vocab); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// ListCompact retrieves the ID and text of all vocabulary items, newest first
+func (db *Database) ListCompact() ([]*CompactVocabulary, error) {
+	return db.ListCompactSorted("created_at")
+}
+
+// ListCompactSorted is ListCompact ordered by the given field, either
+// "created_at" or "updated_at". Only the id and text columns are read.
+func (db *Database) ListCompactSorted(field string) ([]*CompactVocabulary, error) {
+	return db.ListCompactSortedPaginated(field, -1, 0)
+}
+
+// ListCompactSortedPaginated is ListCompactSorted limited to one page. A
+// negative limit returns every item from offset on.
+func (db *Database) ListCompactSortedPaginated(field string, limit, offset int) ([]*CompactVocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	items, err := db.queryCompact(`SELECT id, text FROM vocabulary WHERE `+notDeleted+` ORDER BY `+order+` LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// queryCompact runs a query selecting the id and text columns
+func (db *Database) queryCompact(query string, args ...any) ([]*CompactVocabulary, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*CompactVocabulary
+	for rows.Next() {
+		item := &CompactVocabulary{}
+		if err := rows.Scan(&item.ID, &item.Text); err != nil {
+			return nil, fmt.Errorf("failed to scan vocabulary: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return items, nil
+}
+
+// Update saves the text, language, translation, context and example of an existing
+// vocabulary item, identified by its ID, and refreshes its updated_at
+// timestamp. Each changed field is recorded in the item's history.
+func (db *Database) Update(vocab *Vocabulary) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old Vocabulary
+	err = tx.QueryRow(`SELECT text, language, COALESCE(translation, ''), COALESCE(context, ''), COALESCE(example, '') FROM vocabulary WHERE id = ? AND `+notDeleted, vocab.ID).
+		Scan(&old.Text, &old.Language, &old.Translation, &old.Context, &old.Example)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("vocabulary with ID %d not found", vocab.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get vocabulary: %w", err)
+	}
+
+	now := db.now()
+	query := `UPDATE vocabulary SET text = ?, language = ?, language_key = ?, normalized = ?, ascii_fold = ?, translation = ?, context = ?, example = ?, updated_at = ? WHERE id = ?`
+	_, err = tx.Exec(query, vocab.Text, vocab.Language, languageKey(vocab.Language), normalizeText(vocab.Text, vocab.Language), foldText(vocab.Text, vocab.Language), vocab.Translation, vocab.Context, vocab.Example, now, vocab.ID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("failed to update vocabulary: %w: %q", ErrDuplicateText, vocab.Text)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update vocabulary: %w", err)
+	}
+
+	err = recordHistory(tx, vocab.ID, now,
+		fieldChange{"text", old.Text, vocab.Text},
+		fieldChange{"language", old.Language, vocab.Language},
+		fieldChange{"translation", old.Translation, vocab.Translation},
+		fieldChange{"context", old.Context, vocab.Context},
+		fieldChange{"example", old.Example, vocab.Example},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete soft-deletes a vocabulary item by ID: it is hidden from reads
+// until Restore brings it back or PurgeDeleted removes it for good. Its tags
+// and history are kept for the restore.
+func (db *Database) Delete(id int) error {
+	result, err := db.conn.Exec(`UPDATE vocabulary SET deleted_at = ? WHERE id = ? AND `+notDeleted, db.now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete vocabulary: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("vocabulary with ID %d not found", id)
+	}
+
+	return nil
+}
+
+// DeleteMany soft-deletes the vocabulary items with the given IDs in a
+// single transaction, like Delete. IDs that do not exist or are already
+// deleted are skipped; the returned count is the number of items actually
+// deleted.
+func (db *Database) DeleteMany(ids []int) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE vocabulary SET deleted_at = ? WHERE id = ? AND ` + notDeleted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare delete: %w", err)
+	}
+	defer stmt.Close()
+
+	now := db.now()
+	deleted := 0
+	for _, id := range ids {
+		result, err := stmt.Exec(now, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete vocabulary %d: %w", id, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		deleted += int(rowsAffected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit delete: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// Restore brings back a soft-deleted vocabulary item with its tags
+func (db *Database) Restore(id int) error {
+	result, err := db.conn.Exec(`UPDATE vocabulary SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore vocabulary: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("deleted vocabulary with ID %d not found: %w", id, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes the items soft-deleted before the given
+// time, together with their tags, and returns how many were removed. A zero
+// time purges every soft-deleted item. Their history is kept, as the audit
+// trail outlives the item.
+func (db *Database) PurgeDeleted(before time.Time) (int, error) {
+	condition := `deleted_at IS NOT NULL`
+	args := []any{}
+	if !before.IsZero() {
+		condition += ` AND deleted_at < ?`
+		args = append(args, formatTimestamp(before))
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Tags are removed explicitly because foreign keys are only enforced on
+	// the connection that enabled them
+	if _, err := tx.Exec(`DELETE FROM vocabulary_tags WHERE vocab_id IN (SELECT id FROM vocabulary WHERE `+condition+`)`, args...); err != nil {
+		return 0, fmt.Errorf("failed to delete tags: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM vocabulary WHERE `+condition, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted vocabulary: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+
+	return int(purged), nil
+}
+
+// DeleteAll removes every vocabulary item with its tags and history, and
+// resets the ID sequence so new items start at 1 again. Unlike Delete, the
+// history has to go too: reused IDs would otherwise inherit stale entries.
+func (db *Database) DeleteAll() (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM vocabulary_tags`); err != nil {
+		return 0, fmt.Errorf("failed to delete tags: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM vocabulary_history`); err != nil {
+		return 0, fmt.Errorf("failed to delete history: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM vocabulary`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete vocabulary: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sqlite_sequence WHERE name IN ('vocabulary', 'vocabulary_history')`); err != nil {
+		return 0, fmt.Errorf("failed to reset ID sequence: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit delete: %w", err)
+	}
+
+	return int(deleted), nil
+}
+
+// SetTranslation stores the translation of a vocabulary item, refreshes its
+// updated_at timestamp and records the change in its history
+func (db *Database) SetTranslation(id int, translation string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old string
+	err = tx.QueryRow(`SELECT COALESCE(translation, '') FROM vocabulary WHERE id = ? AND `+notDeleted, id).Scan(&old)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("vocabulary with ID %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get vocabulary: %w", err)
+	}
+
+	now := db.now()
+	if _, err := tx.Exec(`UPDATE vocabulary SET translation = ?, updated_at = ? WHERE id = ?`, translation, now, id); err != nil {
+		return fmt.Errorf("failed to set translation: %w", err)
+	}
+
+	if err := recordHistory(tx, id, now, fieldChange{"translation", old, translation}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit translation: %w", err)
+	}
+
+	return nil
+}
+
+// ListUntranslated returns vocabulary items without a translation, oldest
+// first
+func (db *Database) ListUntranslated(limit, offset int) ([]*Vocabulary, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE COALESCE(translation, '') = '' AND ` + notDeleted + ` ORDER BY id LIMIT ? OFFSET ?`
+
+	items, err := db.queryVocabulary(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untranslated vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// CountUntranslated returns the number of vocabulary items without a
+// translation
+func (db *Database) CountUntranslated() (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM vocabulary WHERE COALESCE(translation, '') = '' AND ` + notDeleted).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count untranslated vocabulary: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListAutoDetected returns vocabulary items stored without a concrete
+// language, either empty or the literal "auto-detect" placeholder
+func (db *Database) ListAutoDetected() ([]*Vocabulary, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE (TRIM(COALESCE(language, '')) = '' OR LOWER(language) = 'auto-detect') AND ` + notDeleted + ` ORDER BY id`
+
+	items, err := db.queryVocabulary(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auto-detected vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// SetLanguage relabels a vocabulary item, recomputes the derived columns
+// that depend on its language and records the change in its history
+func (db *Database) SetLanguage(id int, language string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var text, old string
+	err = tx.QueryRow(`SELECT text, language FROM vocabulary WHERE id = ? AND `+notDeleted, id).Scan(&text, &old)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("vocabulary with ID %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get vocabulary: %w", err)
+	}
+
+	now := db.now()
+	query := `UPDATE vocabulary SET language = ?, language_key = ?, normalized = ?, ascii_fold = ?, updated_at = ? WHERE id = ?`
+	_, err = tx.Exec(query, language, languageKey(language), normalizeText(text, language), foldText(text, language), now, id)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("failed to set language: %w: %q", ErrDuplicateText, text)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set language: %w", err)
+	}
+
+	if err := recordHistory(tx, id, now, fieldChange{"language", old, language}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit language: %w", err)
+	}
+
+	return nil
+}
+
+// IncrementOccurrences records another occurrence of an existing vocabulary
+// item, identified by its text. A text that differs from the stored item
+// only by case, compared with the casing rules of language, counts toward
+// that item.
+func (db *Database) IncrementOccurrences(text, language string) error {
+	query := `UPDATE vocabulary SET occurrences = COALESCE(occurrences, 1) + 1, updated_at = ?
+		WHERE id = (` + storedItem + `)`
+	result, err := db.conn.Exec(query, db.now(), text, normalizeText(text, language), text)
+	if err != nil {
+		return fmt.Errorf("failed to increment occurrences: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("vocabulary with text '%s' not found", text)
+	}
+
+	return nil
+}
+
+// IncrementFrequency records that another document yielded an existing
+// vocabulary item, identified by its text like in IncrementOccurrences
+func (db *Database) IncrementFrequency(text, language string) error {
+	found, err := incrementFrequency(db.conn, text, language)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("vocabulary with text '%s' not found", text)
+	}
+	return nil
+}
+
+// incrementFrequency adds one to the frequency of the live item stored
+// under text, compared with the casing rules of language, and reports
+// whether there was one
+func incrementFrequency(conn execer, text, language string) (bool, error) {
+	query := `UPDATE vocabulary SET frequency = COALESCE(frequency, 1) + 1 WHERE id = (` + storedItem + `)`
+	result, err := conn.Exec(query, text, normalizeText(text, language), text)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment frequency: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// ExistsNormalized checks if a vocabulary item with the same normalized form
+// as text, compared with the casing rules of language, already exists, so
+// "madrid" matches a stored "Madrid".
+func (db *Database) ExistsNormalized(text, language string) (bool, error) {
+	query := `SELECT COUNT(*) FROM vocabulary WHERE (text = ? OR normalized = ?) AND ` + notDeleted
+
+	var count int
+	err := db.conn.QueryRow(query, text, normalizeText(text, language)).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if text exists: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// ExistsText checks if a vocabulary item with the given text already
+// exists, ignoring case and surrounding whitespace but not accents. It is
+// ExistsNormalized with the default casing rules.
+func (db *Database) ExistsText(text string) (bool, error) {
+	return db.ExistsNormalized(text, "")
+}
+
+// GetByText retrieves a vocabulary item by its text
+func (db *Database) GetByText(text string) (*Vocabulary, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE text = ? AND ` + notDeleted
+
+	vocab, err := scanVocabulary(db.conn.QueryRow(query, text))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("vocabulary with text '%s' not found", text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vocabulary by text: %w", err)
+	}
+
+	return vocab, nil
+}
+
+// GetByNormalized retrieves the vocabulary item text refers to, ignoring
+// case like IncrementOccurrences and preferring an exact match
+func (db *Database) GetByNormalized(text, language string) (*Vocabulary, error) {
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary
+		WHERE (text = ? OR normalized = ?) AND ` + notDeleted + ` ORDER BY text = ? DESC, id LIMIT 1`
+
+	vocab, err := scanVocabulary(db.conn.QueryRow(query, text, normalizeText(text, language), text))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("vocabulary with text '%s' not found", text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vocabulary by text: %w", err)
+	}
+
+	return vocab, nil
+}
+
+// RebuildDerived recomputes derived columns (normalized, ascii_fold,
+// language_key, source) for every row in a single transaction. Rows created before these columns
+// existed have NULL values until this runs. When older rows differ only by
+// case, the first one stored gets the normalized value and the others keep
+// NULL, so none of them is lost to the unique index.
+func (db *Database) RebuildDerived() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, text, language FROM vocabulary ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	type derived struct {
+		id          int
+		normalized  sql.NullString
+		folded      string
+		languageKey string
+	}
+	var values []derived
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id int
+		var text, lang string
+		if err := rows.Scan(&id, &text, &lang); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan vocabulary: %w", err)
+		}
+		normalized := normalizeText(text, lang)
+		values = append(values, derived{id, sql.NullString{String: normalized, Valid: !seen[normalized]}, foldText(text, lang), languageKey(lang)})
+		seen[normalized] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close()
+
+	stmt, err := tx.Prepare(`UPDATE vocabulary SET normalized = ?, ascii_fold = ?, language_key = ?, source = COALESCE(source, '') WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare update: %w", err)
+	}
+	defer stmt.Close()
+
+	// Clear every normalized value first so rows can swap values without
+	// colliding in the unique index part-way through
+	if _, err := tx.Exec(`UPDATE vocabulary SET normalized = NULL`); err != nil {
+		return fmt.Errorf("failed to reset normalized text: %w", err)
+	}
+	for _, value := range values {
+		if _, err := stmt.Exec(value.normalized, value.folded, value.languageKey, value.id); err != nil {
+			return fmt.Errorf("failed to update vocabulary %d: %w", value.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rebuild: %w", err)
+	}
+
+	return nil
+}
+
+// Count returns the total number of vocabulary items, not counting
+// soft-deleted ones
+func (db *Database) Count() (int, error) {
+	query := `SELECT COUNT(*) FROM vocabulary WHERE ` + notDeleted
+
+	var count int
+	err := db.conn.QueryRow(query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count vocabulary: %w", err)
+	}
+
+	return count, nil
+}
+
+// languageMatch is the WHERE condition selecting rows of one language,
+// ignoring case and surrounding whitespace; its argument comes from
+// languageKey. It compares the indexed language_key column, which is written
+// from Go because SQLite's LOWER only folds ASCII letters.
+const languageMatch = `language_key = ?`
+
+// languageKey trims and lower-cases a language name for languageMatch and
+// the language_key column
+func languageKey(language string) string {
+	return strings.ToLower(strings.TrimSpace(language))
+}
+
+// Filter narrows a vocabulary listing to one language, one tag and a
+// created_at range. Empty fields and zero times do not filter; From and To
+// are both inclusive. Soft-deleted items are left out unless IncludeDeleted
+// is set.
+type Filter struct {
+	Language       string
+	Tag            string
+	From           time.Time
+	To             time.Time
+	IncludeDeleted bool
+}
+
+// where returns the WHERE clause selecting the rows matching the filter,
+// or an empty string when nothing is filtered, together with its arguments
+func (f Filter) where() (string, []any) {
+	var conditions []string
+	var args []any
+	if !f.IncludeDeleted {
+		conditions = append(conditions, notDeleted)
+	}
+	if language := languageKey(f.Language); language != "" {
+		conditions = append(conditions, languageMatch)
+		args = append(args, language)
+	}
+	if tag := strings.ToLower(strings.TrimSpace(f.Tag)); tag != "" {
+		conditions = append(conditions, `id IN (SELECT vocab_id FROM vocabulary_tags WHERE tag = ?)`)
+		args = append(args, tag)
+	}
+	// Timestamps are stored as UTC text that sorts chronologically, so the
+	// bounds are compared in the same format
+	switch {
+	case !f.From.IsZero() && !f.To.IsZero():
+		conditions = append(conditions, `created_at BETWEEN ? AND ?`)
+		args = append(args, formatTimestamp(f.From), formatTimestamp(f.To))
+	case !f.From.IsZero():
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, formatTimestamp(f.From))
+	case !f.To.IsZero():
+		conditions = append(conditions, `created_at <= ?`)
+		args = append(args, formatTimestamp(f.To))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conditions, " AND "), args
+}
+
+// ListFilteredPaginated returns one page of the items matching filter,
+// ordered newest first by the given field. A negative limit returns every
+// item from offset on.
+func (db *Database) ListFilteredPaginated(filter Filter, field string, limit, offset int) ([]*Vocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	where, args := filter.where()
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary` + where + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+
+	items, err := db.queryVocabulary(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// GetRandom returns up to n vocabulary items in random order
+func (db *Database) GetRandom(n int) ([]*Vocabulary, error) {
+	return db.GetRandomFiltered(Filter{}, n)
+}
+
+// GetRandomFiltered returns up to n items matching filter in random order,
+// or an empty slice when none match
+func (db *Database) GetRandomFiltered(filter Filter, n int) ([]*Vocabulary, error) {
+	where, args := filter.where()
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary` + where + ` ORDER BY RANDOM() LIMIT ?`
+
+	items, err := db.queryVocabulary(query, append(args, n)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get random vocabulary: %w", err)
+	}
+	if items == nil {
+		items = []*Vocabulary{}
+	}
+
+	return items, nil
+}
+
+// ListByDateRange returns the items created between from and to, both
+// inclusive, newest first. A zero from or to leaves that end of the range
+// open.
+func (db *Database) ListByDateRange(from, to time.Time) ([]*Vocabulary, error) {
+	return db.ListFilteredPaginated(Filter{From: from, To: to}, "created_at", -1, 0)
+}
+
+// ListCompactFilteredPaginated is ListFilteredPaginated reading only the id
+// and text columns
+func (db *Database) ListCompactFilteredPaginated(filter Filter, field string, limit, offset int) ([]*CompactVocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	where, args := filter.where()
+	items, err := db.queryCompact(`SELECT id, text FROM vocabulary`+where+` ORDER BY `+order+` LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// CountFiltered returns the number of vocabulary items matching filter
+func (db *Database) CountFiltered(filter Filter) (int, error) {
+	where, args := filter.where()
+
+	var count int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM vocabulary`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count vocabulary: %w", err)
+	}
+
+	return count, nil
+}
+
+// SearchByLanguage returns all vocabulary items for a specific language,
+// newest first. The language is matched ignoring case and surrounding
+// whitespace.
+func (db *Database) SearchByLanguage(language string) ([]*Vocabulary, error) {
+	return db.SearchByLanguagePaginated(language, "created_at", -1, 0)
+}
+
+// SearchByLanguagePaginated is SearchByLanguage ordered newest first by the
+// given field and limited to one page. A negative limit returns every item
+// from offset on.
+func (db *Database) SearchByLanguagePaginated(language, field string, limit, offset int) ([]*Vocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE ` + languageMatch + ` AND ` + notDeleted + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+
+	items, err := db.queryVocabulary(query, languageKey(language), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search by language: %w", err)
+	}
+
+	return items, nil
+}
+
+// ListCompactByLanguagePaginated is SearchByLanguagePaginated reading only
+// the id and text columns
+func (db *Database) ListCompactByLanguagePaginated(language, field string, limit, offset int) ([]*CompactVocabulary, error) {
+	order, ok := sortOrders[field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field '%s'", field)
+	}
+
+	items, err := db.queryCompact(`SELECT id, text FROM vocabulary WHERE `+languageMatch+` AND `+notDeleted+` ORDER BY `+order+` LIMIT ? OFFSET ?`, languageKey(language), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search by language: %w", err)
+	}
+
+	return items, nil
+}
+
+// CountLanguage returns the number of vocabulary items of one language,
+// matched like SearchByLanguage
+func (db *Database) CountLanguage(language string) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM vocabulary WHERE `+languageMatch+` AND `+notDeleted, languageKey(language)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count vocabulary: %w", err)
+	}
+
+	return count, nil
+}
+
+// SearchFolded returns vocabulary whose text contains query, ignoring case
+// and diacritics, so "si" matches both "si" and "sí". Rows stored before the
+// ascii_fold column existed are only found after RebuildDerived.
+func (db *Database) SearchFolded(query string) ([]*Vocabulary, error) {
+	folded := foldText(query, "")
+	if folded == "" {
+		return nil, nil
+	}
+
+	pattern := "%" + likeEscaper.Replace(folded) + "%"
+	sqlQuery := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE ascii_fold LIKE ? ESCAPE '\' AND ` + notDeleted + ` ORDER BY created_at DESC`
+
+	items, err := db.queryVocabulary(sqlQuery, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// Search returns vocabulary whose text contains query, ignoring case but not
+// diacritics, newest first. % and _ in query match literally.
+func (db *Database) Search(query string) ([]*Vocabulary, error) {
+	normalized := normalizeText(query, "")
+	if normalized == "" {
+		return nil, nil
+	}
+
+	pattern := "%" + likeEscaper.Replace(normalized) + "%"
+	sqlQuery := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE COALESCE(normalized, text) LIKE ? ESCAPE '\' AND ` + notDeleted + ` ORDER BY created_at DESC`
+
+	items, err := db.queryVocabulary(sqlQuery, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search vocabulary: %w", err)
+	}
+
+	return items, nil
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -1,9 +1,14 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -119,6 +124,81 @@ func TestGetVocabulary(t *testing.T) {
 	}
 }
 
+// TestVocabularyContext tests storing and returning sentence context
+func TestVocabularyContext(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "gato", Language: "es", Context: "El gato duerme."})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if retrieved.Context != "El gato duerme." {
+		t.Errorf("Expected context to round-trip, got %q", retrieved.Context)
+	}
+
+	// Rows from before the column existed have NULL context and must still load
+	if _, err := db.conn.Exec(`INSERT INTO vocabulary (text, language, context) VALUES ('perro', 'es', NULL)`); err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+
+	all, err := db.List()
+	if err != nil {
+		t.Fatalf("Failed to list vocabulary with NULL context: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(all))
+	}
+}
+
+// TestVocabularyExample tests storing, updating and exporting example
+// sentences
+func TestVocabularyExample(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "gato", Language: "es", Example: "Mi gato es negro."})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if retrieved.Example != "Mi gato es negro." {
+		t.Errorf("Expected example to round-trip, got %q", retrieved.Example)
+	}
+
+	retrieved.Example = "El gato duerme."
+	if err := db.Update(retrieved); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	updated, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if updated.Example != "El gato duerme." {
+		t.Errorf("Expected updated example, got %q", updated.Example)
+	}
+	if got := updated.FieldValue("example"); got != "El gato duerme." {
+		t.Errorf("Expected example export field, got %v", got)
+	}
+
+	// Rows from before the column existed have NULL example and must still load
+	if _, err := db.conn.Exec(`INSERT INTO vocabulary (text, language, example) VALUES ('perro', 'es', NULL)`); err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+	if _, err := db.List(); err != nil {
+		t.Fatalf("Failed to list vocabulary with NULL example: %v", err)
+	}
+}
+
 // TestGetNonexistent tests retrieving a non-existent item
 func TestGetNonexistent(t *testing.T) {
 	db := setupTestDB(t)
@@ -159,6 +239,45 @@ func TestListVocabulary(t *testing.T) {
 	}
 }
 
+// TestListSortedByUpdatedAt tests ordering by the last change
+func TestListSortedByUpdatedAt(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clock := &fixedClock{t: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	db.SetClock(clock)
+
+	db.Insert(&Vocabulary{Text: "viejo", Language: "es"})
+	clock.t = clock.t.Add(time.Hour)
+	db.Insert(&Vocabulary{Text: "nuevo", Language: "es"})
+
+	// Touching the older row moves it to the front by updated_at only
+	clock.t = clock.t.Add(time.Hour)
+	if err := db.IncrementOccurrences("viejo", ""); err != nil {
+		t.Fatalf("Failed to increment: %v", err)
+	}
+
+	byCreated, err := db.ListSorted("created_at")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if byCreated[0].Text != "nuevo" {
+		t.Errorf("Expected 'nuevo' first by created_at, got %s", byCreated[0].Text)
+	}
+
+	byUpdated, err := db.ListSorted("updated_at")
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if byUpdated[0].Text != "viejo" {
+		t.Errorf("Expected 'viejo' first by updated_at, got %s", byUpdated[0].Text)
+	}
+
+	if _, err := db.ListSorted("text; DROP TABLE vocabulary"); err == nil {
+		t.Error("Expected error for unsupported sort field")
+	}
+}
+
 // TestDeleteVocabulary tests deleting a vocabulary item
 func TestDeleteVocabulary(t *testing.T) {
 	db := setupTestDB(t)
@@ -187,189 +306,2138 @@ func TestDeleteVocabulary(t *testing.T) {
 	}
 }
 
-// TestExistsText tests checking if text already exists
-func TestExistsText(t *testing.T) {
+// TestDeleteMany tests that existing IDs are deleted together while missing
+// and repeated IDs are not counted
+func TestDeleteMany(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	vocab := &Vocabulary{
-		Text:     "exists_test",
-		Language: "en",
+	hola, _ := db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"})
+	adios, _ := db.Insert(&Vocabulary{Text: "adiós", Language: "Spanish"})
+	gracias, _ := db.Insert(&Vocabulary{Text: "gracias", Language: "Spanish"})
+	if _, err := db.TagIDs([]int{hola}, "greetings"); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
 	}
 
-	// Should not exist initially
-	exists, err := db.ExistsText(vocab.Text)
+	deleted, err := db.DeleteMany([]int{hola, 9999, adios, hola})
 	if err != nil {
-		t.Fatalf("Failed to check existence: %v", err)
+		t.Fatalf("Failed to delete: %v", err)
 	}
-	if exists {
-		t.Error("Text should not exist before insert")
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted, got %d", deleted)
 	}
 
-	// Insert it
-	_, err = db.Insert(vocab)
-	if err != nil {
-		t.Fatalf("Failed to insert: %v", err)
+	for _, id := range []int{hola, adios} {
+		if _, err := db.Get(id); err == nil {
+			t.Errorf("Expected item %d to be deleted", id)
+		}
 	}
-
-	// Should exist now
-	exists, err = db.ExistsText(vocab.Text)
-	if err != nil {
-		t.Fatalf("Failed to check existence: %v", err)
+	if _, err := db.Get(gracias); err != nil {
+		t.Errorf("Expected gracias to be kept: %v", err)
 	}
-	if !exists {
-		t.Error("Text should exist after insert")
+	if items, _ := db.ListByTag("greetings"); len(items) != 0 {
+		t.Errorf("Expected deleted items to be hidden from tag listing, got %d items", len(items))
+	}
+
+	if deleted, err := db.DeleteMany(nil); err != nil || deleted != 0 {
+		t.Errorf("Expected nothing deleted for no IDs, got %d, %v", deleted, err)
 	}
 }
 
-// TestSQLInjection tests that parameterized queries prevent SQL injection
-func TestSQLInjection(t *testing.T) {
+// TestSoftDeleteRestore tests that deleted items are hidden from reads but
+// keep their tags and come back on restore
+func TestSoftDeleteRestore(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	// Attempt SQL injection in text field
-	maliciousText := "'; DROP TABLE vocabulary; --"
-	vocab := &Vocabulary{
-		Text:     maliciousText,
-		Language: "en",
+	clock := &fixedClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	db.SetClock(clock)
+
+	id, _ := db.Insert(&Vocabulary{Text: "perro", Language: "Spanish"})
+	db.Insert(&Vocabulary{Text: "gato", Language: "Spanish"})
+	db.AddTag(id, "animals")
+
+	if err := db.Delete(id); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := db.Delete(id); err == nil {
+		t.Error("Expected error deleting an already deleted item")
 	}
 
-	id, err := db.Insert(vocab)
-	if err != nil {
-		t.Fatalf("Failed to insert: %v", err)
+	if _, err := db.Get(id); err == nil {
+		t.Error("Expected deleted item to be hidden from Get")
+	}
+	if _, err := db.GetByText("perro"); err == nil {
+		t.Error("Expected deleted item to be hidden from GetByText")
+	}
+	if items, _ := db.List(); len(items) != 1 || items[0].Text != "gato" {
+		t.Errorf("Expected only gato to be listed, got %v", items)
+	}
+	if count, _ := db.Count(); count != 1 {
+		t.Errorf("Expected count 1, got %d", count)
+	}
+	if items, _ := db.Search("perro"); len(items) != 0 {
+		t.Errorf("Expected deleted item to be hidden from search, got %d items", len(items))
+	}
+	if exists, _ := db.ExistsText("perro"); exists {
+		t.Error("Expected deleted item not to count as stored")
+	}
+	if err := db.IncrementFrequency("perro", "Spanish"); err == nil {
+		t.Error("Expected deleted item not to be counted again")
 	}
 
-	// Verify the malicious text was stored as-is (not executed)
-	retrieved, err := db.Get(id)
+	items, err := db.ListFilteredPaginated(Filter{IncludeDeleted: true}, "created_at", -1, 0)
 	if err != nil {
-		t.Fatalf("Failed to retrieve: %v", err)
+		t.Fatalf("Failed to list with deleted items: %v", err)
 	}
-
-	if retrieved.Text != maliciousText {
-		t.Errorf("Text was modified, possible injection: got '%s'", retrieved.Text)
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items with deleted ones included, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.Text == "perro" && (item.DeletedAt == nil || !item.DeletedAt.Equal(clock.t)) {
+			t.Errorf("Expected perro deleted at %v, got %v", clock.t, item.DeletedAt)
+		}
+		if item.Text == "gato" && item.DeletedAt != nil {
+			t.Errorf("Expected gato not to be deleted, got %v", item.DeletedAt)
+		}
 	}
 
-	// Verify table still exists by listing
-	_, err = db.List()
+	if err := db.Restore(id); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	restored, err := db.Get(id)
 	if err != nil {
-		t.Error("Table was dropped, SQL injection vulnerability exists!")
+		t.Fatalf("Expected restored item to be found: %v", err)
+	}
+	if restored.DeletedAt != nil || len(restored.Tags) != 1 || restored.Tags[0] != "animals" {
+		t.Errorf("Expected restored item with its tag, got %+v", restored)
+	}
+
+	if err := db.Restore(id); err == nil {
+		t.Error("Expected error restoring an item that is not deleted")
+	}
+	if err := db.Restore(9999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows restoring a missing item, got %v", err)
 	}
 }
 
-// TestExportToJSON tests exporting database to JSON
-func TestExportToJSON(t *testing.T) {
+// TestInsertRevivesDeleted tests that storing a soft-deleted word again
+// brings its row back with the new fields instead of skipping it
+func TestInsertRevivesDeleted(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	// Insert test data
-	items := []string{"export1", "export2", "export3"}
-	for _, text := range items {
-		vocab := &Vocabulary{
-			Text:     text,
-			Language: "en",
-		}
-		_, err := db.Insert(vocab)
-		if err != nil {
-			t.Fatalf("Failed to insert: %v", err)
-		}
+	clock := &fixedClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	db.SetClock(clock)
+
+	tests := []struct {
+		name   string
+		insert func(vocab *Vocabulary) (int, error)
+	}{
+		{"Insert", db.Insert},
+		{"InsertMany", func(vocab *Vocabulary) (int, error) {
+			_, _, err := db.InsertMany([]*Vocabulary{vocab})
+			return vocab.ID, err
+		}},
+		{"Upsert", func(vocab *Vocabulary) (int, error) {
+			id, _, err := db.Upsert(vocab)
+			return id, err
+		}},
 	}
 
-	// Export to JSON
-	tmpDir := t.TempDir()
-	exportPath := filepath.Join(tmpDir, "export.json")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := db.Insert(&Vocabulary{Text: "Perro", Language: "Spanish", Translation: "hound"})
+			if err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+			db.AddTag(id, "animals")
+			if err := db.Delete(id); err != nil {
+				t.Fatalf("Failed to delete: %v", err)
+			}
 
-	err := db.ExportToJSON(exportPath)
-	if err != nil {
-		t.Fatalf("Failed to export: %v", err)
+			clock.t = clock.t.Add(time.Hour)
+			revivedID, err := tc.insert(&Vocabulary{Text: "perro", Language: "Spanish", Translation: "dog"})
+			if err != nil {
+				t.Fatalf("Expected the deleted word to be stored again, got %v", err)
+			}
+			if revivedID != id {
+				t.Errorf("Expected the deleted row %d to be revived, got %d", id, revivedID)
+			}
+
+			vocab, err := db.Get(id)
+			if err != nil {
+				t.Fatalf("Expected the revived item to be found: %v", err)
+			}
+			if vocab.Text != "perro" || vocab.Translation != "dog" || len(vocab.Tags) != 0 || !vocab.CreatedAt.Equal(clock.t) {
+				t.Errorf("Expected a fresh perro/dog without tags created at %v, got %+v", clock.t, vocab)
+			}
+
+			if _, err := db.Insert(&Vocabulary{Text: "PERRO", Language: "Spanish"}); !errors.Is(err, ErrDuplicateText) {
+				t.Errorf("Expected the revived item to block duplicates, got %v", err)
+			}
+			if _, err := db.DeleteAll(); err != nil {
+				t.Fatalf("Failed to clear vocabulary: %v", err)
+			}
+		})
 	}
+}
 
-	// Verify file was created
-	if _, err := os.Stat(exportPath); os.IsNotExist(err) {
-		t.Error("Export file was not created")
+// TestExtractionCache tests storing and replacing cached extractions
+func TestExtractionCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	key := ExtractionKey{TextHash: "abc", Language: "auto-detect"}
+	cached, err := db.GetCachedExtraction(key)
+	if err != nil || cached != nil {
+		t.Fatalf("Expected a miss, got %v (%v)", cached, err)
 	}
 
-	// Read and verify content (basic check)
-	content, err := os.ReadFile(exportPath)
-	if err != nil {
-		t.Fatalf("Failed to read export file: %v", err)
+	if err := db.CacheExtraction(key, CachedExtraction{Language: "Spanish", Items: `[{"Text":"hola"}]`}); err != nil {
+		t.Fatalf("Failed to cache extraction: %v", err)
+	}
+	if err := db.CacheExtraction(key, CachedExtraction{Language: "Spanish", Items: `[{"Text":"adiós"}]`}); err != nil {
+		t.Fatalf("Failed to replace cached extraction: %v", err)
 	}
 
-	if len(content) == 0 {
-		t.Error("Export file is empty")
+	cached, err = db.GetCachedExtraction(key)
+	if err != nil || cached == nil {
+		t.Fatalf("Expected a hit, got %v (%v)", cached, err)
+	}
+	if cached.Language != "Spanish" || cached.Items != `[{"Text":"adiós"}]` {
+		t.Errorf("Expected the replaced extraction, got %+v", cached)
+	}
+
+	for _, other := range []ExtractionKey{
+		{TextHash: "abc", Language: "French"},
+		{TextHash: "abc", Language: "auto-detect", WithContext: true},
+		{TextHash: "abc", Language: "auto-detect", Limits: "min=3 max=4"},
+		{TextHash: "def", Language: "auto-detect"},
+	} {
+		if cached, _ := db.GetCachedExtraction(other); cached != nil {
+			t.Errorf("Expected a miss for %+v, got %+v", other, cached)
+		}
 	}
 }
 
-// TestConcurrentInserts tests concurrent inserts for race conditions
-func TestConcurrentInserts(t *testing.T) {
+// TestPurgeDeleted tests permanently removing items deleted before a cutoff
+func TestPurgeDeleted(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	done := make(chan error, 10)
-	numGoroutines := 10
+	clock := &fixedClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	db.SetClock(clock)
 
-	for i := 0; i < numGoroutines; i++ {
-		go func(n int) {
-			vocab := &Vocabulary{
-				Text:     fmt.Sprintf("concurrent_%d", n),
-				Language: "en",
-			}
-			_, err := db.Insert(vocab)
-			done <- err
-		}(i)
+	old, _ := db.Insert(&Vocabulary{Text: "viejo", Language: "Spanish"})
+	recent, _ := db.Insert(&Vocabulary{Text: "nuevo", Language: "Spanish"})
+	kept, _ := db.Insert(&Vocabulary{Text: "casa", Language: "Spanish"})
+	db.AddTag(old, "lesson")
+
+	db.Delete(old)
+	clock.t = clock.t.AddDate(0, 0, 40)
+	db.Delete(recent)
+
+	purged, err := db.PurgeDeleted(clock.t.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged item, got %d", purged)
+	}
+	if err := db.Restore(old); err == nil {
+		t.Error("Expected purged item to be gone")
+	}
+	if tags, _ := db.Tags(old); len(tags) != 0 {
+		t.Errorf("Expected tags of purged item to be removed, got %v", tags)
 	}
 
-	// Wait for all goroutines and check errors
-	var errors []error
-	for i := 0; i < numGoroutines; i++ {
-		if err := <-done; err != nil {
-			errors = append(errors, err)
-		}
+	// A purged word can be added again
+	if _, err := db.Insert(&Vocabulary{Text: "viejo", Language: "Spanish"}); err != nil {
+		t.Errorf("Expected purged word to be insertable: %v", err)
 	}
 
-	if len(errors) > 0 {
-		t.Errorf("Concurrent inserts failed with %d errors: %v", len(errors), errors[0])
+	if purged, _ := db.PurgeDeleted(time.Time{}); purged != 1 {
+		t.Errorf("Expected the zero time to purge the remaining deleted item, got %d", purged)
 	}
+	if _, err := db.Get(kept); err != nil {
+		t.Errorf("Expected undeleted item to be kept: %v", err)
+	}
+}
 
-	// Verify all were inserted
-	all, err := db.List()
+// TestDeleteAll tests that clearing the vocabulary removes tags and history
+// and restarts IDs at 1
+func TestDeleteAll(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hola, _ := db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"})
+	db.Insert(&Vocabulary{Text: "adiós", Language: "Spanish"})
+	if _, err := db.TagIDs([]int{hola}, "greetings"); err != nil {
+		t.Fatalf("Failed to tag: %v", err)
+	}
+	if err := db.SetTranslation(hola, "hello"); err != nil {
+		t.Fatalf("Failed to translate: %v", err)
+	}
+
+	deleted, err := db.DeleteAll()
 	if err != nil {
-		t.Fatalf("Failed to list: %v", err)
+		t.Fatalf("Failed to delete all: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted, got %d", deleted)
+	}
+	if count, _ := db.Count(); count != 0 {
+		t.Errorf("Expected empty vocabulary, got %d items", count)
+	}
+	if tags, _ := db.Tags(hola); len(tags) != 0 {
+		t.Errorf("Expected tags to be removed, got %v", tags)
 	}
 
-	if len(all) != numGoroutines {
-		t.Errorf("Expected %d items, got %d", numGoroutines, len(all))
+	id, err := db.Insert(&Vocabulary{Text: "gracias", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if id != 1 {
+		t.Errorf("Expected IDs to restart at 1, got %d", id)
+	}
+	if history, _ := db.History(id); len(history) != 0 {
+		t.Errorf("Expected no history for the new item, got %d entries", len(history))
+	}
+
+	if deleted, err := db.DeleteAll(); err != nil || deleted != 1 {
+		t.Errorf("Expected 1 deleted, got %d, %v", deleted, err)
 	}
 }
 
-// TestCreatedAtTimestamp tests that created_at is set correctly
-func TestCreatedAtTimestamp(t *testing.T) {
+// TestSearchByLanguage tests that the language filter ignores case and
+// surrounding whitespace and pages like the unfiltered list
+func TestSearchByLanguage(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	before := time.Now().UTC()
-	vocab := &Vocabulary{
-		Text:     "timestamp_test",
-		Language: "en",
+	db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"})
+	db.Insert(&Vocabulary{Text: "gracias", Language: "spanish "})
+	db.Insert(&Vocabulary{Text: "bonjour", Language: "French"})
+
+	items, err := db.SearchByLanguage(" SPANISH")
+	if err != nil {
+		t.Fatalf("Failed to search by language: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected 2 Spanish items, got %d", len(items))
 	}
 
-	id, err := db.Insert(vocab)
+	page, err := db.SearchByLanguagePaginated("spanish", "created_at", 1, 1)
 	if err != nil {
-		t.Fatalf("Failed to insert: %v", err)
+		t.Fatalf("Failed to page by language: %v", err)
+	}
+	if len(page) != 1 || page[0].Text != items[1].Text {
+		t.Errorf("Expected second page to hold %q, got %v", items[1].Text, page)
 	}
 
-	time.Sleep(10 * time.Millisecond) // Small delay to ensure timestamp difference
-	after := time.Now().UTC()
+	compact, err := db.ListCompactByLanguagePaginated("french", "updated_at", -1, 0)
+	if err != nil || len(compact) != 1 || compact[0].Text != "bonjour" {
+		t.Errorf("Expected bonjour in the compact French list, got %v, %v", compact, err)
+	}
 
-	retrieved, err := db.Get(id)
+	if count, err := db.CountLanguage("Spanish"); err != nil || count != 2 {
+		t.Errorf("Expected 2 Spanish items counted, got %d, %v", count, err)
+	}
+	if items, err := db.SearchByLanguage("German"); err != nil || len(items) != 0 {
+		t.Errorf("Expected no German items, got %d, %v", len(items), err)
+	}
+	if _, err := db.SearchByLanguagePaginated("Spanish", "text", -1, 0); err == nil {
+		t.Error("Expected error for unsupported sort field")
+	}
+
+	// Case is folded beyond ASCII, which SQLite's LOWER leaves alone
+	db.Insert(&Vocabulary{Text: "merhaba", Language: "TÜRKÇE"})
+	if count, err := db.CountLanguage("türkçe"); err != nil || count != 1 {
+		t.Errorf("Expected 1 Türkçe item counted, got %d, %v", count, err)
+	}
+
+	var plan, detail string
+	var id, parent, unused int
+	rows, err := db.conn.Query(`EXPLAIN QUERY PLAN SELECT id FROM vocabulary WHERE `+languageMatch, "spanish")
 	if err != nil {
-		t.Fatalf("Failed to retrieve: %v", err)
+		t.Fatalf("Failed to explain query: %v", err)
+	}
+	for rows.Next() {
+		rows.Scan(&id, &parent, &unused, &detail)
+		plan += detail + "\n"
 	}
+	rows.Close()
+	if !strings.Contains(plan, "idx_language_key") {
+		t.Errorf("Expected the language filter to use idx_language_key, got plan:\n%s", plan)
+	}
+}
 
-	// CreatedAt should be between before and after (with 1 second tolerance for SQLite)
-	createdUTC := retrieved.CreatedAt.UTC()
-	if createdUTC.Before(before.Add(-1*time.Second)) || createdUTC.After(after.Add(1*time.Second)) {
-		t.Errorf("CreatedAt timestamp %v is outside expected range [%v, %v]",
-			createdUTC, before, after)
+// TestInsertPreservesFirstCasing tests that a case-only duplicate is
+// rejected and the first-seen casing is kept
+func TestInsertPreservesFirstCasing(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Insert(&Vocabulary{Text: "Madrid", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	_, err := db.Insert(&Vocabulary{Text: "madrid", Language: "Spanish"})
+	if !errors.Is(err, ErrDuplicateText) {
+		t.Fatalf("Expected ErrDuplicateText for a case-only duplicate, got %v", err)
+	}
+
+	items, err := db.List()
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if len(items) != 1 || items[0].Text != "Madrid" {
+		t.Fatalf("Expected a single row with the original casing, got %+v", items)
+	}
+
+	exists, err := db.ExistsNormalized("MADRID", "Spanish")
+	if err != nil || !exists {
+		t.Errorf("Expected ExistsNormalized to match another casing, got %v, %v", exists, err)
+	}
+	if exists, _ := db.ExistsText("madrid"); !exists {
+		t.Error("Expected ExistsText to ignore case")
+	}
+
+	if err := db.IncrementOccurrences("madrid", ""); err != nil {
+		t.Fatalf("IncrementOccurrences failed: %v", err)
+	}
+	item, _ := db.GetByText("Madrid")
+	if item.Occurrences != 2 {
+		t.Errorf("Expected the occurrence to count toward Madrid, got %d", item.Occurrences)
+	}
+}
+
+// TestNormalizedUniqueness tests that texts differing only by case or
+// surrounding whitespace collapse while accented spellings stay distinct
+func TestNormalizedUniqueness(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tests := []struct {
+		text    string
+		wantDup bool
+	}{
+		{"Hola", false},
+		{"hola", true},
+		{"hola ", true},
+		{" HOLA", true},
+		{"Café", false},
+		{"cafe", false},
+		{"CAFÉ", true},
+	}
+
+	for _, tc := range tests {
+		_, err := db.Insert(&Vocabulary{Text: tc.text, Language: "Spanish"})
+		if got := errors.Is(err, ErrDuplicateText); got != tc.wantDup {
+			t.Errorf("Insert(%q): expected duplicate %v, got %v", tc.text, tc.wantDup, err)
+		}
+		if exists, err := db.ExistsText(tc.text); err != nil || !exists {
+			t.Errorf("ExistsText(%q): expected true, got %v, %v", tc.text, exists, err)
+		}
+	}
+
+	if count, _ := db.Count(); count != 3 {
+		t.Errorf("Expected Hola, Café and cafe to be stored, got %d rows", count)
+	}
+	if item, err := db.GetByText("Hola"); err != nil || item.Text != "Hola" {
+		t.Errorf("Expected the original text to be kept for display, got %v, %v", item, err)
+	}
+
+	// The unique index also guards edits
+	cafe, _ := db.GetByText("cafe")
+	cafe.Text = "HOLA"
+	if err := db.Update(cafe); !errors.Is(err, ErrDuplicateText) {
+		t.Errorf("Expected ErrDuplicateText when editing into a duplicate, got %v", err)
+	}
+}
+
+// TestRebuildDerivedKeepsLegacyDuplicates tests that rows stored before
+// normalization that differ only by case survive a rebuild
+func TestRebuildDerivedKeepsLegacyDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.conn.Exec(`INSERT INTO vocabulary (text, language, normalized) VALUES (?, ?, NULL), (?, ?, NULL)`,
+		"Hola", "es", "hola", "es")
+	if err != nil {
+		t.Fatalf("Failed to insert legacy rows: %v", err)
+	}
+
+	if err := db.RebuildDerived(); err != nil {
+		t.Fatalf("Failed to rebuild derived columns: %v", err)
+	}
+	// Rebuilding twice must not collide with the values of the first run
+	if err := db.RebuildDerived(); err != nil {
+		t.Fatalf("Failed to rebuild derived columns again: %v", err)
+	}
+
+	if count, _ := db.Count(); count != 2 {
+		t.Errorf("Expected both legacy rows to be kept, got %d", count)
+	}
+	var normalized sql.NullString
+	db.conn.QueryRow(`SELECT normalized FROM vocabulary WHERE text = 'Hola'`).Scan(&normalized)
+	if normalized.String != "hola" {
+		t.Errorf("Expected the first row to get the normalized value, got %v", normalized)
+	}
+	if _, err := db.Insert(&Vocabulary{Text: "HOLA", Language: "es"}); !errors.Is(err, ErrDuplicateText) {
+		t.Errorf("Expected new copies to be rejected after a rebuild, got %v", err)
+	}
+}
+
+// TestNormalizedIndexFallback tests that a database already holding
+// colliding normalized values still opens
+func TestNormalizedIndexFallback(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	database.conn.Exec(`DROP INDEX idx_normalized_unique`)
+	_, err = database.conn.Exec(`INSERT INTO vocabulary (text, language, normalized) VALUES ('Hola', 'es', 'hola'), ('hola', 'es', 'hola')`)
+	if err != nil {
+		t.Fatalf("Failed to insert colliding rows: %v", err)
+	}
+	database.Close()
+
+	database, err = NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Expected a database with colliding rows to open, got %v", err)
+	}
+	defer database.Close()
+
+	if _, err := database.Insert(&Vocabulary{Text: "HOLA", Language: "es"}); !errors.Is(err, ErrDuplicateText) {
+		t.Errorf("Expected Insert to keep rejecting duplicates, got %v", err)
+	}
+}
+
+// TestBusyTimeout tests that the busy timeout reaches every pooled
+// connection of a file-backed database
+func TestBusyTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    int
+	}{
+		{"Default", 0, 5000},
+		{"Custom", 250 * time.Millisecond, 250},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			database, err := NewDatabaseWithOptions(filepath.Join(t.TempDir(), "test.db"), Options{BusyTimeout: tc.timeout})
+			if err != nil {
+				t.Fatalf("Failed to create database: %v", err)
+			}
+			defer database.Close()
+
+			// Holding two connections open makes the pool create a second one
+			first, err := database.conn.Conn(context.Background())
+			if err != nil {
+				t.Fatalf("Failed to get connection: %v", err)
+			}
+			defer first.Close()
+			second, err := database.conn.Conn(context.Background())
+			if err != nil {
+				t.Fatalf("Failed to get connection: %v", err)
+			}
+			defer second.Close()
+
+			for i, conn := range []*sql.Conn{first, second} {
+				var got int
+				if err := conn.QueryRowContext(context.Background(), `PRAGMA busy_timeout`).Scan(&got); err != nil {
+					t.Fatalf("Failed to read busy timeout: %v", err)
+				}
+				if got != tc.want {
+					t.Errorf("Connection %d: expected busy timeout %d, got %d", i+1, tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckpoint tests that a checkpoint empties the write-ahead log
+func TestCheckpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer database.Close()
+
+	for _, text := range []string{"hola", "adiós", "gracias"} {
+		if _, err := database.Insert(&Vocabulary{Text: text, Language: "es"}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+	if info, err := os.Stat(dbPath + "-wal"); err != nil || info.Size() == 0 {
+		t.Fatalf("Expected writes in the WAL file, got %v", err)
+	}
+
+	if err := database.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	info, err := os.Stat(dbPath + "-wal")
+	if err != nil {
+		t.Fatalf("Failed to stat WAL file: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected an empty WAL file after checkpoint, got %d bytes", info.Size())
+	}
+
+	count, err := database.Count()
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 items after checkpoint, got %d", count)
+	}
+
+	memory, err := NewDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer memory.Close()
+	if err := memory.Checkpoint(); err != nil {
+		t.Errorf("Expected checkpoint of an in-memory database to succeed, got %v", err)
+	}
+}
+
+// TestMigrateLegacyDatabase tests that a database from before any column
+// was added, with no schema_version table, upgrades without losing rows and
+// that reopening it applies nothing twice
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	// One column added by hand before versioning existed must not be added again
+	_, err = legacy.Exec(`
+		CREATE TABLE vocabulary (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			text TEXT UNIQUE NOT NULL,
+			language TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		ALTER TABLE vocabulary ADD COLUMN translation TEXT DEFAULT '';
+		INSERT INTO vocabulary (text, language, translation) VALUES ('hola', 'Spanish', 'hello'), ('perro', 'Spanish', '');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	legacy.Close()
+
+	for i := range 2 {
+		database, err := NewDatabase(dbPath)
+		if err != nil {
+			t.Fatalf("Open %d: failed to open legacy database: %v", i+1, err)
+		}
+
+		version, err := database.SchemaVersion()
+		if err != nil {
+			t.Fatalf("SchemaVersion failed: %v", err)
+		}
+		if version != len(migrations) {
+			t.Errorf("Open %d: expected schema version %d, got %d", i+1, len(migrations), version)
+		}
+
+		var rows int
+		database.conn.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&rows)
+		if rows != 1 {
+			t.Errorf("Open %d: expected one schema_version row, got %d", i+1, rows)
+		}
+
+		vocab, err := database.GetByText("hola")
+		if err != nil {
+			t.Fatalf("Open %d: legacy row lost: %v", i+1, err)
+		}
+		if vocab.Translation != "hello" || vocab.Occurrences != 1 {
+			t.Errorf("Open %d: unexpected legacy row %+v", i+1, vocab)
+		}
+		if count, _ := database.Count(); count != 2 {
+			t.Errorf("Open %d: expected 2 rows, got %d", i+1, count)
+		}
+		if count, _ := database.CountLanguage("spanish"); count != 2 {
+			t.Errorf("Open %d: expected legacy rows to match the language filter, got %d", i+1, count)
+		}
+
+		if i == 0 {
+			// New writes use the migrated columns
+			if _, err := database.Insert(&Vocabulary{Text: "gato", Language: "Spanish", Context: "el gato"}); err != nil {
+				t.Fatalf("Insert after migration failed: %v", err)
+			}
+			database.conn.Exec(`DELETE FROM vocabulary WHERE text = 'gato'`)
+		}
+		database.Close()
+	}
+}
+
+// TestMigrateSchemaTooNew tests that a database written by a newer version
+// is refused rather than modified
+func TestMigrateSchemaTooNew(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	database, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	database.conn.Exec(`UPDATE schema_version SET version = ?`, len(migrations)+1)
+	database.Close()
+
+	if _, err := NewDatabase(dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+// TestExistsText tests checking if text already exists
+func TestExistsText(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	vocab := &Vocabulary{
+		Text:     "exists_test",
+		Language: "en",
+	}
+
+	// Should not exist initially
+	exists, err := db.ExistsText(vocab.Text)
+	if err != nil {
+		t.Fatalf("Failed to check existence: %v", err)
+	}
+	if exists {
+		t.Error("Text should not exist before insert")
+	}
+
+	// Insert it
+	_, err = db.Insert(vocab)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// Should exist now
+	exists, err = db.ExistsText(vocab.Text)
+	if err != nil {
+		t.Fatalf("Failed to check existence: %v", err)
+	}
+	if !exists {
+		t.Error("Text should exist after insert")
+	}
+}
+
+// TestIncrementOccurrences tests bumping the occurrence counter
+func TestIncrementOccurrences(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "repeat", Language: "en"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := db.IncrementOccurrences("repeat", ""); err != nil {
+			t.Fatalf("Failed to increment occurrences: %v", err)
+		}
+	}
+
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if retrieved.Occurrences != 3 {
+		t.Errorf("Expected 3 occurrences, got %d", retrieved.Occurrences)
+	}
+
+	if err := db.IncrementOccurrences("missing", ""); err == nil {
+		t.Error("Expected error when incrementing a missing word")
+	}
+
+	// Turkish casing finds "Irmak" from "IRMAK", which the default rules
+	// would lowercase to "irmak"
+	if _, err := db.Insert(&Vocabulary{Text: "Irmak", Language: "Turkish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.IncrementOccurrences("IRMAK", "Turkish"); err != nil {
+		t.Errorf("Expected Turkish casing to match the stored word: %v", err)
+	}
+}
+
+// TestIncrementFrequency tests counting repeat documents and listing the
+// most frequent words first
+func TestIncrementFrequency(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Insert(&Vocabulary{Text: "rare", Language: "en"})
+	common, _ := db.Insert(&Vocabulary{Text: "Common", Language: "en"})
+	db.Insert(&Vocabulary{Text: "newest", Language: "en"})
+
+	// A different case counts toward the stored word
+	for _, text := range []string{"Common", "common"} {
+		if err := db.IncrementFrequency(text, "en"); err != nil {
+			t.Fatalf("Failed to increment frequency: %v", err)
+		}
+	}
+
+	retrieved, err := db.Get(common)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if retrieved.Frequency != 3 || retrieved.Occurrences != 1 {
+		t.Errorf("Expected frequency 3 and occurrences 1, got %d and %d", retrieved.Frequency, retrieved.Occurrences)
+	}
+
+	items, err := db.ListFilteredPaginated(Filter{}, "frequency", -1, 0)
+	if err != nil {
+		t.Fatalf("Failed to list by frequency: %v", err)
+	}
+	var order []string
+	for _, item := range items {
+		order = append(order, item.Text)
+	}
+	if got := strings.Join(order, ","); got != "Common,newest,rare" {
+		t.Errorf("Expected most frequent first, then newest, got %s", got)
+	}
+
+	if err := db.IncrementFrequency("missing", "en"); err == nil {
+		t.Error("Expected error when incrementing a missing word")
+	}
+}
+
+// TestUpsert tests inserting new items and filling in the translation of
+// existing ones
+func TestUpsert(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, inserted, err := db.Upsert(&Vocabulary{Text: "Hola", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to upsert new item: %v", err)
+	}
+	if !inserted || id == 0 {
+		t.Fatalf("Expected a new item, got ID %d inserted %v", id, inserted)
+	}
+
+	// A case-only difference updates the stored item
+	for i := 0; i < 2; i++ {
+		gotID, inserted, err := db.Upsert(&Vocabulary{Text: "hola", Language: "Spanish", Translation: "hello"})
+		if err != nil {
+			t.Fatalf("Failed to upsert existing item: %v", err)
+		}
+		if inserted || gotID != id {
+			t.Errorf("Expected existing ID %d, got %d inserted %v", id, gotID, inserted)
+		}
+	}
+
+	// An empty translation leaves the stored one alone
+	if _, _, err := db.Upsert(&Vocabulary{Text: "Hola", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to upsert without translation: %v", err)
+	}
+
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if retrieved.Text != "Hola" || retrieved.Translation != "hello" {
+		t.Errorf("Expected Hola translated as hello, got %q as %q", retrieved.Text, retrieved.Translation)
+	}
+	if count, _ := db.Count(); count != 1 {
+		t.Errorf("Expected 1 item, got %d", count)
+	}
+
+	// Upserting the same translation again records no further history
+	history, err := db.History(id)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 1 || history[0].NewValue != "hello" {
+		t.Errorf("Expected one translation change, got %+v", history)
+	}
+}
+
+// TestUpdateSetsUpdatedAt tests that updates advance updated_at while
+// created_at stays fixed
+func TestUpdateSetsUpdatedAt(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "hola", Language: "es"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	inserted, _ := db.Get(id)
+	if !inserted.UpdatedAt.Equal(inserted.CreatedAt) {
+		t.Errorf("Expected updated_at to equal created_at on insert, got %v and %v", inserted.UpdatedAt, inserted.CreatedAt)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	inserted.Context = "Hola, amigo."
+	if err := db.Update(inserted); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	updated, _ := db.Get(id)
+	if !updated.CreatedAt.Equal(inserted.CreatedAt) {
+		t.Errorf("created_at changed from %v to %v", inserted.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(inserted.UpdatedAt) {
+		t.Errorf("Expected updated_at to advance past %v, got %v", inserted.UpdatedAt, updated.UpdatedAt)
+	}
+	if updated.Context != "Hola, amigo." {
+		t.Errorf("Expected updated context, got %q", updated.Context)
+	}
+
+	if err := db.Update(&Vocabulary{ID: 9999, Text: "nada", Language: "es"}); err == nil {
+		t.Error("Expected error updating non-existent vocabulary")
+	}
+}
+
+// TestGetRandom tests picking random items, with and without a language
+// filter
+func TestGetRandom(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	items, err := db.GetRandom(5)
+	if err != nil {
+		t.Fatalf("Failed to get random items: %v", err)
+	}
+	if items == nil || len(items) != 0 {
+		t.Errorf("Expected an empty slice, got %v", items)
+	}
+
+	db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"})
+	db.Insert(&Vocabulary{Text: "gracias", Language: "Spanish"})
+	db.Insert(&Vocabulary{Text: "danke", Language: "German"})
+
+	if items, _ := db.GetRandom(2); len(items) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(items))
+	}
+	if items, _ := db.GetRandom(10); len(items) != 3 {
+		t.Errorf("Expected all 3 items, got %d", len(items))
+	}
+
+	items, err = db.GetRandomFiltered(Filter{Language: "spanish"}, 10)
+	if err != nil {
+		t.Fatalf("Failed to get random items by language: %v", err)
+	}
+	for _, item := range items {
+		if item.Language != "Spanish" {
+			t.Errorf("Expected only Spanish items, got %q", item.Language)
+		}
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected 2 Spanish items, got %d", len(items))
+	}
+}
+
+// TestSQLInjection tests that parameterized queries prevent SQL injection
+func TestSQLInjection(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Attempt SQL injection in text field
+	maliciousText := "'; DROP TABLE vocabulary; --"
+	vocab := &Vocabulary{
+		Text:     maliciousText,
+		Language: "en",
+	}
+
+	id, err := db.Insert(vocab)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// Verify the malicious text was stored as-is (not executed)
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to retrieve: %v", err)
+	}
+
+	if retrieved.Text != maliciousText {
+		t.Errorf("Text was modified, possible injection: got '%s'", retrieved.Text)
+	}
+
+	// Verify table still exists by listing
+	_, err = db.List()
+	if err != nil {
+		t.Error("Table was dropped, SQL injection vulnerability exists!")
+	}
+}
+
+// TestExportToJSON tests exporting database to JSON
+func TestExportToJSON(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Insert test data
+	items := []string{"export1", "export2", "export3"}
+	for _, text := range items {
+		vocab := &Vocabulary{
+			Text:     text,
+			Language: "en",
+		}
+		_, err := db.Insert(vocab)
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Export to JSON
+	tmpDir := t.TempDir()
+	exportPath := filepath.Join(tmpDir, "export.json")
+
+	err := db.ExportToJSON(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	// Verify file was created
+	if _, err := os.Stat(exportPath); os.IsNotExist(err) {
+		t.Error("Export file was not created")
+	}
+
+	// Read and verify content (basic check)
+	content, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+
+	if len(content) == 0 {
+		t.Error("Export file is empty")
+	}
+}
+
+// TestConcurrentInserts tests concurrent inserts for race conditions
+func TestConcurrentInserts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	done := make(chan error, 10)
+	numGoroutines := 10
+
+	for i := 0; i < numGoroutines; i++ {
+		go func(n int) {
+			vocab := &Vocabulary{
+				Text:     fmt.Sprintf("concurrent_%d", n),
+				Language: "en",
+			}
+			_, err := db.Insert(vocab)
+			done <- err
+		}(i)
+	}
+
+	// Wait for all goroutines and check errors
+	var errors []error
+	for i := 0; i < numGoroutines; i++ {
+		if err := <-done; err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) > 0 {
+		t.Errorf("Concurrent inserts failed with %d errors: %v", len(errors), errors[0])
+	}
+
+	// Verify all were inserted
+	all, err := db.List()
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+
+	if len(all) != numGoroutines {
+		t.Errorf("Expected %d items, got %d", numGoroutines, len(all))
+	}
+}
+
+// TestInsertMany tests batch inserts, skipping texts that are already stored
+// or repeated within the batch
+func TestInsertMany(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Insert(&Vocabulary{Text: "hola", Language: "Spanish"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	items := []*Vocabulary{
+		{Text: "Hola", Language: "Spanish"},
+		{Text: "gracias", Language: "Spanish", Translation: "thank you", Occurrences: 2},
+		{Text: "adiós", Language: "Spanish"},
+		{Text: "Gracias", Language: "Spanish"},
+	}
+	inserted, repeated, err := db.InsertMany(items)
+	if err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("Expected 2 inserted, got %d", inserted)
+	}
+
+	wantInserted := []bool{false, true, true, false}
+	wantRepeated := []bool{false, false, false, true}
+	for i, item := range items {
+		if (item.ID != 0) != wantInserted[i] {
+			t.Errorf("Item %q: expected inserted %v, got ID %d", item.Text, wantInserted[i], item.ID)
+		}
+		if repeated[i] != wantRepeated[i] {
+			t.Errorf("Item %q: expected repeated %v, got %v", item.Text, wantRepeated[i], repeated[i])
+		}
+	}
+
+	stored, err := db.Get(items[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to get inserted item: %v", err)
+	}
+	if stored.Text != "gracias" || stored.Translation != "thank you" || stored.Occurrences != 2 {
+		t.Errorf("Unexpected stored item: %+v", stored)
+	}
+
+	count, err := db.Count()
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 items, got %d", count)
+	}
+}
+
+// TestInsertManyCountsFrequency tests that skipped items raise the
+// frequency of the stored item, matched with the casing rules of their
+// language
+func TestInsertManyCountsFrequency(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "Irmak", Language: "Turkish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// Turkish lowercases "I" to "ı", which the default rules would turn
+	// into "i" and miss the stored word
+	if _, _, err := db.InsertMany([]*Vocabulary{{Text: "IRMAK", Language: "Turkish"}, {Text: "deniz", Language: "Turkish"}}); err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+
+	stored, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to get vocabulary: %v", err)
+	}
+	if stored.Frequency != 2 {
+		t.Errorf("Expected frequency 2, got %d", stored.Frequency)
+	}
+}
+
+// TestInsertManyCaseVariantsInBatch tests that case variants in one batch
+// count as one document: a new word stays at frequency 1 and a stored word
+// goes up by one
+func TestInsertManyCaseVariantsInBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	id, err := db.Insert(&Vocabulary{Text: "perro", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	items := []*Vocabulary{
+		{Text: "Casa", Language: "Spanish"},
+		{Text: "casa", Language: "Spanish"},
+		{Text: "perro", Language: "Spanish"},
+		{Text: "Perro", Language: "Spanish"},
+	}
+	inserted, repeated, err := db.InsertMany(items)
+	if err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+	if inserted != 1 || !slices.Equal(repeated, []bool{false, true, false, true}) {
+		t.Errorf("Expected 1 inserted and the repeats flagged, got %d and %v", inserted, repeated)
+	}
+
+	for _, tc := range []struct {
+		id   int
+		want int
+	}{{items[0].ID, 1}, {id, 2}} {
+		stored, err := db.Get(tc.id)
+		if err != nil {
+			t.Fatalf("Failed to get vocabulary: %v", err)
+		}
+		if stored.Frequency != tc.want {
+			t.Errorf("%s: expected frequency %d, got %d", stored.Text, tc.want, stored.Frequency)
+		}
+	}
+}
+
+// TestConcurrentInsertMany tests that overlapping batches from several
+// goroutines store every text exactly once
+func TestConcurrentInsertMany(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "concurrent.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	const numGoroutines, batchSize = 10, 20
+	done := make(chan int, numGoroutines)
+	errs := make(chan error, numGoroutines)
+
+	for i := 0; i < numGoroutines; i++ {
+		go func(n int) {
+			// Neighbouring batches overlap by half
+			items := make([]*Vocabulary, batchSize)
+			for j := range items {
+				items[j] = &Vocabulary{Text: fmt.Sprintf("batch_%d", n*batchSize/2+j), Language: "en"}
+			}
+			inserted, _, err := db.InsertMany(items)
+			if err != nil {
+				errs <- err
+				return
+			}
+			done <- inserted
+		}(i)
+	}
+
+	total := 0
+	for i := 0; i < numGoroutines; i++ {
+		select {
+		case inserted := <-done:
+			total += inserted
+		case err := <-errs:
+			t.Errorf("Concurrent batch failed: %v", err)
+		}
+	}
+
+	want := (numGoroutines + 1) * batchSize / 2
+	if total != want {
+		t.Errorf("Expected %d inserted in total, got %d", want, total)
+	}
+	count, err := db.Count()
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != want {
+		t.Errorf("Expected %d items, got %d", want, count)
+	}
+}
+
+// benchmarkWords returns n distinct vocabulary items
+func benchmarkWords(n, round int) []*Vocabulary {
+	items := make([]*Vocabulary, n)
+	for i := range items {
+		items[i] = &Vocabulary{Text: fmt.Sprintf("word_%d_%d", round, i), Language: "en"}
+	}
+	return items
+}
+
+// BenchmarkInsert200 inserts 200 words one transaction at a time, the way
+// documents were stored before InsertMany
+func BenchmarkInsert200(b *testing.B) {
+	db, err := NewDatabase(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < b.N; i++ {
+		for _, item := range benchmarkWords(200, i) {
+			if _, err := db.Insert(item); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkInsertMany200 inserts 200 words in a single InsertMany batch
+func BenchmarkInsertMany200(b *testing.B) {
+	db, err := NewDatabase(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := db.InsertMany(benchmarkWords(200, i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestCreatedAtTimestamp tests that created_at is set correctly
+func TestCreatedAtTimestamp(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	before := time.Now().UTC()
+	vocab := &Vocabulary{
+		Text:     "timestamp_test",
+		Language: "en",
+	}
+
+	id, err := db.Insert(vocab)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond) // Small delay to ensure timestamp difference
+	after := time.Now().UTC()
+
+	retrieved, err := db.Get(id)
+	if err != nil {
+		t.Fatalf("Failed to retrieve: %v", err)
+	}
+
+	// CreatedAt should be between before and after (with 1 second tolerance for SQLite)
+	createdUTC := retrieved.CreatedAt.UTC()
+	if createdUTC.Before(before.Add(-1*time.Second)) || createdUTC.After(after.Add(1*time.Second)) {
+		t.Errorf("CreatedAt timestamp %v is outside expected range [%v, %v]",
+			createdUTC, before, after)
+	}
+}
+
+// TestRebuildDerived tests backfilling derived columns on pre-migration rows
+func TestRebuildDerived(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Simulate rows written before the derived columns existed
+	_, err := db.conn.Exec(`INSERT INTO vocabulary (text, language, normalized, source) VALUES (?, ?, NULL, NULL), (?, ?, NULL, NULL)`,
+		"  Hola ", "es", "GRACIAS", "es")
+	if err != nil {
+		t.Fatalf("Failed to insert legacy rows: %v", err)
+	}
+
+	if err := db.RebuildDerived(); err != nil {
+		t.Fatalf("Failed to rebuild derived columns: %v", err)
+	}
+
+	expected := map[string]string{
+		"  Hola ": "hola",
+		"GRACIAS": "gracias",
+	}
+
+	for text, want := range expected {
+		var normalized, source sql.NullString
+		err := db.conn.QueryRow(`SELECT normalized, source FROM vocabulary WHERE text = ?`, text).Scan(&normalized, &source)
+		if err != nil {
+			t.Fatalf("Failed to read rebuilt row %q: %v", text, err)
+		}
+		if !normalized.Valid || normalized.String != want {
+			t.Errorf("Expected normalized %q for %q, got %v", want, text, normalized)
+		}
+		if !source.Valid || source.String != "" {
+			t.Errorf("Expected empty source for %q, got %v", text, source)
+		}
+	}
+}
+
+// TestAddMissingColumnsIdempotent tests that reopening a database does not
+// re-add columns
+func TestAddMissingColumnsIdempotent(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := NewDatabase(dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open database (attempt %d): %v", i+1, err)
+		}
+		db.Close()
+	}
+}
+
+// fixedClock is a Clock that returns a settable time
+type fixedClock struct {
+	t time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.t
+}
+
+// TestInjectedClockTimestamps tests that created_at comes from the database
+// clock, so time-dependent behavior is deterministic
+func TestInjectedClockTimestamps(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clock := &fixedClock{t: time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)}
+	db.SetClock(clock)
+
+	firstID, err := db.Insert(&Vocabulary{Text: "primero", Language: "es"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// A clock in another zone is stored as UTC
+	clock.t = time.Date(2024, 3, 16, 1, 0, 0, 250*int(time.Millisecond), time.FixedZone("CET", 3600))
+	secondID, err := db.Insert(&Vocabulary{Text: "segundo", Language: "es"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	first, _ := db.Get(firstID)
+	if !first.CreatedAt.Equal(time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected exact created_at 2024-03-15 09:30:00, got %v", first.CreatedAt)
+	}
+
+	second, _ := db.Get(secondID)
+	if !second.CreatedAt.Equal(time.Date(2024, 3, 16, 0, 0, 0, 250*int(time.Millisecond), time.UTC)) {
+		t.Errorf("Expected exact created_at 2024-03-16 00:00:00.250 UTC, got %v", second.CreatedAt)
+	}
+
+	// Ordering by created_at follows the injected times
+	all, err := db.List()
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if len(all) != 2 || all[0].Text != "segundo" || all[1].Text != "primero" {
+		t.Errorf("Expected newest first ordering, got %v", all)
+	}
+
+	db.SetClock(nil)
+	if _, ok := db.clock.(systemClock); !ok {
+		t.Error("SetClock(nil) should restore the system clock")
+	}
+}
+
+// TestBackupTo tests that a backup can be reopened with the same rows
+func TestBackupTo(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, text := range []string{"uno", "dos", "tres"} {
+		if _, err := db.Insert(&Vocabulary{Text: text, Language: "es"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.BackupTo(backupPath); err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+
+	backup, err := NewDatabase(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer backup.Close()
+
+	count, err := backup.Count()
+	if err != nil {
+		t.Fatalf("Failed to count backup rows: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 rows in backup, got %d", count)
+	}
+
+	if err := db.BackupTo(backupPath); err == nil {
+		t.Error("Expected error backing up over an existing database")
+	}
+}
+
+// TestConsumeQuota tests the daily upload quota and its rollover
+func TestConsumeQuota(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	clock := &fixedClock{time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)}
+	database.SetClock(clock)
+
+	var recorded QuotaUsage
+	for i := 1; i <= 2; i++ {
+		usage, allowed, err := database.ConsumeQuota("203.0.113.7", 2)
+		if err != nil {
+			t.Fatalf("ConsumeQuota failed: %v", err)
+		}
+		if !allowed || usage.Used != i {
+			t.Errorf("Upload %d: expected allowed with %d used, got %v with %d", i, i, allowed, usage.Used)
+		}
+		recorded = usage
+	}
+
+	usage, allowed, err := database.ConsumeQuota("203.0.113.7", 2)
+	if err != nil {
+		t.Fatalf("ConsumeQuota failed: %v", err)
+	}
+	if allowed || usage.Used != 2 {
+		t.Errorf("Expected quota exceeded with 2 used, got %v with %d", allowed, usage.Used)
+	}
+	if want := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC); !usage.ResetAt.Equal(want) {
+		t.Errorf("Expected reset at %v, got %v", want, usage.ResetAt)
+	}
+	if usage.RetryAfter != 30*time.Minute {
+		t.Errorf("Expected retry after 30m, got %v", usage.RetryAfter)
+	}
+
+	// Releasing a failed upload makes room for another one
+	if err := database.ReleaseQuota("203.0.113.7", recorded); err != nil {
+		t.Fatalf("ReleaseQuota failed: %v", err)
+	}
+	if usage, allowed, _ := database.ConsumeQuota("203.0.113.7", 2); !allowed || usage.Used != 2 {
+		t.Errorf("Expected a released upload to be available again, got %v with %d used", allowed, usage.Used)
+	}
+
+	if _, allowed, _ := database.ConsumeQuota("198.51.100.1", 2); !allowed {
+		t.Error("Quota should be tracked per client")
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	if usage, allowed, _ := database.ConsumeQuota("203.0.113.7", 2); !allowed || usage.Used != 1 {
+		t.Errorf("Expected quota to reset on the next day, got %v with %d used", allowed, usage.Used)
+	}
+}
+
+// TestListCompact tests the id/text projection
+func TestListCompact(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	clock := &fixedClock{time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	database.SetClock(clock)
+	for _, text := range []string{"hola", "adiós"} {
+		if _, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		clock.t = clock.t.Add(time.Minute)
+	}
+
+	items, err := database.ListCompact()
+	if err != nil {
+		t.Fatalf("ListCompact failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 compact items, got %d", len(items))
+	}
+	if items[0].Text != "adiós" || items[1].Text != "hola" || items[0].ID == 0 {
+		t.Errorf("Unexpected compact list: %+v, %+v", items[0], items[1])
+	}
+
+	if _, err := database.ListCompactSorted("text"); err == nil {
+		t.Error("Expected error for unsupported sort field")
+	}
+}
+
+// TestUntranslated tests counting and listing rows without a translation
+func TestUntranslated(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	seed := []*Vocabulary{
+		{Text: "hola", Language: "Spanish", Translation: "hello"},
+		{Text: "adiós", Language: "Spanish"},
+		{Text: "gracias", Language: "Spanish", Translation: "thanks"},
+		{Text: "perro", Language: "Spanish"},
+		{Text: "gato", Language: "Spanish"},
+	}
+	for _, item := range seed {
+		if _, err := database.Insert(item); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	count, err := database.CountUntranslated()
+	if err != nil {
+		t.Fatalf("CountUntranslated failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 untranslated, got %d", count)
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{10, 0, []string{"adiós", "perro", "gato"}},
+		{2, 0, []string{"adiós", "perro"}},
+		{2, 2, []string{"gato"}},
+		{10, 3, nil},
+	}
+	for _, tc := range tests {
+		items, err := database.ListUntranslated(tc.limit, tc.offset)
+		if err != nil {
+			t.Fatalf("ListUntranslated failed: %v", err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Text)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("ListUntranslated(%d, %d) = %v, want %v", tc.limit, tc.offset, got, tc.want)
+		}
+	}
+}
+
+// TestPruneBackups tests that only the newest backups are kept
+func TestPruneBackups(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	clock := &fixedClock{time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)}
+	database.SetClock(clock)
+
+	var paths []string
+	for i := 0; i < 5; i++ {
+		path, err := database.BackupToDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		paths = append(paths, path)
+		clock.t = clock.t.Add(time.Hour)
+	}
+
+	// Unrelated files in the directory must survive pruning, including
+	// upload temp files when the directory is shared with TMPDIR
+	notes := filepath.Join(dir, "notes.txt")
+	upload := filepath.Join(dir, "parsely-123-vocab.db")
+	for _, path := range []string{notes, upload} {
+		if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	removed, err := PruneBackups(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to prune backups: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 backups removed, got %d", removed)
+	}
+
+	for i, path := range paths {
+		_, err := os.Stat(path)
+		if kept := i >= 3; kept != (err == nil) {
+			t.Errorf("Backup %d: expected kept=%v, stat error %v", i, kept, err)
+		}
+	}
+	for _, path := range []string{notes, upload} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Unrelated file should not be removed: %v", err)
+		}
+	}
+
+	if removed, err := PruneBackups(dir, 2); err != nil || removed != 0 {
+		t.Errorf("Expected nothing to prune, got %d, %v", removed, err)
+	}
+}
+
+// TestSearchFolded tests that folded search ignores diacritics while
+// storage stays accent-sensitive
+func TestSearchFolded(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	for _, text := range []string{"si", "sí", "no", "100%_sí"} {
+		if _, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", text, err)
+		}
+	}
+
+	items, err := database.SearchFolded("si")
+	if err != nil {
+		t.Fatalf("SearchFolded failed: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, item := range items {
+		found[item.Text] = true
+	}
+	if !found["si"] || !found["sí"] || found["no"] {
+		t.Errorf("Expected si and sí to match, got %v", found)
+	}
+
+	items, err = database.SearchFolded("%_S")
+	if err != nil {
+		t.Fatalf("SearchFolded failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Text != "100%_sí" {
+		t.Errorf("Expected wildcards to match literally, got %d items", len(items))
+	}
+
+	if exists, _ := database.ExistsText("sí"); !exists {
+		t.Error("Accented text should still be stored separately")
+	}
+}
+
+// TestTagVocabulary tests tagging by IDs and by search query
+func TestTagVocabulary(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	ids := make(map[string]int)
+	for _, text := range []string{"manzana", "pan", "perro", "panadería"} {
+		id, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"})
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		ids[text] = id
+	}
+
+	tagged, err := database.TagIDs([]int{ids["manzana"], ids["pan"], 9999}, "Food")
+	if err != nil {
+		t.Fatalf("TagIDs failed: %v", err)
+	}
+	if tagged != 2 {
+		t.Errorf("Expected 2 items tagged by ID, got %d", tagged)
+	}
+
+	// "pan" is already tagged, so only "panadería" is new
+	tagged, err = database.TagMatching("PAN", "food")
+	if err != nil {
+		t.Fatalf("TagMatching failed: %v", err)
+	}
+	if tagged != 1 {
+		t.Errorf("Expected 1 item newly tagged by query, got %d", tagged)
+	}
+
+	items, err := database.ListByTag("food")
+	if err != nil {
+		t.Fatalf("ListByTag failed: %v", err)
+	}
+	var texts []string
+	for _, item := range items {
+		texts = append(texts, item.Text)
+	}
+	slices.Sort(texts)
+	if !slices.Equal(texts, []string{"manzana", "pan", "panadería"}) {
+		t.Errorf("Unexpected tagged items: %v", texts)
+	}
+
+	if tags, _ := database.Tags(ids["perro"]); len(tags) != 0 {
+		t.Errorf("Expected perro to stay untagged, got %v", tags)
+	}
+
+	if _, err := database.TagIDs([]int{ids["perro"]}, "  "); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+
+	// A soft-deleted item keeps its tags for a restore; purging removes them
+	if err := database.Delete(ids["pan"]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if tags, _ := database.Tags(ids["pan"]); len(tags) != 1 {
+		t.Errorf("Expected tags to be kept with the deleted item, got %v", tags)
+	}
+	if _, err := database.PurgeDeleted(time.Time{}); err != nil {
+		t.Fatalf("PurgeDeleted failed: %v", err)
+	}
+	if tags, _ := database.Tags(ids["pan"]); len(tags) != 0 {
+		t.Errorf("Expected tags to be removed with the purged item, got %v", tags)
+	}
+}
+
+// TestAddRemoveTag tests tagging single items and reading tags back on Get
+func TestAddRemoveTag(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	id, err := database.Insert(&Vocabulary{Text: "manzana", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	for _, tag := range []string{"Lesson 1", "food", "food"} {
+		if err := database.AddTag(id, tag); err != nil {
+			t.Fatalf("AddTag(%q) failed: %v", tag, err)
+		}
+	}
+
+	vocab, err := database.Get(id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !slices.Equal(vocab.Tags, []string{"food", "lesson 1"}) {
+		t.Errorf("Expected tags [food lesson 1], got %v", vocab.Tags)
+	}
+
+	if err := database.RemoveTag(id, " FOOD "); err != nil {
+		t.Fatalf("RemoveTag failed: %v", err)
+	}
+	if err := database.RemoveTag(id, "unused"); err != nil {
+		t.Errorf("Expected removing a missing tag to succeed, got %v", err)
+	}
+	vocab, _ = database.Get(id)
+	if !slices.Equal(vocab.Tags, []string{"lesson 1"}) {
+		t.Errorf("Expected tags [lesson 1], got %v", vocab.Tags)
+	}
+
+	if err := database.AddTag(id, "a,b"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected ErrInvalidTag for a comma, got %v", err)
+	}
+	if err := database.AddTag(9999, "food"); err == nil {
+		t.Error("Expected error tagging a missing item")
+	}
+
+	other, _ := database.Insert(&Vocabulary{Text: "perro", Language: "Spanish"})
+	vocab, _ = database.Get(other)
+	if vocab.Tags == nil || len(vocab.Tags) != 0 {
+		t.Errorf("Expected an empty tag list, got %#v", vocab.Tags)
+	}
+}
+
+// TestParseTags tests parsing comma separated tag lists
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"food", []string{"food"}},
+		{"Lesson 3, food,FOOD, ,verbs", []string{"lesson 3", "food", "verbs"}},
+	}
+
+	for _, tc := range tests {
+		if got := ParseTags(tc.input); !slices.Equal(got, tc.want) {
+			t.Errorf("ParseTags(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+// TestListByDateRange tests inclusive and open-ended created_at ranges
+func TestListByDateRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	clock := &fixedClock{}
+	db.SetClock(clock)
+	for i, text := range []string{"uno", "dos", "tres"} {
+		clock.t = time.Date(2024, 1, 10*i+1, 12, 0, 0, 0, time.UTC)
+		if _, err := db.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     string
+	}{
+		{"closed", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 1, 11, 12, 0, 0, 0, time.UTC), "dos,uno"},
+		{"only from", time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), time.Time{}, "tres,dos"},
+		{"only to", time.Time{}, time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), "uno"},
+		{"open", time.Time{}, time.Time{}, "tres,dos,uno"},
+		{"non-UTC bound", time.Date(2024, 1, 21, 13, 0, 0, 0, time.FixedZone("CET", 3600)), time.Time{}, "tres"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := db.ListByDateRange(tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("Failed to list: %v", err)
+			}
+			var got []string
+			for _, item := range items {
+				got = append(got, item.Text)
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Errorf("Expected %s, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestListFiltered tests combining the language and tag filters
+func TestListFiltered(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	ids := make(map[string]int)
+	for _, item := range []struct{ text, language string }{
+		{"manzana", "Spanish"}, {"pan", "Spanish"}, {"perro", "Spanish"}, {"pomme", "French"},
+	} {
+		id, err := database.Insert(&Vocabulary{Text: item.text, Language: item.language})
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		ids[item.text] = id
+	}
+	if _, err := database.TagIDs([]int{ids["manzana"], ids["pan"], ids["pomme"]}, "food"); err != nil {
+		t.Fatalf("TagIDs failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"No filter", Filter{}, 4},
+		{"Language", Filter{Language: "spanish"}, 3},
+		{"Tag", Filter{Tag: " Food "}, 3},
+		{"Language and tag", Filter{Language: "Spanish", Tag: "food"}, 2},
+		{"Unknown tag", Filter{Tag: "verbs"}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := database.ListFilteredPaginated(tc.filter, "created_at", -1, 0)
+			if err != nil {
+				t.Fatalf("ListFilteredPaginated failed: %v", err)
+			}
+			compact, err := database.ListCompactFilteredPaginated(tc.filter, "updated_at", -1, 0)
+			if err != nil {
+				t.Fatalf("ListCompactFilteredPaginated failed: %v", err)
+			}
+			count, err := database.CountFiltered(tc.filter)
+			if err != nil {
+				t.Fatalf("CountFiltered failed: %v", err)
+			}
+			if len(items) != tc.want || len(compact) != tc.want || count != tc.want {
+				t.Errorf("Expected %d items, got %d, %d compact and count %d", tc.want, len(items), len(compact), count)
+			}
+		})
+	}
+}
+
+// TestVocabularyHistory tests that updates and enrichment record each changed
+// field and that history is retained after the item is deleted
+func TestVocabularyHistory(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	changedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	database.SetClock(&fixedClock{changedAt})
+
+	id, err := database.Insert(&Vocabulary{Text: "casa", Language: "Spanish"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if err := database.Update(&Vocabulary{ID: id, Text: "casa", Language: "Spanish", Context: "Mi casa es roja."}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := database.SetTranslation(id, "house"); err != nil {
+		t.Fatalf("SetTranslation failed: %v", err)
+	}
+	// Writing the same value again is not a change
+	if err := database.SetTranslation(id, "house"); err != nil {
+		t.Fatalf("SetTranslation failed: %v", err)
+	}
+
+	history, err := database.History(id)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+
+	want := []HistoryEntry{
+		{Field: "context", OldValue: "", NewValue: "Mi casa es roja."},
+		{Field: "translation", OldValue: "", NewValue: "house"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("Expected %d history entries, got %d: %+v", len(want), len(history), history)
+	}
+	for i, entry := range history {
+		if entry.VocabID != id || entry.Field != want[i].Field || entry.OldValue != want[i].OldValue || entry.NewValue != want[i].NewValue {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want[i], entry)
+		}
+		if !entry.ChangedAt.Equal(changedAt) {
+			t.Errorf("Entry %d: expected changed_at %v, got %v", i, changedAt, entry.ChangedAt)
+		}
+	}
+
+	if err := database.Delete(id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	history, err = database.History(id)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != len(want) {
+		t.Errorf("Expected history to be retained after delete, got %d entries", len(history))
+	}
+
+	if err := database.Update(&Vocabulary{ID: id, Text: "casa", Language: "Spanish"}); err == nil {
+		t.Error("Expected error updating a deleted item")
+	}
+}
+
+// TestListPaginated tests that pages are windows over the newest-first list
+func TestListPaginated(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	clock := &fixedClock{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	database.SetClock(clock)
+	for _, text := range []string{"uno", "dos", "tres", "cuatro", "cinco"} {
+		clock.t = clock.t.Add(time.Minute)
+		if _, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{2, 0, []string{"cinco", "cuatro"}},
+		{2, 2, []string{"tres", "dos"}},
+		{2, 4, []string{"uno"}},
+		{2, 5, nil},
+	}
+
+	for _, tc := range tests {
+		items, err := database.ListPaginated(tc.limit, tc.offset)
+		if err != nil {
+			t.Fatalf("ListPaginated(%d, %d) failed: %v", tc.limit, tc.offset, err)
+		}
+		var texts []string
+		for _, item := range items {
+			texts = append(texts, item.Text)
+		}
+		if !slices.Equal(texts, tc.want) {
+			t.Errorf("ListPaginated(%d, %d) = %v, want %v", tc.limit, tc.offset, texts, tc.want)
+		}
+	}
+}
+
+// TestSearch tests case-insensitive substring search with literal wildcards
+func TestSearch(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	for _, text := range []string{"Ñandú", "ñoño", "100%", "100 puntos", "a_b", "axb", "sí"} {
+		if _, err := database.Insert(&Vocabulary{Text: text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert %s: %v", text, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ÑAN", []string{"Ñandú"}},
+		{"ñ", []string{"ñoño", "Ñandú"}},
+		{"%", []string{"100%"}},
+		{"_", []string{"a_b"}},
+		{"si", nil}, // diacritics still count; SearchFolded ignores them
+		{"  ", nil},
+	}
+
+	for _, tc := range tests {
+		items, err := database.Search(tc.query)
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", tc.query, err)
+		}
+		var texts []string
+		for _, item := range items {
+			texts = append(texts, item.Text)
+		}
+		slices.Sort(texts)
+		want := slices.Clone(tc.want)
+		slices.Sort(want)
+		if !slices.Equal(texts, want) {
+			t.Errorf("Search(%q) = %v, want %v", tc.query, texts, want)
+		}
+	}
+}
+
+// TestCountByDay tests that items are counted per UTC day over the last 30
+// days, with empty days reported as zero
+func TestCountByDay(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	now := time.Date(2025, 6, 30, 23, 30, 0, 0, time.UTC)
+	clock := &fixedClock{}
+	database.SetClock(clock)
+	for _, v := range []struct {
+		text string
+		at   time.Time
+	}{
+		{"hola", now},
+		// Already July 1 in UTC+2, but still June 30 in UTC
+		{"adiós", time.Date(2025, 7, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))},
+		{"gracias", now.AddDate(0, 0, -1)},
+		{"amigo", now.AddDate(0, 0, -29)},
+		{"viejo", now.AddDate(0, 0, -30)},
+		{"borrado", now},
+	} {
+		clock.t = v.at
+		if _, err := database.Insert(&Vocabulary{Text: v.text, Language: "Spanish"}); err != nil {
+			t.Fatalf("Failed to insert %q: %v", v.text, err)
+		}
+	}
+	deleted, _ := database.GetByText("borrado")
+	if err := database.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	clock.t = now
+	counts, err := database.CountByDay()
+	if err != nil {
+		t.Fatalf("CountByDay failed: %v", err)
+	}
+	if len(counts) != CountByDayWindow {
+		t.Errorf("Expected %d days, got %d: %v", CountByDayWindow, len(counts), counts)
+	}
+
+	want := map[string]int{
+		"2025-06-30": 2,
+		"2025-06-29": 1,
+		"2025-06-15": 0,
+		"2025-06-01": 1,
+	}
+	for day, count := range want {
+		if got, ok := counts[day]; !ok || got != count {
+			t.Errorf("Expected %d items on %s, got %d (present: %v)", count, day, got, ok)
+		}
+	}
+	if _, ok := counts["2025-05-31"]; ok {
+		t.Errorf("Expected days before the window to be left out, got %v", counts)
+	}
+}
+
+// TestCountByLanguage tests per-language counts and newest timestamps
+func TestCountByLanguage(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+
+	clock := &fixedClock{time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)}
+	database.SetClock(clock)
+	for _, v := range []*Vocabulary{
+		{Text: "hola", Language: "Spanish"},
+		{Text: "bonjour", Language: "French"},
+		{Text: "adiós", Language: "Spanish"},
+	} {
+		clock.t = clock.t.Add(time.Minute)
+		if _, err := database.Insert(v); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	counts, err := database.CountByLanguage()
+	if err != nil {
+		t.Fatalf("CountByLanguage failed: %v", err)
+	}
+	if len(counts) != 2 || counts["Spanish"] != 2 || counts["French"] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	latest, err := database.LatestByLanguage()
+	if err != nil {
+		t.Fatalf("LatestByLanguage failed: %v", err)
+	}
+	if !latest["Spanish"].Equal(clock.t) || !latest["French"].Equal(clock.t.Add(-time.Minute)) {
+		t.Errorf("Unexpected latest timestamps: %v", latest)
+	}
+
+	// A language loses its entry once its last item is gone
+	french, _ := database.GetByText("bonjour")
+	if err := database.Delete(french.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	counts, _ = database.CountByLanguage()
+	if _, ok := counts["French"]; ok {
+		t.Errorf("Expected French to disappear, got %v", counts)
 	}
 }
 
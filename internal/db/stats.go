@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// CountByLanguage returns the number of vocabulary items per language.
+// Languages without items do not appear.
+func (db *Database) CountByLanguage() (map[string]int, error) {
+	rows, err := db.conn.Query(`SELECT language, COUNT(*) FROM vocabulary WHERE ` + notDeleted + ` GROUP BY language`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count vocabulary by language: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var language string
+		var count int
+		if err := rows.Scan(&language, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan language count: %w", err)
+		}
+		counts[language] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// LatestByLanguage returns the created_at of the newest vocabulary item per
+// language. Languages without items do not appear.
+func (db *Database) LatestByLanguage() (map[string]time.Time, error) {
+	rows, err := db.conn.Query(`SELECT language, MAX(created_at) FROM vocabulary WHERE ` + notDeleted + ` GROUP BY language`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest vocabulary by language: %w", err)
+	}
+	defer rows.Close()
+
+	latest := make(map[string]time.Time)
+	for rows.Next() {
+		var language, value string
+		if err := rows.Scan(&language, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan latest timestamp: %w", err)
+		}
+		t, err := parseStoredTimestamp(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse created_at %q: %w", value, err)
+		}
+		latest[language] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return latest, nil
+}
+
+// CountByDayWindow is the number of days, ending today, that CountByDay
+// reports
+const CountByDayWindow = 30
+
+// CountByDay returns the number of vocabulary items added on each of the
+// last CountByDayWindow days, keyed by YYYY-MM-DD. created_at is stored in
+// UTC and days are grouped in UTC, so a day's count does not shift with the
+// server's time zone. Days without items are included with a count of zero.
+func (db *Database) CountByDay() (map[string]int, error) {
+	today := db.clock.Now().UTC()
+	counts := make(map[string]int, CountByDayWindow)
+	for i := range CountByDayWindow {
+		counts[today.AddDate(0, 0, -i).Format(time.DateOnly)] = 0
+	}
+	from := today.AddDate(0, 0, 1-CountByDayWindow).Format(time.DateOnly)
+	to := today.Format(time.DateOnly)
+
+	// Without a 'localtime' modifier strftime keeps the stored UTC time
+	const day = `strftime('%Y-%m-%d', created_at)`
+	rows, err := db.conn.Query(`SELECT `+day+`, COUNT(*) FROM vocabulary
+		WHERE `+notDeleted+` AND `+day+` BETWEEN ? AND ?
+		GROUP BY 1`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count vocabulary by day: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		var count int
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan day count: %w", err)
+		}
+		counts[date] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
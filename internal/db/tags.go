@@ -0,0 +1,228 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTag is returned for an empty tag or one containing a comma
+var ErrInvalidTag = errors.New("tag must not be empty or contain commas")
+
+// normalizeTag trims and lowercases a tag so "Food" and "food " are the same.
+// Commas are rejected because tags are read and written as comma separated
+// lists.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || strings.Contains(tag, ",") {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// ParseTags parses a comma separated tag list such as "Food, lesson 3" into
+// normalized tags. Empty entries are dropped and repeated tags kept once, in
+// first-seen order.
+func ParseTags(s string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(s, ",") {
+		tag, err := normalizeTag(tag)
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// AddTag applies tag to the vocabulary item with the given ID. Adding a tag
+// the item already carries is not an error.
+func (db *Database) AddTag(id int, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM vocabulary WHERE id = ? AND `+notDeleted+`)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check vocabulary: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("vocabulary with ID %d not found", id)
+	}
+
+	if _, err := db.conn.Exec(`INSERT OR IGNORE INTO vocabulary_tags (vocab_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+		return fmt.Errorf("failed to tag vocabulary: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveTag removes tag from the vocabulary item with the given ID.
+// Removing a tag the item does not carry is not an error.
+func (db *Database) RemoveTag(id int, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.conn.Exec(`DELETE FROM vocabulary_tags WHERE vocab_id = ? AND tag = ?`, id, tag); err != nil {
+		return fmt.Errorf("failed to remove tag: %w", err)
+	}
+
+	return nil
+}
+
+// TagTexts applies every tag to the stored items whose normalized text
+// matches one of texts, in a single transaction. Texts that are not stored
+// are ignored.
+func (db *Database) TagTexts(texts []string, language string, tags []string) error {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) == 0 || len(texts) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO vocabulary_tags (vocab_id, tag) SELECT id, ? FROM vocabulary WHERE normalized = ? AND ` + notDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to prepare tag insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, text := range texts {
+		key := normalizeText(text, language)
+		for _, tag := range normalized {
+			if _, err := stmt.Exec(tag, key); err != nil {
+				return fmt.Errorf("failed to tag %q: %w", text, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	return nil
+}
+
+// TagIDs applies tag to the vocabulary items with the given IDs in a single
+// transaction. IDs that do not exist are ignored. It returns the number of
+// items newly tagged; items that already carry the tag are not counted.
+func (db *Database) TagIDs(ids []int, tag string) (int, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO vocabulary_tags (vocab_id, tag) SELECT id, ? FROM vocabulary WHERE id = ? AND ` + notDeleted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare tag insert: %w", err)
+	}
+	defer stmt.Close()
+
+	tagged := 0
+	for _, id := range ids {
+		result, err := stmt.Exec(tag, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to tag vocabulary %d: %w", id, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		tagged += int(rowsAffected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	return tagged, nil
+}
+
+// TagMatching applies tag to every item SearchFolded would return for query,
+// returning the number of items newly tagged
+func (db *Database) TagMatching(query, tag string) (int, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	folded := foldText(query, "")
+	if folded == "" {
+		return 0, fmt.Errorf("search query must not be empty")
+	}
+
+	pattern := "%" + likeEscaper.Replace(folded) + "%"
+	result, err := db.conn.Exec(`INSERT OR IGNORE INTO vocabulary_tags (vocab_id, tag) SELECT id, ? FROM vocabulary WHERE ascii_fold LIKE ? ESCAPE '\' AND `+notDeleted, tag, pattern)
+	if err != nil {
+		return 0, fmt.Errorf("failed to tag vocabulary: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// Tags returns the tags of a vocabulary item in alphabetical order
+func (db *Database) Tags(id int) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT tag FROM vocabulary_tags WHERE vocab_id = ? ORDER BY tag`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+// ListByTag returns the vocabulary items carrying tag, newest first
+func (db *Database) ListByTag(tag string) ([]*Vocabulary, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + vocabularyColumns + ` FROM vocabulary WHERE id IN (SELECT vocab_id FROM vocabulary_tags WHERE tag = ?) AND ` + notDeleted + ` ORDER BY ` + sortOrders["created_at"]
+
+	items, err := db.queryVocabulary(query, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vocabulary by tag: %w", err)
+	}
+
+	return items, nil
+}
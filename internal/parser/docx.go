@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/nguyenthenguyen/docx"
 )
@@ -19,7 +22,11 @@ func ParseDOCX(filePath string) (string, error) {
 	// Read the DOCX file
 	doc, err := docx.ReadDocxFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open DOCX: %w", err)
+		content, readErr := os.ReadFile(filePath)
+		if readErr != nil {
+			return "", fmt.Errorf("failed to read DOCX: %w", readErr)
+		}
+		return "", docxOpenError(err, content)
 	}
 	defer doc.Close()
 
@@ -34,17 +41,51 @@ func ParseDOCX(filePath string) (string, error) {
 	return strings.TrimSpace(text), nil
 }
 
-// ParseDOCXFromReader extracts text from a DOCX by creating a temp file
-func ParseDOCXFromReader(reader io.Reader, filename string) (string, error) {
-	// Create temporary file
-	tmpPath, err := CreateTempFile(reader, filename)
+// ParseDOCXFromReader extracts text from a DOCX io.Reader (for uploaded files)
+func ParseDOCXFromReader(reader io.Reader, size int64) (string, error) {
+	content, err := readLimited(reader, size)
 	if err != nil {
 		return "", err
 	}
-	defer CleanupTempFile(tmpPath)
 
-	// Parse the temporary file
-	return ParseDOCX(tmpPath)
+	doc, err := docx.ReadDocxFromMemory(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", docxOpenError(err, content)
+	}
+	defer doc.Close()
+
+	text := strings.TrimSpace(doc.Editable().GetContent())
+	if len(text) == 0 {
+		return "", fmt.Errorf("no text content found in DOCX")
+	}
+
+	return text, nil
+}
+
+// oleMagic starts an OLE compound file. Word saves a password-protected
+// DOCX as one holding an EncryptionInfo stream instead of as a ZIP archive.
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// oleEncryptionInfo is the EncryptionInfo stream name as the compound file
+// directory stores it, in UTF-16LE
+var oleEncryptionInfo = utf16LE("EncryptionInfo")
+
+// docxOpenError wraps an error from opening a DOCX, reporting an encrypted
+// file as ErrPasswordProtected
+func docxOpenError(err error, content []byte) error {
+	if bytes.HasPrefix(content, oleMagic) && bytes.Contains(content, oleEncryptionInfo) {
+		return ErrPasswordProtected
+	}
+	return fmt.Errorf("failed to open DOCX: %w", err)
+}
+
+// utf16LE encodes an ASCII string as UTF-16LE
+func utf16LE(s string) []byte {
+	encoded := make([]byte, 0, 2*len(s))
+	for i := 0; i < len(s); i++ {
+		encoded = append(encoded, s[i], 0)
+	}
+	return encoded
 }
 
 // CreateTempFile creates a temporary file from an io.Reader (for web uploads)
@@ -56,7 +97,7 @@ func CreateTempFile(reader io.Reader, filename string) (string, error) {
 
 	// Create temp directory if it doesn't exist
 	tmpDir := os.TempDir()
-	tempFile, err := os.CreateTemp(tmpDir, "parsely-*-"+filename)
+	tempFile, err := os.CreateTemp(tmpDir, tempFilePrefix+"*-"+filename)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -71,7 +112,7 @@ func CreateTempFile(reader io.Reader, filename string) (string, error) {
 
 	if written > MaxFileSize {
 		os.Remove(tempFile.Name())
-		return "", fmt.Errorf("file too large: %d bytes (max: %d bytes)", written, MaxFileSize)
+		return "", &FileTooLargeError{Limit: MaxFileSize}
 	}
 
 	return tempFile.Name(), nil
@@ -84,3 +125,33 @@ func CleanupTempFile(filePath string) error {
 	}
 	return nil
 }
+
+// ReapOrphanedTempFiles removes upload temp files older than olderThan from
+// the temp directory (os.TempDir, which honors TMPDIR). These are left behind
+// when the process dies between CreateTempFile and CleanupTempFile.
+func ReapOrphanedTempFiles(olderThan time.Duration) (removed int, err error) {
+	tmpDir := os.TempDir()
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temp directory: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isTempFileName(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := CleanupTempFile(filepath.Join(tmpDir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
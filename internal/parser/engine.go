@@ -0,0 +1,248 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PDF engine names accepted by ParserConfig.PDFEngine
+const (
+	PDFEngineInternal  = "internal"
+	PDFEnginePdftotext = "pdftotext"
+)
+
+// ErrUnknownPDFEngine is returned when ParserConfig names an engine that
+// does not exist
+var ErrUnknownPDFEngine = errors.New("unknown PDF engine")
+
+// PDFEngine extracts the text of a PDF file. from and to select a 1-indexed,
+// inclusive page range; zero values extract the whole document. Engines that
+// run an external program stop it once ctx is done.
+type PDFEngine interface {
+	Name() string
+	// Available reports whether the engine can run on this machine
+	Available() bool
+	ExtractText(ctx context.Context, filePath string, from, to int) (string, ParseMetadata, error)
+}
+
+// pdfEngines are the engines ParserConfig can select from; tests replace
+// them with fakes
+var pdfEngines = map[string]PDFEngine{
+	PDFEngineInternal:  internalEngine{},
+	PDFEnginePdftotext: pdftotextEngine{binary: "pdftotext"},
+}
+
+// ParserConfig controls how documents are parsed. The zero value uses the
+// internal PDF engine.
+type ParserConfig struct {
+	// PDFEngine is PDFEngineInternal or PDFEnginePdftotext; empty means
+	// internal. An engine that is unavailable, such as pdftotext without the
+	// poppler binary installed, falls back to internal.
+	PDFEngine string
+}
+
+// Engine returns the PDF engine to use, applying the internal fallback
+func (c ParserConfig) Engine() (PDFEngine, error) {
+	name := strings.ToLower(strings.TrimSpace(c.PDFEngine))
+	if name == "" {
+		name = PDFEngineInternal
+	}
+
+	engine, ok := pdfEngines[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPDFEngine, c.PDFEngine)
+	}
+	if !engine.Available() {
+		return pdfEngines[PDFEngineInternal], nil
+	}
+	return engine, nil
+}
+
+// ParseDocument is the package-level ParseDocument with PDFs extracted by
+// the configured engine
+func (c ParserConfig) ParseDocument(ctx context.Context, filePath string) (string, error) {
+	text, _, err := c.ParseDocumentWithMeta(ctx, filePath)
+	return text, err
+}
+
+// ParseDocumentWithMeta is ParseDocument that also reports what was
+// extracted, including the page counts for PDFs
+func (c ParserConfig) ParseDocumentWithMeta(ctx context.Context, filePath string) (string, ParseMetadata, error) {
+	if DetectFileType(filePath) != TypePDF {
+		text, err := ParseDocument(filePath)
+		if err != nil {
+			return "", ParseMetadata{}, err
+		}
+		return text, textMetadata(text), nil
+	}
+	return c.ParsePDFWithMeta(ctx, filePath, 0, 0)
+}
+
+// ParsePDFPages extracts a page range of a PDF file with the configured
+// engine; zero bounds extract the whole document
+func (c ParserConfig) ParsePDFPages(ctx context.Context, filePath string, from, to int) (string, error) {
+	text, _, err := c.ParsePDFWithMeta(ctx, filePath, from, to)
+	return text, err
+}
+
+// ParsePDFWithMeta is ParsePDFPages that also reports how many pages were
+// read and skipped
+func (c ParserConfig) ParsePDFWithMeta(ctx context.Context, filePath string, from, to int) (string, ParseMetadata, error) {
+	engine, err := c.Engine()
+	if err != nil {
+		return "", ParseMetadata{}, err
+	}
+	if err := ValidateFileSize(filePath); err != nil {
+		return "", ParseMetadata{}, err
+	}
+	return engine.ExtractText(ctx, filePath, from, to)
+}
+
+// ParseDocumentFromReader is the package-level ParseDocumentFromReader with
+// PDFs extracted by the configured engine
+func (c ParserConfig) ParseDocumentFromReader(ctx context.Context, reader io.Reader, filename string, size int64) (string, error) {
+	text, _, err := c.ParseDocumentFromReaderWithMeta(ctx, reader, filename, size)
+	return text, err
+}
+
+// ParseDocumentFromReaderWithMeta is ParseDocumentWithMeta for an in-memory
+// document
+func (c ParserConfig) ParseDocumentFromReaderWithMeta(ctx context.Context, reader io.Reader, filename string, size int64) (string, ParseMetadata, error) {
+	if DetectFileType(filename) != TypePDF {
+		text, err := ParseDocumentFromReader(reader, filename, size)
+		if err != nil {
+			return "", ParseMetadata{}, err
+		}
+		return text, textMetadata(text), nil
+	}
+	return c.ParsePDFFromReaderWithMeta(ctx, reader, size, 0, 0)
+}
+
+// ParsePDFPagesFromReader is ParsePDFPages for an in-memory PDF
+func (c ParserConfig) ParsePDFPagesFromReader(ctx context.Context, reader io.Reader, size int64, from, to int) (string, error) {
+	text, _, err := c.ParsePDFFromReaderWithMeta(ctx, reader, size, from, to)
+	return text, err
+}
+
+// ParsePDFFromReaderWithMeta is ParsePDFWithMeta for an in-memory PDF. The
+// internal engine reads it directly; external engines get a temp file.
+func (c ParserConfig) ParsePDFFromReaderWithMeta(ctx context.Context, reader io.Reader, size int64, from, to int) (string, ParseMetadata, error) {
+	engine, err := c.Engine()
+	if err != nil {
+		return "", ParseMetadata{}, err
+	}
+
+	if engine.Name() == PDFEngineInternal {
+		return ParsePDFFromReaderWithMeta(reader, size, from, to)
+	}
+
+	if size > MaxFileSize {
+		return "", ParseMetadata{}, &FileTooLargeError{Size: size, Limit: MaxFileSize}
+	}
+	tmpPath, err := CreateTempFile(reader, "upload.pdf")
+	if err != nil {
+		return "", ParseMetadata{}, err
+	}
+	defer CleanupTempFile(tmpPath)
+
+	return engine.ExtractText(ctx, tmpPath, from, to)
+}
+
+// internalEngine extracts text with the ledongthuc/pdf library
+type internalEngine struct{}
+
+func (internalEngine) Name() string { return PDFEngineInternal }
+
+func (internalEngine) Available() bool { return true }
+
+func (internalEngine) ExtractText(_ context.Context, filePath string, from, to int) (string, ParseMetadata, error) {
+	return ParsePDFWithMeta(filePath, from, to)
+}
+
+// pdftotextTimeout bounds a single pdftotext run, so a PDF that makes it
+// hang cannot hold a request forever
+var pdftotextTimeout = 2 * time.Minute
+
+// pdftotextEngine shells out to poppler's pdftotext, which copes with some
+// PDFs the internal library cannot read
+type pdftotextEngine struct {
+	binary string
+}
+
+func (e pdftotextEngine) Name() string { return PDFEnginePdftotext }
+
+func (e pdftotextEngine) Available() bool {
+	_, err := exec.LookPath(e.binary)
+	return err == nil
+}
+
+func (e pdftotextEngine) ExtractText(ctx context.Context, filePath string, from, to int) (string, ParseMetadata, error) {
+	args := []string{"-q", "-enc", "UTF-8"}
+	if from != 0 || to != 0 {
+		total, err := countPDFPages(filePath)
+		if err != nil {
+			return "", ParseMetadata{}, err
+		}
+		if err := ValidatePageRange(from, to, total); err != nil {
+			return "", ParseMetadata{}, err
+		}
+		args = append(args, "-f", strconv.Itoa(from), "-l", strconv.Itoa(to))
+	}
+	// A trailing "-" writes the text to stdout
+	args = append(args, filepath.Clean(filePath), "-")
+
+	ctx, cancel := context.WithTimeout(ctx, pdftotextTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.binary, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ParseMetadata{}, fmt.Errorf("pdftotext stopped: %w", ctxErr)
+	}
+	if err != nil {
+		// pdftotext -q gives no reason, so check for a password with the
+		// internal library
+		if _, openErr := countPDFPages(filePath); errors.Is(openErr, ErrPasswordProtected) {
+			return "", ParseMetadata{}, openErr
+		}
+		return "", ParseMetadata{}, fmt.Errorf("pdftotext failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	content := strings.TrimSpace(string(output))
+	if len(content) == 0 {
+		pages, err := countPDFPages(filePath)
+		if err != nil {
+			return "", ParseMetadata{}, err
+		}
+		return "", ParseMetadata{}, emptyPDFError(pages)
+	}
+	// pdftotext ends every page with a form feed. It does not report pages
+	// it failed to read, so none are counted as skipped.
+	meta := ParseMetadata{
+		Pages:     strings.Count(string(output), "\f"),
+		CharCount: utf8.RuneCountInString(content),
+	}
+	return content, meta, nil
+}
+
+// countPDFPages returns the number of pages in a PDF file
+func countPDFPages(filePath string) (int, error) {
+	file, reader, err := openPDFFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return reader.NumPage(), nil
+}
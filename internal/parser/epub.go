@@ -0,0 +1,272 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrEncryptedEPUB is returned for an EPUB whose content is DRM-protected or
+// otherwise encrypted, which cannot be read without the key
+var ErrEncryptedEPUB = errors.New("EPUB is DRM-protected or encrypted and cannot be read")
+
+// epubFontObfuscation lists the encryption algorithms EPUBs use to obfuscate
+// embedded fonts. They leave the text readable, so they are not DRM.
+var epubFontObfuscation = map[string]bool{
+	"http://www.idpf.org/2008/embedding": true,
+	"http://ns.adobe.com/pdf/enc#RC":     true,
+}
+
+// epubSkippedElements are XHTML elements whose content is not chapter text
+var epubSkippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "svg": true,
+}
+
+// epubBlockElements are XHTML elements that start a new line of text
+var epubBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "section": true, "article": true, "dt": true, "dd": true,
+}
+
+// ParseEPUB extracts the text of an EPUB e-book, chapter by chapter in
+// reading order
+func ParseEPUB(filePath string) (string, error) {
+	if err := ValidateFileSize(filePath); err != nil {
+		return "", err
+	}
+
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer archive.Close()
+
+	return epubText(&archive.Reader)
+}
+
+// ParseEPUBFromReader extracts text from an EPUB io.Reader (for uploaded
+// files)
+func ParseEPUBFromReader(reader io.Reader, size int64) (string, error) {
+	content, err := readLimited(reader, size)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open EPUB: %w", err)
+	}
+
+	return epubText(archive)
+}
+
+// epubText reads the package document named by META-INF/container.xml and
+// returns the text of the chapters in its spine, one line per block element
+func epubText(archive *zip.Reader) (string, error) {
+	if err := checkEPUBEncryption(archive); err != nil {
+		return "", err
+	}
+
+	packagePath, err := epubPackagePath(archive)
+	if err != nil {
+		return "", err
+	}
+	chapters, err := epubSpine(archive, packagePath)
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, chapter := range chapters {
+		if err := epubChapterText(archive, chapter, &text); err != nil {
+			return "", err
+		}
+		text.WriteByte('\n')
+	}
+
+	result := joinTextLines(text.String())
+	if len(result) == 0 {
+		return "", fmt.Errorf("no text content found in EPUB")
+	}
+
+	return result, nil
+}
+
+// checkEPUBEncryption returns ErrEncryptedEPUB when the archive carries
+// Adobe DRM rights or encrypts anything other than fonts
+func checkEPUBEncryption(archive *zip.Reader) error {
+	if _, err := archive.Open("META-INF/rights.xml"); err == nil {
+		return ErrEncryptedEPUB
+	}
+
+	file, err := archive.Open("META-INF/encryption.xml")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var encryption struct {
+		Data []struct {
+			Method struct {
+				Algorithm string `xml:"Algorithm,attr"`
+			} `xml:"EncryptionMethod"`
+		} `xml:"EncryptedData"`
+	}
+	if err := xml.NewDecoder(file).Decode(&encryption); err != nil {
+		return fmt.Errorf("failed to read EPUB encryption.xml: %w", err)
+	}
+
+	for _, data := range encryption.Data {
+		if !epubFontObfuscation[data.Method.Algorithm] {
+			return ErrEncryptedEPUB
+		}
+	}
+	return nil
+}
+
+// epubPackagePath returns the archive path of the OPF package document
+func epubPackagePath(archive *zip.Reader) (string, error) {
+	file, err := archive.Open("META-INF/container.xml")
+	if err != nil {
+		return "", fmt.Errorf("invalid EPUB: META-INF/container.xml not found")
+	}
+	defer file.Close()
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.NewDecoder(file).Decode(&container); err != nil {
+		return "", fmt.Errorf("failed to read EPUB container: %w", err)
+	}
+
+	for _, rootfile := range container.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			return rootfile.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("invalid EPUB: no package document in container.xml")
+}
+
+// epubSpine returns the archive paths of the XHTML chapters listed in the
+// package document's spine, in reading order. Items marked linear="no",
+// such as pop-up notes, are left out.
+func epubSpine(archive *zip.Reader, packagePath string) ([]string, error) {
+	file, err := archive.Open(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPUB: package document %s not found", packagePath)
+	}
+	defer file.Close()
+
+	var pkg struct {
+		Items []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.NewDecoder(file).Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("failed to read EPUB package document: %w", err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Items))
+	for _, item := range pkg.Items {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	base := path.Dir(packagePath)
+	var chapters []string
+	for _, ref := range pkg.ItemRefs {
+		href, ok := hrefs[ref.IDRef]
+		if !ok || ref.Linear == "no" {
+			continue
+		}
+		href, _, _ = strings.Cut(href, "#")
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapters = append(chapters, path.Join(base, href))
+	}
+
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("invalid EPUB: spine lists no chapters")
+	}
+	return chapters, nil
+}
+
+// epubChapterText appends the visible text of one XHTML chapter to text.
+// The decoder runs in HTML mode so that HTML entities and unclosed void
+// elements found in real-world books do not stop extraction.
+func epubChapterText(archive *zip.Reader, chapter string, text *strings.Builder) error {
+	file, err := archive.Open(chapter)
+	if err != nil {
+		return fmt.Errorf("invalid EPUB: chapter %s not found", chapter)
+	}
+	defer file.Close()
+
+	decoder := xml.NewDecoder(file)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	skipped := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read EPUB chapter %s: %w", chapter, err)
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			// Line breaks in the source are only formatting; blocks decide
+			// where lines end
+			if skipped == 0 {
+				text.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(string(t)))
+			}
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if epubSkippedElements[name] {
+				skipped++
+			} else if epubBlockElements[name] {
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if epubSkippedElements[name] {
+				skipped--
+			} else if epubBlockElements[name] {
+				text.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// joinTextLines collapses the whitespace within every line and drops the
+// lines left empty
+func joinTextLines(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const epubContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// epubPackage lists the chapters in the manifest in a different order than
+// the spine, and marks the notes as non-linear
+const epubPackage = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<manifest>
+<item id="notes" href="Text/notes.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch2" href="Text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch1" href="Text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+<item id="css" href="style.css" media-type="text/css"/>
+</manifest>
+<spine>
+<itemref idref="ch1"/>
+<itemref idref="ch2"/>
+<itemref idref="notes" linear="no"/>
+</spine>
+</package>`
+
+const epubChapter1 = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Capítulo 1</title><style>p { margin: 0 }</style></head>
+<body>
+<h1>Capítulo 1</h1>
+<p>El <em>perro</em> come
+  una manzana.</p>
+<p>Hola&nbsp;amigo<br/>¿Qué tal?</p>
+<script>var x = 1;</script>
+</body>
+</html>`
+
+const epubChapter2 = `<html><body><p>La casa es <b>grande</b>.<p>Adiós</body></html>`
+
+// epubFiles returns the files of a valid EPUB with two chapters
+func epubFiles() map[string]string {
+	return map[string]string{
+		"mimetype":                      "application/epub+zip",
+		"META-INF/container.xml":        epubContainer,
+		"OEBPS/content.opf":             epubPackage,
+		"OEBPS/Text/chapter1.xhtml":     epubChapter1,
+		"OEBPS/Text/chapter 2.xhtml":    epubChapter2,
+		"OEBPS/Text/notes.xhtml":        `<html><body><p>Nota al pie</p></body></html>`,
+		"OEBPS/style.css":               "p { margin: 0 }",
+		"META-INF/com.apple.ibooks.xml": "<display_options/>",
+	}
+}
+
+// TestParseEPUB tests extracting chapter text from an EPUB in spine order
+func TestParseEPUB(t *testing.T) {
+	content := buildZip(t, epubFiles())
+	path := filepath.Join(t.TempDir(), "novel.epub")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	want := "Capítulo 1\nEl perro come una manzana.\nHola amigo\n¿Qué tal?\nLa casa es grande.\nAdiós"
+
+	text, err := ParseDocument(path)
+	if err != nil {
+		t.Fatalf("Failed to parse EPUB: %v", err)
+	}
+	if text != want {
+		t.Errorf("Expected %q, got %q", want, text)
+	}
+
+	text, err = ParseDocumentFromReader(bytes.NewReader(content), "novel.epub", int64(len(content)))
+	if err != nil {
+		t.Fatalf("Failed to parse EPUB from reader: %v", err)
+	}
+	if text != want {
+		t.Errorf("Expected %q from reader, got %q", want, text)
+	}
+}
+
+// TestParseEPUBFontObfuscation tests that obfuscated fonts alone do not
+// make an EPUB count as encrypted
+func TestParseEPUBFontObfuscation(t *testing.T) {
+	files := epubFiles()
+	files["META-INF/encryption.xml"] = `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+<enc:EncryptedData><enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+<enc:CipherData><enc:CipherReference URI="OEBPS/font.otf"/></enc:CipherData></enc:EncryptedData>
+</encryption>`
+	content := buildZip(t, files)
+
+	if _, err := ParseEPUBFromReader(bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Errorf("Expected EPUB with obfuscated fonts to parse, got %v", err)
+	}
+}
+
+// TestParseEPUBInvalid tests encrypted, corrupt, empty and oversized EPUB
+// files
+func TestParseEPUBInvalid(t *testing.T) {
+	t.Cleanup(func() { SetMaxFileSize(DefaultMaxFileSize) })
+
+	encrypted := epubFiles()
+	encrypted["META-INF/encryption.xml"] = `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+<enc:EncryptedData><enc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+<enc:CipherData><enc:CipherReference URI="OEBPS/Text/chapter1.xhtml"/></enc:CipherData></enc:EncryptedData>
+</encryption>`
+	adobeDRM := epubFiles()
+	adobeDRM["META-INF/rights.xml"] = `<adept:rights xmlns:adept="http://ns.adobe.com/adept"/>`
+	noContainer := epubFiles()
+	delete(noContainer, "META-INF/container.xml")
+	missingChapter := epubFiles()
+	delete(missingChapter, "OEBPS/Text/chapter1.xhtml")
+
+	tests := []struct {
+		name    string
+		content []byte
+		limit   int64
+		wantErr error
+	}{
+		{"Encrypted chapters", buildZip(t, encrypted), 0, ErrEncryptedEPUB},
+		{"Adobe DRM", buildZip(t, adobeDRM), 0, ErrEncryptedEPUB},
+		{"Missing container.xml", buildZip(t, noContainer), 0, nil},
+		{"Missing chapter", buildZip(t, missingChapter), 0, nil},
+		{"Not a zip", []byte("not a zip"), 0, nil},
+		{"No text", buildZip(t, map[string]string{
+			"META-INF/container.xml":     epubContainer,
+			"OEBPS/content.opf":          epubPackage,
+			"OEBPS/Text/chapter1.xhtml":  "<html><body><p> </p></body></html>",
+			"OEBPS/Text/chapter 2.xhtml": "<html><body></body></html>",
+		}), 0, nil},
+		{"Too large", buildZip(t, epubFiles()), 16, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			SetMaxFileSize(tc.limit)
+
+			path := filepath.Join(t.TempDir(), "novel.epub")
+			if err := os.WriteFile(path, tc.content, 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			_, err := ParseEPUB(path)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+			var sizeErr *FileTooLargeError
+			if tc.limit > 0 && !errors.As(err, &sizeErr) {
+				t.Errorf("Expected FileTooLargeError, got %v", err)
+			}
+		})
+	}
+}
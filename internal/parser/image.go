@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrImageDocument is returned when an image is passed to a text parser.
+// Images have no text layer and are read by a vision model instead.
+var ErrImageDocument = errors.New("images have no text to parse")
+
+// imageTypes maps the extensions of supported images to their MIME types.
+// DetectFileType, ImageMIMEType and SupportedImageMIMEType all read it, so
+// an image that is accepted for upload is also accepted by the AI client.
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// ImageMIMEType returns the MIME type of an image file from its extension,
+// or "" for anything that is not a supported image
+func ImageMIMEType(filename string) string {
+	return imageTypes[strings.ToLower(filepath.Ext(filename))]
+}
+
+// SupportedImageMIMEType reports whether mimeType is the type of a
+// supported image
+func SupportedImageMIMEType(mimeType string) bool {
+	for _, t := range imageTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadImage reads an uploaded image, enforcing the same size limit as
+// documents
+func ReadImage(reader io.Reader, size int64) ([]byte, error) {
+	return readLimited(reader, size)
+}
+
+// ReadImageFile reads an image file from disk, enforcing the size limit
+func ReadImageFile(filePath string) ([]byte, error) {
+	if err := ValidateFileSize(filePath); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Block-level markdown syntax, matched against a single line
+var (
+	mdFence         = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeading       = regexp.MustCompile(`^\s{0,3}#{1,6}(\s+|$)`)
+	mdClosingHashes = regexp.MustCompile(`\s+#+\s*$`)
+	mdRule          = regexp.MustCompile(`^\s*(([-*_=])\s*){3,}$`)
+	mdBlockquote    = regexp.MustCompile(`^\s*(>\s?)+`)
+	mdListItem      = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?`)
+	mdLinkDef       = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*\S+`)
+	mdTableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Inline markdown syntax, applied in this order
+var mdInline = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile("`+([^`]*)`+"), "$1"},
+	{regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`), "$1"},
+	{regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`), "$1"},
+	{regexp.MustCompile(`\[([^\]]*)\]\[[^\]]*\]`), "$1"},
+	{regexp.MustCompile(`<(https?://|mailto:)[^>]*>`), ""},
+	{regexp.MustCompile(`</?[a-zA-Z][^>]*>`), ""},
+	{regexp.MustCompile(`\*{1,3}([^*\n]+?)\*{1,3}`), "$1"},
+	{regexp.MustCompile(`\b_{1,3}([^_\n]+?)_{1,3}\b`), "$1"},
+	{regexp.MustCompile(`~~([^~\n]+)~~`), "$1"},
+}
+
+// mdEscapable lists the characters a backslash escapes. While the inline
+// rules run, escaped characters are swapped for private-use runes so they
+// are not taken for syntax.
+const mdEscapable = "\\`*_{}[]()#+-.!|>~"
+
+var mdEscape = regexp.MustCompile(`\\[\\` + "`" + `*_{}\[\]()#+\-.!|>~]`)
+
+// mdEscapeBase is the first private-use rune standing in for an escaped
+// character
+const mdEscapeBase = 0xE000
+
+// ParseMarkdown extracts the prose of a Markdown file, which must be UTF-8.
+// Markdown syntax is stripped so it does not end up in the extracted
+// vocabulary: link and image text is kept without the URL.
+func ParseMarkdown(filePath string) (string, error) {
+	if err := ValidateFileSize(filePath); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read markdown file: %w", err)
+	}
+
+	return markdownContent(content)
+}
+
+// ParseMarkdownFromReader extracts the prose of Markdown from an io.Reader
+func ParseMarkdownFromReader(reader io.Reader, size int64) (string, error) {
+	content, err := readLimited(reader, size)
+	if err != nil {
+		return "", err
+	}
+
+	return markdownContent(content)
+}
+
+// markdownContent validates the raw bytes of a Markdown file and strips
+// its syntax
+func markdownContent(content []byte) (string, error) {
+	if !utf8.Valid(content) {
+		return "", ErrInvalidUTF8
+	}
+
+	text := strings.TrimSpace(stripMarkdown(string(content)))
+	if len(text) == 0 {
+		return "", fmt.Errorf("no text content found in Markdown")
+	}
+
+	return text, nil
+}
+
+// stripMarkdown removes Markdown syntax line by line. Code blocks keep
+// their content, since language notes often put examples in them, but lose
+// their fences.
+func stripMarkdown(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		if mdFence.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		if mdRule.MatchString(line) || mdLinkDef.MatchString(line) || mdTableDivider.MatchString(line) {
+			continue
+		}
+
+		line = mdEscape.ReplaceAllStringFunc(line, func(escaped string) string {
+			return string(rune(mdEscapeBase + strings.IndexByte(mdEscapable, escaped[1])))
+		})
+		line = mdBlockquote.ReplaceAllString(line, "")
+		if mdHeading.MatchString(line) {
+			line = mdClosingHashes.ReplaceAllString(mdHeading.ReplaceAllString(line, ""), "")
+		}
+		line = mdListItem.ReplaceAllString(line, "")
+		if strings.Contains(line, "|") {
+			line = strings.TrimSpace(strings.Trim(strings.TrimSpace(line), "|"))
+			line = strings.Join(strings.Fields(strings.ReplaceAll(line, "|", " ")), " ")
+		}
+		for _, rule := range mdInline {
+			line = rule.pattern.ReplaceAllString(line, rule.replacement)
+		}
+		line = strings.Map(func(r rune) rune {
+			if r >= mdEscapeBase && r < mdEscapeBase+rune(len(mdEscapable)) {
+				return rune(mdEscapable[r-mdEscapeBase])
+			}
+			return r
+		}, line)
+
+		out = append(out, line)
+	}
+
+	return strings.Join(out, "\n")
+}
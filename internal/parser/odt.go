@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingODTContent is returned for an ODT archive without content.xml,
+// usually a corrupt or truncated file
+var ErrMissingODTContent = errors.New("invalid ODT: content.xml not found")
+
+// odtTextNamespace is the XML namespace of OpenDocument text elements
+const odtTextNamespace = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+// ParseODT extracts text content from an OpenDocument Text file
+func ParseODT(filePath string) (string, error) {
+	// Validate file size first
+	if err := ValidateFileSize(filePath); err != nil {
+		return "", err
+	}
+
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ODT: %w", err)
+	}
+	defer archive.Close()
+
+	return odtText(&archive.Reader)
+}
+
+// ParseODTFromReader extracts text from an ODT io.Reader (for uploaded files)
+func ParseODTFromReader(reader io.Reader, size int64) (string, error) {
+	content, err := readLimited(reader, size)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open ODT: %w", err)
+	}
+
+	return odtText(archive)
+}
+
+// odtText reads content.xml from an ODT archive and returns its text, one
+// line per paragraph or heading
+func odtText(archive *zip.Reader) (string, error) {
+	file, err := archive.Open("content.xml")
+	if err != nil {
+		return "", ErrMissingODTContent
+	}
+	defer file.Close()
+
+	// Only text inside paragraphs and headings is content; character data
+	// between them is formatting whitespace
+	var text strings.Builder
+	depth := 0
+	decoder := xml.NewDecoder(file)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read ODT content: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			if depth > 0 {
+				text.Write(t)
+			}
+		case xml.StartElement:
+			if t.Name.Space != odtTextNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				depth++
+			case "s":
+				text.WriteString(strings.Repeat(" ", odtSpaceCount(t)))
+			case "tab":
+				text.WriteByte('\t')
+			case "line-break":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if t.Name.Space == odtTextNamespace && (t.Name.Local == "p" || t.Name.Local == "h") {
+				depth--
+				text.WriteByte('\n')
+			}
+		}
+	}
+
+	result := strings.TrimSpace(text.String())
+	if len(result) == 0 {
+		return "", fmt.Errorf("no text content found in ODT")
+	}
+
+	return result, nil
+}
+
+// odtSpaceCount returns the number of spaces a text:s element stands for
+func odtSpaceCount(element xml.StartElement) int {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == odtTextNamespace && attr.Name.Local == "c" {
+			if n, err := strconv.Atoi(attr.Value); err == nil && n > 0 && n <= 1000 {
+				return n
+			}
+		}
+	}
+	return 1
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// odtContent is a minimal content.xml with a heading, paragraphs, and
+// encoded spaces, tabs and line breaks
+const odtContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:text>
+<text:h text:outline-level="1">Lección 1</text:h>
+<text:p>Hola<text:s text:c="3"/>amigo</text:p>
+<text:p>gracias<text:tab/>thank you<text:line-break/>de nada</text:p>
+</office:text></office:body>
+</office:document-content>`
+
+// buildZip returns a ZIP archive, such as an ODT or EPUB, holding the given
+// files
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to build archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestParseODT tests extracting paragraph text from an ODT file
+func TestParseODT(t *testing.T) {
+	content := buildZip(t, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.text",
+		"content.xml": odtContent,
+	})
+	path := filepath.Join(t.TempDir(), "lesson.odt")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	want := "Lección 1\nHola   amigo\ngracias\tthank you\nde nada"
+
+	text, err := ParseDocument(path)
+	if err != nil {
+		t.Fatalf("Failed to parse ODT: %v", err)
+	}
+	if text != want {
+		t.Errorf("Expected %q, got %q", want, text)
+	}
+
+	text, err = ParseDocumentFromReader(bytes.NewReader(content), "lesson.odt", int64(len(content)))
+	if err != nil {
+		t.Fatalf("Failed to parse ODT from reader: %v", err)
+	}
+	if text != want {
+		t.Errorf("Expected %q from reader, got %q", want, text)
+	}
+}
+
+// TestParseODTInvalid tests corrupt, empty and oversized ODT files
+func TestParseODTInvalid(t *testing.T) {
+	t.Cleanup(func() { SetMaxFileSize(DefaultMaxFileSize) })
+
+	tests := []struct {
+		name    string
+		content []byte
+		limit   int64
+		wantErr error
+	}{
+		{"Missing content.xml", buildZip(t, map[string]string{"mimetype": "application/vnd.oasis.opendocument.text"}), 0, ErrMissingODTContent},
+		{"Not a zip", []byte("not a zip"), 0, nil},
+		{"No text", buildZip(t, map[string]string{"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"/>`}), 0, nil},
+		{"Malformed XML", buildZip(t, map[string]string{"content.xml": "<text:p>unclosed"}), 0, nil},
+		{"Too large", buildZip(t, map[string]string{"content.xml": odtContent}), 16, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			SetMaxFileSize(tc.limit)
+
+			path := filepath.Join(t.TempDir(), "lesson.odt")
+			if err := os.WriteFile(path, tc.content, 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			_, err := ParseODT(path)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+			var sizeErr *FileTooLargeError
+			if tc.limit > 0 && !errors.As(err, &sizeErr) {
+				t.Errorf("Expected FileTooLargeError, got %v", err)
+			}
+		})
+	}
+}
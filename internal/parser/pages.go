@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPageRange is returned for malformed, inverted or out-of-bounds
+// page ranges
+var ErrInvalidPageRange = errors.New("invalid page range")
+
+// ParsePageRange parses a page range such as "1-20" or "5" into 1-indexed,
+// inclusive bounds
+func ParsePageRange(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	start, end, isRange := strings.Cut(s, "-")
+	if !isRange {
+		end = start
+	}
+
+	from, err = strconv.Atoi(strings.TrimSpace(start))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidPageRange, s)
+	}
+	to, err = strconv.Atoi(strings.TrimSpace(end))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidPageRange, s)
+	}
+
+	if from < 1 || to < from {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidPageRange, s)
+	}
+
+	return from, to, nil
+}
+
+// ValidatePageRange checks that from-to is a valid range within a document
+// of totalPages pages
+func ValidatePageRange(from, to, totalPages int) error {
+	if from < 1 {
+		return fmt.Errorf("%w: pages start at 1, got %d", ErrInvalidPageRange, from)
+	}
+	if to < from {
+		return fmt.Errorf("%w: end page %d is before start page %d", ErrInvalidPageRange, to, from)
+	}
+	if to > totalPages {
+		return fmt.Errorf("%w: page %d requested but document has %d pages", ErrInvalidPageRange, to, totalPages)
+	}
+	return nil
+}
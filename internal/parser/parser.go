@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,10 +16,51 @@ const (
 	TypeUnknown FileType = iota
 	TypePDF
 	TypeDOCX
+	TypeODT
+	TypeTXT
+	TypeImage
+	TypeMarkdown
+	TypeEPUB
 )
 
-// MaxFileSize is the maximum allowed file size (10MB)
-const MaxFileSize = 10 * 1024 * 1024
+// ErrPasswordProtected is returned for a PDF or DOCX that cannot be read
+// without a password
+var ErrPasswordProtected = errors.New("document is password-protected; please remove protection and retry")
+
+// tempFilePrefix marks temp files created for uploads so orphans can be found
+const tempFilePrefix = "parsely-"
+
+// isTempFileName reports whether name has the form os.CreateTemp gives
+// tempFilePrefix+"*-"+filename: the prefix, a run of digits and a dash. Other
+// files starting with the prefix, such as database backups, do not match.
+func isTempFileName(name string) bool {
+	rest, ok := strings.CutPrefix(name, tempFilePrefix)
+	if !ok {
+		return false
+	}
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && strings.HasPrefix(rest[digits:], "-")
+}
+
+// DefaultMaxFileSize is the file size limit used unless SetMaxFileSize
+// changes it (10MB)
+const DefaultMaxFileSize = 10 * 1024 * 1024
+
+// MaxFileSize is the maximum allowed file size. Set it at startup with
+// SetMaxFileSize rather than while documents are being parsed.
+var MaxFileSize int64 = DefaultMaxFileSize
+
+// SetMaxFileSize changes the maximum allowed file size; values below one
+// byte restore DefaultMaxFileSize
+func SetMaxFileSize(bytes int64) {
+	if bytes < 1 {
+		bytes = DefaultMaxFileSize
+	}
+	MaxFileSize = bytes
+}
 
 // DetectFileType determines the file type based on extension
 func DetectFileType(filename string) FileType {
@@ -27,7 +70,18 @@ func DetectFileType(filename string) FileType {
 		return TypePDF
 	case ".docx":
 		return TypeDOCX
+	case ".odt":
+		return TypeODT
+	case ".txt":
+		return TypeTXT
+	case ".md", ".markdown":
+		return TypeMarkdown
+	case ".epub":
+		return TypeEPUB
 	default:
+		if _, ok := imageTypes[ext]; ok {
+			return TypeImage
+		}
 		return TypeUnknown
 	}
 }
@@ -40,7 +94,7 @@ func ValidateFileSize(filePath string) error {
 	}
 
 	if info.Size() > MaxFileSize {
-		return fmt.Errorf("file too large: %d bytes (max: %d bytes)", info.Size(), MaxFileSize)
+		return &FileTooLargeError{Size: info.Size(), Limit: MaxFileSize}
 	}
 
 	return nil
@@ -91,7 +145,40 @@ func ParseDocument(filePath string) (string, error) {
 		return ParsePDF(filePath)
 	case TypeDOCX:
 		return ParseDOCX(filePath)
+	case TypeODT:
+		return ParseODT(filePath)
+	case TypeTXT:
+		return ParseTXT(filePath)
+	case TypeMarkdown:
+		return ParseMarkdown(filePath)
+	case TypeEPUB:
+		return ParseEPUB(filePath)
+	case TypeImage:
+		return "", ErrImageDocument
 	default:
 		return "", fmt.Errorf("unsupported file type: %s", filepath.Ext(filePath))
 	}
 }
+
+// ParseDocumentFromReader extracts text from an in-memory document, choosing
+// the parser from the filename's extension
+func ParseDocumentFromReader(reader io.Reader, filename string, size int64) (string, error) {
+	switch DetectFileType(filename) {
+	case TypePDF:
+		return ParsePDFFromReader(reader, size)
+	case TypeDOCX:
+		return ParseDOCXFromReader(reader, size)
+	case TypeODT:
+		return ParseODTFromReader(reader, size)
+	case TypeTXT:
+		return ParseTXTFromReader(reader, size)
+	case TypeMarkdown:
+		return ParseMarkdownFromReader(reader, size)
+	case TypeEPUB:
+		return ParseEPUBFromReader(reader, size)
+	case TypeImage:
+		return "", ErrImageDocument
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", filepath.Ext(filename))
+	}
+}
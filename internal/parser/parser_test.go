@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestParsePDF tests extracting text from a valid PDF
@@ -21,6 +24,91 @@ func TestParseDOCX(t *testing.T) {
 	t.Skip("DOCX test file creation pending - will test with real files")
 }
 
+// TestParseTXT tests extracting text from a plain text file
+func TestParseTXT(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	validPath := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(validPath, []byte("  hola mundo\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	text, err := ParseTXT(validPath)
+	if err != nil {
+		t.Fatalf("Failed to parse text file: %v", err)
+	}
+	if text != "hola mundo" {
+		t.Errorf("Expected trimmed content 'hola mundo', got %q", text)
+	}
+
+	emptyPath := filepath.Join(tmpDir, "empty.txt")
+	if err := os.WriteFile(emptyPath, []byte("  \n "), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := ParseTXT(emptyPath); err == nil || !strings.Contains(err.Error(), "no text content found") {
+		t.Errorf("Expected 'no text content found' error for empty file, got: %v", err)
+	}
+
+	// "café" in Latin-1
+	latin1Path := filepath.Join(tmpDir, "latin1.txt")
+	if err := os.WriteFile(latin1Path, []byte("caf\xe9"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if _, err := ParseTXT(latin1Path); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 for a Latin-1 file, got: %v", err)
+	}
+}
+
+// TestParseMarkdown tests that Markdown syntax is stripped down to prose
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Headings", "# Lección uno\n## Saludos ##\nhola", "Lección uno\nSaludos\nhola"},
+		{"Emphasis", "**hola**, *adiós* and ___gracias___ ~~no~~", "hola, adiós and gracias no"},
+		{"Underscores inside words", "mi_palabra_favorita", "mi_palabra_favorita"},
+		{"Links keep text", "Ver [el diccionario](https://rae.es \"RAE\") y ![un perro](perro.png)", "Ver el diccionario y un perro"},
+		{"Reference links", "Ver [la guía][1]\n\n[1]: https://example.com/guia", "Ver la guía"},
+		{"Autolinks", "Fuente: <https://example.com>", "Fuente:"},
+		{"Code fences", "Ejemplo:\n```text\nyo hablo\n```\nfin", "Ejemplo:\nyo hablo\nfin"},
+		{"Inline code", "el verbo `hablar`", "el verbo hablar"},
+		{"Lists and quotes", "- uno\n2. dos\n- [x] tres\n> cita", "uno\ndos\ntres\ncita"},
+		{"Rules", "arriba\n\n---\n\nabajo", "arriba\n\n\nabajo"},
+		{"Tables", "| palabra | traducción |\n|---|:---:|\n| perro | dog |", "palabra traducción\nperro dog"},
+		{"Escapes", "\\*no es énfasis\\*", "*no es énfasis*"},
+		{"HTML", "<b>negrita</b><br/>", "negrita"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notes.md")
+			if err := os.WriteFile(path, []byte(tc.input), 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			text, err := ParseMarkdown(path)
+			if err != nil {
+				t.Fatalf("Failed to parse markdown: %v", err)
+			}
+			if text != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, text)
+			}
+		})
+	}
+
+	latin1Path := filepath.Join(t.TempDir(), "latin1.md")
+	if err := os.WriteFile(latin1Path, []byte("# caf\xe9"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := ParseMarkdown(latin1Path); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 for a Latin-1 file, got: %v", err)
+	}
+}
+
 // TestParseInvalidFile tests handling corrupted files
 func TestParseInvalidFile(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -76,6 +164,46 @@ func TestParseEmptyDOCX(t *testing.T) {
 	t.Skip("Empty DOCX test requires proper ZIP structure - integration test")
 }
 
+// TestParseDOCXPasswordProtected tests that an encrypted Word document,
+// stored as an OLE compound file, gives ErrPasswordProtected while other
+// unreadable files do not
+func TestParseDOCXPasswordProtected(t *testing.T) {
+	encrypted := append(append([]byte{}, oleMagic...), make([]byte, 504)...)
+	encrypted = append(encrypted, utf16LE("EncryptionInfo")...)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		protected bool
+	}{
+		{"Encrypted", encrypted, true},
+		{"Compound file without encryption", append(append([]byte{}, oleMagic...), make([]byte, 504)...), false},
+		{"Not a zip", []byte("not a zip"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "lesson.docx")
+			if err := os.WriteFile(path, tc.content, 0600); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			_, err := ParseDOCX(path)
+			if err == nil {
+				t.Fatal("ParseDOCX() expected error")
+			}
+			if errors.Is(err, ErrPasswordProtected) != tc.protected {
+				t.Errorf("ParseDOCX() error = %v, protected %v", err, tc.protected)
+			}
+
+			_, err = ParseDOCXFromReader(bytes.NewReader(tc.content), int64(len(tc.content)))
+			if errors.Is(err, ErrPasswordProtected) != tc.protected {
+				t.Errorf("ParseDOCXFromReader() error = %v, protected %v", err, tc.protected)
+			}
+		})
+	}
+}
+
 // TestParseLargeFile tests handling large files up to the limit
 func TestParseLargeFile(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -131,7 +259,18 @@ func TestDetectFileType(t *testing.T) {
 		{"notes.PDF", TypePDF},
 		{"lesson.docx", TypeDOCX},
 		{"file.DOCX", TypeDOCX},
-		{"invalid.txt", TypeUnknown},
+		{"notes.odt", TypeODT},
+		{"notes.ODT", TypeODT},
+		{"notes.txt", TypeTXT},
+		{"notes.md", TypeMarkdown},
+		{"README.Markdown", TypeMarkdown},
+		{"novel.epub", TypeEPUB},
+		{"novel.EPUB", TypeEPUB},
+		{"page.jpg", TypeImage},
+		{"page.JPEG", TypeImage},
+		{"flashcard.png", TypeImage},
+		{"animation.gif", TypeUnknown},
+		{"invalid.rtf", TypeUnknown},
 		{"no_extension", TypeUnknown},
 		{"doc.pdf.bak", TypeUnknown},
 	}
@@ -144,6 +283,81 @@ func TestDetectFileType(t *testing.T) {
 	}
 }
 
+// TestImageMIMEType tests that every image DetectFileType accepts has a MIME
+// type the AI client accepts, and nothing else does
+func TestImageMIMEType(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"page.jpg", "image/jpeg"},
+		{"page.JPEG", "image/jpeg"},
+		{"flashcard.png", "image/png"},
+		{"animation.gif", ""},
+		{"photo.webp", ""},
+		{"lesson.pdf", ""},
+	}
+
+	for _, tc := range tests {
+		mimeType := ImageMIMEType(tc.filename)
+		if mimeType != tc.expected {
+			t.Errorf("ImageMIMEType(%s) = %q, expected %q", tc.filename, mimeType, tc.expected)
+		}
+		isImage := DetectFileType(tc.filename) == TypeImage
+		if isImage != (mimeType != "") {
+			t.Errorf("DetectFileType(%s) image = %v, but MIME type is %q", tc.filename, isImage, mimeType)
+		}
+		if mimeType != "" && !SupportedImageMIMEType(mimeType) {
+			t.Errorf("SupportedImageMIMEType(%q) = false for %s", mimeType, tc.filename)
+		}
+	}
+
+	for _, mimeType := range []string{"image/gif", "image/webp", ""} {
+		if SupportedImageMIMEType(mimeType) {
+			t.Errorf("SupportedImageMIMEType(%q) = true, expected false", mimeType)
+		}
+	}
+}
+
+// TestHumanBytes tests formatting byte counts for error messages
+func TestHumanBytes(t *testing.T) {
+	tests := []struct {
+		input    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{1024*1024 - 1, "1024.0 KB"},
+		{1024 * 1024, "1.0 MB"},
+		{10 * 1024 * 1024, "10.0 MB"},
+		{11534336, "11.0 MB"},
+		{1024 * 1024 * 1024, "1.0 GB"},
+		{5 * 1024 * 1024 * 1024 * 1024, "5.0 TB"},
+	}
+
+	for _, tc := range tests {
+		result := humanBytes(tc.input)
+		if result != tc.expected {
+			t.Errorf("humanBytes(%d) = %q, expected %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+// TestFileTooLargeError tests the size error message and raw values
+func TestFileTooLargeError(t *testing.T) {
+	err := &FileTooLargeError{Size: 11534336, Limit: 10 * 1024 * 1024}
+	if err.Error() != "file too large: 11.0 MB (max 10.0 MB)" {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+
+	unknown := &FileTooLargeError{Limit: 10 * 1024 * 1024}
+	if unknown.Error() != "file too large (max 10.0 MB)" {
+		t.Errorf("Unexpected message for unknown size: %s", unknown.Error())
+	}
+}
+
 // TestValidateFileSize tests file size validation
 func TestValidateFileSize(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -177,6 +391,47 @@ func TestValidateFileSize(t *testing.T) {
 	}
 }
 
+// TestSetMaxFileSize tests that every size check follows a changed limit
+func TestSetMaxFileSize(t *testing.T) {
+	t.Cleanup(func() { SetMaxFileSize(DefaultMaxFileSize) })
+	SetMaxFileSize(100)
+
+	content := bytes.Repeat([]byte("a"), 101)
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	var sizeErr *FileTooLargeError
+	if err := ValidateFileSize(path); !errors.As(err, &sizeErr) || sizeErr.Limit != 100 {
+		t.Errorf("ValidateFileSize: expected a 100 byte limit, got %v", err)
+	}
+	if _, err := ParseTXTFromReader(bytes.NewReader(content), -1); !errors.As(err, &sizeErr) {
+		t.Errorf("ParseTXTFromReader: expected FileTooLargeError, got %v", err)
+	}
+	if _, err := ParseMarkdownFromReader(bytes.NewReader(content), -1); !errors.As(err, &sizeErr) {
+		t.Errorf("ParseMarkdownFromReader: expected FileTooLargeError, got %v", err)
+	}
+	mdPath := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(mdPath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := ParseMarkdown(mdPath); !errors.As(err, &sizeErr) {
+		t.Errorf("ParseMarkdown: expected FileTooLargeError, got %v", err)
+	}
+	if _, err := CreateTempFile(bytes.NewReader(content), "notes.txt"); !errors.As(err, &sizeErr) {
+		t.Errorf("CreateTempFile: expected FileTooLargeError, got %v", err)
+	}
+
+	SetMaxFileSize(0)
+	if MaxFileSize != DefaultMaxFileSize {
+		t.Errorf("Expected a zero limit to restore the default, got %d", MaxFileSize)
+	}
+	if err := ValidateFileSize(path); err != nil {
+		t.Errorf("Expected the default limit to accept the file, got %v", err)
+	}
+}
+
 // TestSanitizeFilename tests filename sanitization for path traversal prevention
 func TestSanitizeFilename(t *testing.T) {
 	tests := []struct {
@@ -211,6 +466,94 @@ func TestSanitizeFilename(t *testing.T) {
 	}
 }
 
+// TestReapOrphanedTempFiles tests that only stale parsely temp files are removed
+func TestReapOrphanedTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	stale := filepath.Join(tmpDir, "parsely-123-notes.pdf")
+	fresh := filepath.Join(tmpDir, "parsely-456-lesson.docx")
+	unrelated := filepath.Join(tmpDir, "other-789.pdf")
+	backup := filepath.Join(tmpDir, "parsely-backup-20250101-030000.db")
+
+	for _, path := range []string{stale, fresh, unrelated, backup} {
+		if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{stale, unrelated, backup} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to age test file: %v", err)
+		}
+	}
+
+	removed, err := ReapOrphanedTempFiles(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reap temp files: %v", err)
+	}
+
+	if removed != 1 {
+		t.Errorf("Expected 1 file removed, got %d", removed)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Stale parsely temp file should be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("Fresh parsely temp file should be kept")
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Error("Files without the parsely prefix should be kept")
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Error("Database backups should be kept")
+	}
+}
+
+// TestParseDocumentFromReader tests in-memory parsing by declared filename
+func TestParseDocumentFromReader(t *testing.T) {
+	pdfContent, err := os.ReadFile("../../testdata/lesson.pdf")
+	if err != nil {
+		t.Fatalf("Failed to read PDF fixture: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		content  []byte
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{"PDF", pdfContent, "lesson.pdf", "Hola amigo", false},
+		{"TXT", []byte("  hola mundo \n"), "notes.txt", "hola mundo", false},
+		{"Empty TXT", []byte("   "), "empty.txt", "", true},
+		{"Markdown", []byte("# Lección\n\n**hola** [mundo](https://example.com)"), "notes.md", "Lección\n\nhola mundo", false},
+		{"Syntax-only Markdown", []byte("---\n\n```\n```"), "empty.md", "", true},
+		{"Invalid UTF-8 TXT", []byte("caf\xe9"), "latin1.txt", "", true},
+		{"Corrupted DOCX", []byte("not a zip"), "notes.docx", "", true},
+		{"Unsupported", []byte("data"), "notes.rtf", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := ParseDocumentFromReader(bytes.NewReader(tc.content), tc.filename, int64(len(tc.content)))
+			if tc.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(text, tc.want) {
+				t.Errorf("Expected %q in %q", tc.want, text)
+			}
+		})
+	}
+}
+
 // TestParseDocument is the main entry point that detects file type
 func TestParseDocument(t *testing.T) {
 	tests := []struct {
@@ -219,7 +562,9 @@ func TestParseDocument(t *testing.T) {
 	}{
 		{"test.pdf", true},  // Invalid PDF content - error expected
 		{"test.docx", true}, // Invalid DOCX content - error expected
-		{"test.txt", true},  // Unsupported type
+		{"test.txt", false}, // Plain text is read as-is
+		{"test.md", false},  // Markdown is stripped to prose
+		{"test.rtf", true},  // Unsupported type
 	}
 
 	for _, tc := range tests {
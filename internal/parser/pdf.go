@@ -2,41 +2,99 @@ package parser
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ledongthuc/pdf"
 )
 
+// ErrScannedPDF is returned when a PDF has pages but none of them carry
+// selectable text, which usually means the pages are scanned images
+var ErrScannedPDF = errors.New("PDF appears to be scanned images with no selectable text (OCR not supported)")
+
+// ParseMetadata describes the text a parser extracted. Pages and
+// SkippedPages are only set for PDFs.
+type ParseMetadata struct {
+	// Pages is the number of pages in the extracted range
+	Pages int
+	// SkippedPages counts pages in the range that were missing or could
+	// not be read, so contributed no text
+	SkippedPages int
+	// CharCount is the number of characters in the extracted text
+	CharCount int
+}
+
+// textMetadata returns the metadata for a document without pages
+func textMetadata(text string) ParseMetadata {
+	return ParseMetadata{CharCount: utf8.RuneCountInString(text)}
+}
+
 // ParsePDF extracts text content from a PDF file
 func ParsePDF(filePath string) (string, error) {
-	// Validate file size first
+	text, _, err := ParsePDFWithMeta(filePath, 0, 0)
+	return text, err
+}
+
+// ParsePDFPages extracts text from pages from through to (1-indexed,
+// inclusive) of a PDF file; zero bounds extract the whole document
+func ParsePDFPages(filePath string, from, to int) (string, error) {
+	text, _, err := ParsePDFWithMeta(filePath, from, to)
+	return text, err
+}
+
+// ParsePDFWithMeta is ParsePDFPages that also reports how many pages were
+// read and skipped
+func ParsePDFWithMeta(filePath string, from, to int) (string, ParseMetadata, error) {
 	if err := ValidateFileSize(filePath); err != nil {
-		return "", err
+		return "", ParseMetadata{}, err
 	}
 
-	// Open the PDF file
-	file, reader, err := pdf.Open(filePath)
+	file, reader, err := openPDFFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open PDF: %w", err)
+		return "", ParseMetadata{}, err
 	}
 	defer file.Close()
 
-	// Extract text from all pages
+	return extractPDFRange(reader, from, to)
+}
+
+// extractPDFRange validates from-to against the document and extracts it;
+// zero bounds select every page
+func extractPDFRange(reader *pdf.Reader, from, to int) (string, ParseMetadata, error) {
+	if from == 0 && to == 0 {
+		return extractPDFPages(reader, 1, reader.NumPage())
+	}
+	if err := ValidatePageRange(from, to, reader.NumPage()); err != nil {
+		return "", ParseMetadata{}, err
+	}
+	return extractPDFPages(reader, from, to)
+}
+
+// extractPDFPages concatenates the plain text of pages from through to,
+// counting pages that are missing or unreadable as skipped. If there are
+// pages but no text, the PDF is reported as ErrScannedPDF.
+func extractPDFPages(reader *pdf.Reader, from, to int) (string, ParseMetadata, error) {
 	var textBuilder strings.Builder
-	totalPages := reader.NumPage()
+	meta := ParseMetadata{Pages: to - from + 1}
+	pages := 0
 
-	for pageNum := 1; pageNum <= totalPages; pageNum++ {
+	for pageNum := from; pageNum <= to; pageNum++ {
 		page := reader.Page(pageNum)
 		if page.V.IsNull() {
+			meta.SkippedPages++
 			continue
 		}
+		pages++
 
 		// Get text content from the page
 		text, err := page.GetPlainText(nil)
 		if err != nil {
-			// Log error but continue with other pages
+			// Skip the page but continue with the others
+			meta.SkippedPages++
 			continue
 		}
 
@@ -44,60 +102,87 @@ func ParsePDF(filePath string) (string, error) {
 		textBuilder.WriteString("\n")
 	}
 
-	content := textBuilder.String()
+	content := strings.TrimSpace(textBuilder.String())
 	if len(content) == 0 {
-		return "", fmt.Errorf("no text content found in PDF")
+		return "", ParseMetadata{}, emptyPDFError(pages)
 	}
 
-	return strings.TrimSpace(content), nil
+	meta.CharCount = utf8.RuneCountInString(content)
+	return content, meta, nil
 }
 
-// ParsePDFFromReader extracts text from a PDF io.Reader (for uploaded files)
-func ParsePDFFromReader(reader io.Reader, size int64) (string, error) {
-	// Validate size
-	if size > MaxFileSize {
-		return "", fmt.Errorf("file too large: %d bytes (max: %d bytes)", size, MaxFileSize)
+// emptyPDFError returns the error for a PDF without text, telling image-only
+// pages apart from a document with no pages at all
+func emptyPDFError(pages int) error {
+	if pages > 0 {
+		return ErrScannedPDF
 	}
+	return fmt.Errorf("no text content found in PDF")
+}
 
-	// Read all content into memory
-	content, err := io.ReadAll(io.LimitReader(reader, MaxFileSize+1))
-	if err != nil {
-		return "", fmt.Errorf("failed to read PDF content: %w", err)
+// openPDFFile opens a PDF file, mapping open errors like pdfOpenError
+func openPDFFile(filePath string) (*os.File, *pdf.Reader, error) {
+	file, reader, err := pdf.Open(filePath)
+	if err == nil {
+		return file, reader, nil
 	}
 
-	if len(content) > MaxFileSize {
-		return "", fmt.Errorf("file too large: %d bytes (max: %d bytes)", len(content), MaxFileSize)
+	content, readErr := os.ReadFile(filePath)
+	if readErr != nil {
+		return nil, nil, fmt.Errorf("failed to open PDF: %w", err)
 	}
+	return nil, nil, pdfOpenError(err, content)
+}
 
-	// Open PDF from bytes
-	pdfReader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
-	if err != nil {
-		return "", fmt.Errorf("failed to parse PDF: %w", err)
+// pdfEncryptKey names the trailer entry of an encrypted PDF
+var pdfEncryptKey = []byte("/Encrypt")
+
+// pdfOpenError wraps an error from opening the PDF in content. The library
+// only decrypts PDFs with an empty user password and older encryption
+// schemes, so a wrong password or an encryption setting it rejects, such as
+// AES-256, is reported as ErrPasswordProtected. The library returns
+// ErrInvalidPassword for the former but has no sentinel for the latter, so
+// any file that failed to open and has an /Encrypt entry counts as encrypted.
+func pdfOpenError(err error, content []byte) error {
+	if errors.Is(err, pdf.ErrInvalidPassword) || bytes.Contains(content, pdfEncryptKey) {
+		return ErrPasswordProtected
 	}
+	return fmt.Errorf("failed to open PDF: %w", err)
+}
 
-	// Extract text from all pages
-	var textBuilder strings.Builder
-	totalPages := pdfReader.NumPage()
+// ParsePDFFromReader extracts text from a PDF io.Reader (for uploaded files)
+func ParsePDFFromReader(reader io.Reader, size int64) (string, error) {
+	text, _, err := ParsePDFFromReaderWithMeta(reader, size, 0, 0)
+	return text, err
+}
 
-	for pageNum := 1; pageNum <= totalPages; pageNum++ {
-		page := pdfReader.Page(pageNum)
-		if page.V.IsNull() {
-			continue
-		}
+// ParsePDFPagesFromReader extracts a page range from a PDF io.Reader
+func ParsePDFPagesFromReader(reader io.Reader, size int64, from, to int) (string, error) {
+	text, _, err := ParsePDFFromReaderWithMeta(reader, size, from, to)
+	return text, err
+}
 
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			continue
-		}
+// ParsePDFFromReaderWithMeta is ParsePDFWithMeta for a PDF io.Reader
+func ParsePDFFromReaderWithMeta(reader io.Reader, size int64, from, to int) (string, ParseMetadata, error) {
+	pdfReader, err := openPDFReader(reader, size)
+	if err != nil {
+		return "", ParseMetadata{}, err
+	}
 
-		textBuilder.WriteString(text)
-		textBuilder.WriteString("\n")
+	return extractPDFRange(pdfReader, from, to)
+}
+
+// openPDFReader reads a size-limited PDF into memory and opens it
+func openPDFReader(reader io.Reader, size int64) (*pdf.Reader, error) {
+	content, err := readLimited(reader, size)
+	if err != nil {
+		return nil, err
 	}
 
-	result := textBuilder.String()
-	if len(result) == 0 {
-		return "", fmt.Errorf("no text content found in PDF")
+	pdfReader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, pdfOpenError(err, content)
 	}
 
-	return strings.TrimSpace(result), nil
+	return pdfReader, nil
 }
@@ -0,0 +1,365 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestPDF writes a minimal PDF with one line of Helvetica text per page
+func writeTestPDF(t *testing.T, pages []string) string {
+	t.Helper()
+	return writeTestPDFWithTrailer(t, pages, "")
+}
+
+// writeTestPDFWithTrailer is writeTestPDF adding extra entries, such as an
+// /Encrypt dictionary, to the trailer
+func writeTestPDFWithTrailer(t *testing.T, pages []string, trailer string) string {
+	t.Helper()
+
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	// Object layout: 1 catalog, 2 page tree, 3 font, then a page and a
+	// content stream for each page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i, text := range pages {
+		// An empty page only strokes a line, like a scan with no text layer
+		stream := "0 0 m 612 792 l S"
+		if text != "" {
+			stream = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+
+	path := filepath.Join(t.TempDir(), "pages.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write test PDF: %v", err)
+	}
+	return path
+}
+
+// TestParsePDFPages tests extracting a page range from a multi-page PDF
+func TestParsePDFPages(t *testing.T) {
+	path := writeTestPDF(t, []string{"alpha one", "bravo two", "charlie three", "delta four"})
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     []string
+		exclude  []string
+	}{
+		{"Middle pages", 2, 3, []string{"bravo two", "charlie three"}, []string{"alpha one", "delta four"}},
+		{"Single page", 1, 1, []string{"alpha one"}, []string{"bravo two"}},
+		{"Last page", 4, 4, []string{"delta four"}, []string{"charlie three"}},
+		{"All pages", 1, 4, []string{"alpha one", "delta four"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := ParsePDFPages(path, tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("Failed to parse pages: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("Expected %q in %q", want, text)
+				}
+			}
+			for _, unwanted := range tc.exclude {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("Did not expect %q in %q", unwanted, text)
+				}
+			}
+		})
+	}
+}
+
+// TestParsePDFPagesInvalidRange tests inverted and out-of-bounds ranges
+func TestParsePDFPagesInvalidRange(t *testing.T) {
+	path := writeTestPDF(t, []string{"alpha one", "bravo two"})
+
+	ranges := []struct{ from, to int }{
+		{0, 1},
+		{2, 1},
+		{1, 3},
+		{5, 6},
+	}
+
+	for _, r := range ranges {
+		_, err := ParsePDFPages(path, r.from, r.to)
+		if !errors.Is(err, ErrInvalidPageRange) {
+			t.Errorf("Expected ErrInvalidPageRange for %d-%d, got %v", r.from, r.to, err)
+		}
+	}
+}
+
+// TestParsePDFScanned tests that a PDF with pages but no text is reported
+// as scanned, while a PDF without pages keeps the generic error
+func TestParsePDFScanned(t *testing.T) {
+	scanned := writeTestPDF(t, []string{"", ""})
+
+	if _, err := ParsePDF(scanned); !errors.Is(err, ErrScannedPDF) {
+		t.Errorf("ParsePDF() error = %v, want ErrScannedPDF", err)
+	}
+
+	data, err := os.ReadFile(scanned)
+	if err != nil {
+		t.Fatalf("Failed to read test PDF: %v", err)
+	}
+	if _, err := ParsePDFFromReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrScannedPDF) {
+		t.Errorf("ParsePDFFromReader() error = %v, want ErrScannedPDF", err)
+	}
+
+	empty := writeTestPDF(t, nil)
+	_, err = ParsePDF(empty)
+	if err == nil {
+		t.Fatal("ParsePDF() expected error for PDF without pages")
+	}
+	if errors.Is(err, ErrScannedPDF) {
+		t.Errorf("ParsePDF() error = %v, want generic no-text error", err)
+	}
+}
+
+// TestParsePDFPasswordProtected tests that PDFs needing a password, or
+// using encryption the library cannot read, give ErrPasswordProtected
+func TestParsePDFPasswordProtected(t *testing.T) {
+	// The U entry does not match the empty user password
+	id := "/ID [<00112233445566778899aabbccddeeff> <00112233445566778899aabbccddeeff>]"
+	hash := "<" + strings.Repeat("00", 32) + ">"
+	tests := []struct {
+		name    string
+		encrypt string
+	}{
+		{"User password", "/Encrypt << /Filter /Standard /V 1 /R 2 /O " + hash + " /U " + hash + " /P -4 >> " + id + " "},
+		{"Unsupported encryption", "/Encrypt << /Filter /Standard /V 5 /R 6 /Length 256 >> " + id + " "},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeTestPDFWithTrailer(t, []string{"secret"}, tc.encrypt)
+
+			if _, err := ParsePDF(path); !errors.Is(err, ErrPasswordProtected) {
+				t.Errorf("ParsePDF() error = %v, want ErrPasswordProtected", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read test PDF: %v", err)
+			}
+			if _, err := ParsePDFFromReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrPasswordProtected) {
+				t.Errorf("ParsePDFFromReader() error = %v, want ErrPasswordProtected", err)
+			}
+		})
+	}
+}
+
+// TestParsePageRange tests parsing the pages query parameter
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		from, to int
+		valid    bool
+	}{
+		{"1-20", 1, 20, true},
+		{"5", 5, 5, true},
+		{" 3 - 7 ", 3, 7, true},
+		{"20-1", 0, 0, false},
+		{"0-3", 0, 0, false},
+		{"a-b", 0, 0, false},
+		{"", 0, 0, false},
+		{"1-", 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		from, to, err := ParsePageRange(tc.input)
+		if tc.valid {
+			if err != nil {
+				t.Errorf("ParsePageRange(%q) unexpected error: %v", tc.input, err)
+			} else if from != tc.from || to != tc.to {
+				t.Errorf("ParsePageRange(%q) = %d-%d, want %d-%d", tc.input, from, to, tc.from, tc.to)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidPageRange) {
+			t.Errorf("ParsePageRange(%q) expected ErrInvalidPageRange, got %v", tc.input, err)
+		}
+	}
+}
+
+// fakePDFEngine is a PDFEngine that records calls instead of parsing
+type fakePDFEngine struct {
+	name      string
+	available bool
+	calls     int
+}
+
+func (e *fakePDFEngine) Name() string { return e.name }
+
+func (e *fakePDFEngine) Available() bool { return e.available }
+
+func (e *fakePDFEngine) ExtractText(_ context.Context, filePath string, from, to int) (string, ParseMetadata, error) {
+	e.calls++
+	return fmt.Sprintf("%s text", e.name), ParseMetadata{}, nil
+}
+
+// TestParserConfigPDFEngine tests engine selection and the fallback to the
+// internal engine when the configured one is unavailable
+func TestParserConfigPDFEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		engine    string
+		available bool
+		want      string
+		wantErr   error
+	}{
+		{"Default is internal", "", true, PDFEngineInternal, nil},
+		{"Explicit internal", "internal", true, PDFEngineInternal, nil},
+		{"pdftotext when installed", "pdftotext", true, PDFEnginePdftotext, nil},
+		{"Case insensitive", " PDFtoText ", true, PDFEnginePdftotext, nil},
+		{"Fallback when binary missing", "pdftotext", false, PDFEngineInternal, nil},
+		{"Unknown engine", "mutool", true, "", ErrUnknownPDFEngine},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			internal := &fakePDFEngine{name: PDFEngineInternal, available: true}
+			external := &fakePDFEngine{name: PDFEnginePdftotext, available: tc.available}
+
+			original := pdfEngines
+			pdfEngines = map[string]PDFEngine{
+				PDFEngineInternal:  internal,
+				PDFEnginePdftotext: external,
+			}
+			t.Cleanup(func() { pdfEngines = original })
+
+			filePath := filepath.Join(t.TempDir(), "lesson.pdf")
+			if err := os.WriteFile(filePath, []byte("%PDF-1.4"), 0600); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			text, err := ParserConfig{PDFEngine: tc.engine}.ParseDocument(context.Background(), filePath)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("Expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDocument failed: %v", err)
+			}
+
+			if want := tc.want + " text"; text != want {
+				t.Errorf("Expected %q, got %q", want, text)
+			}
+			if tc.want == PDFEngineInternal && (internal.calls != 1 || external.calls != 0) {
+				t.Errorf("Expected only the internal engine to run, got internal=%d external=%d", internal.calls, external.calls)
+			}
+			if tc.want == PDFEnginePdftotext && (external.calls != 1 || internal.calls != 0) {
+				t.Errorf("Expected only pdftotext to run, got internal=%d external=%d", internal.calls, external.calls)
+			}
+		})
+	}
+}
+
+// TestPdftotextTimeout tests that a pdftotext run that hangs is stopped
+// once its time limit passes
+func TestPdftotextTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test uses a shell script in place of pdftotext")
+	}
+
+	binary := filepath.Join(t.TempDir(), "pdftotext")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\nexec sleep 10\n"), 0700); err != nil {
+		t.Fatalf("Failed to write fake binary: %v", err)
+	}
+
+	original := pdftotextTimeout
+	pdftotextTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { pdftotextTimeout = original })
+
+	path := writeTestPDF(t, []string{"hola"})
+	start := time.Now()
+	_, _, err := pdftotextEngine{binary: binary}.ExtractText(context.Background(), path, 0, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected pdftotext to be stopped, took %v", elapsed)
+	}
+}
+
+// TestParsePDFWithMeta tests the page and character counts reported for a
+// PDF, including pages that could not be read
+func TestParsePDFWithMeta(t *testing.T) {
+	path := writeTestPDF(t, []string{"alpha one", "bravo two", "charlie three", "delta four"})
+
+	// Point the second page of a two-page PDF at an object that does not
+	// exist; the replacement keeps the length so the xref offsets hold
+	data, err := os.ReadFile(writeTestPDF(t, []string{"alpha one", "bravo two"}))
+	if err != nil {
+		t.Fatalf("Failed to read test PDF: %v", err)
+	}
+	broken := filepath.Join(t.TempDir(), "broken.pdf")
+	data = bytes.Replace(data, []byte("/Kids [4 0 R 6 0 R]"), []byte("/Kids [4 0 R 8 0 R]"), 1)
+	if err := os.WriteFile(broken, data, 0600); err != nil {
+		t.Fatalf("Failed to write test PDF: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		from, to int
+		want     ParseMetadata
+	}{
+		{"Whole document", path, 0, 0, ParseMetadata{Pages: 4}},
+		{"Page range", path, 2, 3, ParseMetadata{Pages: 2}},
+		{"Unreadable page", broken, 0, 0, ParseMetadata{Pages: 2, SkippedPages: 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			text, meta, err := ParsePDFWithMeta(tc.path, tc.from, tc.to)
+			if err != nil {
+				t.Fatalf("ParsePDFWithMeta failed: %v", err)
+			}
+			if meta.Pages != tc.want.Pages || meta.SkippedPages != tc.want.SkippedPages {
+				t.Errorf("Expected %d pages with %d skipped, got %d with %d skipped", tc.want.Pages, tc.want.SkippedPages, meta.Pages, meta.SkippedPages)
+			}
+			if want := len([]rune(text)); meta.CharCount != want {
+				t.Errorf("Expected CharCount %d, got %d", want, meta.CharCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+)
+
+// FileTooLargeError reports a document that exceeds the size limit.
+// Size and Limit are raw byte counts; Error formats them for humans.
+// Size is zero when the input was cut off by a limited reader and its full
+// length is unknown.
+type FileTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	if e.Size <= 0 {
+		return fmt.Sprintf("file too large (max %s)", humanBytes(e.Limit))
+	}
+	return fmt.Sprintf("file too large: %s (max %s)", humanBytes(e.Size), humanBytes(e.Limit))
+}
+
+// humanBytes formats a byte count using binary units, e.g. "11.0 MB"
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit && exp < 3; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// readLimited reads all of reader, failing with FileTooLargeError if the
+// declared size or the actual content exceeds MaxFileSize
+func readLimited(reader io.Reader, size int64) ([]byte, error) {
+	if size > MaxFileSize {
+		return nil, &FileTooLargeError{Size: size, Limit: MaxFileSize}
+	}
+
+	content, err := io.ReadAll(io.LimitReader(reader, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+
+	if int64(len(content)) > MaxFileSize {
+		return nil, &FileTooLargeError{Limit: MaxFileSize}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is returned for a text file that is not UTF-8 encoded
+var ErrInvalidUTF8 = errors.New("text file is not valid UTF-8")
+
+// ParseTXT extracts text content from a plain text file, which must be UTF-8
+func ParseTXT(filePath string) (string, error) {
+	// Validate file size first
+	if err := ValidateFileSize(filePath); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read text file: %w", err)
+	}
+
+	return txtContent(content)
+}
+
+// ParseTXTFromReader extracts text from a plain text io.Reader
+func ParseTXTFromReader(reader io.Reader, size int64) (string, error) {
+	content, err := readLimited(reader, size)
+	if err != nil {
+		return "", err
+	}
+
+	return txtContent(content)
+}
+
+// txtContent validates and trims the raw bytes of a text file
+func txtContent(content []byte) (string, error) {
+	if !utf8.Valid(content) {
+		return "", ErrInvalidUTF8
+	}
+
+	text := strings.TrimSpace(string(content))
+	if len(text) == 0 {
+		return "", fmt.Errorf("no text content found in TXT")
+	}
+
+	return text, nil
+}